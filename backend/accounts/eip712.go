@@ -0,0 +1,188 @@
+package accounts
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+const eip712DomainType = "EIP712Domain"
+
+var primitiveTypeRegexp = regexp.MustCompile(`^(address|bool|string|bytes([1-9]|[12][0-9]|3[0-2])?|u?int(8|16|24|32|40|48|56|64|72|80|88|96|104|112|120|128|136|144|152|160|168|176|184|192|200|208|216|224|232|240|248|256)?)$`)
+
+var arraySuffixRegexp = regexp.MustCompile(`(\[\d*\])+$`)
+
+// VerifyTypedData hashes the given EIP-712 typed data and checks whether the
+// signature was produced by expectedSigner.
+//
+// The primary type is derived from the type definitions: it is the single type
+// that is not referenced by any other type (EIP712Domain excluded). If the
+// EIP712Domain type is not part of types, it is built from the fields set on
+// the domain. The signature must be 65 bytes; both 0/1 and 27/28 recovery ids
+// are accepted.
+//
+// It returns false with a nil error when the signature is well formed but was
+// made by someone else, and an error when the typed data or signature is
+// malformed.
+func VerifyTypedData(domain apitypes.TypedDataDomain, types apitypes.Types, message apitypes.TypedDataMessage, signature []byte, expectedSigner common.Address) (bool, error) {
+	if len(signature) != crypto.SignatureLength {
+		return false, fmt.Errorf("invalid signature length: %d", len(signature))
+	}
+
+	hash, err := HashTypedData(domain, types, message)
+	if err != nil {
+		return false, err
+	}
+
+	sig := make([]byte, crypto.SignatureLength)
+	copy(sig, signature)
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	if sig[crypto.RecoveryIDOffset] > 1 {
+		return false, fmt.Errorf("invalid signature recovery id: %d", signature[crypto.RecoveryIDOffset])
+	}
+
+	pubKey, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return false, fmt.Errorf("failed to recover signer: %w", err)
+	}
+
+	return crypto.PubkeyToAddress(*pubKey) == expectedSigner, nil
+}
+
+// HashTypedData returns the EIP-712 digest keccak256("\x19\x01" ‖ domainSeparator ‖ hashStruct(message))
+// for the given domain, type definitions and message.
+func HashTypedData(domain apitypes.TypedDataDomain, types apitypes.Types, message apitypes.TypedDataMessage) ([]byte, error) {
+	if err := validateTypes(types); err != nil {
+		return nil, fmt.Errorf("invalid type definitions: %w", err)
+	}
+
+	allTypes := make(apitypes.Types, len(types)+1)
+	for name, fields := range types {
+		allTypes[name] = fields
+	}
+	if _, ok := allTypes[eip712DomainType]; !ok {
+		domainFields := domainType(domain)
+		if len(domainFields) == 0 {
+			return nil, errors.New("domain is undefined")
+		}
+		allTypes[eip712DomainType] = domainFields
+	}
+
+	primaryType, err := primaryTypeOf(types)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, _, err := apitypes.TypedDataAndHash(apitypes.TypedData{
+		Types:       allTypes,
+		PrimaryType: primaryType,
+		Domain:      domain,
+		Message:     message,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash typed data: %w", err)
+	}
+
+	return hash, nil
+}
+
+// domainType builds the EIP712Domain type definition from the fields that are
+// set on the domain, in the order mandated by the specification.
+func domainType(domain apitypes.TypedDataDomain) []apitypes.Type {
+	var fields []apitypes.Type
+	if domain.Name != "" {
+		fields = append(fields, apitypes.Type{Name: "name", Type: "string"})
+	}
+	if domain.Version != "" {
+		fields = append(fields, apitypes.Type{Name: "version", Type: "string"})
+	}
+	if domain.ChainId != nil {
+		fields = append(fields, apitypes.Type{Name: "chainId", Type: "uint256"})
+	}
+	if domain.VerifyingContract != "" {
+		fields = append(fields, apitypes.Type{Name: "verifyingContract", Type: "address"})
+	}
+	if domain.Salt != "" {
+		fields = append(fields, apitypes.Type{Name: "salt", Type: "bytes32"})
+	}
+	return fields
+}
+
+// primaryTypeOf returns the only type that is not referenced by another type.
+func primaryTypeOf(types apitypes.Types) (string, error) {
+	referenced := make(map[string]bool)
+	for name, fields := range types {
+		for _, field := range fields {
+			if ref := baseType(field.Type); ref != name {
+				referenced[ref] = true
+			}
+		}
+	}
+
+	var candidates []string
+	for name := range types {
+		if name != eip712DomainType && !referenced[name] {
+			candidates = append(candidates, name)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return "", errors.New("no primary type found in type definitions")
+	case 1:
+		return candidates[0], nil
+	default:
+		return "", fmt.Errorf("ambiguous primary type, candidates: %s", strings.Join(candidates, ", "))
+	}
+}
+
+// validateTypes checks that every field has a name and a type, and that every
+// non-primitive type it references is defined.
+func validateTypes(types apitypes.Types) error {
+	if len(types) == 0 {
+		return errors.New("no types defined")
+	}
+
+	for name, fields := range types {
+		if name == "" {
+			return errors.New("empty type name")
+		}
+		if len(fields) == 0 && name != eip712DomainType {
+			return fmt.Errorf("type %q has no fields", name)
+		}
+
+		for i, field := range fields {
+			if field.Name == "" {
+				return fmt.Errorf("type %q field %d: empty name", name, i)
+			}
+			if field.Type == "" {
+				return fmt.Errorf("type %q field %q: empty type", name, field.Name)
+			}
+
+			ref := baseType(field.Type)
+			if ref == name {
+				return fmt.Errorf("type %q cannot reference itself", name)
+			}
+			if primitiveTypeRegexp.MatchString(ref) {
+				continue
+			}
+			if _, ok := types[ref]; !ok {
+				return fmt.Errorf("type %q field %q: undefined type %q", name, field.Name, field.Type)
+			}
+		}
+	}
+
+	return nil
+}
+
+// baseType strips any array suffix, e.g. "Person[]" becomes "Person".
+func baseType(typ string) string {
+	return arraySuffixRegexp.ReplaceAllString(typ, "")
+}
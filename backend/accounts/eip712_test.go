@@ -0,0 +1,98 @@
+package accounts
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// The Mail example of the EIP-712 specification, with its digest and the
+// signature of its sender, the account of the private key keccak256("cow").
+var (
+	mailTypes = apitypes.Types{
+		"Person": {{Name: "name", Type: "string"}, {Name: "wallet", Type: "address"}},
+		"Mail":   {{Name: "from", Type: "Person"}, {Name: "to", Type: "Person"}, {Name: "contents", Type: "string"}},
+	}
+	mailDomain = apitypes.TypedDataDomain{
+		Name:              "Ether Mail",
+		Version:           "1",
+		ChainId:           (*math.HexOrDecimal256)(big.NewInt(1)),
+		VerifyingContract: "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC",
+	}
+	mailMessage = apitypes.TypedDataMessage{
+		"from":     map[string]interface{}{"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+		"to":       map[string]interface{}{"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},
+		"contents": "Hello, Bob!",
+	}
+	mailDigest    = "be609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2"
+	mailSignature = hexutil.MustDecode("0x4355c47d63924e8a72e509b65029052eb6c299d53a04e167c5775fd466751c9d07299936d304c153f6443dfa05f40ff007d72911b6f72307f996231605b915621c")
+	mailSender    = common.HexToAddress("0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826")
+)
+
+func TestHashTypedData(t *testing.T) {
+	hash, err := HashTypedData(mailDomain, mailTypes, mailMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(hash); got != mailDigest {
+		t.Fatalf("digest = %s, want %s", got, mailDigest)
+	}
+}
+
+func TestVerifyTypedData(t *testing.T) {
+	// mailSignature uses a 27/28 recovery id; lowered is the same signature
+	// with a 0/1 one.
+	lowered := append([]byte(nil), mailSignature...)
+	lowered[64] -= 27
+	badRecovery := append([]byte(nil), mailSignature...)
+	badRecovery[64] = 5
+	tampered := apitypes.TypedDataMessage{"from": mailMessage["from"], "to": mailMessage["to"], "contents": "Hello, Mallory!"}
+
+	tests := []struct {
+		name      string
+		domain    apitypes.TypedDataDomain
+		types     apitypes.Types
+		message   apitypes.TypedDataMessage
+		signature []byte
+		signer    common.Address
+		want      bool
+		wantErr   bool
+	}{
+		{name: "signed by the sender", domain: mailDomain, types: mailTypes, message: mailMessage, signature: mailSignature, signer: mailSender, want: true},
+		{name: "0/1 recovery id", domain: mailDomain, types: mailTypes, message: mailMessage, signature: lowered, signer: mailSender, want: true},
+		{name: "wrong signer", domain: mailDomain, types: mailTypes, message: mailMessage, signature: mailSignature, signer: common.HexToAddress("0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB")},
+		{name: "tampered message", domain: mailDomain, types: mailTypes, message: tampered, signature: mailSignature, signer: mailSender},
+		{name: "other domain", domain: apitypes.TypedDataDomain{Name: "Ether Mail", Version: "2", ChainId: mailDomain.ChainId, VerifyingContract: mailDomain.VerifyingContract}, types: mailTypes, message: mailMessage, signature: mailSignature, signer: mailSender},
+		{name: "short signature", domain: mailDomain, types: mailTypes, message: mailMessage, signature: mailSignature[:64], signer: mailSender, wantErr: true},
+		{name: "invalid recovery id", domain: mailDomain, types: mailTypes, message: mailMessage, signature: badRecovery, signer: mailSender, wantErr: true},
+		{name: "undefined domain", types: mailTypes, message: mailMessage, signature: mailSignature, signer: mailSender, wantErr: true},
+		{
+			name: "undefined field type", domain: mailDomain, message: mailMessage, signature: mailSignature, signer: mailSender, wantErr: true,
+			types: apitypes.Types{"Mail": {{Name: "from", Type: "Person"}, {Name: "contents", Type: "string"}}},
+		},
+		{
+			name: "ambiguous primary type", domain: mailDomain, message: mailMessage, signature: mailSignature, signer: mailSender, wantErr: true,
+			types: apitypes.Types{"Mail": {{Name: "contents", Type: "string"}}, "Note": {{Name: "contents", Type: "string"}}},
+		},
+		{
+			name: "self-referencing type", domain: mailDomain, message: mailMessage, signature: mailSignature, signer: mailSender, wantErr: true,
+			types: apitypes.Types{"Mail": {{Name: "replyTo", Type: "Mail[]"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyTypedData(tt.domain, tt.types, tt.message, tt.signature, tt.signer)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyTypedData() error = %v, want error: %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("VerifyTypedData() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"math/big"
 	"nft-marketplace/config"
+	"nft-marketplace/utils"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
@@ -18,7 +19,7 @@ import (
 func SingInTransaction(address string) {
 	config := config.LoadConfig()
 
-	client, err := ethclient.Dial(config.BlockChainRPC)
+	client, err := utils.DialEthereum(context.Background(), config.BlockChainRPC, config.RPCDialTimeout)
 	if err != nil {
 		log.Fatal("Failed to connect to Ethereum client:", err)
 	}
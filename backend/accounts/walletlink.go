@@ -0,0 +1,95 @@
+package accounts
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// EIP-712 domain of wallet links.
+const (
+	WalletLinkDomainName    = "Azmolo Marketplace"
+	WalletLinkDomainVersion = "1"
+)
+
+// MaxWalletLinkLifetime bounds how far in the future a wallet link deadline
+// may be, so that a leaked signature cannot be used for long.
+const MaxWalletLinkLifetime = time.Hour
+
+// Errors returned by VerifyWalletLink.
+var (
+	ErrWalletLinkExpired   = errors.New("wallet link deadline has passed")
+	ErrWalletLinkTooLong   = errors.New("wallet link deadline is too far in the future")
+	ErrWalletLinkSignature = errors.New("signature does not prove control of the wallet")
+)
+
+// WalletLink is a user's proof that they control Wallet, which lets the
+// marketplace act for Wallet on the user's behalf.
+//
+// It is signed by Wallet as the EIP-712 typed data
+//
+//	WalletLink(string username,address wallet,uint256 deadline)
+//
+// in the domain {name: WalletLinkDomainName, version:
+// WalletLinkDomainVersion}. Binding the username keeps the signature from
+// linking the wallet to another account. Deadline is a Unix time in seconds.
+type WalletLink struct {
+	Username string
+	Wallet   common.Address
+	Deadline int64
+}
+
+// walletLinkTypes are the EIP-712 types of a WalletLink.
+var walletLinkTypes = apitypes.Types{
+	"WalletLink": {
+		{Name: "username", Type: "string"},
+		{Name: "wallet", Type: "address"},
+		{Name: "deadline", Type: "uint256"},
+	},
+}
+
+// HashWalletLink returns the EIP-712 digest of link, the hash its wallet
+// signs.
+func HashWalletLink(link WalletLink) ([]byte, error) {
+	return HashTypedData(walletLinkDomain(), walletLinkTypes, walletLinkMessage(link))
+}
+
+// VerifyWalletLink checks that signature is link signed by link.Wallet and
+// that link.Deadline is after now but at most MaxWalletLinkLifetime later. It
+// fails with ErrWalletLinkExpired, ErrWalletLinkTooLong or
+// ErrWalletLinkSignature.
+func VerifyWalletLink(link WalletLink, signature []byte, now time.Time) error {
+	deadline := time.Unix(link.Deadline, 0)
+	if !deadline.After(now) {
+		return ErrWalletLinkExpired
+	}
+	if deadline.Sub(now) > MaxWalletLinkLifetime {
+		return fmt.Errorf("%w: at most %s", ErrWalletLinkTooLong, MaxWalletLinkLifetime)
+	}
+
+	ok, err := VerifyTypedData(walletLinkDomain(), walletLinkTypes, walletLinkMessage(link), signature, link.Wallet)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWalletLinkSignature, err)
+	}
+	if !ok {
+		return ErrWalletLinkSignature
+	}
+	return nil
+}
+
+func walletLinkDomain() apitypes.TypedDataDomain {
+	return apitypes.TypedDataDomain{Name: WalletLinkDomainName, Version: WalletLinkDomainVersion}
+}
+
+func walletLinkMessage(link WalletLink) apitypes.TypedDataMessage {
+	return apitypes.TypedDataMessage{
+		"username": link.Username,
+		"wallet":   link.Wallet.Hex(),
+		"deadline": (*math.HexOrDecimal256)(big.NewInt(link.Deadline)),
+	}
+}
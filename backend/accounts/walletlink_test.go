@@ -0,0 +1,77 @@
+package accounts
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestVerifyWalletLink(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wallet := crypto.PubkeyToAddress(key.PublicKey)
+	now := time.Unix(1_700_000_000, 0)
+	link := WalletLink{Username: "alice", Wallet: wallet, Deadline: now.Add(10 * time.Minute).Unix()}
+
+	tests := []struct {
+		name      string
+		signed    WalletLink
+		checked   WalletLink
+		signer    *ecdsa.PrivateKey
+		recoverID byte
+		want      error
+	}{
+		{name: "valid", signed: link, checked: link, signer: key},
+		{name: "valid with 27/28 recovery id", signed: link, checked: link, signer: key, recoverID: 27},
+		{name: "signed by another key", signed: link, checked: link, signer: other, want: ErrWalletLinkSignature},
+		{name: "signed for another user", signed: WalletLink{Username: "mallory", Wallet: wallet, Deadline: link.Deadline}, checked: link, signer: key, want: ErrWalletLinkSignature},
+		{name: "deadline passed", signed: WalletLink{Username: "alice", Wallet: wallet, Deadline: now.Unix()}, checked: WalletLink{Username: "alice", Wallet: wallet, Deadline: now.Unix()}, signer: key, want: ErrWalletLinkExpired},
+		{name: "deadline too far", signed: WalletLink{Username: "alice", Wallet: wallet, Deadline: now.Add(2 * time.Hour).Unix()}, checked: WalletLink{Username: "alice", Wallet: wallet, Deadline: now.Add(2 * time.Hour).Unix()}, signer: key, want: ErrWalletLinkTooLong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sig := signWalletLink(t, tt.signed, tt.signer)
+			sig[crypto.RecoveryIDOffset] += tt.recoverID
+
+			err := VerifyWalletLink(tt.checked, sig, now)
+			if tt.want == nil && err != nil {
+				t.Fatalf("VerifyWalletLink() = %v, want nil", err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("VerifyWalletLink() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyWalletLinkMalformedSignature(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	link := WalletLink{Username: "alice", Deadline: now.Add(time.Minute).Unix()}
+
+	if err := VerifyWalletLink(link, []byte{1, 2, 3}, now); !errors.Is(err, ErrWalletLinkSignature) {
+		t.Fatalf("VerifyWalletLink() = %v, want %v", err, ErrWalletLinkSignature)
+	}
+}
+
+// signWalletLink signs link with key.
+func signWalletLink(t *testing.T, link WalletLink, key *ecdsa.PrivateKey) []byte {
+	t.Helper()
+	digest, err := HashWalletLink(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := crypto.Sign(digest, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
@@ -0,0 +1,243 @@
+// Package chaintest runs a fake Ethereum node for tests, so that code talking
+// to the marketplace contract can be exercised without a chain.
+package chaintest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	marketplace "nft-marketplace/blockchain"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// ChainID is the chain ID the node reports.
+const ChainID = 1337
+
+// Contract is the address tests deploy the marketplace at.
+var Contract = common.HexToAddress("0x00000000000000000000000000000000000c0de1")
+
+// Node is a JSON-RPC endpoint answering the methods a test registers with
+// Handle. eth_call requests are decoded against the marketplace ABI, and those
+// given to AddABI, and answered by the functions registered with HandleCall.
+type Node struct {
+	t      testing.TB
+	Client *ethclient.Client
+	// URL is the endpoint Client is connected to, for code that dials the
+	// node itself.
+	URL string
+
+	mu       sync.Mutex
+	abis     []*abi.ABI
+	methods  map[string]func(params []json.RawMessage) (any, error)
+	calls    map[string]func(args []any) ([]any, error)
+	requests map[string]int
+}
+
+var marketplaceABI = func() *abi.ABI {
+	parsed, err := marketplace.MarketplaceMetaData.GetAbi()
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}()
+
+// NewNode starts a Node for the rest of the test, with Client connected to
+// it. It only answers eth_chainId and eth_call until methods are registered.
+func NewNode(t testing.TB) *Node {
+	t.Helper()
+
+	n := &Node{
+		t:        t,
+		abis:     []*abi.ABI{marketplaceABI},
+		methods:  make(map[string]func([]json.RawMessage) (any, error)),
+		calls:    make(map[string]func([]any) ([]any, error)),
+		requests: make(map[string]int),
+	}
+	n.Handle("eth_chainId", Returns(hexutil.Uint64(ChainID)))
+	n.Handle("eth_call", n.ethCall)
+
+	server := httptest.NewServer(http.HandlerFunc(n.serveHTTP))
+	t.Cleanup(server.Close)
+
+	client, err := ethclient.Dial(server.URL)
+	if err != nil {
+		t.Fatalf("failed to dial fake node: %v", err)
+	}
+	t.Cleanup(client.Close)
+	n.Client, n.URL = client, server.URL
+
+	return n
+}
+
+// Handle answers method, such as "eth_sendRawTransaction", with fn, which
+// receives the raw request parameters.
+func (n *Node) Handle(method string, fn func(params []json.RawMessage) (any, error)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.methods[method] = fn
+}
+
+// AddABI lets HandleCall answer the methods of contractABI, such as those of
+// the ERC-721 contract, on top of the marketplace's.
+func (n *Node) AddABI(contractABI *abi.ABI) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.abis = append(n.abis, contractABI)
+}
+
+// HandleCall answers eth_calls of the marketplace method, or of a method of
+// an ABI given to AddABI, such as "commissionPercent", with fn, which receives
+// the unpacked arguments and returns the outputs to pack. Calls are told
+// apart by method name only, whatever contract they are sent to.
+func (n *Node) HandleCall(method string, fn func(args []any) ([]any, error)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.calls[method] = fn
+}
+
+// Count returns how many times method was requested. Marketplace methods are
+// counted by their name, as given to HandleCall.
+func (n *Node) Count(method string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.requests[method]
+}
+
+// Returns is a Handle function always answering result.
+func Returns(result any) func([]json.RawMessage) (any, error) {
+	return func([]json.RawMessage) (any, error) { return result, nil }
+}
+
+// Outputs is a HandleCall function always returning outputs.
+func Outputs(outputs ...any) func([]any) ([]any, error) {
+	return func([]any) ([]any, error) { return outputs, nil }
+}
+
+func (n *Node) ethCall(params []json.RawMessage) (any, error) {
+	var call struct {
+		Data  hexutil.Bytes `json:"data"`
+		Input hexutil.Bytes `json:"input"`
+	}
+	if err := json.Unmarshal(params[0], &call); err != nil {
+		return nil, err
+	}
+	data := call.Input
+	if len(data) == 0 {
+		data = call.Data
+	}
+	n.mu.Lock()
+	var method *abi.Method
+	for _, contractABI := range n.abis {
+		if m, err := contractABI.MethodById(data); err == nil {
+			method = m
+			break
+		}
+	}
+	if method == nil {
+		n.mu.Unlock()
+		return nil, fmt.Errorf("unknown method selector %x", data[:min(len(data), 4)])
+	}
+	n.requests[method.Name]++
+	fn := n.calls[method.Name]
+	n.mu.Unlock()
+	if fn == nil {
+		return nil, fmt.Errorf("unexpected call of %s", method.Name)
+	}
+
+	args, err := method.Inputs.Unpack(data[4:])
+	if err != nil {
+		return nil, err
+	}
+	outputs, err := fn(args)
+	if err != nil {
+		return nil, err
+	}
+	packed, err := method.Outputs.Pack(outputs...)
+	if err != nil {
+		n.t.Errorf("failed to pack %s outputs: %v", method.Name, err)
+		return nil, err
+	}
+	return hexutil.Bytes(packed), nil
+}
+
+type request struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *responseError  `json:"error,omitempty"`
+}
+
+func (n *Node) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if strings.HasPrefix(strings.TrimSpace(string(body)), "[") {
+		var batch []request
+		if err := json.Unmarshal(body, &batch); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		responses := make([]response, len(batch))
+		for i, req := range batch {
+			responses[i] = n.dispatch(req)
+		}
+		json.NewEncoder(w).Encode(responses)
+		return
+	}
+
+	var req request
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(n.dispatch(req))
+}
+
+func (n *Node) dispatch(req request) response {
+	n.mu.Lock()
+	if req.Method != "eth_call" {
+		n.requests[req.Method]++
+	}
+	fn := n.methods[req.Method]
+	n.mu.Unlock()
+
+	resp := response{JSONRPC: "2.0", ID: req.ID}
+	if fn == nil {
+		resp.Error = &responseError{Code: -32601, Message: "the method " + req.Method + " does not exist"}
+		return resp
+	}
+	result, err := fn(req.Params)
+	if err != nil {
+		resp.Error = &responseError{Code: -32000, Message: err.Error()}
+		return resp
+	}
+	if result == nil {
+		result = json.RawMessage("null")
+	}
+	resp.Result = result
+	return resp
+}
@@ -0,0 +1,151 @@
+package breaker
+
+import (
+	"errors"
+	"nft-marketplace/clock"
+	"sync"
+	"time"
+)
+
+// State is the state of a Breaker.
+type State int
+
+const (
+	// Closed lets every call through.
+	Closed State = iota
+	// Open fails every call fast until the cooldown is over.
+	Open
+	// HalfOpen lets a single probe call through to test for recovery.
+	HalfOpen
+)
+
+const (
+	defaultThreshold = 5
+	defaultCooldown  = 30 * time.Second
+)
+
+// ErrOpen is returned by Allow while the breaker is open.
+var ErrOpen = errors.New("circuit breaker is open")
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	default:
+		return "half-open"
+	}
+}
+
+// Breaker is a circuit breaker. It opens after Threshold consecutive failures,
+// fails calls fast for Cooldown and then lets one probe through: a successful
+// probe closes it again and a failed one reopens it for another cooldown. It is
+// safe for concurrent use.
+type Breaker struct {
+	Threshold int
+	Cooldown  time.Duration
+	// OnStateChange, if set, is called with every state transition, while
+	// the breaker's lock is held.
+	OnStateChange func(from, to State)
+	// Clock measures the cooldown. Nil means the wall clock.
+	Clock clock.Clock
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// New returns a closed breaker. Non-positive values use the defaults of five
+// failures and a 30 second cooldown.
+func New(threshold int, cooldown time.Duration) *Breaker {
+	if threshold <= 0 {
+		threshold = defaultThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = defaultCooldown
+	}
+	return &Breaker{Threshold: threshold, Cooldown: cooldown}
+}
+
+// Allow reports whether a call may be made, returning ErrOpen when it may
+// not. Every allowed call must be followed by Success, Failure or Release.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Open:
+		if clock.OrReal(b.Clock).Now().Sub(b.openedAt) < b.Cooldown {
+			return ErrOpen
+		}
+		b.setState(HalfOpen)
+		fallthrough
+	case HalfOpen:
+		if b.probing {
+			return ErrOpen
+		}
+		b.probing = true
+	}
+
+	return nil
+}
+
+// Success records a successful call.
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures, b.probing = 0, false
+	if b.state != Closed {
+		b.setState(Closed)
+	}
+}
+
+// Failure records a failed call.
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == HalfOpen || (b.state == Closed && b.failures >= b.Threshold) {
+		b.probing = false
+		b.openedAt = clock.OrReal(b.Clock).Now()
+		b.setState(Open)
+	}
+}
+
+// Release ends an allowed call whose outcome says nothing about the
+// protected resource, such as one cancelled by its caller. The state and the
+// failure count are left as they are; a half-open breaker lets another probe
+// through.
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == HalfOpen {
+		b.probing = false
+	}
+}
+
+// State returns the current state. An open breaker whose cooldown is over is
+// reported as half-open.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == Open && clock.OrReal(b.Clock).Now().Sub(b.openedAt) >= b.Cooldown {
+		return HalfOpen
+	}
+	return b.state
+}
+
+func (b *Breaker) setState(to State) {
+	from := b.state
+	b.state = to
+	if b.OnStateChange != nil && from != to {
+		b.OnStateChange(from, to)
+	}
+}
@@ -0,0 +1,109 @@
+package breaker
+
+import (
+	"nft-marketplace/clock"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	const (
+		allow   = "allow"
+		success = "success"
+		failure = "failure"
+		release = "release"
+		wait    = "wait"
+	)
+	type step struct {
+		op string
+		// wantErr is whether allow fails; wantState is checked after every
+		// step.
+		wantErr   bool
+		wantState State
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "opens after threshold failures",
+			steps: []step{
+				{op: allow}, {op: failure, wantState: Closed},
+				{op: allow}, {op: failure, wantState: Open},
+				{op: allow, wantErr: true, wantState: Open},
+			},
+		},
+		{
+			name: "success resets the failure count",
+			steps: []step{
+				{op: allow}, {op: failure},
+				{op: allow}, {op: success},
+				{op: allow}, {op: failure, wantState: Closed},
+			},
+		},
+		{
+			name: "successful probe closes",
+			steps: []step{
+				{op: allow}, {op: failure}, {op: allow}, {op: failure, wantState: Open},
+				{op: wait, wantState: HalfOpen},
+				{op: allow, wantState: HalfOpen},
+				{op: allow, wantErr: true, wantState: HalfOpen},
+				{op: success, wantState: Closed},
+			},
+		},
+		{
+			name: "failed probe reopens",
+			steps: []step{
+				{op: allow}, {op: failure}, {op: allow}, {op: failure, wantState: Open},
+				{op: wait, wantState: HalfOpen}, {op: allow, wantState: HalfOpen}, {op: failure, wantState: Open},
+				{op: allow, wantErr: true, wantState: Open},
+			},
+		},
+		{
+			name: "released probe stays half-open",
+			steps: []step{
+				{op: allow}, {op: failure}, {op: allow}, {op: failure, wantState: Open},
+				{op: wait, wantState: HalfOpen}, {op: allow, wantState: HalfOpen}, {op: release, wantState: HalfOpen},
+				// The slot is free for another probe.
+				{op: allow, wantState: HalfOpen},
+				{op: failure, wantState: Open},
+			},
+		},
+		{
+			name: "release keeps the failure count",
+			steps: []step{
+				{op: allow}, {op: failure},
+				{op: allow}, {op: release, wantState: Closed},
+				{op: allow}, {op: failure, wantState: Open},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := clock.NewFake(time.Unix(1_700_000_000, 0))
+			b := New(2, time.Minute)
+			b.Clock = fake
+
+			for i, s := range tt.steps {
+				switch s.op {
+				case allow:
+					if err := b.Allow(); (err != nil) != s.wantErr {
+						t.Fatalf("step %d: Allow() = %v, want error %t", i, err, s.wantErr)
+					}
+				case success:
+					b.Success()
+				case failure:
+					b.Failure()
+				case release:
+					b.Release()
+				case wait:
+					fake.Advance(time.Minute)
+				}
+				if got := b.State(); got != s.wantState {
+					t.Fatalf("step %d (%s): state = %s, want %s", i, s.op, got, s.wantState)
+				}
+			}
+		})
+	}
+}
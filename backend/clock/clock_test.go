@@ -0,0 +1,89 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeAfter(t *testing.T) {
+	start := time.Unix(1_800_000_000, 0)
+
+	tests := []struct {
+		name    string
+		after   time.Duration
+		advance []time.Duration
+		// wantFired is whether the channel has fired after each advance.
+		wantFired []bool
+	}{
+		{name: "due", after: time.Minute, advance: []time.Duration{time.Minute}, wantFired: []bool{true}},
+		{name: "not yet due", after: time.Minute, advance: []time.Duration{59 * time.Second}, wantFired: []bool{false}},
+		{name: "due over several advances", after: time.Minute, advance: []time.Duration{30 * time.Second, 30 * time.Second}, wantFired: []bool{false, true}},
+		{name: "past due", after: time.Second, advance: []time.Duration{time.Hour}, wantFired: []bool{true}},
+		{name: "no wait", after: 0, wantFired: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := NewFake(start)
+			ch := fake.After(tt.after)
+			if tt.after <= 0 {
+				if got := <-ch; !got.Equal(start) {
+					t.Fatalf("After(%s) fired at %s, want %s", tt.after, got, start)
+				}
+				return
+			}
+
+			var elapsed time.Duration
+			for i, d := range tt.advance {
+				fake.Advance(d)
+				elapsed += d
+				select {
+				case got := <-ch:
+					if !tt.wantFired[i] {
+						t.Fatalf("fired after %s, want after %s", elapsed, tt.after)
+					}
+					if want := start.Add(elapsed); !got.Equal(want) {
+						t.Fatalf("fired with %s, want %s", got, want)
+					}
+				default:
+					if tt.wantFired[i] {
+						t.Fatalf("not fired after %s", elapsed)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFakeAdvanceWaiters(t *testing.T) {
+	fake := NewFake(time.Unix(1_800_000_000, 0))
+	late := fake.After(3 * time.Second)
+	early := fake.After(time.Second)
+	never := fake.After(time.Hour)
+
+	fake.Advance(5 * time.Second)
+	for name, ch := range map[string]<-chan time.Time{"early": early, "late": late} {
+		select {
+		case <-ch:
+		default:
+			t.Fatalf("%s waiter not fired", name)
+		}
+	}
+	select {
+	case <-never:
+		t.Fatal("waiter fired before it was due")
+	default:
+	}
+	if got, want := fake.Now(), time.Unix(1_800_000_005, 0); !got.Equal(want) {
+		t.Fatalf("Now() = %s, want %s", got, want)
+	}
+}
+
+func TestOrReal(t *testing.T) {
+	fake := NewFake(time.Unix(1_800_000_000, 0))
+	if OrReal(nil) != Real {
+		t.Fatal("OrReal(nil) is not the wall clock")
+	}
+	if OrReal(fake) != Clock(fake) {
+		t.Fatal("OrReal(fake) is not the fake clock")
+	}
+}
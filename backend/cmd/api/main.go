@@ -2,8 +2,13 @@ package main
 
 import (
 	"log"
+	"nft-marketplace/config"
 	"nft-marketplace/db"
+	"nft-marketplace/db/migrate"
+	"nft-marketplace/db/migrations"
 	"nft-marketplace/handlers"
+	"nft-marketplace/logging"
+	"nft-marketplace/middleware"
 	"os"
 
 	"github.com/gin-gonic/gin"
@@ -11,38 +16,77 @@ import (
 	"gorm.io/gorm"
 )
 
-func DBInit() *gorm.DB {
-	db, err := db.ConnectDB()
+// DBInit opens the API's connection pool, which the db package functions
+// share, and migrates the schema.
+func DBInit(cfg *config.Config) *gorm.DB {
+	conn, err := db.Open(cfg)
 	if err != nil {
 		log.Fatalf("Failed to connect to the database: %v", err)
 	}
+	db.SetDefault(conn)
 
-	return db
+	if err := migrate.Migrate(conn, migrations.FS); err != nil {
+		log.Fatalf("Failed to migrate the database: %v", err)
+	}
+
+	return conn
 }
 
-func SetupRouter() *gin.Engine {
+func SetupRouter(cfg *config.Config) *gin.Engine {
 	r := gin.Default()
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	r.HandleMethodNotAllowed = true
+	r.NoRoute(handlers.NotFound())
+	r.NoMethod(handlers.MethodNotAllowed())
 
-	db := DBInit()
+	db := DBInit(cfg)
 
 	server := handlers.NewServer(db)
 
 	router := r.Group("/api")
 
 	//router.Use(middleware.JwtAuthMiddleware())
-	router.POST("/register", server.Register)
-	router.POST("/login", server.Login)
+	authRoutes(router, server, cfg)
 
 	return r
 }
 
+// authRoutes registers the account routes on router. Both login routes check
+// the same credentials, so they share one rate limit: otherwise the legacy
+// alias would be an unthrottled way around it.
+func authRoutes(router gin.IRoutes, server *handlers.Server, cfg *config.Config) {
+	loginLimit := middleware.RateLimit(cfg.LoginRateLimit, cfg.LoginRateWindow)
+
+	router.POST("/register", server.Register)
+	router.POST("/login", loginLimit, server.Login)
+	router.POST("/logout", server.Logout)
+	router.POST("/wallet", server.LinkWallet)
+	router.POST("/auth/login", loginLimit, server.AuthLogin)
+}
+
 func main() {
 	if err := godotenv.Load(); err != nil {
 		log.Fatalf("Error loading .env file: %v", err)
 	}
+	if err := logging.Configure(os.Getenv("LOG_LEVEL"), os.Getenv("API_SECRET"), os.Getenv("DB_PASSWORD")); err != nil {
+		log.Printf("Invalid LOG_LEVEL, using info: %v", err)
+	}
 	port := os.Getenv("SERVER_ADDRESS")
 
-	r := SetupRouter()
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		conn, err := db.Open(config.LoadConfig())
+		if err != nil {
+			log.Fatalf("Failed to connect to the database: %v", err)
+		}
+		if err := migrate.Run(conn, migrations.FS, os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	r := SetupRouter(config.LoadConfig())
 
 	log.Fatal(r.Run(port))
 }
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"nft-marketplace/config"
+	"nft-marketplace/handlers"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLoginRoutesShareRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name string
+		// paths are the login routes tried in turn from one client.
+		paths      []string
+		wantStatus []int
+	}{
+		{
+			name:       "legacy alias",
+			paths:      []string{"/api/login", "/api/login", "/api/login"},
+			wantStatus: []int{http.StatusBadRequest, http.StatusBadRequest, http.StatusTooManyRequests},
+		},
+		{
+			name:       "alternating routes",
+			paths:      []string{"/api/auth/login", "/api/login", "/api/auth/login", "/api/login"},
+			wantStatus: []int{http.StatusBadRequest, http.StatusBadRequest, http.StatusTooManyRequests, http.StatusTooManyRequests},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			// Only failed validations are tried, which need no database.
+			authRoutes(r.Group("/api"), handlers.NewServer(nil), &config.Config{LoginRateLimit: 2, LoginRateWindow: time.Minute})
+
+			for i, path := range tt.paths {
+				req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{}`))
+				req.Header.Set("Content-Type", "application/json")
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+
+				if w.Code != tt.wantStatus[i] {
+					t.Fatalf("request %d to %s: status = %d, want %d: %s", i+1, path, w.Code, tt.wantStatus[i], w.Body)
+				}
+			}
+		})
+	}
+}
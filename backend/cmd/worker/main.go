@@ -1,29 +1,47 @@
 package main
 
 import (
+	"context"
 	"log"
+	"math/big"
+	"net/http"
 	"nft-marketplace/config"
 	"nft-marketplace/db"
+	"nft-marketplace/db/migrate"
+	"nft-marketplace/db/migrations"
+	"nft-marketplace/featureflags"
 	"nft-marketplace/handlers"
+	"nft-marketplace/logging"
+	"nft-marketplace/metrics"
 	"nft-marketplace/middleware"
+	"nft-marketplace/rpcpool"
 	"nft-marketplace/services"
+	"nft-marketplace/supervisor"
+	"nft-marketplace/utils"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"gorm.io/gorm"
 )
 
-func InitDB() *gorm.DB {
-	db, err := db.ConnectDB()
+// InitDB opens the worker's connection pool, which the db package functions
+// share.
+func InitDB(cfg *config.Config) *gorm.DB {
+	conn, err := db.Open(cfg)
 	if err != nil {
 		log.Fatalf("Failed to connect to the database: %v", err)
 	}
+	db.SetDefault(conn)
 
-	return db
+	return conn
 }
 
 func main() {
@@ -31,40 +49,310 @@ func main() {
 		log.Fatal("Failed to load .env file:", err)
 	}
 	cfg := config.LoadConfig()
+	if err := logging.Configure(cfg.LogLevel, cfg.PrivateKey, cfg.APISecret, cfg.DBPass, cfg.AdminToken); err != nil {
+		log.Printf("Invalid LOG_LEVEL, using info: %v", err)
+	}
 
-	db := InitDB()
+	db := InitDB(cfg)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrate.Run(db, migrations.FS, os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+	if err := migrate.Migrate(db, migrations.FS); err != nil {
+		log.Fatalf("Failed to migrate the database: %v", err)
+	}
 
-	client, err := ethclient.Dial(cfg.BlockChainRPC)
+	utils.SetRPCBreaker(cfg.RPCBreakerThreshold, cfg.RPCBreakerCooldown)
+	utils.SetPageLimits(cfg.PaginationDefaultLimit, cfg.PaginationMaxLimit)
+	utils.SetWeiJSONNumbers(cfg.WeiJSONNumbers)
+	client, err := utils.DialEthereum(context.Background(), cfg.BlockChainRPC, cfg.RPCDialTimeout)
 	if err != nil {
 		log.Panicf("Failed to connect to Ethereum client: %v", err)
 	}
 
 	privateKey, err := crypto.HexToECDSA(cfg.PrivateKey)
 	if err != nil {
-		log.Fatalf("Invalid private key: %v", err)
+		log.Fatal("Invalid private key")
+	}
+
+	var blocklist []common.Address
+	for _, address := range cfg.RecipientBlocklist {
+		if !common.IsHexAddress(address) {
+			log.Fatalf("Invalid address in RECIPIENT_BLOCKLIST: %s", address)
+		}
+		blocklist = append(blocklist, common.HexToAddress(address))
+	}
+
+	var treasury common.Address
+	if cfg.TreasuryAddress != "" {
+		if !common.IsHexAddress(cfg.TreasuryAddress) {
+			log.Fatalf("Invalid TREASURY_ADDRESS: %s", cfg.TreasuryAddress)
+		}
+		treasury = common.HexToAddress(cfg.TreasuryAddress)
+	}
+
+	parsedERC721, err := abi.JSON(strings.NewReader(services.ERC721ABI))
+	if err != nil {
+		log.Fatalf("Failed to parse ERC-721 ABI: %v", err)
+	}
+	collections := services.NewCollectionRegistry()
+	for _, entry := range cfg.Collections {
+		id, address, ok := strings.Cut(entry, "=")
+		if !ok || !common.IsHexAddress(address) {
+			log.Fatalf("Invalid entry in COLLECTIONS, expected id=address: %s", entry)
+		}
+		if _, err := collections.Register(id, common.HexToAddress(address), parsedERC721, client); err != nil {
+			log.Fatalf("Failed to register collection %s: %v", id, err)
+		}
+	}
+
+	var queryCache services.QueryCache
+	if cfg.QueryCacheTTL > 0 {
+		queryCache = services.NewMemoryQueryCache(cfg.QueryCacheTTL, cfg.QueryCacheSize)
+	}
+
+	var pinQueue *services.PinQueue
+	if cfg.IPFSNodeAddress != "" {
+		pinQueue = services.NewPinQueue(services.NewIPFSPinner(cfg.IPFSNodeAddress), cfg.IPFSPinConcurrency, cfg.IPFSPinQueueSize)
+		if cfg.IPFSPinAttempts > 0 {
+			pinQueue.MaxAttempts = cfg.IPFSPinAttempts
+		}
 	}
 
 	etherService := &services.EthereumService{
-		Client:          client,
-		ContractAddress: common.HexToAddress(cfg.ContractAddress),
-		PrivateKey:      privateKey,
-		Contract:        nil,
+		Client:             client,
+		ContractAddress:    common.HexToAddress(cfg.ContractAddress),
+		PrivateKey:         privateKey,
+		DegradedReads:      cfg.DegradedReads,
+		ReconcileRPS:       cfg.ReconcileRPS,
+		MaxGasPrice:        cfg.MaxGasPrice,
+		ResubmitInterval:   cfg.ResubmitInterval,
+		GasStrategy:        cfg.GasStrategy,
+		DurableNonces:      cfg.DurableNonces,
+		RecipientBlocklist: blocklist,
+		TreasuryAddress:    treasury,
+		MinListingPrice:    cfg.MinListingPrice,
+		MaxListingPrice:    cfg.MaxListingPrice,
+		Collections:        collections,
+		LogScanChunkSize:   uint64(max(cfg.LogScanChunkSize, 0)),
+		DeployBlock:        cfg.ContractDeployBlock,
+		WebsocketRPC:       cfg.IndexerRPC,
+		MaxReorgDepth:      uint64(max(cfg.IndexerMaxReorgDepth, 0)),
+		MintConfirmTimeout: cfg.MintConfirmTimeout,
+		MintConfirmations:  uint64(max(cfg.MintConfirmations, 0)),
+		VerifyPurchases:    cfg.VerifyPurchases,
+		QueryCache:         queryCache,
+		PinQueue:           pinQueue,
+		RPCPool:            rpcpool.New(cfg.RPCConcurrency),
+	}
+
+	if cfg.VerifyABI {
+		if err := etherService.VerifyABICompatibility(context.Background()); err != nil {
+			log.Fatalf("Marketplace ABI check failed: %v", err)
+		}
+	}
+
+	if err := etherService.CheckCollections(context.Background()); err != nil {
+		log.Fatalf("COLLECTIONS does not match the marketplace: %v", err)
+	}
+
+	if cfg.DurableNonces {
+		if err := etherService.SyncNonce(context.Background()); err != nil {
+			log.Fatalf("Failed to sync the transaction nonce: %v", err)
+		}
+	}
+
+	if cfg.SelfTest {
+		runSelfTest(etherService, cfg)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	app := supervisor.New(cfg.ShutdownTimeout)
+
+	if cfg.ReconcileInterval > 0 {
+		app.Add("reconciler", func(ctx context.Context) error {
+			etherService.RunReconciler(ctx, cfg.ReconcileInterval, cfg.ReconcileBatchSize)
+			return nil
+		})
+	}
+
+	if cfg.StaleListingInterval > 0 {
+		app.Add("listing expirer", func(ctx context.Context) error {
+			etherService.RunListingExpirer(ctx, cfg.StaleListingInterval, cfg.StaleListingAge, cfg.ReconcileBatchSize)
+			return nil
+		})
+	}
+
+	if cfg.RevocationCleanupInterval > 0 {
+		app.Add("revocation cleanup", func(ctx context.Context) error {
+			utils.RunRevocationCleanup(ctx, cfg.RevocationCleanupInterval)
+			return nil
+		})
+	}
+
+	engagement := services.NewEngagement(cfg.ViewDebounce)
+	if cfg.ViewCleanupInterval > 0 {
+		app.Add("view cleanup", func(ctx context.Context) error {
+			engagement.RunViewCleanup(ctx, cfg.ViewCleanupInterval)
+			return nil
+		})
+	}
+
+	if cfg.IndexerRPC != "" {
+		if !utils.IsWebsocketURL(cfg.IndexerRPC) {
+			log.Printf("Warning: INDEXER_RPC is not a ws:// or wss:// URL, the event indexer needs subscriptions")
+		}
+		app.Add("event indexer", func(ctx context.Context) error {
+			return etherService.RunIndexer(ctx, cfg.IndexerRPC, cfg.IndexerStartBlock)
+		})
+	}
+
+	if pinQueue != nil {
+		app.Add("pin queue", func(ctx context.Context) error {
+			pinQueue.Run(ctx)
+			return nil
+		})
+	}
+
+	// Commission cache invalidation is best effort: the cache still expires
+	// on its own, so failing to watch does not stop the worker.
+	if !utils.IsWebsocketURL(cfg.BlockChainRPC) {
+		log.Printf("Warning: BLOCKCHAIN_RPC is not a ws:// or wss:// URL, commission updates cannot be watched")
+	}
+	app.Add("commission watcher", func(ctx context.Context) error {
+		if err := etherService.WatchCommissionUpdates(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Commission cache invalidation disabled: %v", err)
+		}
+		return nil
+	})
+
+	mintingSwitch := services.NewMintingSwitch(cfg.MintingPaused)
+	mintAllowlist := services.NewMintAllowlist(cfg.MintAllowlistEnabled)
+	mintQuota := services.NewMintQuota(cfg.MintDailyQuota)
+
+	flagDefaults, err := featureflags.ParseDefaults(cfg.FeatureFlags)
+	if err != nil {
+		log.Fatalf("Invalid FEATURE_FLAGS: %v", err)
+	}
+	flags := featureflags.New(flagDefaults, cfg.FeatureFlagCacheTTL)
+	featureflags.SetDefault(flags)
+
+	// Panics are handled by middleware.Recover around the whole router, which
+	// answers with a JSON error instead of gin's plain 500.
+	router := gin.New()
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
 	}
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(handlers.NotFound())
+	router.NoMethod(handlers.MethodNotAllowed())
+	router.Use(gin.Logger())
+	router.Use(middleware.Metrics())
 
-	router := gin.Default()
+	headers := middleware.DefaultSecureHeaderOptions()
+	headers.AllowedOrigins = cfg.CORSAllowedOrigins
+	headers.AllowedHeaders = append(headers.AllowedHeaders, middleware.AdminTokenHeader)
+	headers.FrameOptions = cfg.FrameOptions
+	headers.ReferrerPolicy = cfg.ReferrerPolicy
+	headers.HSTSMaxAge = cfg.HSTSMaxAge
+	router.Use(middleware.SecureHeaders(headers))
+	queueTimeout := time.Duration(0)
+	if cfg.ConcurrencyLimitMode == "queue" {
+		queueTimeout = cfg.ConcurrencyQueueTimeout
+	}
+	router.Use(middleware.LimitConcurrency(cfg.MaxConcurrentRequests, queueTimeout))
+	// Timeout buffers responses, so streamed ones are routed before it.
+	router.GET("/sellers/:address/export", handlers.ExportSellerHistory())
+	router.Use(middleware.Timeout(cfg.RequestTimeout))
+	if cfg.LogBodies {
+		log.Printf("Warning: LOG_BODIES is enabled, request and response bodies are logged")
+		router.Use(middleware.LogBodies(cfg.LogBodyLimit))
+	}
 
 	server := handlers.NewServers(db)
 
 	middlewareNFTs := router.Group("/nfts")
 
 	middlewareNFTs.Use(middleware.MintNFT(etherService))
-	router.POST("/Create", server.MintNFT(etherService))
+	router.POST("/Create", middleware.MintingEnabled(mintingSwitch), middleware.MintAllowlisted(mintAllowlist), middleware.MintQuotaLimited(mintQuota), server.MintNFT(etherService))
 	middlewareNFTs.Use(middleware.GetNFTs(etherService))
 	router.GET("/nfts/:id", handlers.GetNFTs(etherService))
+	router.GET("/nfts/trending", handlers.GetTrendingNFTs())
+	router.GET("/nfts/:id/owner", handlers.GetOwner(etherService))
+	router.POST("/ownership/verify", handlers.VerifyOwnership(etherService))
+	router.GET("/nfts/:id/history", handlers.GetListingHistory(etherService))
+	router.GET("/nfts/:id/snapshot", handlers.GetTokenSnapshot(etherService, engagement))
+	router.POST("/nfts/:id/views", handlers.CountTokenView(etherService, engagement))
+	router.POST("/nfts/:id/favorite", middleware.JwtAuthMiddleware(), handlers.ToggleFavorite(etherService, engagement))
 	middlewareNFTs.Use(middleware.BuyNFT(etherService))
 	router.POST("/Buy", handlers.BuyNFT(etherService))
+	router.GET("/users/:id/nfts", handlers.GetUserNFTs(etherService))
+	router.GET("/pins/:id", handlers.GetPinJob(etherService))
 	router.GET("/Search", handlers.SearchNFTs(etherService))
+	router.GET("/commission", handlers.GetCommission(etherService))
+	router.GET("/contract/owner", handlers.GetContractOwner(etherService))
+	router.GET("/fees", handlers.GetFees(etherService))
+	router.GET("/ens/:name", handlers.ResolveENS(etherService))
 	router.DELETE("/nfts/:id", handlers.DeleteNFT(etherService))
+	router.GET("/listings", handlers.GetListings(etherService))
+	router.GET("/listings/:id/cost", handlers.GetPurchaseCost(etherService))
+	router.DELETE("/listings/:id", middleware.JwtAuthMiddleware(), handlers.CancelListing(etherService))
+	router.DELETE("/listings", middleware.JwtAuthMiddleware(), handlers.CancelAllListings(etherService))
+	relayLimit := &middleware.RateLimiter{Limit: cfg.RelayRateLimit, Window: cfg.RelayRateWindow, Key: middleware.UserKey}
+	router.POST("/listings/relay", middleware.MintingEnabled(mintingSwitch), middleware.JwtAuthMiddleware(), relayLimit.Handler(), handlers.RelayListing(etherService))
+	router.GET("/events", handlers.GetEvents())
+	router.GET("/health", handlers.Health())
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	admin := router.Group("/admin")
+	admin.Use(middleware.AdminAuthMiddleware(cfg.AdminToken))
+	admin.POST("/minting", handlers.SetMinting(mintingSwitch))
+	admin.POST("/signer", handlers.RotateSigner(etherService))
+	admin.GET("/users", handlers.ListUsers())
+	admin.GET("/mint-allowlist", handlers.GetMintAllowlist(mintAllowlist))
+	admin.POST("/mint-allowlist", handlers.AddToMintAllowlist(mintAllowlist))
+	admin.DELETE("/mint-allowlist/:address", handlers.RemoveFromMintAllowlist(mintAllowlist))
+	admin.GET("/flags", handlers.GetFeatureFlags(flags))
+	admin.PUT("/flags/:name", handlers.SetFeatureFlag(flags))
+	admin.DELETE("/flags/:name", handlers.ClearFeatureFlag(flags))
+	admin.GET("/revenue", handlers.GetCommissionRevenue())
+
+	address := os.Getenv("SERVER_ADDRESS")
+	if address == "" {
+		address = ":8080"
+	}
+	app.Add("http server", supervisor.HTTPServer(&http.Server{Addr: address, Handler: middleware.Recover(router)}, cfg.ShutdownTimeout))
 
-	router.Run(os.Getenv("SERVER_ADDRESS"))
+	err = app.Run(ctx)
+	etherService.Close()
+	if err != nil {
+		log.Fatalf("Worker stopped: %v", err)
+	}
+}
+
+// runSelfTest lists and cancels the configured self-test token, stopping the
+// worker with a report of how far it got if anything fails.
+func runSelfTest(etherService *services.EthereumService, cfg *config.Config) {
+	if cfg.SelfTestTokenID == nil {
+		log.Fatal("SELF_TEST requires SELF_TEST_TOKEN_ID")
+	}
+	price := cfg.SelfTestPrice
+	if price == nil || price.Sign() <= 0 {
+		price = big.NewInt(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.SelfTestTimeout)
+	defer cancel()
+
+	report, err := etherService.RunSelfTest(ctx, cfg.SelfTestTokenID, price)
+	if err != nil {
+		log.Fatalf("Startup self-test failed on chain %v with account %s, token %s: completed %v, listing %v, list tx %s, cancel tx %s: %v",
+			report.ChainID, report.Account.Hex(), report.TokenID, report.Completed, report.ListingID, report.ListTx.Hex(), report.CancelTx.Hex(), err)
+	}
+	log.Printf("Startup self-test passed on chain %s: listed token %s as listing %s (tx %s) and cancelled it (tx %s)",
+		report.ChainID, report.TokenID, report.ListingID, report.ListTx.Hex(), report.CancelTx.Hex())
 }
@@ -2,7 +2,11 @@ package config
 
 import (
 	"log"
+	"math/big"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -13,18 +17,200 @@ type Config struct {
 	DBPort string `mapstructure:"DB_PORT"`
 	DBUser string `mapstructure:"DB_USER"`
 	DBPass string `mapstructure:"DB_PASSWORD"`
+	// DBStatementTimeout cancels database statements running longer, so
+	// that a pathological search cannot hold a connection. Zero disables it;
+	// migrations are never cut short.
+	DBStatementTimeout time.Duration `mapstructure:"DB_STATEMENT_TIMEOUT"`
 
 	ServerAddress string `mapstructure:"SERVER_ADDRESS"`
+	// ShutdownTimeout bounds how long the worker waits for in-flight requests
+	// and background jobs to stop on shutdown.
+	ShutdownTimeout time.Duration `mapstructure:"SHUTDOWN_TIMEOUT"`
+	// RequestTimeout bounds how long a request may take before it is answered
+	// with 503 Service Unavailable. Zero disables the limit.
+	RequestTimeout time.Duration `mapstructure:"REQUEST_TIMEOUT"`
+	// MaxConcurrentRequests caps how many requests are served at once. Zero
+	// disables the limit.
+	MaxConcurrentRequests int `mapstructure:"MAX_CONCURRENT_REQUESTS"`
+	// ConcurrencyLimitMode is what happens to requests beyond
+	// MaxConcurrentRequests: "reject" answers them with 503 Service
+	// Unavailable at once, "queue" has them wait up to
+	// ConcurrencyQueueTimeout for a slot first.
+	ConcurrencyLimitMode    string        `mapstructure:"CONCURRENCY_LIMIT_MODE"`
+	ConcurrencyQueueTimeout time.Duration `mapstructure:"CONCURRENCY_QUEUE_TIMEOUT"`
+
+	// PaginationDefaultLimit is the page size of list endpoints when a
+	// request gives no limit, and PaginationMaxLimit the largest a request
+	// may ask for.
+	PaginationDefaultLimit int `mapstructure:"PAGINATION_DEFAULT_LIMIT"`
+	PaginationMaxLimit     int `mapstructure:"PAGINATION_MAX_LIMIT"`
+	// WeiJSONNumbers encodes wei amounts in responses as JSON numbers, as
+	// before, rather than as decimal strings.
+	WeiJSONNumbers bool `mapstructure:"WEI_JSON_NUMBERS"`
+
+	// QueryCacheTTL is how long results of hot database queries, such as
+	// searches, are cached; zero disables the cache. QueryCacheSize caps the
+	// number of cached results.
+	QueryCacheTTL  time.Duration `mapstructure:"QUERY_CACHE_TTL"`
+	QueryCacheSize int           `mapstructure:"QUERY_CACHE_SIZE"`
+
+	BlockChainRPC string `mapstructure:"BLOCKCHAIN_RPC"`
+	// RPCDialTimeout bounds how long connecting to BLOCKCHAIN_RPC may take.
+	RPCDialTimeout time.Duration `mapstructure:"RPC_DIAL_TIMEOUT"`
+	// RPCBreakerThreshold consecutive failed RPC requests open the circuit
+	// breaker, which then fails requests fast for RPCBreakerCooldown.
+	RPCBreakerThreshold int           `mapstructure:"RPC_BREAKER_THRESHOLD"`
+	RPCBreakerCooldown  time.Duration `mapstructure:"RPC_BREAKER_COOLDOWN"`
 
-	BlockChainRPC   string `mapstructure:"BLOCKCHAIN_RPC"`
 	PrivateKey      string `mapstructure:"PRIVATE_KEY"`
 	MarketplaceABI  string `mapstructure:"MARKETPLACE_ABI"`
 	ContractAddress string `mapstructure:"CONTRACT_ADDRESS"`
+	// VerifyABI checks on startup that the marketplace ABI matches the
+	// deployed contract and stops the worker if it does not.
+	VerifyABI bool `mapstructure:"VERIFY_ABI"`
+	// SelfTest lists SelfTestTokenID at SelfTestPrice wei and cancels the
+	// listing on startup, stopping the worker if either fails. It is refused
+	// on mainnets.
+	SelfTest        bool          `mapstructure:"SELF_TEST"`
+	SelfTestTokenID *big.Int      `mapstructure:"SELF_TEST_TOKEN_ID"`
+	SelfTestPrice   *big.Int      `mapstructure:"SELF_TEST_PRICE_WEI"`
+	SelfTestTimeout time.Duration `mapstructure:"SELF_TEST_TIMEOUT"`
 
 	IPFSNodeAddress string `mapstructure:"IPFS_NODE_ADDRESS"`
+	// IPFSPinConcurrency, IPFSPinQueueSize and IPFSPinAttempts configure the
+	// queue pinning mint metadata to IPFSNodeAddress in the background.
+	IPFSPinConcurrency int `mapstructure:"IPFS_PIN_CONCURRENCY"`
+	IPFSPinQueueSize   int `mapstructure:"IPFS_PIN_QUEUE_SIZE"`
+	IPFSPinAttempts    int `mapstructure:"IPFS_PIN_ATTEMPTS"`
 
 	TokenLifespan string `mapstructure:"TOKEN_HOUR_LIFESPAN"`
 	APISecret     string `mapstructure:"API_SECRET"`
+
+	// DegradedReads lets read endpoints fall back to on-chain data when the
+	// database is unavailable.
+	DegradedReads bool `mapstructure:"DEGRADED_READS"`
+
+	// ReconcileInterval is how often DB listings are checked against the
+	// chain. Zero disables the reconciler.
+	ReconcileInterval  time.Duration `mapstructure:"RECONCILE_INTERVAL"`
+	ReconcileBatchSize int           `mapstructure:"RECONCILE_BATCH_SIZE"`
+	// ReconcileRPS caps the on-chain checks the reconciler makes per second.
+	ReconcileRPS int `mapstructure:"RECONCILE_RPS"`
+
+	// StaleListingInterval is how often active DB listings older than
+	// StaleListingAge are re-verified on-chain. Zero disables the check.
+	StaleListingInterval time.Duration `mapstructure:"STALE_LISTING_INTERVAL"`
+	StaleListingAge      time.Duration `mapstructure:"STALE_LISTING_AGE"`
+
+	// ViewDebounce is how long repeated views of a token by the same user or
+	// client IP count once. ViewCleanupInterval is how often the views older
+	// than that are removed; zero disables the cleanup.
+	ViewDebounce        time.Duration `mapstructure:"VIEW_DEBOUNCE"`
+	ViewCleanupInterval time.Duration `mapstructure:"VIEW_CLEANUP_INTERVAL"`
+
+	// RevocationCleanupInterval is how often the revocations of expired
+	// tokens are removed. Zero disables the cleanup.
+	RevocationCleanupInterval time.Duration `mapstructure:"REVOCATION_CLEANUP_INTERVAL"`
+
+	// LoginRateLimit caps the login attempts each client IP may make per
+	// LoginRateWindow. Zero disables the limit.
+	LoginRateLimit  int           `mapstructure:"LOGIN_RATE_LIMIT"`
+	LoginRateWindow time.Duration `mapstructure:"LOGIN_RATE_WINDOW"`
+	// RelayRateLimit caps the listings each user may have relayed, at the
+	// service's expense, per RelayRateWindow. Zero disables the limit.
+	RelayRateLimit  int           `mapstructure:"RELAY_RATE_LIMIT"`
+	RelayRateWindow time.Duration `mapstructure:"RELAY_RATE_WINDOW"`
+	// TrustedProxies are the addresses or CIDRs of the reverse proxies whose
+	// X-Forwarded-For header tells the client IP that rate limits and view
+	// counts go by. Empty trusts none: the client IP is the peer address.
+	TrustedProxies []string `mapstructure:"TRUSTED_PROXIES"`
+
+	// AdminToken guards the /admin endpoints. Admin endpoints reject every
+	// request while it is empty.
+	AdminToken string `mapstructure:"ADMIN_TOKEN"`
+	// MintingPaused is the minting kill switch used until an operator
+	// overrides it through the admin endpoint.
+	MintingPaused bool `mapstructure:"MINTING_PAUSED"`
+	// MintAllowlistEnabled restricts minting to the creators allowlisted
+	// through the admin endpoints.
+	MintAllowlistEnabled bool `mapstructure:"MINT_ALLOWLIST_ENABLED"`
+	// MintDailyQuota caps the mints of each user per UTC day. Zero lifts
+	// the cap.
+	MintDailyQuota int `mapstructure:"MINT_DAILY_QUOTA"`
+	// FeatureFlags are the defaults of the feature flags, as "name=true" or
+	// "name=false" items, which the admin endpoints can override.
+	FeatureFlags []string `mapstructure:"FEATURE_FLAGS"`
+	// FeatureFlagCacheTTL is how long overrides made by other workers may
+	// take to be seen.
+	FeatureFlagCacheTTL time.Duration `mapstructure:"FEATURE_FLAG_CACHE_TTL"`
+
+	// MaxGasPrice caps, in wei, the fees stuck transactions are bumped to.
+	MaxGasPrice      *big.Int      `mapstructure:"MAX_GAS_PRICE"`
+	ResubmitInterval time.Duration `mapstructure:"TX_RESUBMIT_INTERVAL"`
+	// DurableNonces keeps transaction nonces in the database so that they
+	// survive restarts.
+	DurableNonces bool `mapstructure:"DURABLE_NONCES"`
+	// GasStrategy prices transactions: economy, standard or fast.
+	GasStrategy GasStrategy `mapstructure:"GAS_STRATEGY"`
+	// MintConfirmTimeout is how long minting waits for its listing
+	// transaction to be mined before answering with a pending result.
+	MintConfirmTimeout time.Duration `mapstructure:"MINT_CONFIRM_TIMEOUT"`
+	// MintConfirmations is how many confirmations a mint waits for before
+	// it is recorded in the database. Zero records it once mined.
+	MintConfirmations int `mapstructure:"MINT_CONFIRMATIONS"`
+	// VerifyPurchases makes purchases check in the background, once mined,
+	// that the buyer received the token.
+	VerifyPurchases bool `mapstructure:"VERIFY_PURCHASES"`
+
+	// RecipientBlocklist is a comma separated list of addresses minting to is
+	// refused for. It defaults to the 0x...dEaD burn address; set it to "none"
+	// to allow every non-zero recipient.
+	RecipientBlocklist []string `mapstructure:"RECIPIENT_BLOCKLIST"`
+	// MinListingPrice and MaxListingPrice bound, in wei, the price tokens can
+	// be listed at. Unset or zero leaves that side unbounded.
+	MinListingPrice *big.Int `mapstructure:"MIN_LISTING_PRICE_WEI"`
+	MaxListingPrice *big.Int `mapstructure:"MAX_LISTING_PRICE_WEI"`
+	// TreasuryAddress is the mint recipient used when a request gives none.
+	TreasuryAddress string `mapstructure:"TREASURY_ADDRESS"`
+
+	// LogScanChunkSize is the number of blocks requested per eth_getLogs
+	// call when replaying marketplace events.
+	LogScanChunkSize int `mapstructure:"LOG_SCAN_CHUNK_SIZE"`
+	// ContractDeployBlock is the block the marketplace was deployed at, where
+	// replays of its events start. It defaults to IndexerStartBlock.
+	ContractDeployBlock uint64 `mapstructure:"CONTRACT_DEPLOY_BLOCK"`
+
+	// Collections is a comma separated list of id=address pairs naming the
+	// NFT contracts traded on the marketplace. The first one is the default
+	// collection.
+	Collections []string `mapstructure:"COLLECTIONS"`
+
+	// IndexerRPC is the websocket endpoint the event indexer and transfer
+	// watches subscribe through. The indexer is disabled while it is empty.
+	IndexerRPC        string `mapstructure:"INDEXER_RPC"`
+	IndexerStartBlock uint64 `mapstructure:"INDEXER_START_BLOCK"`
+	// IndexerMaxReorgDepth is the deepest reorg, in blocks, the indexer
+	// rolls back and reindexes.
+	IndexerMaxReorgDepth int `mapstructure:"INDEXER_MAX_REORG_DEPTH"`
+
+	// RPCConcurrency caps the calls batch reads have in flight at once.
+	RPCConcurrency int `mapstructure:"RPC_CONCURRENCY"`
+
+	// CORSAllowedOrigins is a comma separated list of the origins allowed to
+	// make cross-origin requests, "*" for any.
+	CORSAllowedOrigins []string `mapstructure:"CORS_ALLOWED_ORIGINS"`
+	// FrameOptions, ReferrerPolicy and HSTSMaxAge override the defaults of
+	// the matching security headers; "none" leaves the header out.
+	FrameOptions   string        `mapstructure:"FRAME_OPTIONS"`
+	ReferrerPolicy string        `mapstructure:"REFERRER_POLICY"`
+	HSTSMaxAge     time.Duration `mapstructure:"HSTS_MAX_AGE"`
+
+	// LogLevel is the minimum level logged: debug, info, warn or error.
+	LogLevel string `mapstructure:"LOG_LEVEL"`
+	// LogBodies logs request and response bodies, cut to LogBodyLimit bytes
+	// and with sensitive fields redacted, for debugging clients.
+	LogBodies    bool `mapstructure:"LOG_BODIES"`
+	LogBodyLimit int  `mapstructure:"LOG_BODY_LIMIT"`
 }
 
 func LoadConfig() *Config {
@@ -33,19 +219,221 @@ func LoadConfig() *Config {
 		log.Fatal("Failed to load .env file:", err)
 	}
 
+	concurrencyMode := strings.ToLower(strings.TrimSpace(os.Getenv("CONCURRENCY_LIMIT_MODE")))
+	switch concurrencyMode {
+	case "":
+		concurrencyMode = "reject"
+	case "reject", "queue":
+	default:
+		log.Fatalf("Invalid CONCURRENCY_LIMIT_MODE %q: expected \"reject\" or \"queue\"", concurrencyMode)
+	}
+
+	gasStrategy, err := ParseGasStrategy(os.Getenv("GAS_STRATEGY"))
+	if err != nil {
+		log.Fatal("Invalid GAS_STRATEGY: ", err)
+	}
+
+	pageDefault, pageMax := getInt("PAGINATION_DEFAULT_LIMIT", 20), getInt("PAGINATION_MAX_LIMIT", 100)
+	if pageDefault < 1 || pageMax < pageDefault {
+		log.Fatalf("Invalid pagination limits: PAGINATION_MAX_LIMIT (%d) must be at least PAGINATION_DEFAULT_LIMIT (%d), which must be at least 1", pageMax, pageDefault)
+	}
+
+	indexerStartBlock := uint64(max(getInt("INDEXER_START_BLOCK", 0), 0))
+
 	return &Config{
-		DBHost:          os.Getenv("DB_HOST"),
-		DBName:          os.Getenv("DB_NAME"),
-		DBPort:          os.Getenv("DB_PORT"),
-		DBUser:          os.Getenv("DB_USER"),
-		DBPass:          os.Getenv("DB_PASSWORD"),
+		DBHost: os.Getenv("DB_HOST"),
+		DBName: os.Getenv("DB_NAME"),
+		DBPort: os.Getenv("DB_PORT"),
+		DBUser: os.Getenv("DB_USER"),
+		DBPass: os.Getenv("DB_PASSWORD"),
+
+		DBStatementTimeout: getDuration("DB_STATEMENT_TIMEOUT", 30*time.Second),
+
 		ServerAddress:   os.Getenv("SERVER_ADDRESS"),
+		ShutdownTimeout: getDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
 		BlockChainRPC:   os.Getenv("BLOCKCHAIN_RPC"),
+		RPCDialTimeout:  getDuration("RPC_DIAL_TIMEOUT", 10*time.Second),
 		PrivateKey:      os.Getenv("PRIVATE_KEY"),
 		MarketplaceABI:  os.Getenv("MARKETPLACE_ABI"),
 		ContractAddress: os.Getenv("CONTRACT_ADDRESS"),
 		IPFSNodeAddress: os.Getenv("IPFS_NODE_ADDRESS"),
 		TokenLifespan:   os.Getenv("TOKEN_HOUR_LIFESPAN"),
 		APISecret:       os.Getenv("API_SECRET"),
+		DegradedReads:   os.Getenv("DEGRADED_READS") == "true",
+		VerifyABI:       os.Getenv("VERIFY_ABI") == "true",
+
+		IPFSPinConcurrency: getInt("IPFS_PIN_CONCURRENCY", 4),
+		IPFSPinQueueSize:   getInt("IPFS_PIN_QUEUE_SIZE", 100),
+		IPFSPinAttempts:    getInt("IPFS_PIN_ATTEMPTS", 5),
+
+		SelfTest:        os.Getenv("SELF_TEST") == "true",
+		SelfTestTokenID: getBigInt("SELF_TEST_TOKEN_ID"),
+		SelfTestPrice:   getBigInt("SELF_TEST_PRICE_WEI"),
+		SelfTestTimeout: getDuration("SELF_TEST_TIMEOUT", 5*time.Minute),
+
+		RequestTimeout: getDuration("REQUEST_TIMEOUT", 90*time.Second),
+
+		MaxConcurrentRequests:   getInt("MAX_CONCURRENT_REQUESTS", 0),
+		ConcurrencyLimitMode:    concurrencyMode,
+		ConcurrencyQueueTimeout: getDuration("CONCURRENCY_QUEUE_TIMEOUT", 5*time.Second),
+
+		PaginationDefaultLimit: pageDefault,
+		PaginationMaxLimit:     pageMax,
+		WeiJSONNumbers:         os.Getenv("WEI_JSON_NUMBERS") == "true",
+
+		QueryCacheTTL:  getDuration("QUERY_CACHE_TTL", 10*time.Second),
+		QueryCacheSize: getInt("QUERY_CACHE_SIZE", 1000),
+
+		RPCBreakerThreshold: getInt("RPC_BREAKER_THRESHOLD", 5),
+		RPCBreakerCooldown:  getDuration("RPC_BREAKER_COOLDOWN", 30*time.Second),
+
+		ReconcileInterval:  getDuration("RECONCILE_INTERVAL", 0),
+		ReconcileBatchSize: getInt("RECONCILE_BATCH_SIZE", 100),
+		ReconcileRPS:       getInt("RECONCILE_RPS", 5),
+
+		StaleListingInterval: getDuration("STALE_LISTING_INTERVAL", 0),
+		StaleListingAge:      getDuration("STALE_LISTING_AGE", 24*time.Hour),
+
+		ViewDebounce:        getDuration("VIEW_DEBOUNCE", 30*time.Minute),
+		ViewCleanupInterval: getDuration("VIEW_CLEANUP_INTERVAL", time.Hour),
+
+		RevocationCleanupInterval: getDuration("REVOCATION_CLEANUP_INTERVAL", time.Hour),
+
+		LoginRateLimit:  getInt("LOGIN_RATE_LIMIT", 10),
+		LoginRateWindow: getDuration("LOGIN_RATE_WINDOW", time.Minute),
+		RelayRateLimit:  getInt("RELAY_RATE_LIMIT", 20),
+		RelayRateWindow: getDuration("RELAY_RATE_WINDOW", time.Hour),
+		TrustedProxies:  getList("TRUSTED_PROXIES", nil),
+
+		AdminToken:    os.Getenv("ADMIN_TOKEN"),
+		MintingPaused: os.Getenv("MINTING_PAUSED") == "true",
+
+		MintAllowlistEnabled: os.Getenv("MINT_ALLOWLIST_ENABLED") == "true",
+
+		MintDailyQuota: getInt("MINT_DAILY_QUOTA", 0),
+
+		FeatureFlags:        getList("FEATURE_FLAGS", nil),
+		FeatureFlagCacheTTL: getDuration("FEATURE_FLAG_CACHE_TTL", 10*time.Second),
+
+		MaxGasPrice:        getBigInt("MAX_GAS_PRICE"),
+		ResubmitInterval:   getDuration("TX_RESUBMIT_INTERVAL", time.Minute),
+		MintConfirmTimeout: getDuration("MINT_CONFIRM_TIMEOUT", time.Minute),
+		MintConfirmations:  getInt("MINT_CONFIRMATIONS", 0),
+		VerifyPurchases:    os.Getenv("VERIFY_PURCHASES") == "true",
+
+		GasStrategy:   gasStrategy,
+		DurableNonces: os.Getenv("DURABLE_NONCES") == "true",
+
+		RecipientBlocklist: getList("RECIPIENT_BLOCKLIST", []string{"0x000000000000000000000000000000000000dEaD"}),
+		TreasuryAddress:    os.Getenv("TREASURY_ADDRESS"),
+		MinListingPrice:    getBigInt("MIN_LISTING_PRICE_WEI"),
+		MaxListingPrice:    getBigInt("MAX_LISTING_PRICE_WEI"),
+
+		LogScanChunkSize:    getInt("LOG_SCAN_CHUNK_SIZE", 5000),
+		ContractDeployBlock: uint64(max(getInt("CONTRACT_DEPLOY_BLOCK", int(indexerStartBlock)), 0)),
+
+		Collections: getList("COLLECTIONS", nil),
+
+		IndexerRPC:        os.Getenv("INDEXER_RPC"),
+		IndexerStartBlock: indexerStartBlock,
+
+		IndexerMaxReorgDepth: getInt("INDEXER_MAX_REORG_DEPTH", 64),
+
+		RPCConcurrency: getInt("RPC_CONCURRENCY", 8),
+
+		CORSAllowedOrigins: getList("CORS_ALLOWED_ORIGINS", nil),
+		FrameOptions:       getString("FRAME_OPTIONS", "DENY"),
+		ReferrerPolicy:     getString("REFERRER_POLICY", "no-referrer"),
+		HSTSMaxAge:         getDuration("HSTS_MAX_AGE", 365*24*time.Hour),
+
+		LogLevel: os.Getenv("LOG_LEVEL"),
+
+		LogBodies:    os.Getenv("LOG_BODIES") == "true",
+		LogBodyLimit: getInt("LOG_BODY_LIMIT", 2048),
+	}
+}
+
+// getString reads a string environment variable, returning def when it is
+// unset and an empty string when it is "none".
+func getString(key, def string) string {
+	switch value := os.Getenv(key); value {
+	case "":
+		return def
+	case "none":
+		return ""
+	default:
+		return value
+	}
+}
+
+// getInt reads an integer environment variable, returning def when it is unset
+// or malformed.
+func getInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using default %d", key, value, def)
+		return def
+	}
+
+	return n
+}
+
+// getDuration reads a duration environment variable such as "30s" or "5m",
+// returning def when it is unset or malformed.
+func getDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
 	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using default %s", key, value, def)
+		return def
+	}
+
+	return d
+}
+
+// getBigInt reads an integer environment variable of arbitrary size, returning
+// nil when it is unset or malformed.
+func getBigInt(key string) *big.Int {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+
+	n, ok := new(big.Int).SetString(value, 10)
+	if !ok {
+		log.Printf("Invalid %s %q, ignoring it", key, value)
+		return nil
+	}
+
+	return n
+}
+
+// getList reads a comma separated environment variable, returning def when it
+// is unset and an empty list when it is "none".
+func getList(key string, def []string) []string {
+	value := os.Getenv(key)
+	switch value {
+	case "":
+		return def
+	case "none":
+		return nil
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
 }
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// GasStrategy selects how aggressively transactions are priced.
+type GasStrategy string
+
+// Gas strategies, from cheapest to fastest to be mined.
+const (
+	GasEconomy  GasStrategy = "economy"
+	GasStandard GasStrategy = "standard"
+	GasFast     GasStrategy = "fast"
+)
+
+// ErrUnknownGasStrategy is returned by ParseGasStrategy for unknown values.
+var ErrUnknownGasStrategy = errors.New("unknown gas strategy")
+
+// ParseGasStrategy parses a gas strategy name, case-insensitively. An empty
+// name is the standard strategy.
+func ParseGasStrategy(s string) (GasStrategy, error) {
+	switch strategy := GasStrategy(strings.ToLower(strings.TrimSpace(s))); strategy {
+	case "":
+		return GasStandard, nil
+	case GasEconomy, GasStandard, GasFast:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("%w %q, expected economy, standard or fast", ErrUnknownGasStrategy, s)
+	}
+}
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseGasStrategy(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    GasStrategy
+		wantErr bool
+	}{
+		{name: "economy", value: "economy", want: GasEconomy},
+		{name: "fast", value: "fast", want: GasFast},
+		{name: "case and spaces", value: " Standard ", want: GasStandard},
+		{name: "empty", value: "", want: GasStandard},
+		{name: "unknown", value: "urgent", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseGasStrategy(tt.value)
+			if errors.Is(err, ErrUnknownGasStrategy) != tt.wantErr {
+				t.Fatalf("ParseGasStrategy(%q) error = %v, want error: %t", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("ParseGasStrategy(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// MintAllowlistEntry is an address allowed to mint while the mint allowlist
+// is enabled.
+type MintAllowlistEntry struct {
+	Address   string    `gorm:"primaryKey; size:42" json:"address"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName keeps the table name singular, as created by its migration.
+func (MintAllowlistEntry) TableName() string {
+	return "mint_allowlist"
+}
+
+// GetMintAllowlist returns the allowlisted addresses, oldest first.
+func GetMintAllowlist() ([]MintAllowlistEntry, error) {
+	db, err := connection()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []MintAllowlistEntry
+	if err := db.Order("created_at, address").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// AddToMintAllowlist allowlists address. Adding an allowlisted address again
+// does nothing.
+func AddToMintAllowlist(address string) error {
+	db, err := connection()
+	if err != nil {
+		return err
+	}
+
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&MintAllowlistEntry{Address: address}).Error
+}
+
+// RemoveFromMintAllowlist removes address from the allowlist. The boolean is
+// false when it was not allowlisted.
+func RemoveFromMintAllowlist(address string) (bool, error) {
+	db, err := connection()
+	if err != nil {
+		return false, err
+	}
+
+	result := db.Where("address = ?", address).Delete(&MintAllowlistEntry{})
+	return result.RowsAffected > 0, result.Error
+}
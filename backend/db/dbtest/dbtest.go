@@ -0,0 +1,49 @@
+// Package dbtest sets up the db package for tests against a mocked database,
+// so that the SQL a test expects can be checked without a Postgres server.
+package dbtest
+
+import (
+	"nft-marketplace/db"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Mock makes the db package functions use a mocked Postgres connection for
+// the rest of the test, and returns the mock to set expectations on. The test
+// fails if expectations are left unmet.
+func Mock(t testing.TB) sqlmock.Sqlmock {
+	t.Helper()
+
+	conn, mock := Open(t)
+	db.SetDefault(conn)
+	t.Cleanup(func() { db.SetDefault(nil) })
+	return mock
+}
+
+// Open returns a mocked Postgres connection for code taking a *gorm.DB, and
+// the mock to set expectations on. The test fails if expectations are left
+// unmet.
+func Open(t testing.TB) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create database mock: %v", err)
+	}
+	conn, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open mocked database: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet database expectations: %v", err)
+		}
+		sqlDB.Close()
+	})
+	return conn, mock
+}
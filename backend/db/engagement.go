@@ -0,0 +1,126 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TokenStats is the popularity of a token: how many times it was viewed and
+// how many users favorited it.
+type TokenStats struct {
+	Views     int64 `json:"views"`
+	Favorites int64 `json:"favorites"`
+}
+
+// TokenView is the last counted view of TokenID by Viewer, a user or client
+// IP, which later views are debounced against.
+type TokenView struct {
+	Viewer   string    `gorm:"primaryKey; size:64" json:"viewer"`
+	TokenID  string    `gorm:"primaryKey; size:78" json:"token_id"`
+	ViewedAt time.Time `gorm:"not null; index" json:"viewed_at"`
+}
+
+// TokenFavorite records that the user with UserID favorited TokenID.
+type TokenFavorite struct {
+	UserID    uint      `gorm:"primaryKey" json:"user_id"`
+	TokenID   string    `gorm:"primaryKey; size:78" json:"token_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// IncrementViews counts a view of tokenID by viewer at now, unless viewer's
+// last counted view of it is after since. It reports whether the view was
+// counted.
+func IncrementViews(tokenID, viewer string, now, since time.Time) (bool, error) {
+	db, err := connection()
+	if err != nil {
+		return false, err
+	}
+
+	counted := false
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Concurrent views by the same viewer wait on the row lock, so only
+		// one of them passes the WHERE clause.
+		result := tx.Exec(`INSERT INTO token_views (viewer, token_id, viewed_at) VALUES (?, ?, ?)
+			ON CONFLICT (viewer, token_id) DO UPDATE
+			SET viewed_at = EXCLUDED.viewed_at
+			WHERE token_views.viewed_at <= ?`, viewer, tokenID, now, since)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		counted = true
+		return tx.Exec(`INSERT INTO token_stats (token_id, views) VALUES (?, 1)
+			ON CONFLICT (token_id) DO UPDATE
+			SET views = token_stats.views + 1`, tokenID).Error
+	})
+	if err != nil {
+		return false, err
+	}
+	return counted, nil
+}
+
+// DeleteViewsBefore removes the views made before cutoff, which no longer
+// debounce anything. It returns how many were removed.
+func DeleteViewsBefore(cutoff time.Time) (int64, error) {
+	db, err := connection()
+	if err != nil {
+		return 0, err
+	}
+
+	result := db.Where("viewed_at < ?", cutoff).Delete(&TokenView{})
+	return result.RowsAffected, result.Error
+}
+
+// ToggleFavorite favorites tokenID for the user with userID, or removes the
+// favorite if the user already had it. It reports whether the token is now
+// favorited.
+func ToggleFavorite(userID uint, tokenID string) (bool, error) {
+	db, err := connection()
+	if err != nil {
+		return false, err
+	}
+
+	favorited := false
+	err = db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ? AND token_id = ?", userID, tokenID).Delete(&TokenFavorite{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			return nil
+		}
+
+		// A concurrent toggle may have just added it, which leaves it
+		// favorited all the same.
+		favorited = true
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&TokenFavorite{UserID: userID, TokenID: tokenID}).Error
+	})
+	if err != nil {
+		return false, err
+	}
+	return favorited, nil
+}
+
+// GetTokenStats returns the views and favorites of tokenID, zero for tokens
+// nobody viewed or favorited.
+func GetTokenStats(tokenID string) (TokenStats, error) {
+	db, err := connection()
+	if err != nil {
+		return TokenStats{}, err
+	}
+
+	var stats TokenStats
+	if err := db.Raw("SELECT views FROM token_stats WHERE token_id = ?", tokenID).Scan(&stats.Views).Error; err != nil {
+		return TokenStats{}, err
+	}
+	if err := db.Model(&TokenFavorite{}).Where("token_id = ?", tokenID).Count(&stats.Favorites).Error; err != nil {
+		return TokenStats{}, err
+	}
+	return stats, nil
+}
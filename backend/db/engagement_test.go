@@ -0,0 +1,91 @@
+package db_test
+
+import (
+	"nft-marketplace/db"
+	"nft-marketplace/db/dbtest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestIncrementViews(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	since := now.Add(-30 * time.Minute)
+
+	tests := []struct {
+		name string
+		// lastViewAfterSince is whether the viewer's last counted view is
+		// recent enough to debounce this one.
+		lastViewAfterSince bool
+		wantCounted        bool
+	}{
+		{name: "first view counted", wantCounted: true},
+		{name: "repeated view debounced", lastViewAfterSince: true, wantCounted: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := dbtest.Mock(t)
+			mock.ExpectBegin()
+			affected := int64(1)
+			if tt.lastViewAfterSince {
+				affected = 0
+			}
+			mock.ExpectExec(`INSERT INTO token_views .* WHERE token_views.viewed_at <= \$4`).
+				WithArgs("ip:198.51.100.7", "42", now, since).
+				WillReturnResult(sqlmock.NewResult(0, affected))
+			if tt.wantCounted {
+				mock.ExpectExec(`INSERT INTO token_stats \(token_id, views\) VALUES \(\$1, 1\)`).
+					WithArgs("42").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+			mock.ExpectCommit()
+
+			counted, err := db.IncrementViews("42", "ip:198.51.100.7", now, since)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if counted != tt.wantCounted {
+				t.Fatalf("counted = %t, want %t", counted, tt.wantCounted)
+			}
+		})
+	}
+}
+
+func TestToggleFavorite(t *testing.T) {
+	tests := []struct {
+		name          string
+		wasFavorite   bool
+		wantFavorited bool
+	}{
+		{name: "favorite", wasFavorite: false, wantFavorited: true},
+		{name: "unfavorite", wasFavorite: true, wantFavorited: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := dbtest.Mock(t)
+			mock.ExpectBegin()
+			deleted := int64(0)
+			if tt.wasFavorite {
+				deleted = 1
+			}
+			mock.ExpectExec(`DELETE FROM "token_favorites" WHERE user_id = \$1 AND token_id = \$2`).
+				WithArgs(7, "42").
+				WillReturnResult(sqlmock.NewResult(0, deleted))
+			if tt.wantFavorited {
+				mock.ExpectExec(`INSERT INTO "token_favorites" .* ON CONFLICT DO NOTHING`).
+					WithArgs(7, "42", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+			mock.ExpectCommit()
+
+			favorited, err := db.ToggleFavorite(7, "42")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if favorited != tt.wantFavorited {
+				t.Fatalf("favorited = %t, want %t", favorited, tt.wantFavorited)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package db
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidCursor is returned by ParseEventCursor for malformed cursors.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Event is a decoded marketplace event stored by the indexer.
+//
+// ListingID, TokenID, Account and Value are set when the event carries them.
+// Account is the seller, buyer or withdrawal recipient; Value is the price of
+// listings and purchases, the amount of withdrawals and the new commission, in
+// basis points, of commission updates. BlockHash is empty for events stored
+// before block hashes were recorded.
+type Event struct {
+	ID          uint      `gorm:"primaryKey" json:"-"`
+	Type        string    `gorm:"size:32; not null; index" json:"type"`
+	BlockNumber uint64    `gorm:"not null; uniqueIndex:idx_events_position,priority:1" json:"block_number"`
+	LogIndex    uint      `gorm:"not null; uniqueIndex:idx_events_position,priority:2" json:"log_index"`
+	BlockHash   string    `gorm:"size:66; not null; default:''" json:"block_hash"`
+	TxHash      string    `gorm:"size:66; not null" json:"tx_hash"`
+	ListingID   string    `gorm:"size:78" json:"listing_id,omitempty"`
+	TokenID     string    `gorm:"size:78" json:"token_id,omitempty"`
+	Account     string    `gorm:"size:42" json:"account,omitempty"`
+	Value       *string   `gorm:"type:numeric(78,0)" json:"value,omitempty"`
+	CreatedAt   time.Time `json:"-"`
+}
+
+// EventCursor is the position of an event in the chain, used to resume
+// listing events after it.
+type EventCursor struct {
+	BlockNumber uint64
+	LogIndex    uint
+}
+
+// String encodes the cursor as an opaque URL-safe token.
+func (c EventCursor) String() string {
+	raw := strconv.FormatUint(c.BlockNumber, 10) + ":" + strconv.FormatUint(uint64(c.LogIndex), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseEventCursor decodes a cursor produced by EventCursor.String.
+func ParseEventCursor(s string) (EventCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return EventCursor{}, ErrInvalidCursor
+	}
+
+	block, index, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return EventCursor{}, ErrInvalidCursor
+	}
+	blockNumber, err := strconv.ParseUint(block, 10, 64)
+	if err != nil {
+		return EventCursor{}, ErrInvalidCursor
+	}
+	logIndex, err := strconv.ParseUint(index, 10, 32)
+	if err != nil {
+		return EventCursor{}, ErrInvalidCursor
+	}
+
+	return EventCursor{BlockNumber: blockNumber, LogIndex: uint(logIndex)}, nil
+}
+
+// Cursor returns the position of the event.
+func (e Event) Cursor() EventCursor {
+	return EventCursor{BlockNumber: e.BlockNumber, LogIndex: e.LogIndex}
+}
+
+// EventFilter selects the events returned by GetEvents.
+type EventFilter struct {
+	// Types restricts the events to the given types. Empty means any type.
+	Types []string
+	// FromBlock and ToBlock bound the block number, inclusively. Nil means
+	// unbounded.
+	FromBlock *uint64
+	ToBlock   *uint64
+	// After skips every event up to and including this position.
+	After *EventCursor
+	Limit int
+}
+
+// SaveEvents stores events, ignoring those already stored at the same
+// position.
+func SaveEvents(events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	db, err := connection()
+	if err != nil {
+		return err
+	}
+
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&events).Error
+}
+
+// DeleteEvent removes the event at the given position of block blockHash,
+// after a reorg dropped it from the chain. An event stored at that position
+// from another block is kept.
+func DeleteEvent(blockNumber uint64, logIndex uint, blockHash string) error {
+	db, err := connection()
+	if err != nil {
+		return err
+	}
+
+	return db.Where("block_number = ? AND log_index = ? AND block_hash IN (?, '')", blockNumber, logIndex, blockHash).Delete(&Event{}).Error
+}
+
+// EventBlock is a block events were stored from.
+type EventBlock struct {
+	Number uint64
+	Hash   string
+}
+
+// GetEventBlocks returns up to limit of the latest blocks below before that
+// events were stored from, newest first. Blocks stored without a hash are left
+// out.
+func GetEventBlocks(before uint64, limit int) ([]EventBlock, error) {
+	blocks := make([]EventBlock, 0)
+
+	db, err := connection()
+	if err != nil {
+		return blocks, err
+	}
+
+	err = db.Model(&Event{}).
+		Distinct("block_number AS number", "block_hash AS hash").
+		Where("block_number < ? AND block_hash <> ''", before).
+		Order("number DESC").
+		Limit(limit).
+		Scan(&blocks).Error
+	return blocks, err
+}
+
+// DeleteEventsAfter removes every event stored from a block above block, to
+// roll the store back to it after a reorg.
+func DeleteEventsAfter(block uint64) error {
+	db, err := connection()
+	if err != nil {
+		return err
+	}
+
+	return db.Where("block_number > ?", block).Delete(&Event{}).Error
+}
+
+// GetListingSeller returns the seller of the listing with the given ID, as
+// stored from its ListingCreated event. found is false when that event is not
+// stored.
+func GetListingSeller(listingID string) (seller string, found bool, err error) {
+	db, err := connection()
+	if err != nil {
+		return "", false, err
+	}
+
+	var event Event
+	err = db.Where("type = ? AND listing_id = ?", "ListingCreated", listingID).
+		Order("block_number DESC, log_index DESC").
+		Take(&event).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	return event.Account, true, nil
+}
+
+// GetLatestEventBlock returns the block number of the last stored event. found
+// is false when no event is stored yet.
+func GetLatestEventBlock() (block uint64, found bool, err error) {
+	db, err := connection()
+	if err != nil {
+		return 0, false, err
+	}
+
+	var event Event
+	err = db.Order("block_number DESC, log_index DESC").Take(&event).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	return event.BlockNumber, true, nil
+}
+
+// GetEvents returns up to filter.Limit events matching filter, in chain order.
+func GetEvents(filter EventFilter) ([]Event, error) {
+	events := make([]Event, 0)
+
+	db, err := connection()
+	if err != nil {
+		return events, err
+	}
+
+	query := db.Model(&Event{})
+	if len(filter.Types) > 0 {
+		query = query.Where("type IN ?", filter.Types)
+	}
+	if filter.FromBlock != nil {
+		query = query.Where("block_number >= ?", *filter.FromBlock)
+	}
+	if filter.ToBlock != nil {
+		query = query.Where("block_number <= ?", *filter.ToBlock)
+	}
+	if filter.After != nil {
+		query = query.Where("(block_number, log_index) > (?, ?)", filter.After.BlockNumber, filter.After.LogIndex)
+	}
+
+	err = query.Order("block_number ASC, log_index ASC").Limit(filter.Limit).Find(&events).Error
+	if err != nil {
+		return events, err
+	}
+
+	return events, nil
+}
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// SellerHistoryRow is a line of a seller's history: one of the NFTs they
+// listed, or one of their sales. SoldTo and SoldAt are only set for sales;
+// Created is the time the NFT was minted through the marketplace, nil when it
+// is not in the nfts table.
+type SellerHistoryRow struct {
+	TokenID string
+	Price   string
+	Status  string
+	Created *time.Time
+	SoldTo  string
+	SoldAt  *time.Time
+}
+
+// Statuses of the rows of a seller's history.
+const (
+	HistoryStatusListed   = "listed"
+	HistoryStatusUnlisted = "unlisted"
+	HistoryStatusSold     = "sold"
+)
+
+// sellerHistoryQuery lists the seller's NFTs and then their sales, each
+// oldest first. Addresses are compared case-insensitively.
+const sellerHistoryQuery = `SELECT token_id, price, CASE WHEN is_active THEN '` + HistoryStatusListed + `' ELSE '` + HistoryStatusUnlisted + `' END AS status,
+		created_at AS created, '' AS sold_to, NULL::timestamptz AS sold_at, 0 AS part
+	FROM nfts WHERE LOWER(seller) = LOWER(?)
+	UNION ALL
+	SELECT s.token_id, s.price::text, '` + HistoryStatusSold + `', n.created_at, s.buyer, s.sold_at, 1
+	FROM sales s LEFT JOIN nfts n ON n.token_id = s.token_id AND s.token_id <> ''
+	WHERE LOWER(s.seller) = LOWER(?)
+	ORDER BY part, created, sold_at, token_id`
+
+// StreamSellerHistory calls fn with every row of the history of seller, as
+// they are read from the database, so that long histories are never held in
+// memory. It stops at the first error fn returns and returns it.
+func StreamSellerHistory(seller string, fn func(SellerHistoryRow) error) error {
+	db, err := connection()
+	if err != nil {
+		return err
+	}
+
+	rows, err := db.Raw(sellerHistoryQuery, seller, seller).Rows()
+	if err != nil {
+		return queryError(db, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			row     SellerHistoryRow
+			created sql.NullTime
+			soldAt  sql.NullTime
+			part    int
+		)
+		if err := rows.Scan(&row.TokenID, &row.Price, &row.Status, &created, &row.SoldTo, &soldAt, &part); err != nil {
+			return err
+		}
+		if created.Valid {
+			row.Created = &created.Time
+		}
+		if soldAt.Valid {
+			row.SoldAt = &soldAt.Time
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return queryError(db, rows.Err())
+}
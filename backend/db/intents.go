@@ -0,0 +1,50 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// ErrIntentUsed is returned by UseListingIntent for intents relayed before.
+var ErrIntentUsed = errors.New("listing intent already used")
+
+// ListingIntent records a signed listing intent relayed on a user's behalf,
+// so that its signature cannot be replayed.
+type ListingIntent struct {
+	Digest    string    `gorm:"primaryKey; size:66" json:"digest"`
+	Signer    string    `gorm:"size:42; not null" json:"signer"`
+	TokenID   string    `gorm:"size:78; not null" json:"token_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UseListingIntent records the intent with the given EIP-712 digest as used.
+// It returns ErrIntentUsed when it already was.
+func UseListingIntent(digest, signer, tokenID string) error {
+	db, err := connection()
+	if err != nil {
+		return err
+	}
+
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&ListingIntent{Digest: digest, Signer: signer, TokenID: tokenID})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrIntentUsed
+	}
+	return nil
+}
+
+// ReleaseListingIntent forgets a used intent, after relaying it failed before
+// anything was sent.
+func ReleaseListingIntent(digest string) error {
+	db, err := connection()
+	if err != nil {
+		return err
+	}
+
+	return db.Where("digest = ?", digest).Delete(&ListingIntent{}).Error
+}
@@ -0,0 +1,262 @@
+package migrate
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Migration is a versioned schema change read from a pair of
+// {version}_{name}.up.sql and {version}_{name}.down.sql files, the layout
+// golang-migrate uses.
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      string
+	Down    string
+
+	hasUp, hasDown bool
+}
+
+// createTable creates the version table with golang-migrate's layout. The
+// dirty flag is always false: each migration runs in a transaction together
+// with the version update, so a failing one cannot leave the schema half
+// migrated.
+const createTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version BIGINT NOT NULL PRIMARY KEY,
+	dirty BOOLEAN NOT NULL
+)`
+
+// Migrate applies every migration of dir that is newer than the schema's
+// current version. It is what the services call at startup.
+//
+// Databases whose tables were created before migrations were tracked, by
+// gorm's AutoMigrate, must first be marked as migrated up to the last
+// migration they already have with the "force" command of Run.
+func Migrate(db *gorm.DB, dir fs.FS) error {
+	return Up(db, dir)
+}
+
+// Up applies, oldest first, every migration of dir newer than the current
+// version. Each migration runs in its own transaction together with the
+// version update, so a failing migration leaves the schema at the previous
+// version.
+func Up(db *gorm.DB, dir fs.FS) error {
+	migrations, err := Load(dir)
+	if err != nil {
+		return err
+	}
+
+	current, err := Version(db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+
+		if err := apply(db, m.Up, m.Version); err != nil {
+			return fmt.Errorf("migration %d_%s up failed: %w", m.Version, m.Name, err)
+		}
+		log.Printf("Applied migration %d_%s", m.Version, m.Name)
+	}
+
+	return nil
+}
+
+// Down reverts the steps most recent applied migrations, or all of them when
+// steps is not positive.
+func Down(db *gorm.DB, dir fs.FS, steps int) error {
+	migrations, err := Load(dir)
+	if err != nil {
+		return err
+	}
+
+	current, err := Version(db)
+	if err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.Version > current {
+			continue
+		}
+		previous := uint64(0)
+		if i > 0 {
+			previous = migrations[i-1].Version
+		}
+		if err := apply(db, m.Down, previous); err != nil {
+			return fmt.Errorf("migration %d_%s down failed: %w", m.Version, m.Name, err)
+		}
+		log.Printf("Reverted migration %d_%s", m.Version, m.Name)
+
+		if steps--; steps == 0 {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Version returns the version the schema is migrated to, zero when no
+// migration was ever applied.
+func Version(db *gorm.DB) (uint64, error) {
+	if err := db.Exec(createTable).Error; err != nil {
+		return 0, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var versions []uint64
+	if err := db.Raw("SELECT version FROM schema_migrations LIMIT 1").Scan(&versions).Error; err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if len(versions) == 0 {
+		return 0, nil
+	}
+
+	return versions[0], nil
+}
+
+// Force records version as the schema's current one without running any
+// migration, for databases whose schema was set up by other means. Version
+// must be one of dir's migrations.
+func Force(db *gorm.DB, dir fs.FS, version uint64) error {
+	migrations, err := Load(dir)
+	if err != nil {
+		return err
+	}
+	i := sort.Search(len(migrations), func(i int) bool { return migrations[i].Version >= version })
+	if i == len(migrations) || migrations[i].Version != version {
+		return fmt.Errorf("no migration with version %d", version)
+	}
+
+	if err := db.Exec(createTable).Error; err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	if err := apply(db, "", version); err != nil {
+		return fmt.Errorf("failed to force version %d: %w", version, err)
+	}
+	log.Printf("Forced schema version to %d_%s", version, migrations[i].Name)
+	return nil
+}
+
+// apply runs script and records version as the current one in a single
+// transaction. Version zero clears the record. Migrations are exempt from the
+// connection's statement timeout, since building an index on a large table
+// may legitimately take longer than any query.
+func apply(db *gorm.DB, script string, version uint64) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SET LOCAL statement_timeout = 0").Error; err != nil {
+			return err
+		}
+		if strings.TrimSpace(script) != "" {
+			if err := tx.Exec(script).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Exec("DELETE FROM schema_migrations").Error; err != nil {
+			return err
+		}
+		if version == 0 {
+			return nil
+		}
+		return tx.Exec("INSERT INTO schema_migrations (version, dirty) VALUES (?, ?)", version, false).Error
+	})
+}
+
+// Load reads the migrations of dir, ordered by version. Every version must
+// have both an up and a down file.
+func Load(dir fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(dir, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[uint64]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || path.Ext(name) != ".sql" {
+			continue
+		}
+
+		base := strings.TrimSuffix(name, ".sql")
+		direction := path.Ext(base)
+		base = strings.TrimSuffix(base, direction)
+		versionStr, title, ok := strings.Cut(base, "_")
+		version, err := strconv.ParseUint(versionStr, 10, 64)
+		if !ok || err != nil || version == 0 || (direction != ".up" && direction != ".down") {
+			return nil, fmt.Errorf("invalid migration file name: %s", name)
+		}
+
+		content, err := fs.ReadFile(dir, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", name, err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: title}
+			byVersion[version] = m
+		}
+		if direction == ".up" {
+			m.Up, m.hasUp = string(content), true
+		} else {
+			m.Down, m.hasDown = string(content), true
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if !m.hasUp || !m.hasDown {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Run executes a migrate command line: "up" applies every pending migration,
+// "down" reverts all of them and "down N" the N most recent, and "force V"
+// marks the schema as migrated up to version V, see Force.
+func Run(db *gorm.DB, dir fs.FS, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up | down [N] | force VERSION")
+	}
+
+	switch args[0] {
+	case "up":
+		return Up(db, dir)
+	case "down":
+		steps := 0
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of migrations to revert: %s", args[1])
+			}
+			steps = n
+		}
+		return Down(db, dir, steps)
+	case "force":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: migrate force VERSION")
+		}
+		version, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version: %s", args[1])
+		}
+		return Force(db, dir, version)
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or force", args[0])
+	}
+}
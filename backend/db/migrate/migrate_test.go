@@ -0,0 +1,167 @@
+package migrate
+
+import (
+	"errors"
+	"nft-marketplace/db/dbtest"
+	"nft-marketplace/db/migrations"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// testMigrations are two migrations on a fresh schema.
+var testMigrations = fstest.MapFS{
+	"1_users.up.sql":   {Data: []byte("CREATE TABLE users (id INT)")},
+	"1_users.down.sql": {Data: []byte("DROP TABLE users")},
+	"2_nfts.up.sql":    {Data: []byte("CREATE TABLE nfts (id INT)")},
+	"2_nfts.down.sql":  {Data: []byte("DROP TABLE nfts")},
+}
+
+// expectVersion expects the schema version to be read as version, zero for
+// none.
+func expectVersion(mock sqlmock.Sqlmock, version uint64) {
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	rows := sqlmock.NewRows([]string{"version"})
+	if version > 0 {
+		rows.AddRow(version)
+	}
+	mock.ExpectQuery(`SELECT version FROM schema_migrations`).WillReturnRows(rows)
+}
+
+// expectApply expects script to be run and version recorded in one
+// transaction, which fails with err when it is not nil.
+func expectApply(mock sqlmock.Sqlmock, script string, version uint64, err error) {
+	mock.ExpectBegin()
+	mock.ExpectExec(`SET LOCAL statement_timeout = 0`).WillReturnResult(sqlmock.NewResult(0, 0))
+	if script != "" {
+		run := mock.ExpectExec(script)
+		if err != nil {
+			run.WillReturnError(err)
+			mock.ExpectRollback()
+			return
+		}
+		run.WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec(`DELETE FROM schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 1))
+	if version > 0 {
+		mock.ExpectExec(`INSERT INTO schema_migrations \(version, dirty\) VALUES \(\$1, \$2\)`).
+			WithArgs(version, false).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+}
+
+func TestRun(t *testing.T) {
+	errSyntax := errors.New("syntax error")
+
+	tests := []struct {
+		name    string
+		args    []string
+		expect  func(mock sqlmock.Sqlmock)
+		wantErr string
+	}{
+		{
+			name: "up from scratch",
+			args: []string{"up"},
+			expect: func(mock sqlmock.Sqlmock) {
+				expectVersion(mock, 0)
+				expectApply(mock, "CREATE TABLE users", 1, nil)
+				expectApply(mock, "CREATE TABLE nfts", 2, nil)
+			},
+		},
+		{
+			name: "up applies only pending",
+			args: []string{"up"},
+			expect: func(mock sqlmock.Sqlmock) {
+				expectVersion(mock, 1)
+				expectApply(mock, "CREATE TABLE nfts", 2, nil)
+			},
+		},
+		{
+			name: "failing migration stops",
+			args: []string{"up"},
+			expect: func(mock sqlmock.Sqlmock) {
+				expectVersion(mock, 0)
+				expectApply(mock, "CREATE TABLE users", 1, errSyntax)
+			},
+			wantErr: "migration 1_users up failed",
+		},
+		{
+			name: "down one step",
+			args: []string{"down", "1"},
+			expect: func(mock sqlmock.Sqlmock) {
+				expectVersion(mock, 2)
+				expectApply(mock, "DROP TABLE nfts", 1, nil)
+			},
+		},
+		{
+			name: "down all",
+			args: []string{"down"},
+			expect: func(mock sqlmock.Sqlmock) {
+				expectVersion(mock, 2)
+				expectApply(mock, "DROP TABLE nfts", 1, nil)
+				expectApply(mock, "DROP TABLE users", 0, nil)
+			},
+		},
+		{
+			// Tables made by AutoMigrate are adopted without running
+			// their migrations.
+			name: "force",
+			args: []string{"force", "1"},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+				expectApply(mock, "", 1, nil)
+			},
+		},
+		{name: "force unknown version", args: []string{"force", "3"}, wantErr: "no migration with version 3"},
+		{name: "invalid steps", args: []string{"down", "0"}, wantErr: "invalid number of migrations"},
+		{name: "unknown command", args: []string{"sideways"}, wantErr: "unknown migrate command"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, mock := dbtest.Open(t)
+			if tt.expect != nil {
+				tt.expect(mock)
+			}
+
+			err := Run(conn, testMigrations, tt.args)
+			if tt.wantErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("err = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		dir     fstest.MapFS
+		wantErr string
+	}{
+		{name: "valid", dir: testMigrations},
+		{name: "missing down file", dir: fstest.MapFS{"1_users.up.sql": {}}, wantErr: "needs both an up and a down file"},
+		{name: "invalid name", dir: fstest.MapFS{"users.up.sql": {}}, wantErr: "invalid migration file name"},
+		{name: "invalid direction", dir: fstest.MapFS{"1_users.sideways.sql": {}}, wantErr: "invalid migration file name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.dir)
+			if tt.wantErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("err = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestShippedMigrationsLoad(t *testing.T) {
+	if _, err := Load(migrations.FS); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,27 @@
+DROP TABLE IF EXISTS sales;
+DROP TABLE IF EXISTS settings;
+
+DROP INDEX IF EXISTS idx_nfts_seller;
+DROP INDEX IF EXISTS idx_nfts_token_id;
+DROP INDEX IF EXISTS idx_nfts_symbol;
+DROP INDEX IF EXISTS idx_nfts_name;
+-- Fails when a price does not fit the original DECIMAL(10, 2), as converting
+-- it would lose data.
+ALTER TABLE nfts
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS created_at,
+    DROP COLUMN IF EXISTS is_active,
+    DROP COLUMN IF EXISTS seller,
+    DROP COLUMN IF EXISTS listing_id,
+    DROP COLUMN IF EXISTS token_id,
+    ALTER COLUMN price TYPE DECIMAL(10, 2) USING price::NUMERIC,
+    ALTER COLUMN symbol TYPE VARCHAR(10);
+
+DROP INDEX IF EXISTS idx_users_deleted_at;
+DROP INDEX IF EXISTS idx_users_username;
+ALTER TABLE users
+    DROP COLUMN IF EXISTS wallet_address,
+    DROP COLUMN IF EXISTS deleted_at,
+    DROP COLUMN IF EXISTS updated_at,
+    ALTER COLUMN created_at TYPE TIMESTAMP,
+    ALTER COLUMN username TYPE VARCHAR(55);
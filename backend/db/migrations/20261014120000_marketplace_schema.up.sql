@@ -0,0 +1,40 @@
+ALTER TABLE users
+    ALTER COLUMN username TYPE VARCHAR(255),
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS wallet_address VARCHAR(42);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users (username);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+ALTER TABLE nfts
+    ALTER COLUMN symbol TYPE VARCHAR(255),
+    ALTER COLUMN price TYPE VARCHAR(255) USING price::TEXT,
+    ADD COLUMN IF NOT EXISTS token_id VARCHAR(78),
+    ADD COLUMN IF NOT EXISTS listing_id VARCHAR(78),
+    ADD COLUMN IF NOT EXISTS seller VARCHAR(42),
+    ADD COLUMN IF NOT EXISTS is_active BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_nfts_name ON nfts (name);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_nfts_symbol ON nfts (symbol);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_nfts_token_id ON nfts (token_id) WHERE token_id <> '';
+CREATE INDEX IF NOT EXISTS idx_nfts_seller ON nfts (seller);
+
+CREATE TABLE IF NOT EXISTS settings (
+    key VARCHAR(255) PRIMARY KEY,
+    value VARCHAR(255) NOT NULL,
+    updated_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS sales (
+    id BIGSERIAL PRIMARY KEY,
+    listing_id VARCHAR(78) NOT NULL,
+    token_id VARCHAR(78) NOT NULL,
+    buyer VARCHAR(42) NOT NULL,
+    price NUMERIC(78, 0) NOT NULL,
+    sold_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_sales_token_id ON sales (token_id);
+CREATE INDEX IF NOT EXISTS idx_sales_sold_at ON sales (sold_at);
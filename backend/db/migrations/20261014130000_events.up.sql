@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS events (
+    id BIGSERIAL PRIMARY KEY,
+    type VARCHAR(32) NOT NULL,
+    block_number BIGINT NOT NULL,
+    log_index INTEGER NOT NULL,
+    tx_hash VARCHAR(66) NOT NULL,
+    listing_id VARCHAR(78),
+    token_id VARCHAR(78),
+    account VARCHAR(42),
+    value NUMERIC(78, 0),
+    created_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_events_position ON events (block_number, log_index);
+CREATE INDEX IF NOT EXISTS idx_events_type ON events (type);
@@ -0,0 +1 @@
+ALTER TABLE events ADD COLUMN IF NOT EXISTS block_hash VARCHAR(66) NOT NULL DEFAULT '';
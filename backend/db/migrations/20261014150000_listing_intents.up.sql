@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS listing_intents (
+    digest VARCHAR(66) PRIMARY KEY,
+    signer VARCHAR(42) NOT NULL,
+    token_id VARCHAR(78) NOT NULL,
+    created_at TIMESTAMPTZ
+);
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS signer_nonces (
+    address VARCHAR(42) PRIMARY KEY,
+    next_nonce BIGINT NOT NULL,
+    updated_at TIMESTAMPTZ
+);
@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS mint_allowlist (
+    address VARCHAR(42) PRIMARY KEY,
+    created_at TIMESTAMPTZ
+);
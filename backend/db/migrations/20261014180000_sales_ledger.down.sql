@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_sales_seller;
+DROP INDEX IF EXISTS idx_sales_buyer;
+DROP INDEX IF EXISTS idx_sales_position;
+ALTER TABLE sales
+    DROP COLUMN IF EXISTS log_index,
+    DROP COLUMN IF EXISTS tx_hash,
+    DROP COLUMN IF EXISTS block_number,
+    DROP COLUMN IF EXISTS seller;
@@ -0,0 +1,8 @@
+ALTER TABLE sales
+    ADD COLUMN IF NOT EXISTS seller VARCHAR(42) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS block_number BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS tx_hash VARCHAR(66) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS log_index INTEGER NOT NULL DEFAULT 0;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sales_position ON sales (tx_hash, log_index) WHERE tx_hash <> '';
+CREATE INDEX IF NOT EXISTS idx_sales_buyer ON sales (buyer);
+CREATE INDEX IF NOT EXISTS idx_sales_seller ON sales (seller);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS token_favorites;
+DROP TABLE IF EXISTS token_views;
+DROP TABLE IF EXISTS token_stats;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS token_stats (
+    token_id VARCHAR(78) PRIMARY KEY,
+    views BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS token_views (
+    viewer VARCHAR(64) NOT NULL,
+    token_id VARCHAR(78) NOT NULL,
+    viewed_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (viewer, token_id)
+);
+CREATE INDEX IF NOT EXISTS idx_token_views_viewed_at ON token_views (viewed_at);
+
+CREATE TABLE IF NOT EXISTS token_favorites (
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_id VARCHAR(78) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, token_id)
+);
+CREATE INDEX IF NOT EXISTS idx_token_favorites_token_id ON token_favorites (token_id);
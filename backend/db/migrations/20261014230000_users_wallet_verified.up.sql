@@ -0,0 +1,3 @@
+-- Wallet addresses stored before they had to be proven are left unverified.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS wallet_verified BOOLEAN NOT NULL DEFAULT FALSE;
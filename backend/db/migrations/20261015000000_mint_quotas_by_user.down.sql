@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS mint_quotas;
+CREATE TABLE IF NOT EXISTS mint_quotas (
+    address VARCHAR(42) NOT NULL,
+    day DATE NOT NULL,
+    count INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (address, day)
+);
@@ -0,0 +1,10 @@
+-- Mint quotas were counted per self-declared wallet address, which fresh
+-- addresses reset. They are counted per user from now on; the counts of the
+-- current day are dropped.
+DROP TABLE IF EXISTS mint_quotas;
+CREATE TABLE IF NOT EXISTS mint_quotas (
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    count INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, day)
+);
@@ -0,0 +1 @@
+-- Backfilled sales cannot be told apart from indexed ones and are kept.
@@ -0,0 +1,16 @@
+-- Purchases indexed before the sales ledger was written to are only in the
+-- events table, so the trending feed never counted them. They are recorded as
+-- sales from their stored events. The events do not carry the purchase time,
+-- so the time they were indexed stands in for it; the seller is that of the
+-- listing's ListingCreated event, when stored.
+INSERT INTO sales (listing_id, token_id, buyer, seller, price, sold_at, block_number, tx_hash, log_index, created_at)
+SELECT e.listing_id, e.token_id, e.account,
+    COALESCE((SELECT c.account FROM events c
+        WHERE c.type = 'ListingCreated' AND c.listing_id = e.listing_id
+        ORDER BY c.block_number DESC, c.log_index DESC LIMIT 1), ''),
+    e.value, COALESCE(e.created_at, NOW()), e.block_number, e.tx_hash, e.log_index, NOW()
+FROM events e
+WHERE e.type = 'PurchaseCompleted'
+    AND e.listing_id IS NOT NULL AND e.token_id IS NOT NULL
+    AND e.account IS NOT NULL AND e.value IS NOT NULL
+    AND NOT EXISTS (SELECT 1 FROM sales s WHERE s.tx_hash = e.tx_hash AND s.log_index = e.log_index);
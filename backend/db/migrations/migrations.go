@@ -0,0 +1,10 @@
+// Package migrations embeds the SQL migrations of the marketplace schema so the
+// binaries can apply them without shipping the files alongside.
+package migrations
+
+import "embed"
+
+// FS holds the {version}_{name}.up.sql and .down.sql files of this directory.
+//
+//go:embed *.sql
+var FS embed.FS
@@ -1,32 +1,104 @@
 package db
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"nft-marketplace/config"
+	"sync/atomic"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-func ConnectDB() (*gorm.DB, error) {
-	cfg := config.LoadConfig()
+// ErrQueryTimeout is returned by queries cancelled for running longer than
+// the configured statement timeout.
+var ErrQueryTimeout = errors.New("database query timed out")
 
+// queryCanceledCode is the Postgres error code of statements cancelled by
+// statement_timeout.
+const queryCanceledCode = "57014"
+
+// ErrNotConnected is returned by the package functions until SetDefault is
+// called.
+var ErrNotConnected = errors.New("database connection not set up")
+
+var defaultDB atomic.Pointer[gorm.DB]
+
+// queryTimeout is the DB_STATEMENT_TIMEOUT read by Open, which withTimeout
+// bounds queries by. Zero leaves them unbounded.
+var queryTimeout atomic.Int64
+
+// Open opens a connection pool to the database. The binaries open one at
+// startup and share it with SetDefault, since every pool holds its own
+// connections. The schema is not migrated here: the binaries apply
+// db/migrations at startup, see migrate.Migrate.
+//
+// Statements running longer than DB_STATEMENT_TIMEOUT are cancelled by the
+// server, which fails them with ErrQueryTimeout once passed through
+// queryError.
+func Open(cfg *config.Config) (*gorm.DB, error) {
 	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPass, cfg.DBName)
+	if cfg.DBStatementTimeout > 0 {
+		connStr += fmt.Sprintf(" statement_timeout=%d", cfg.DBStatementTimeout.Milliseconds())
+	}
 
 	db, err := gorm.Open(postgres.Open(connStr), &gorm.Config{})
 	if err != nil {
-		log.Fatal("Failed to connect to the database:", err)
+		log.Printf("Failed to connect to the database: %v", err)
+		return nil, fmt.Errorf("failed to connect to the database: %w", err)
 	}
+	queryTimeout.Store(int64(max(cfg.DBStatementTimeout, 0)))
 
-	if err := db.AutoMigrate(&User{}); err != nil {
-		log.Fatal("Failed to migrate the database:", err)
+	log.Println("Connected to the database")
+	return db, nil
+}
+
+// SetDefault makes conn the connection the package functions use.
+func SetDefault(conn *gorm.DB) {
+	defaultDB.Store(conn)
+}
+
+// connection returns the connection set with SetDefault, or ErrNotConnected.
+func connection() (*gorm.DB, error) {
+	db := defaultDB.Load()
+	if db == nil {
+		return nil, ErrNotConnected
 	}
+	return db, nil
+}
 
-	if err := db.AutoMigrate(&Nfts{}); err != nil {
-		log.Fatal("Failed to migrate the database:", err)
+// withTimeout returns db bound to a copy of ctx that expires after the
+// statement timeout read by Open, so that the client gives up on a slow query
+// too, even when the server fails to cancel it, and so that a cancelled
+// request cancels its query. Without a timeout configured, db is bound to ctx
+// as is.
+func withTimeout(ctx context.Context, db *gorm.DB) (*gorm.DB, context.CancelFunc) {
+	timeout := time.Duration(queryTimeout.Load())
+	if timeout <= 0 {
+		return db.WithContext(ctx), func() {}
 	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return db.WithContext(ctx), cancel
+}
 
-	log.Println("Connected to the database")
-	return db, nil
+// queryError wraps err, from a query run on db, with ErrQueryTimeout when the
+// query was cancelled for taking too long, whether by the server or by the
+// context of withTimeout. Drivers report a cancelled context in their own
+// ways, so the context is checked rather than err.
+func queryError(db *gorm.DB, err error) error {
+	if err == nil {
+		return nil
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == queryCanceledCode {
+		return fmt.Errorf("%w: %w", ErrQueryTimeout, err)
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(db.Statement.Context.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrQueryTimeout, err)
+	}
+	return err
 }
@@ -0,0 +1,102 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestConnectionIsShared(t *testing.T) {
+	previous := defaultDB.Load()
+	t.Cleanup(func() { defaultDB.Store(previous) })
+
+	defaultDB.Store(nil)
+	if _, err := connection(); !errors.Is(err, ErrNotConnected) {
+		t.Fatalf("connection() before SetDefault = %v, want %v", err, ErrNotConnected)
+	}
+	// Package functions fail fast rather than open a pool of their own.
+	if _, err := IsTokenRevoked("jti"); !errors.Is(err, ErrNotConnected) {
+		t.Fatalf("IsTokenRevoked() before SetDefault = %v, want %v", err, ErrNotConnected)
+	}
+
+	conn := &gorm.DB{}
+	SetDefault(conn)
+	for range 3 {
+		got, err := connection()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != conn {
+			t.Fatalf("connection() = %p, want the shared %p", got, conn)
+		}
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		delay   time.Duration
+		// cancel cancels the caller's context while the query runs.
+		cancel    bool
+		err       error
+		wantTimed bool
+	}{
+		{name: "fast query", timeout: time.Second},
+		{name: "slow query cancelled at the timeout", timeout: 20 * time.Millisecond, delay: time.Second, wantTimed: true},
+		{name: "cancelled by the server", timeout: time.Second, err: &pgconn.PgError{Code: queryCanceledCode}, wantTimed: true},
+		{name: "cancelled by the caller", timeout: time.Second, delay: time.Second, cancel: true},
+		{name: "no timeout", delay: 50 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer sqlDB.Close()
+			conn, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+			if err != nil {
+				t.Fatal(err)
+			}
+			previous, previousTimeout := defaultDB.Load(), queryTimeout.Load()
+			t.Cleanup(func() {
+				defaultDB.Store(previous)
+				queryTimeout.Store(previousTimeout)
+			})
+			SetDefault(conn)
+			queryTimeout.Store(int64(tt.timeout))
+
+			query := mock.ExpectQuery(`SELECT \* FROM "nfts" WHERE name LIKE \$1`).WithArgs("%cat%").WillDelayFor(tt.delay)
+			if tt.err != nil {
+				query.WillReturnError(tt.err)
+			} else {
+				query.WillReturnRows(sqlmock.NewRows([]string{"token_id"}).AddRow("7"))
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				time.AfterFunc(20*time.Millisecond, cancel)
+			}
+			start := time.Now()
+			_, err = GetNFTsByName(ctx, "cat")
+			if errors.Is(err, ErrQueryTimeout) != tt.wantTimed {
+				t.Fatalf("err = %v, want ErrQueryTimeout: %t", err, tt.wantTimed)
+			}
+			if (err != nil) != (tt.wantTimed || tt.cancel) {
+				t.Fatalf("err = %v, want failing: %t", err, tt.wantTimed || tt.cancel)
+			}
+			if tt.delay >= time.Second && time.Since(start) >= tt.delay {
+				t.Fatalf("query ran for %s, want it cancelled", time.Since(start))
+			}
+		})
+	}
+}
@@ -1,51 +1,143 @@
 package db
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"time"
 )
 
 type Nfts struct {
-	ID          uint   `gorm:"primaryKey" json:"id"`
-	Name        string `gorm:"size:255; not null; unique" json:"name"`
-	Symbol      string `gorm:"size:255; not null; unique" json:"symbol"`
-	Description string `gorm:"size:255; not null;" json:"description"`
-	Price       string `gorm:"size:255; not null;" json:"price"`
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"size:255; not null; unique" json:"name"`
+	Symbol      string    `gorm:"size:255; not null; unique" json:"symbol"`
+	Description string    `gorm:"size:255; not null;" json:"description"`
+	Price       string    `gorm:"size:255; not null;" json:"price"`
+	TokenID     string    `gorm:"size:78; index:idx_nfts_token_id,unique,where:token_id <> ''" json:"token_id"`
+	ListingID   string    `gorm:"size:78" json:"listing_id"`
+	Seller      string    `gorm:"size:42; index" json:"seller"`
+	IsActive    bool      `gorm:"not null; default:false" json:"is_active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
 }
 
 // GetNFTsByName retrieves a list of NFTs with the given name from the database.
 //
-// The function takes a single parameter `name` which is the name of the NFT to search for.
-// It returns a list of NFTs that match the given name. If there is an error during the database query, it returns an error.
+// The function takes the caller's context, whose cancellation cancels the query, and
+// `name`, the name of the NFT to search for. It returns a list of NFTs that match the given name. No match is not an error: the
+// list is empty and the error is nil. If the database query fails, it returns an error,
+// ErrQueryTimeout when it ran longer than the statement timeout.
 //
 // Returns:
-// - A `db.Nfts` containing the list of NFTs with the specified name.
+// - A `[]db.Nfts` containing the NFTs with the specified name, never nil.
 // - An `error` if the database query fails.
-func GetNFTsByName(name string) (Nfts, error) {
-	var nfts Nfts
+func GetNFTsByName(ctx context.Context, name string) ([]Nfts, error) {
+	nfts := []Nfts{}
 
-	db, err := ConnectDB()
+	db, err := connection()
 	if err != nil {
-		return nfts, err
+		return nil, err
 	}
 
+	db, cancel := withTimeout(ctx, db)
+	defer cancel()
 	if err := db.Where("name LIKE ?", "%"+name+"%").Find(&nfts).Error; err != nil {
+		return nil, queryError(db, err)
+	}
+
+	return nfts, nil
+}
+
+// GetNFTByTokenID returns the NFT with the given on-chain token ID.
+func GetNFTByTokenID(tokenID string) (Nfts, error) {
+	var nft Nfts
+
+	db, err := connection()
+	if err != nil {
+		return nft, err
+	}
+
+	if err := db.Where("token_id = ?", tokenID).Take(&nft).Error; err != nil {
+		return nft, err
+	}
+
+	return nft, nil
+}
+
+// GetNFTsByTokenIDs returns the NFTs with the given on-chain token IDs. Token
+// IDs without a row are skipped.
+func GetNFTsByTokenIDs(tokenIDs []string) ([]Nfts, error) {
+	var nfts []Nfts
+
+	db, err := connection()
+	if err != nil {
+		return nfts, err
+	}
+
+	if err := db.Where("token_id IN ?", tokenIDs).Find(&nfts).Error; err != nil {
+		return nfts, err
+	}
+
+	return nfts, nil
+}
+
+// GetNFTsBySeller returns the NFTs recorded with the given seller address,
+// ordered by token ID.
+func GetNFTsBySeller(ctx context.Context, seller string) ([]Nfts, error) {
+	var nfts []Nfts
+
+	db, err := connection()
+	if err != nil {
 		return nfts, err
 	}
 
+	db, cancel := withTimeout(ctx, db)
+	defer cancel()
+	if err := db.Where("LOWER(seller) = LOWER(?)", seller).Order("token_id ASC").Find(&nfts).Error; err != nil {
+		return nfts, queryError(db, err)
+	}
+
 	return nfts, nil
 }
 
+// GetNFTsBySellerPage returns up to limit of the NFTs recorded with the given
+// seller address, skipping the first offset, ordered by token ID, along with
+// how many there are in total. A non-positive limit returns all of them.
+func GetNFTsBySellerPage(ctx context.Context, seller string, limit, offset int) ([]Nfts, int64, error) {
+	nfts := []Nfts{}
+
+	db, err := connection()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	db, cancel := withTimeout(ctx, db)
+	defer cancel()
+	query := db.Model(&Nfts{}).Where("LOWER(seller) = LOWER(?)", seller)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, queryError(db, err)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Order("token_id ASC").Offset(offset).Find(&nfts).Error; err != nil {
+		return nil, 0, queryError(db, err)
+	}
+
+	return nfts, total, nil
+}
+
 func DeleteNFT(id string) error {
 	var nfts Nfts
 
-	db, err := ConnectDB()
+	db, err := connection()
 	if err != nil {
-		log.Printf("Failed to connect to database: %v", err)
 		return err
 	}
 
 	if err := db.Where("id = ?", id).Delete(&nfts).Error; err != nil {
-		log.Fatalf("Failed to delete NFT: %v", err)
+		return fmt.Errorf("failed to delete NFT %s: %w", id, err)
 	}
 
 	return nil
@@ -54,7 +146,7 @@ func DeleteNFT(id string) error {
 func GetAllNFTs() ([]Nfts, error) {
 	var nfts []Nfts
 
-	db, err := ConnectDB()
+	db, err := connection()
 	if err != nil {
 		return nfts, err
 	}
@@ -65,3 +157,60 @@ func GetAllNFTs() ([]Nfts, error) {
 
 	return nfts, nil
 }
+
+// GetNFTsForReconcile returns up to limit NFTs with a token ID, least recently
+// updated first, so that repeated reconciliation runs cycle through every row.
+func GetNFTsForReconcile(limit int) ([]Nfts, error) {
+	var nfts []Nfts
+
+	db, err := connection()
+	if err != nil {
+		return nfts, err
+	}
+
+	if err := db.Where("token_id <> ?", "").Order("updated_at ASC").Limit(limit).Find(&nfts).Error; err != nil {
+		return nfts, err
+	}
+
+	return nfts, nil
+}
+
+// GetStaleActiveNFTs returns up to limit active NFTs whose status was last
+// updated before the given time, oldest first.
+func GetStaleActiveNFTs(before time.Time, limit int) ([]Nfts, error) {
+	var nfts []Nfts
+
+	db, err := connection()
+	if err != nil {
+		return nfts, err
+	}
+
+	err = db.Where("is_active = ? AND token_id <> ? AND updated_at < ?", true, "", before).
+		Order("updated_at ASC").Limit(limit).Find(&nfts).Error
+	if err != nil {
+		return nfts, err
+	}
+
+	return nfts, nil
+}
+
+// UpdateNFTStatus sets the cached listing status of an NFT. The row's
+// updated_at is refreshed even when the status does not change, marking it as
+// verified.
+func UpdateNFTStatus(id uint, isActive bool, listingID string) error {
+	db, err := connection()
+	if err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{
+		"is_active":  isActive,
+		"listing_id": listingID,
+		"updated_at": time.Now(),
+	}
+	if err := db.Model(&Nfts{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
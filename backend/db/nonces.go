@@ -0,0 +1,61 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// SignerNonce is the next nonce to use for transactions signed by Address.
+type SignerNonce struct {
+	Address   string    `gorm:"primaryKey; size:42" json:"address"`
+	NextNonce uint64    `gorm:"not null" json:"next_nonce"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SetNonce resets the next nonce of address to nonce, as read from the chain
+// on startup.
+func SetNonce(address string, nonce uint64) error {
+	db, err := connection()
+	if err != nil {
+		return err
+	}
+
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "address"}},
+		DoUpdates: clause.AssignmentColumns([]string{"next_nonce", "updated_at"}),
+	}).Create(&SignerNonce{Address: address, NextNonce: nonce}).Error
+}
+
+// ReserveNonce atomically hands out the next nonce of address and advances
+// it. pending is the account's pending nonce on chain: the nonce handed out is
+// never below it, so transactions sent by other means are not collided with.
+func ReserveNonce(address string, pending uint64) (uint64, error) {
+	db, err := connection()
+	if err != nil {
+		return 0, err
+	}
+
+	var nonce uint64
+	err = db.Raw(`INSERT INTO signer_nonces (address, next_nonce, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (address) DO UPDATE
+		SET next_nonce = GREATEST(signer_nonces.next_nonce, EXCLUDED.next_nonce - 1) + 1, updated_at = EXCLUDED.updated_at
+		RETURNING next_nonce - 1`, address, pending+1, time.Now()).Scan(&nonce).Error
+	return nonce, err
+}
+
+// ReleaseNonce hands nonce, reserved for address by ReserveNonce for a
+// transaction that never reached the node, back to the store so that the
+// next reservation reuses it. It only does so while nonce is the last one
+// handed out, and reports whether it did.
+func ReleaseNonce(address string, nonce uint64) (bool, error) {
+	db, err := connection()
+	if err != nil {
+		return false, err
+	}
+
+	result := db.Model(&SignerNonce{}).
+		Where("address = ? AND next_nonce = ?", address, nonce+1).
+		Update("next_nonce", nonce)
+	return result.RowsAffected == 1, result.Error
+}
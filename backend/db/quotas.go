@@ -0,0 +1,55 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MintQuota counts the mints of the user with UserID on Day, a UTC date.
+type MintQuota struct {
+	UserID uint      `gorm:"primaryKey" json:"user_id"`
+	Day    time.Time `gorm:"primaryKey; type:date" json:"day"`
+	Count  int       `gorm:"not null; default:0" json:"count"`
+}
+
+// TableName is the plural its migration creates, which gorm would not derive.
+func (MintQuota) TableName() string {
+	return "mint_quotas"
+}
+
+// IncrementMintCount atomically counts a mint of the user with userID on day
+// unless they already minted limit times that day. It returns the day's count
+// and whether the mint was counted.
+func IncrementMintCount(userID uint, day time.Time, limit int) (int, bool, error) {
+	db, err := connection()
+	if err != nil {
+		return 0, false, err
+	}
+
+	var count int
+	result := db.Raw(`INSERT INTO mint_quotas (user_id, day, count) VALUES (?, ?, 1)
+		ON CONFLICT (user_id, day) DO UPDATE
+		SET count = mint_quotas.count + 1
+		WHERE mint_quotas.count < ?
+		RETURNING count`, userID, day, limit).Scan(&count)
+	if result.Error != nil {
+		return 0, false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return limit, false, nil
+	}
+	return count, true, nil
+}
+
+// DecrementMintCount gives back a mint counted on day by IncrementMintCount,
+// for mints that failed.
+func DecrementMintCount(userID uint, day time.Time) error {
+	db, err := connection()
+	if err != nil {
+		return err
+	}
+
+	return db.Model(&MintQuota{}).Where("user_id = ? AND day = ? AND count > 0", userID, day).
+		Update("count", gorm.Expr("count - 1")).Error
+}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// RevokedToken is a token rejected before its expiry, identified by its JTI.
+// It is kept until the token would have expired anyway.
+type RevokedToken struct {
+	JTI       string    `gorm:"column:jti; primaryKey; size:64" json:"jti"`
+	ExpiresAt time.Time `gorm:"not null; index" json:"expires_at"`
+}
+
+// RevokeToken records the token with the given JTI as revoked until
+// expiresAt. Revoking a token twice keeps the first record.
+func RevokeToken(jti string, expiresAt time.Time) error {
+	db, err := connection()
+	if err != nil {
+		return err
+	}
+
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&RevokedToken{JTI: jti, ExpiresAt: expiresAt}).Error
+}
+
+// IsTokenRevoked reports whether the token with the given JTI was revoked.
+func IsTokenRevoked(jti string) (bool, error) {
+	db, err := connection()
+	if err != nil {
+		return false, err
+	}
+
+	var count int64
+	if err := db.Model(&RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// DeleteExpiredRevocations removes the revocations of tokens expired by now,
+// which are rejected for their expiry alone, and returns how many it removed.
+func DeleteExpiredRevocations(now time.Time) (int64, error) {
+	db, err := connection()
+	if err != nil {
+		return 0, err
+	}
+
+	result := db.Where("expires_at <= ?", now).Delete(&RevokedToken{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,174 @@
+package db
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// Sale is a completed purchase, recorded from the marketplace's
+// PurchaseCompleted events. A sale is identified by the transaction and log
+// index of its event; Seller is empty when it could not be determined.
+// CommissionRate is the marketplace's commission at the time of the sale, in
+// basis points, nil for sales recorded before it was kept.
+type Sale struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	ListingID      string    `gorm:"size:78; not null" json:"listing_id"`
+	TokenID        string    `gorm:"size:78; not null; index" json:"token_id"`
+	Buyer          string    `gorm:"size:42; not null; index" json:"buyer"`
+	Seller         string    `gorm:"size:42; not null; default:''; index" json:"seller"`
+	Price          string    `gorm:"type:numeric(78,0); not null" json:"price"`
+	CommissionRate *int64    `json:"commission_rate"`
+	SoldAt         time.Time `gorm:"not null; index" json:"sold_at"`
+	BlockNumber    uint64    `gorm:"not null; default:0" json:"block_number"`
+	TxHash         string    `gorm:"size:66; not null; default:''; uniqueIndex:idx_sales_position,priority:1,where:tx_hash <> ''" json:"tx_hash"`
+	LogIndex       uint      `gorm:"not null; default:0; uniqueIndex:idx_sales_position,priority:2" json:"log_index"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// SaleFilter selects the sales returned by GetSales. Empty fields do not
+// filter.
+type SaleFilter struct {
+	Buyer   string
+	Seller  string
+	TokenID string
+	// From and To bound the sale time, inclusively. Nil means unbounded.
+	From *time.Time
+	To   *time.Time
+	// Limit caps the number of sales returned; zero means no limit.
+	Limit  int
+	Offset int
+}
+
+// TrendingNft is an NFT ranked by its recent sales.
+type TrendingNft struct {
+	Nfts      `gorm:"embedded"`
+	SaleCount int64  `json:"sale_count"`
+	Volume    string `json:"volume"`
+}
+
+// RecordSale stores a completed purchase. Recording a sale whose transaction
+// and log index are already stored does nothing, so events indexed twice are
+// only counted once.
+func RecordSale(sale Sale) error {
+	db, err := connection()
+	if err != nil {
+		return err
+	}
+
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&sale).Error
+}
+
+// GetSales returns the sales matching filter, oldest first. Addresses are
+// compared case-insensitively.
+func GetSales(filter SaleFilter) ([]Sale, error) {
+	sales := make([]Sale, 0)
+
+	db, err := connection()
+	if err != nil {
+		return sales, err
+	}
+
+	query := db.Model(&Sale{})
+	if filter.Buyer != "" {
+		query = query.Where("LOWER(buyer) = LOWER(?)", filter.Buyer)
+	}
+	if filter.Seller != "" {
+		query = query.Where("LOWER(seller) = LOWER(?)", filter.Seller)
+	}
+	if filter.TokenID != "" {
+		query = query.Where("token_id = ?", filter.TokenID)
+	}
+	if filter.From != nil {
+		query = query.Where("sold_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("sold_at <= ?", *filter.To)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	if filter.Offset > 0 {
+		query = query.Offset(filter.Offset)
+	}
+
+	if err := query.Order("sold_at ASC, id ASC").Find(&sales).Error; err != nil {
+		return sales, err
+	}
+
+	return sales, nil
+}
+
+// DeleteSale removes the sale recorded from the given event, after a reorg
+// dropped it from the chain.
+func DeleteSale(txHash string, logIndex uint) error {
+	db, err := connection()
+	if err != nil {
+		return err
+	}
+
+	return db.Where("tx_hash = ? AND log_index = ?", txHash, logIndex).Delete(&Sale{}).Error
+}
+
+// DeleteSalesAfter removes every sale recorded from a block above block, to
+// roll the ledger back to it after a reorg.
+func DeleteSalesAfter(block uint64) error {
+	db, err := connection()
+	if err != nil {
+		return err
+	}
+
+	return db.Where("block_number > ?", block).Delete(&Sale{}).Error
+}
+
+// GetCommissionRevenue returns the commission the marketplace earned on the
+// sales made from from, inclusive, to to, exclusive, in wei. Each sale's
+// commission is computed from its price and its CommissionRate and truncated,
+// as the contract does; sales with no CommissionRate are not counted.
+func GetCommissionRevenue(from, to time.Time) (*big.Int, error) {
+	db, err := connection()
+	if err != nil {
+		return nil, err
+	}
+
+	var total string
+	err = db.Model(&Sale{}).
+		Select("COALESCE(SUM(TRUNC(price * commission_rate / 10000)), 0)::text").
+		Where("commission_rate IS NOT NULL AND sold_at >= ? AND sold_at < ?", from, to).
+		Scan(&total).Error
+	if err != nil {
+		return nil, err
+	}
+
+	revenue, ok := new(big.Int).SetString(total, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid commission revenue %q", total)
+	}
+	return revenue, nil
+}
+
+// GetTrendingNfts returns up to limit NFTs that sold within the last window,
+// ordered by number of sales and then by sale volume in wei.
+func GetTrendingNfts(window time.Duration, limit int) ([]TrendingNft, error) {
+	var trending []TrendingNft
+
+	db, err := connection()
+	if err != nil {
+		return trending, err
+	}
+
+	err = db.Table("nfts").
+		Select("nfts.*, COUNT(sales.id) AS sale_count, COALESCE(SUM(sales.price), 0)::text AS volume").
+		Joins("JOIN sales ON sales.token_id = nfts.token_id AND sales.sold_at >= ?", time.Now().Add(-window)).
+		Group("nfts.id").
+		Order("sale_count DESC, SUM(sales.price) DESC, nfts.id ASC").
+		Limit(limit).
+		Scan(&trending).Error
+	if err != nil {
+		return trending, err
+	}
+
+	return trending, nil
+}
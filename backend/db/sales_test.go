@@ -0,0 +1,81 @@
+package db_test
+
+import (
+	"database/sql/driver"
+	"nft-marketplace/db"
+	"nft-marketplace/db/dbtest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// soldSince matches the start of a trending window of the given length,
+// allowing for the time the test takes to run.
+type soldSince time.Duration
+
+func (w soldSince) Match(v driver.Value) bool {
+	start, ok := v.(time.Time)
+	if !ok {
+		return false
+	}
+	want := time.Now().Add(-time.Duration(w))
+	return !start.After(want) && want.Sub(start) < time.Minute
+}
+
+func TestGetTrendingNfts(t *testing.T) {
+	tests := []struct {
+		name   string
+		window time.Duration
+		limit  int
+		rows   [][]driver.Value
+		want   []string
+	}{
+		{
+			name:   "ranked by sales in the window",
+			window: 24 * time.Hour,
+			limit:  10,
+			rows: [][]driver.Value{
+				{uint(2), "2", int64(3), "3000"},
+				{uint(1), "1", int64(1), "5000"},
+			},
+			want: []string{"2", "1"},
+		},
+		{
+			name:   "wider window",
+			window: 7 * 24 * time.Hour,
+			limit:  1,
+			rows:   [][]driver.Value{{uint(1), "1", int64(4), "9000"}},
+			want:   []string{"1"},
+		},
+		{name: "nothing sold", window: time.Hour, limit: 10, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := dbtest.Mock(t)
+			rows := sqlmock.NewRows([]string{"id", "token_id", "sale_count", "volume"})
+			for _, row := range tt.rows {
+				rows.AddRow(row...)
+			}
+			mock.ExpectQuery(`SELECT nfts\.\*, COUNT\(sales\.id\) AS sale_count.* JOIN sales ON sales\.token_id = nfts\.token_id AND sales\.sold_at >= \$1 GROUP BY "nfts"\."id" ORDER BY sale_count DESC`).
+				WithArgs(soldSince(tt.window), tt.limit).
+				WillReturnRows(rows)
+
+			trending, err := db.GetTrendingNfts(tt.window, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(trending) != len(tt.want) {
+				t.Fatalf("got %d NFTs, want %d", len(trending), len(tt.want))
+			}
+			for i, nft := range trending {
+				if nft.TokenID != tt.want[i] {
+					t.Fatalf("NFT %d is token %s, want %s", i, nft.TokenID, tt.want[i])
+				}
+				if nft.SaleCount != tt.rows[i][2].(int64) || nft.Volume != tt.rows[i][3].(string) {
+					t.Fatalf("NFT %d has %d sales for %s, want %v for %v", i, nft.SaleCount, nft.Volume, tt.rows[i][2], tt.rows[i][3])
+				}
+			}
+		})
+	}
+}
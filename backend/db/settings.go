@@ -0,0 +1,77 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Setting is a runtime setting persisted across restarts.
+type Setting struct {
+	Key       string    `gorm:"primaryKey; size:255" json:"key"`
+	Value     string    `gorm:"size:255; not null" json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// GetSetting returns the value stored for key. The boolean is false when the
+// setting has never been stored.
+func GetSetting(key string) (string, bool, error) {
+	var setting Setting
+
+	db, err := connection()
+	if err != nil {
+		return "", false, err
+	}
+
+	if err := db.Where("key = ?", key).Take(&setting).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+
+	return setting.Value, true, nil
+}
+
+// SetSetting stores value for key, replacing any previous value.
+func SetSetting(key, value string) error {
+	db, err := connection()
+	if err != nil {
+		return err
+	}
+
+	setting := Setting{Key: key, Value: value, UpdatedAt: time.Now()}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+	}).Create(&setting).Error
+}
+
+// GetSettingsWithPrefix returns the settings whose key starts with prefix,
+// ordered by key.
+func GetSettingsWithPrefix(prefix string) ([]Setting, error) {
+	db, err := connection()
+	if err != nil {
+		return nil, err
+	}
+
+	var settings []Setting
+	if err := db.Where("key LIKE ?", escapeLike(prefix)+"%").Order("key").Find(&settings).Error; err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// DeleteSetting removes the value stored for key. The boolean is false when
+// the setting was not stored.
+func DeleteSetting(key string) (bool, error) {
+	db, err := connection()
+	if err != nil {
+		return false, err
+	}
+
+	result := db.Where("key = ?", key).Delete(&Setting{})
+	return result.RowsAffected > 0, result.Error
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"errors"
+	"fmt"
 	"html"
 	"log"
 	"strings"
@@ -14,23 +15,27 @@ type User struct {
 	gorm.Model
 	Username string `gorm:"size:255;not null;unique" json:"username" binding:"required"`
 	Password string `gorm:"size:255;not null;" json:"password" binding:"required"`
+	// WalletAddress is the Ethereum address the user acts as on the marketplace.
+	WalletAddress string `gorm:"size:42" json:"wallet_address"`
+	// WalletVerified reports that the user proved control of WalletAddress,
+	// see accounts.WalletLink. Unverified addresses are never acted on.
+	WalletVerified bool `gorm:"not null; default:false" json:"wallet_verified"`
 }
 
 // GetUserById retrieves a user from the database by their unique ID.
-// It returns the User object along with any associated groceries,
-// or an error if the user is not found or there is a database issue.
+// It returns the User object, or an error if the user is not found or there is
+// a database issue.
 func GetUserById(uid uint) (User, error) {
 	var user User
 
-	db, err := ConnectDB()
+	db, err := connection()
 	if err != nil {
 		log.Println(err)
 		return User{}, err
 	}
 
-	if err := db.Preload("Groceries").Where("id=?", uid).Find(&user).Error; err != nil {
+	if err := db.Where("id=?", uid).Take(&user).Error; err != nil {
 		return user, errors.New("user not found")
-
 	}
 
 	return user, nil
@@ -43,8 +48,7 @@ func GetUserById(uid uint) (User, error) {
 func (u *User) HashedPassword() error {
 	hashPass, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
 	if err != nil {
-		log.Fatalf("Failed to hash password: %v", err)
-		return err
+		return fmt.Errorf("failed to hash password: %w", err)
 	}
 
 	u.Password = string(hashPass)
@@ -60,3 +64,36 @@ func (u *User) HashedPassword() error {
 func VerifyPassword(password, hashPass string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hashPass), []byte(password))
 }
+
+// ListUsers returns up to limit users, ordered by ID and skipping the first
+// offset, along with the total number of users matching. When search is not
+// empty only users whose username contains it, case-insensitively, are
+// considered.
+func ListUsers(limit, offset int, search string) ([]User, int64, error) {
+	users := make([]User, 0)
+
+	db, err := connection()
+	if err != nil {
+		return users, 0, err
+	}
+
+	query := db.Model(&User{})
+	if search = strings.TrimSpace(search); search != "" {
+		query = query.Where("username ILIKE ?", "%"+escapeLike(search)+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return users, 0, err
+	}
+	if err := query.Order("id").Limit(limit).Offset(offset).Find(&users).Error; err != nil {
+		return users, 0, err
+	}
+
+	return users, total, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s so that it matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,191 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	marketplace "nft-marketplace/blockchain"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	ListingCreatedEvent    = "ListingCreated"
+	PurchaseCompletedEvent = "PurchaseCompleted"
+	ListingCancelledEvent  = "ListingCancelled"
+	FundsWithdrawnEvent    = "FundsWithdrawn"
+	CommissionUpdatedEvent = "CommissionUpdated"
+)
+
+// ErrUnexpectedEvent is returned when a log is not the event being parsed.
+var ErrUnexpectedEvent = errors.New("unexpected event")
+
+// ListingCreated is emitted when a seller lists a token on the marketplace.
+type ListingCreated struct {
+	ID        *big.Int
+	Seller    common.Address
+	TokenID   *big.Int
+	Price     *big.Int
+	Timestamp *big.Int
+	Raw       types.Log
+}
+
+// PurchaseCompleted is emitted when a buyer purchases a listing.
+type PurchaseCompleted struct {
+	ID        *big.Int
+	Buyer     common.Address
+	TokenID   *big.Int
+	Price     *big.Int
+	Timestamp *big.Int
+	Raw       types.Log
+}
+
+// ListingCancelled is emitted when a seller cancels a listing.
+type ListingCancelled struct {
+	ID     *big.Int
+	Seller common.Address
+	Raw    types.Log
+}
+
+// FundsWithdrawn is emitted when a recipient withdraws their pending funds.
+type FundsWithdrawn struct {
+	Amount    *big.Int
+	Recipient common.Address
+	Raw       types.Log
+}
+
+// CommissionUpdated is emitted when the marketplace owner changes the commission.
+type CommissionUpdated struct {
+	NewPercent *big.Int
+	Raw        types.Log
+}
+
+// ParseListingCreated decodes a ListingCreated log.
+func ParseListingCreated(log types.Log) (*ListingCreated, error) {
+	fields, err := unpack(ListingCreatedEvent, log)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListingCreated{
+		ID:        fields["id"].(*big.Int),
+		Seller:    fields["seller"].(common.Address),
+		TokenID:   fields["tokenId"].(*big.Int),
+		Price:     fields["price"].(*big.Int),
+		Timestamp: fields["timestamp"].(*big.Int),
+		Raw:       log,
+	}, nil
+}
+
+// ParsePurchaseCompleted decodes a PurchaseCompleted log.
+func ParsePurchaseCompleted(log types.Log) (*PurchaseCompleted, error) {
+	fields, err := unpack(PurchaseCompletedEvent, log)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PurchaseCompleted{
+		ID:        fields["id"].(*big.Int),
+		Buyer:     fields["buyer"].(common.Address),
+		TokenID:   fields["tokenId"].(*big.Int),
+		Price:     fields["price"].(*big.Int),
+		Timestamp: fields["timestamp"].(*big.Int),
+		Raw:       log,
+	}, nil
+}
+
+// ParseListingCancelled decodes a ListingCancelled log.
+func ParseListingCancelled(log types.Log) (*ListingCancelled, error) {
+	fields, err := unpack(ListingCancelledEvent, log)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListingCancelled{
+		ID:     fields["id"].(*big.Int),
+		Seller: fields["seller"].(common.Address),
+		Raw:    log,
+	}, nil
+}
+
+// ParseFundsWithdrawn decodes a FundsWithdrawn log.
+func ParseFundsWithdrawn(log types.Log) (*FundsWithdrawn, error) {
+	fields, err := unpack(FundsWithdrawnEvent, log)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FundsWithdrawn{
+		Amount:    fields["amount"].(*big.Int),
+		Recipient: fields["recipient"].(common.Address),
+		Raw:       log,
+	}, nil
+}
+
+// ParseCommissionUpdated decodes a CommissionUpdated log.
+func ParseCommissionUpdated(log types.Log) (*CommissionUpdated, error) {
+	fields, err := unpack(CommissionUpdatedEvent, log)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CommissionUpdated{
+		NewPercent: fields["newPercent"].(*big.Int),
+		Raw:        log,
+	}, nil
+}
+
+// Topic returns the topic hash identifying the given marketplace event.
+func Topic(name string) (common.Hash, error) {
+	parsed, err := marketplace.MarketplaceMetaData.GetAbi()
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("failed to parse marketplace ABI: %w", err)
+	}
+
+	event, ok := parsed.Events[name]
+	if !ok {
+		return common.Hash{}, fmt.Errorf("unknown event: %s", name)
+	}
+
+	return event.ID, nil
+}
+
+// unpack checks that log is the named event and decodes both its indexed
+// topics and its data fields into a single map keyed by argument name.
+func unpack(name string, log types.Log) (map[string]interface{}, error) {
+	parsed, err := marketplace.MarketplaceMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse marketplace ABI: %w", err)
+	}
+
+	event, ok := parsed.Events[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown event: %s", name)
+	}
+
+	if len(log.Topics) == 0 || log.Topics[0] != event.ID {
+		return nil, fmt.Errorf("%w: log is not a %s event", ErrUnexpectedEvent, name)
+	}
+
+	var indexed abi.Arguments
+	for _, arg := range event.Inputs {
+		if arg.Indexed {
+			indexed = append(indexed, arg)
+		}
+	}
+	if len(log.Topics)-1 != len(indexed) {
+		return nil, fmt.Errorf("failed to decode %s: expected %d indexed topics, got %d", name, len(indexed), len(log.Topics)-1)
+	}
+
+	fields := make(map[string]interface{}, len(event.Inputs))
+	if err := parsed.UnpackIntoMap(fields, name, log.Data); err != nil {
+		return nil, fmt.Errorf("failed to decode %s data: %w", name, err)
+	}
+	if err := abi.ParseTopicsIntoMap(fields, indexed, log.Topics[1:]); err != nil {
+		return nil, fmt.Errorf("failed to decode %s topics: %w", name, err)
+	}
+
+	return fields, nil
+}
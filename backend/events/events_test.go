@@ -0,0 +1,147 @@
+package events
+
+import (
+	"errors"
+	"math/big"
+	marketplace "nft-marketplace/blockchain"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	seller = common.HexToAddress("0x0000000000000000000000000000000000005e11")
+	buyer  = common.HexToAddress("0x00000000000000000000000000000000000b0b01")
+)
+
+// eventLog returns a log of the marketplace event name with the given indexed
+// topics and non-indexed values.
+func eventLog(t *testing.T, name string, topics []common.Hash, values ...any) types.Log {
+	t.Helper()
+
+	parsed, err := marketplace.MarketplaceMetaData.GetAbi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := parsed.Events[name].Inputs.NonIndexed().Pack(values...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return types.Log{Topics: append([]common.Hash{parsed.Events[name].ID}, topics...), Data: data}
+}
+
+func TestParse(t *testing.T) {
+	id := common.BigToHash(big.NewInt(3))
+	created := eventLog(t, ListingCreatedEvent, []common.Hash{id, common.BytesToHash(seller.Bytes())}, big.NewInt(7), big.NewInt(1e18), big.NewInt(1_700_000_000))
+	purchased := eventLog(t, PurchaseCompletedEvent, []common.Hash{id, common.BytesToHash(buyer.Bytes())}, big.NewInt(7), big.NewInt(1e18), big.NewInt(1_700_000_100))
+	cancelled := eventLog(t, ListingCancelledEvent, []common.Hash{id, common.BytesToHash(seller.Bytes())})
+	withdrawn := eventLog(t, FundsWithdrawnEvent, []common.Hash{common.BytesToHash(seller.Bytes())}, big.NewInt(5e17))
+	commission := eventLog(t, CommissionUpdatedEvent, nil, big.NewInt(250))
+
+	tests := []struct {
+		name  string
+		parse func(types.Log) (any, error)
+		log   types.Log
+		want  any
+	}{
+		{
+			name:  "listing created",
+			parse: func(l types.Log) (any, error) { return ParseListingCreated(l) },
+			log:   created,
+			want:  &ListingCreated{ID: big.NewInt(3), Seller: seller, TokenID: big.NewInt(7), Price: big.NewInt(1e18), Timestamp: big.NewInt(1_700_000_000), Raw: created},
+		},
+		{
+			name:  "purchase completed",
+			parse: func(l types.Log) (any, error) { return ParsePurchaseCompleted(l) },
+			log:   purchased,
+			want:  &PurchaseCompleted{ID: big.NewInt(3), Buyer: buyer, TokenID: big.NewInt(7), Price: big.NewInt(1e18), Timestamp: big.NewInt(1_700_000_100), Raw: purchased},
+		},
+		{
+			name:  "listing cancelled",
+			parse: func(l types.Log) (any, error) { return ParseListingCancelled(l) },
+			log:   cancelled,
+			want:  &ListingCancelled{ID: big.NewInt(3), Seller: seller, Raw: cancelled},
+		},
+		{
+			name:  "funds withdrawn",
+			parse: func(l types.Log) (any, error) { return ParseFundsWithdrawn(l) },
+			log:   withdrawn,
+			want:  &FundsWithdrawn{Amount: big.NewInt(5e17), Recipient: seller, Raw: withdrawn},
+		},
+		{
+			name:  "commission updated",
+			parse: func(l types.Log) (any, error) { return ParseCommissionUpdated(l) },
+			log:   commission,
+			want:  &CommissionUpdated{NewPercent: big.NewInt(250), Raw: commission},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.parse(tt.log)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	id := common.BigToHash(big.NewInt(3))
+	created := eventLog(t, ListingCreatedEvent, []common.Hash{id, common.BytesToHash(seller.Bytes())}, big.NewInt(7), big.NewInt(1e18), big.NewInt(1_700_000_000))
+
+	missingTopic := created
+	missingTopic.Topics = created.Topics[:2]
+	truncated := created
+	truncated.Data = created.Data[:40]
+
+	tests := []struct {
+		name           string
+		log            types.Log
+		wantUnexpected bool
+	}{
+		{name: "other event", log: eventLog(t, ListingCancelledEvent, []common.Hash{id, common.BytesToHash(seller.Bytes())}), wantUnexpected: true},
+		{name: "no topics", log: types.Log{Data: created.Data}, wantUnexpected: true},
+		{name: "missing indexed topic", log: missingTopic},
+		{name: "truncated data", log: truncated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseListingCreated(tt.log)
+			if err == nil {
+				t.Fatal("malformed log was decoded")
+			}
+			if errors.Is(err, ErrUnexpectedEvent) != tt.wantUnexpected {
+				t.Fatalf("err = %v, want ErrUnexpectedEvent: %t", err, tt.wantUnexpected)
+			}
+		})
+	}
+}
+
+func TestTopic(t *testing.T) {
+	tests := []struct {
+		name    string
+		event   string
+		want    common.Hash
+		wantErr bool
+	}{
+		{name: "marketplace event", event: PurchaseCompletedEvent, want: crypto.Keccak256Hash([]byte("PurchaseCompleted(uint256,address,uint256,uint256,uint256)"))},
+		{name: "unknown event", event: "Transfer", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic, err := Topic(tt.event)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Topic(%q) error = %v, want error: %t", tt.event, err, tt.wantErr)
+			}
+			if topic != tt.want {
+				t.Fatalf("Topic(%q) = %s, want %s", tt.event, topic.Hex(), tt.want.Hex())
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+// Package featureflags provides runtime toggles. Every flag has a default,
+// from the configuration, which operators can override at runtime; overrides
+// are persisted in the settings table so that they survive restarts and are
+// seen by every worker.
+package featureflags
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"nft-marketplace/clock"
+	"nft-marketplace/db"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// settingPrefix prefixes the settings keys of flag overrides.
+	settingPrefix   = "flag:"
+	defaultCacheTTL = 10 * time.Second
+)
+
+// ErrInvalidName is returned for flag names that are not 1 to 64 lowercase
+// letters, digits, dots, dashes and underscores.
+var ErrInvalidName = errors.New("invalid feature flag name")
+
+var nameRegexp = regexp.MustCompile(`^[a-z0-9._-]{1,64}$`)
+
+// Flag is the state of a feature flag.
+type Flag struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	Default bool   `json:"default"`
+	// Overridden reports that Enabled comes from an override rather than
+	// from Default.
+	Overridden bool `json:"overridden"`
+}
+
+// Flags resolves feature flags. The overrides are read from the database at
+// most once per CacheTTL, so a change made by another worker is seen within
+// that time; changes made through Set and Clear are seen at once. It is safe
+// for concurrent use.
+type Flags struct {
+	CacheTTL time.Duration
+	// Clock measures the cache TTL. Nil means the wall clock.
+	Clock clock.Clock
+
+	defaults map[string]bool
+
+	mu        sync.Mutex
+	overrides map[string]bool
+	expires   time.Time
+}
+
+// New returns flags with the given defaults, whose overrides are cached for
+// cacheTTL, ten seconds when it is not positive.
+func New(defaults map[string]bool, cacheTTL time.Duration) *Flags {
+	if cacheTTL <= 0 {
+		cacheTTL = defaultCacheTTL
+	}
+	copied := make(map[string]bool, len(defaults))
+	for name, enabled := range defaults {
+		copied[name] = enabled
+	}
+	return &Flags{CacheTTL: cacheTTL, defaults: copied}
+}
+
+// ParseDefaults parses flag defaults given as "name=true" or "name=false"
+// items; a bare "name" enables the flag.
+func ParseDefaults(items []string) (map[string]bool, error) {
+	defaults := make(map[string]bool, len(items))
+	for _, item := range items {
+		name, value, found := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		if err := ValidateName(name); err != nil {
+			return nil, err
+		}
+		enabled := true
+		if found {
+			var err error
+			if enabled, err = strconv.ParseBool(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("invalid value %q for feature flag %s", value, name)
+			}
+		}
+		defaults[name] = enabled
+	}
+	return defaults, nil
+}
+
+// ValidateName checks that name is a valid flag name.
+func ValidateName(name string) error {
+	if !nameRegexp.MatchString(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+	return nil
+}
+
+// IsEnabled reports whether the named flag is enabled: its override when it
+// has one, otherwise its default. Unknown flags are disabled. When the
+// overrides cannot be read, the last ones read keep being used.
+func (f *Flags) IsEnabled(name string) bool {
+	if enabled, ok := f.loadOverrides()[name]; ok {
+		return enabled
+	}
+	return f.defaults[name]
+}
+
+// All returns the flags that have a default or an override, sorted by name.
+func (f *Flags) All() []Flag {
+	overrides := f.loadOverrides()
+
+	flags := make([]Flag, 0, len(f.defaults)+len(overrides))
+	for name, enabled := range f.defaults {
+		flag := Flag{Name: name, Enabled: enabled, Default: enabled}
+		if override, ok := overrides[name]; ok {
+			flag.Enabled, flag.Overridden = override, true
+		}
+		flags = append(flags, flag)
+	}
+	for name, enabled := range overrides {
+		if _, ok := f.defaults[name]; !ok {
+			flags = append(flags, Flag{Name: name, Enabled: enabled, Overridden: true})
+		}
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+	return flags
+}
+
+// Set persists an override of the named flag and then applies it.
+func (f *Flags) Set(name string, enabled bool) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	if err := db.SetSetting(settingPrefix+name, strconv.FormatBool(enabled)); err != nil {
+		return fmt.Errorf("failed to persist feature flag %s: %w", name, err)
+	}
+
+	f.invalidate()
+	log.Printf("Feature flag %s set to %t", name, enabled)
+	return nil
+}
+
+// Clear removes the override of the named flag, which reverts to its default.
+// The boolean is false when it had no override.
+func (f *Flags) Clear(name string) (bool, error) {
+	if err := ValidateName(name); err != nil {
+		return false, err
+	}
+	removed, err := db.DeleteSetting(settingPrefix + name)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove feature flag %s: %w", name, err)
+	}
+
+	f.invalidate()
+	if removed {
+		log.Printf("Feature flag %s reverted to its default", name)
+	}
+	return removed, nil
+}
+
+// loadOverrides returns the cached overrides, reading them again once they
+// expired.
+func (f *Flags) loadOverrides() map[string]bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := clock.OrReal(f.Clock).Now()
+	if f.overrides != nil && now.Before(f.expires) {
+		return f.overrides
+	}
+	// Failures are retried after a full TTL rather than on every check.
+	f.expires = now.Add(f.CacheTTL)
+
+	settings, err := db.GetSettingsWithPrefix(settingPrefix)
+	if err != nil {
+		log.Printf("Failed to load feature flag overrides: %v", err)
+		if f.overrides == nil {
+			f.overrides = map[string]bool{}
+		}
+		return f.overrides
+	}
+
+	overrides := make(map[string]bool, len(settings))
+	for _, setting := range settings {
+		name := strings.TrimPrefix(setting.Key, settingPrefix)
+		enabled, err := strconv.ParseBool(setting.Value)
+		if err != nil {
+			log.Printf("Ignoring invalid override %q of feature flag %s", setting.Value, name)
+			continue
+		}
+		overrides[name] = enabled
+	}
+	f.overrides = overrides
+	return overrides
+}
+
+// invalidate makes the next check read the overrides again.
+func (f *Flags) invalidate() {
+	f.mu.Lock()
+	f.expires = time.Time{}
+	f.mu.Unlock()
+}
+
+var defaultFlags atomic.Pointer[Flags]
+
+// SetDefault makes flags the ones the package-level IsEnabled resolves.
+func SetDefault(flags *Flags) {
+	defaultFlags.Store(flags)
+}
+
+// IsEnabled reports whether the named flag of the flags set with SetDefault is
+// enabled. Every flag is disabled until SetDefault is called.
+func IsEnabled(name string) bool {
+	flags := defaultFlags.Load()
+	if flags == nil {
+		return false
+	}
+	return flags.IsEnabled(name)
+}
@@ -0,0 +1,144 @@
+package featureflags
+
+import (
+	"errors"
+	"nft-marketplace/clock"
+	"nft-marketplace/db/dbtest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestParseDefaults(t *testing.T) {
+	tests := []struct {
+		name    string
+		items   []string
+		want    map[string]bool
+		wantErr bool
+		// wantInvalidName is whether the error is ErrInvalidName.
+		wantInvalidName bool
+	}{
+		{name: "explicit values", items: []string{"relay=true", " minting = false "}, want: map[string]bool{"relay": true, "minting": false}},
+		{name: "bare name enables", items: []string{"degraded_reads"}, want: map[string]bool{"degraded_reads": true}},
+		{name: "none", want: map[string]bool{}},
+		{name: "invalid name", items: []string{"Relay=true"}, wantErr: true, wantInvalidName: true},
+		{name: "invalid value", items: []string{"relay=maybe"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDefaults(tt.items)
+			if (err != nil) != tt.wantErr || errors.Is(err, ErrInvalidName) != tt.wantInvalidName {
+				t.Fatalf("err = %v, want error: %t, invalid name: %t", err, tt.wantErr, tt.wantInvalidName)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("defaults = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// expectOverrides expects the overrides to be read, returning the given
+// flag:value settings, or failing when settings is nil.
+func expectOverrides(mock sqlmock.Sqlmock, settings map[string]string) {
+	query := mock.ExpectQuery(`SELECT \* FROM "settings" WHERE key LIKE \$1 ORDER BY key`).WithArgs("flag:%")
+	if settings == nil {
+		query.WillReturnError(errors.New("connection refused"))
+		return
+	}
+	rows := sqlmock.NewRows([]string{"key", "value"})
+	for name, value := range settings {
+		rows.AddRow("flag:"+name, value)
+	}
+	query.WillReturnRows(rows)
+}
+
+func TestIsEnabled(t *testing.T) {
+	defaults := map[string]bool{"relay": true, "minting": false}
+
+	tests := []struct {
+		name      string
+		overrides map[string]string
+		want      map[string]bool
+	}{
+		{name: "defaults", overrides: map[string]string{}, want: map[string]bool{"relay": true, "minting": false, "unknown": false}},
+		{name: "overridden", overrides: map[string]string{"relay": "false", "minting": "true"}, want: map[string]bool{"relay": false, "minting": true}},
+		{name: "override without default", overrides: map[string]string{"beta": "true"}, want: map[string]bool{"beta": true}},
+		{name: "invalid override ignored", overrides: map[string]string{"relay": "off"}, want: map[string]bool{"relay": true}},
+		{name: "overrides unreadable", want: map[string]bool{"relay": true, "minting": false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := dbtest.Mock(t)
+			expectOverrides(mock, tt.overrides)
+
+			flags := New(defaults, time.Minute)
+			flags.Clock = clock.NewFake(time.Unix(1_800_000_000, 0))
+			// Every check after the first is served from the cache.
+			for name, want := range tt.want {
+				if got := flags.IsEnabled(name); got != want {
+					t.Errorf("IsEnabled(%q) = %t, want %t", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestOverridesCache(t *testing.T) {
+	mock := dbtest.Mock(t)
+	fake := clock.NewFake(time.Unix(1_800_000_000, 0))
+	flags := New(map[string]bool{"relay": true}, time.Minute)
+	flags.Clock = fake
+
+	expectOverrides(mock, map[string]string{})
+	if !flags.IsEnabled("relay") {
+		t.Fatal("relay disabled before any override")
+	}
+
+	// Another worker's override is seen once the cache expires.
+	fake.Advance(30 * time.Second)
+	if !flags.IsEnabled("relay") {
+		t.Fatal("cached overrides were read again before expiring")
+	}
+	fake.Advance(30 * time.Second)
+	expectOverrides(mock, map[string]string{"relay": "false"})
+	if flags.IsEnabled("relay") {
+		t.Fatal("override not seen after the cache expired")
+	}
+
+	// A failed read keeps the last overrides.
+	fake.Advance(time.Minute)
+	expectOverrides(mock, nil)
+	if flags.IsEnabled("relay") {
+		t.Fatal("override lost when the overrides could not be read")
+	}
+
+	// An override set here is seen at once.
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "settings"`).WithArgs("flag:relay", "true", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if err := flags.Set("relay", true); err != nil {
+		t.Fatal(err)
+	}
+	expectOverrides(mock, map[string]string{"relay": "true"})
+	if !flags.IsEnabled("relay") {
+		t.Fatal("override set here not seen at once")
+	}
+}
+
+func TestAll(t *testing.T) {
+	mock := dbtest.Mock(t)
+	expectOverrides(mock, map[string]string{"relay": "false", "beta": "true"})
+
+	flags := New(map[string]bool{"relay": true, "minting": true}, time.Minute)
+	want := []Flag{
+		{Name: "beta", Enabled: true, Overridden: true},
+		{Name: "minting", Enabled: true, Default: true},
+		{Name: "relay", Enabled: false, Default: true, Overridden: true},
+	}
+	if got := flags.All(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("All() = %+v, want %+v", got, want)
+	}
+}
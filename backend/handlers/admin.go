@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"nft-marketplace/db"
+	"nft-marketplace/featureflags"
+	"nft-marketplace/services"
+	"nft-marketplace/utils"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+)
+
+// SetMinting pauses or resumes minting. The function expects a JSON request with
+// a single boolean field "paused". The new state is persisted, so it survives
+// restarts. It responds with the resulting state and status code 200, with a bad
+// request error if the request is invalid, with an unsupported media type error if
+// it is not JSON, and with an internal server error if the state cannot be
+// persisted.
+func SetMinting(sw *services.MintingSwitch) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Paused *bool `json:"paused"`
+		}
+
+		err := utils.ParseJSON(c, &request)
+		if errors.Is(err, utils.ErrUnsupportedMediaType) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil || request.Paused == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Field \"paused\" is required"})
+			return
+		}
+
+		if err := sw.SetPaused(*request.Paused); err != nil {
+			log.Printf("Error updating minting state: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update minting state: " + err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"paused": sw.Paused()})
+	}
+}
+
+// RotateSigner replaces the key the service signs transactions with. The
+// function expects a JSON request with a single field "private_key", the new
+// key in hex. It responds with the previous and new signing addresses and the
+// new account's pending nonce and status code 200, with a bad request error if
+// the key is missing or invalid, with an unsupported media type error if the
+// request is not JSON, and with an internal server error if the new account
+// cannot be read from the chain. The key is never logged or echoed back.
+func RotateSigner(ethService *services.EthereumService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			PrivateKey string `json:"private_key"`
+		}
+
+		err := utils.ParseJSON(c, &request)
+		if errors.Is(err, utils.ErrUnsupportedMediaType) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil || request.PrivateKey == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Field \"private_key\" is required"})
+			return
+		}
+
+		rotation, err := ethService.RotateSigner(c.Request.Context(), request.PrivateKey)
+		if errors.Is(err, services.ErrInvalidPrivateKey) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid private key"})
+			return
+		}
+		if err != nil {
+			log.Printf("Error rotating signing key: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate signing key"})
+			return
+		}
+
+		c.JSON(http.StatusOK, rotation)
+	}
+}
+
+// AdminUser is a user as listed to administrators, without its password hash.
+type AdminUser struct {
+	ID            uint   `json:"id"`
+	Username      string `json:"username"`
+	WalletAddress string `json:"wallet_address,omitempty"`
+	// WalletVerified reports that the user proved control of WalletAddress.
+	WalletVerified bool      `json:"wallet_verified"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// ListUsers returns the registered users, ordered by ID and paginated with the
+// "limit" and "offset" query parameters. The "search" query parameter keeps only
+// users whose username, the login they registered with, contains it. Password
+// hashes are never included. It responds with a bad request error for malformed pagination parameters and with
+// an internal server error if the users cannot be read.
+func ListUsers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, offset, err := utils.ParsePagination(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		users, total, err := db.ListUsers(limit, offset, c.Query("search"))
+		if err != nil {
+			log.Printf("Error listing users: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list users"})
+			return
+		}
+
+		page := make([]AdminUser, 0, len(users))
+		for _, user := range users {
+			page = append(page, AdminUser{
+				ID:             user.ID,
+				Username:       user.Username,
+				WalletAddress:  user.WalletAddress,
+				WalletVerified: user.WalletVerified,
+				CreatedAt:      user.CreatedAt,
+			})
+		}
+
+		utils.WritePaged(c, http.StatusOK, page, total, limit, offset)
+	}
+}
+
+// GetMintAllowlist returns whether minting is restricted to the allowlist and
+// the allowlisted addresses, with status code 200.
+func GetMintAllowlist(list *services.MintAllowlist) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"enabled": list.Enabled(), "addresses": list.Addresses()})
+	}
+}
+
+// AddToMintAllowlist allowlists a creator. The function expects a JSON request
+// with a single field "address". The entry is persisted, so it survives
+// restarts. It responds with status code 200, with a bad request error if the
+// address is missing or invalid, with an unsupported media type error if the
+// request is not JSON, and with an internal server error if the entry cannot be
+// persisted.
+func AddToMintAllowlist(list *services.MintAllowlist) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Address string `json:"address"`
+		}
+
+		err := utils.ParseJSON(c, &request)
+		if errors.Is(err, utils.ErrUnsupportedMediaType) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil || !common.IsHexAddress(request.Address) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Field \"address\" must be a valid address"})
+			return
+		}
+
+		address := common.HexToAddress(request.Address)
+		if err := list.Add(address); err != nil {
+			log.Printf("Error updating mint allowlist: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update mint allowlist"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"address": address.Hex(), "allowed": true})
+	}
+}
+
+// RemoveFromMintAllowlist removes the creator at the ":address" path parameter
+// from the allowlist. It responds with status code 200, with a bad request error
+// if the address is invalid, with a not found error if it was not allowlisted,
+// and with an internal server error if the entry cannot be removed.
+func RemoveFromMintAllowlist(list *services.MintAllowlist) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value := c.Param("address")
+		if !common.IsHexAddress(value) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid address: " + value})
+			return
+		}
+
+		address := common.HexToAddress(value)
+		removed, err := list.Remove(address)
+		if err != nil {
+			log.Printf("Error updating mint allowlist: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update mint allowlist"})
+			return
+		}
+		if !removed {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Address is not allowlisted"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"address": address.Hex(), "allowed": false})
+	}
+}
+
+// GetFeatureFlags returns every feature flag with a default or an override,
+// with its state, default and whether it is overridden, and status code 200.
+func GetFeatureFlags(flags *featureflags.Flags) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"flags": flags.All()})
+	}
+}
+
+// SetFeatureFlag overrides the feature flag at the ":name" path parameter. The
+// function expects a JSON request with a single boolean field "enabled". The
+// override is persisted, so it survives restarts and reaches every worker. It
+// responds with status code 200, with a bad request error if the name or the
+// request is invalid, with an unsupported media type error if it is not JSON,
+// and with an internal server error if the override cannot be persisted.
+func SetFeatureFlag(flags *featureflags.Flags) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		if err := featureflags.ValidateName(name); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var request struct {
+			Enabled *bool `json:"enabled"`
+		}
+
+		err := utils.ParseJSON(c, &request)
+		if errors.Is(err, utils.ErrUnsupportedMediaType) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil || request.Enabled == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Field \"enabled\" is required"})
+			return
+		}
+
+		if err := flags.Set(name, *request.Enabled); err != nil {
+			log.Printf("Error updating feature flag: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update feature flag"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"name": name, "enabled": flags.IsEnabled(name)})
+	}
+}
+
+// ClearFeatureFlag removes the override of the feature flag at the ":name"
+// path parameter, which reverts to its default. It responds with the resulting
+// state and status code 200, with a bad request error if the name is invalid,
+// with a not found error if the flag had no override, and with an internal
+// server error if the override cannot be removed.
+func ClearFeatureFlag(flags *featureflags.Flags) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		if err := featureflags.ValidateName(name); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		removed, err := flags.Clear(name)
+		if err != nil {
+			log.Printf("Error updating feature flag: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update feature flag"})
+			return
+		}
+		if !removed {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Feature flag is not overridden"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"name": name, "enabled": flags.IsEnabled(name)})
+	}
+}
+
+const defaultRevenuePeriod = 30 * 24 * time.Hour
+
+// GetCommissionRevenue returns the commission earned on the sales made within
+// a period, in wei, with status code 200. The "from" and "to" query parameters
+// bound the period as RFC 3339 times, from inclusive and to exclusive; to
+// defaults to now and from to 30 days before to. Sales are charged the
+// commission rate in force when they were made. It responds with a bad request
+// error for malformed or reversed bounds and with an internal server error if
+// the sales cannot be read.
+func GetCommissionRevenue() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		to := time.Now().UTC()
+		if value := c.Query("to"); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to: " + value})
+				return
+			}
+			to = parsed
+		}
+		from := to.Add(-defaultRevenuePeriod)
+		if value := c.Query("from"); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from: " + value})
+				return
+			}
+			from = parsed
+		}
+		if !from.Before(to) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+			return
+		}
+
+		revenue, err := db.GetCommissionRevenue(from, to)
+		if err != nil {
+			log.Printf("Error computing commission revenue: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute commission revenue"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"from": from, "to": to, "revenue": revenue.String()})
+	}
+}
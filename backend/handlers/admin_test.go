@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"nft-marketplace/blockchain/chaintest"
+	"nft-marketplace/services"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRotateSigner(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	oldKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKeyHex := hex.EncodeToString(crypto.FromECDSA(newKey))
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "rotated", body: `{"private_key":"0x` + newKeyHex + `"}`, wantStatus: http.StatusOK},
+		{name: "missing key", body: `{}`, wantStatus: http.StatusBadRequest},
+		{name: "invalid key", body: `{"private_key":"not a key"}`, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := chaintest.NewNode(t)
+			node.Handle("eth_getTransactionCount", chaintest.Returns(hexutil.Uint64(4)))
+			ethService := &services.EthereumService{Client: node.Client, ContractAddress: chaintest.Contract, PrivateKey: oldKey}
+
+			r := gin.New()
+			r.POST("/admin/signer", RotateSigner(ethService))
+			req := httptest.NewRequest(http.MethodPost, "/admin/signer", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if strings.Contains(w.Body.String(), newKeyHex) {
+				t.Fatalf("response %s echoes the key", w.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if ethService.PrivateKey != oldKey {
+					t.Fatal("signing key replaced by a rejected request")
+				}
+				return
+			}
+
+			var got services.SignerRotation
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			want := services.SignerRotation{
+				Previous: crypto.PubkeyToAddress(oldKey.PublicKey),
+				Current:  crypto.PubkeyToAddress(newKey.PublicKey),
+				Nonce:    4,
+			}
+			if got != want {
+				t.Fatalf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
@@ -1,18 +1,40 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"nft-marketplace/accounts"
 	"nft-marketplace/db"
 	"nft-marketplace/utils"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
+// RegisterUserInput is the body of a registration. A wallet address must come
+// with the proof that the user controls it: WalletSignature is the hex
+// signature by the wallet of the accounts.WalletLink of the username, the
+// wallet and WalletDeadline.
 type RegisterUserInput struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username        string `json:"username"`
+	Password        string `json:"password"`
+	WalletAddress   string `json:"wallet_address"`
+	WalletSignature string `json:"wallet_signature"`
+	WalletDeadline  int64  `json:"wallet_deadline"`
+}
+
+// LinkWalletInput is the body of a wallet link: the wallet and the proof that
+// the user controls it, as in RegisterUserInput.
+type LinkWalletInput struct {
+	WalletAddress   string `json:"wallet_address"`
+	WalletSignature string `json:"wallet_signature"`
+	WalletDeadline  int64  `json:"wallet_deadline"`
 }
 
 type LoginUserInput struct {
@@ -20,6 +42,19 @@ type LoginUserInput struct {
 	Password string `json:"password"`
 }
 
+// LoginResponse is the body of a successful login. Token is the JWT to send
+// as a bearer token; UserID and ExpiresAt repeat its claims so that clients
+// need not decode it.
+type LoginResponse struct {
+	Token     string    `json:"token"`
+	UserID    uint      `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ErrInvalidCredentials is returned by the login checks when no user has the
+// given username or the password does not match.
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
 type DeleteUserInput struct {
 	Id uint `json:"id"`
 }
@@ -37,11 +72,13 @@ func NewServer(db *gorm.DB) *Server {
 	return &Server{db: db}
 }
 
-// Register takes a username and password as input and creates a new user. If the
-// input is invalid, it returns a 400 Bad Request response. If the user is created
-// successfully, it returns a 201 Created response with a message indicating that
-// the user was created. If there is a database error, it returns a 500 Internal
-// Server Error response.
+// Register takes a username, a password and an optional wallet address as input and
+// creates a new user. The wallet address must be proven with a signed wallet link
+// (see RegisterUserInput) and is then embedded in the user's tokens. If the
+// input is invalid, it returns a 400 Bad Request response listing every invalid
+// field (see ValidateRegister). If the user is created successfully, it returns a
+// 201 Created response with a message indicating that the user was created. If
+// there is a database error, it returns a 500 Internal Server Error response.
 func (s *Server) Register(c *gin.Context) {
 	var Input RegisterUserInput
 
@@ -50,18 +87,17 @@ func (s *Server) Register(c *gin.Context) {
 		return
 	}
 
-	user := db.User{Username: Input.Username, Password: Input.Password}
-	user.HashedPassword()
-
-	if user.Username == "" || user.Password == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+	if err := ValidateRegister(Input); err != nil {
+		utils.WriteValidationError(c, err)
 		return
 	}
 
-	if err := utils.ValidatePassword(Input.Password); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+	user := db.User{Username: Input.Username, Password: Input.Password}
+	if Input.WalletAddress != "" {
+		user.WalletAddress = common.HexToAddress(Input.WalletAddress).Hex()
+		user.WalletVerified = true
 	}
+	user.HashedPassword()
 
 	if err := s.db.Create(&user).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -75,7 +111,9 @@ func (s *Server) Register(c *gin.Context) {
 // credentials are valid. If the credentials are invalid, it returns an error. The function
 // queries the database for the user with the given username and if the user is found, it
 // verifies the password using bcrypt. If the verification fails, it returns an error.
-// Otherwise, it generates a JWT token using the user's ID and returns it.
+// Otherwise, it generates a JWT token using the user's ID and returns it in a
+// LoginResponse, together with the user's ID and the token's expiry. Missing
+// fields are all reported at once with a 400 Bad Request response.
 func (s *Server) Login(c *gin.Context) {
 	var input LoginUserInput
 
@@ -84,15 +122,200 @@ func (s *Server) Login(c *gin.Context) {
 		return
 	}
 
+	if err := ValidateLogin(input); err != nil {
+		utils.WriteValidationError(c, err)
+		return
+	}
+
 	user := db.User{Username: input.Username, Password: input.Password}
 
-	token, err := s.LoginCheck(user.Username, user.Password)
+	response, err := s.login(user.Username, user.Password)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, response)
+}
+
+// AuthLogin is the JSON login endpoint: it takes a username and password like
+// Login and responds with 200 OK and a LoginResponse when they are valid.
+//
+// The body must be declared as application/json, or 415 Unsupported Media Type
+// is returned; a malformed body gets 400 Bad Request, and missing fields are
+// all reported at once with a 400 Bad Request response (see ValidateLogin).
+// Unknown usernames and wrong passwords get the same 401 Unauthorized response,
+// and failures to check them 500 Internal Server Error. The route is meant to
+// be rate limited, see middleware.RateLimit.
+func (s *Server) AuthLogin(c *gin.Context) {
+	var input LoginUserInput
+	if err := utils.ParseJSON(c, &input); err != nil {
+		c.JSON(utils.ParseStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := ValidateLogin(input); err != nil {
+		utils.WriteValidationError(c, err)
+		return
+	}
+
+	response, err := s.login(input.Username, input.Password)
+	if errors.Is(err, ErrInvalidCredentials) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Error logging in user %s: %v", input.Username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+		return
+	}
+
+	utils.Write(c, http.StatusOK, response)
+}
+
+// LinkWallet sets the wallet address of the authenticated user, or verifies the
+// one they registered with before wallets had to be proven, from a signed
+// wallet link of their username (see LinkWalletInput). It responds with 200 OK
+// and a LoginResponse carrying a new token with the wallet, with 401
+// Unauthorized without a valid token, with 400 Bad Request listing the invalid
+// fields, and with 500 Internal Server Error if the user cannot be updated.
+func (s *Server) LinkWallet(c *gin.Context) {
+	if err := utils.SetAuthenticatedUser(c); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := utils.AuthenticatedUserID(c)
+
+	var input LinkWalletInput
+	if err := utils.ParseJSON(c, &input); err != nil {
+		c.JSON(utils.ParseStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	var user db.User
+	if err := s.db.Where("id = ?", userID).Take(&user).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+		return
+	}
+
+	var verr utils.ValidationError
+	validateWalletLink(&verr, user.Username, input.WalletAddress, input.WalletSignature, input.WalletDeadline)
+	if err := verr.Err(); err != nil {
+		utils.WriteValidationError(c, &verr)
+		return
+	}
+
+	user.WalletAddress = common.HexToAddress(input.WalletAddress).Hex()
+	user.WalletVerified = true
+	err := s.db.Model(&db.User{}).Where("id = ?", user.ID).
+		Updates(map[string]any{"wallet_address": user.WalletAddress, "wallet_verified": true}).Error
+	if err != nil {
+		log.Printf("Error linking wallet of user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link wallet"})
+		return
+	}
+
+	issued, err := utils.IssueToken(user)
+	if err != nil {
+		log.Printf("Error issuing token for user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue token"})
+		return
+	}
+	utils.Write(c, http.StatusOK, LoginResponse{Token: issued.Token, UserID: issued.UserID, ExpiresAt: issued.ExpiresAt})
+}
+
+// Logout revokes the token the request is authenticated with, so that it is
+// rejected from then on rather than until it expires. It responds with 200 OK
+// once revoked, with 401 Unauthorized if the token is missing, invalid or
+// already revoked, with 400 Bad Request for tokens issued without a JTI, which
+// cannot be revoked, and with 500 Internal Server Error if the revocation
+// cannot be stored.
+func (s *Server) Logout(c *gin.Context) {
+	token, err := utils.GetToken(c)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		return
+	}
+
+	err = utils.RevokeToken(claims)
+	if errors.Is(err, utils.ErrTokenNotRevocable) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Error revoking token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// ValidateRegister checks every field of a registration request, returning a
+// *utils.ValidationError listing each invalid one, or nil.
+func ValidateRegister(input RegisterUserInput) *utils.ValidationError {
+	var verr utils.ValidationError
+	if input.Username == "" {
+		verr.Add("username", "is required")
+	}
+	if input.Password == "" {
+		verr.Add("password", "is required")
+	} else if err := utils.ValidatePassword(input.Password); err != nil {
+		verr.Add("password", err.Error())
+	}
+	if input.WalletAddress != "" {
+		validateWalletLink(&verr, input.Username, input.WalletAddress, input.WalletSignature, input.WalletDeadline)
+	}
+
+	if verr.Err() == nil {
+		return nil
+	}
+	return &verr
+}
+
+// validateWalletLink adds to verr the fields of a wallet link that fail to
+// prove that the user named username controls wallet.
+func validateWalletLink(verr *utils.ValidationError, username, wallet, signature string, deadline int64) {
+	if err := utils.ValidateEthereumAddress(wallet); err != nil {
+		verr.Add("wallet_address", "is not a valid Ethereum address")
+		return
+	}
+	sig, err := hexutil.Decode(signature)
+	if err != nil {
+		verr.Add("wallet_signature", "must be a 0x-prefixed hex signature")
+		return
+	}
+
+	link := accounts.WalletLink{Username: username, Wallet: common.HexToAddress(wallet), Deadline: deadline}
+	err = accounts.VerifyWalletLink(link, sig, utils.Clock.Now())
+	switch {
+	case errors.Is(err, accounts.ErrWalletLinkExpired), errors.Is(err, accounts.ErrWalletLinkTooLong):
+		verr.Add("wallet_deadline", err.Error())
+	case err != nil:
+		verr.Add("wallet_signature", accounts.ErrWalletLinkSignature.Error())
+	}
+}
+
+// ValidateLogin checks every field of a login request, returning a
+// *utils.ValidationError listing each invalid one, or nil.
+func ValidateLogin(input LoginUserInput) *utils.ValidationError {
+	var verr utils.ValidationError
+	if input.Username == "" {
+		verr.Add("username", "is required")
+	}
+	if input.Password == "" {
+		verr.Add("password", "is required")
+	}
 
-	c.JSON(http.StatusAccepted, gin.H{"token": token})
+	if verr.Err() == nil {
+		return nil
+	}
+	return &verr
 }
 
 // LoginCheck takes a username and password as input and returns a valid JWT token if the
@@ -101,25 +324,42 @@ func (s *Server) Login(c *gin.Context) {
 // verifies the password using bcrypt. If the verification fails, it returns an error.
 // Otherwise, it generates a JWT token using the user's ID and returns it.
 func (s *Server) LoginCheck(username, password string) (string, error) {
+	response, err := s.login(username, password)
+	if err != nil {
+		return "", err
+	}
+	return response.Token, nil
+}
+
+// login checks the credentials like LoginCheck and returns the issued token
+// with its claims. Unknown usernames and wrong passwords fail with
+// ErrInvalidCredentials.
+func (s *Server) login(username, password string) (LoginResponse, error) {
 	var err error
 
 	user := db.User{}
 
 	if err = s.db.Model(db.User{}).Where("username=?", username).Take(&user).Error; err != nil {
-		return "", err
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return LoginResponse{}, ErrInvalidCredentials
+		}
+		return LoginResponse{}, err
 	}
 
 	err = db.VerifyPassword(password, user.Password)
 
-	if err != nil && err == bcrypt.ErrMismatchedHashAndPassword {
-		return "", err
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return LoginResponse{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return LoginResponse{}, err
 	}
 
-	token, err := utils.GenerateToken(user)
+	issued, err := utils.IssueToken(user)
 
 	if err != nil {
-		return "", err
+		return LoginResponse{}, err
 	}
 
-	return token, nil
+	return LoginResponse{Token: issued.Token, UserID: issued.UserID, ExpiresAt: issued.ExpiresAt}, nil
 }
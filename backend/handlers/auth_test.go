@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"nft-marketplace/accounts"
+	"nft-marketplace/clock"
+	"nft-marketplace/db/dbtest"
+	"nft-marketplace/utils"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestValidateRegisterWalletProof(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	previous := utils.Clock
+	utils.Clock = clock.NewFake(now)
+	t.Cleanup(func() { utils.Clock = previous })
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wallet := crypto.PubkeyToAddress(key.PublicKey)
+	deadline := now.Add(5 * time.Minute).Unix()
+	sign := func(username string) string {
+		digest, err := accounts.HashWalletLink(accounts.WalletLink{Username: username, Wallet: wallet, Deadline: deadline})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig, err := crypto.Sign(digest, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hexutil.Encode(sig)
+	}
+
+	tests := []struct {
+		name  string
+		input RegisterUserInput
+		want  []string
+	}{
+		{name: "no wallet", input: RegisterUserInput{}},
+		{name: "proven wallet", input: RegisterUserInput{WalletAddress: wallet.Hex(), WalletSignature: sign("alice"), WalletDeadline: deadline}},
+		{name: "wallet without proof", input: RegisterUserInput{WalletAddress: wallet.Hex()}, want: []string{"wallet_signature"}},
+		{name: "proof for another username", input: RegisterUserInput{WalletAddress: wallet.Hex(), WalletSignature: sign("mallory"), WalletDeadline: deadline}, want: []string{"wallet_signature"}},
+		{name: "expired proof", input: RegisterUserInput{WalletAddress: wallet.Hex(), WalletSignature: sign("alice"), WalletDeadline: now.Unix()}, want: []string{"wallet_deadline"}},
+		{name: "invalid address", input: RegisterUserInput{WalletAddress: "0x123"}, want: []string{"wallet_address"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.input.Username, tt.input.Password = "alice", "Sup3r-Secret-Passw0rd!"
+
+			verr := ValidateRegister(tt.input)
+			var got []string
+			if verr != nil {
+				for _, v := range verr.Violations {
+					got = append(got, v.Field)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("violations = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("violations = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthLogin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("API_SECRET", "test-secret")
+	t.Setenv("TOKEN_HOUR_LIFESPAN", "1")
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("Sup3r-Secret-Passw0rd!"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "username", "password", "wallet_address", "wallet_verified"}).
+			AddRow(7, "alice", string(hash), "", false)
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		// rows answers the user lookup; nil expects none.
+		rows       *sqlmock.Rows
+		wantStatus int
+	}{
+		{name: "valid credentials", body: `{"username":"alice","password":"Sup3r-Secret-Passw0rd!"}`, rows: userRows(), wantStatus: http.StatusOK},
+		{name: "wrong password", body: `{"username":"alice","password":"guess"}`, rows: userRows(), wantStatus: http.StatusUnauthorized},
+		{name: "unknown user", body: `{"username":"bob","password":"guess"}`, rows: sqlmock.NewRows([]string{"id"}), wantStatus: http.StatusUnauthorized},
+		{name: "missing fields", body: `{}`, wantStatus: http.StatusBadRequest},
+		{name: "malformed body", body: `{"username":`, wantStatus: http.StatusBadRequest},
+		{name: "not JSON", contentType: "application/x-www-form-urlencoded", body: "username=alice", wantStatus: http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, mock := dbtest.Open(t)
+			if tt.rows != nil {
+				mock.ExpectQuery(`SELECT \* FROM "users" WHERE username=\$1`).WillReturnRows(tt.rows)
+			}
+			r := gin.New()
+			r.POST("/api/auth/login", NewServer(conn).AuthLogin)
+
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", contentType)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var response LoginResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if response.Token == "" || response.UserID != 7 {
+				t.Fatalf("response = %+v, want a token for user 7", response)
+			}
+		})
+	}
+}
+
+func TestLoginViolations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name string
+		body string
+		want []utils.FieldViolation
+	}{
+		{
+			name: "every field missing",
+			body: `{}`,
+			want: []utils.FieldViolation{{Field: "username", Description: "is required"}, {Field: "password", Description: "is required"}},
+		},
+		{
+			name: "password missing",
+			body: `{"username":"alice"}`,
+			want: []utils.FieldViolation{{Field: "password", Description: "is required"}},
+		},
+	}
+	for _, tt := range tests {
+		for _, route := range []struct {
+			path    string
+			handler func(*Server) gin.HandlerFunc
+		}{
+			{path: "/api/auth/login", handler: func(s *Server) gin.HandlerFunc { return s.AuthLogin }},
+			{path: "/api/login", handler: func(s *Server) gin.HandlerFunc { return s.Login }},
+		} {
+			t.Run(tt.name+" "+route.path, func(t *testing.T) {
+				// Invalid input is rejected before the database is queried.
+				r := gin.New()
+				r.POST(route.path, route.handler(NewServer(nil)))
+
+				req := httptest.NewRequest(http.MethodPost, route.path, strings.NewReader(tt.body))
+				req.Header.Set("Content-Type", "application/json")
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+
+				if w.Code != http.StatusBadRequest {
+					t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusBadRequest, w.Body)
+				}
+				var response struct {
+					Violations []utils.FieldViolation `json:"violations"`
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(response.Violations, tt.want) {
+					t.Fatalf("violations = %+v, want %+v", response.Violations, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestRegisterViolations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name string
+		body string
+		want []utils.FieldViolation
+	}{
+		{
+			name: "every field missing",
+			body: `{}`,
+			want: []utils.FieldViolation{{Field: "username", Description: "is required"}, {Field: "password", Description: "is required"}},
+		},
+		{
+			name: "short password and invalid wallet",
+			body: `{"username":"alice","password":"abc","wallet_address":"not an address"}`,
+			want: []utils.FieldViolation{
+				{Field: "password", Description: "password must be at least 8 characters long"},
+				{Field: "wallet_address", Description: "is not a valid Ethereum address"},
+			},
+		},
+		{
+			name: "wallet without proof",
+			body: `{"username":"alice","password":"secret","wallet_address":"0x00000000000000000000000000000000000000aa"}`,
+			want: []utils.FieldViolation{{Field: "wallet_signature", Description: "must be a 0x-prefixed hex signature"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Invalid input is rejected before the database is queried.
+			r := gin.New()
+			r.POST("/api/register", NewServer(nil).Register)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/register", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusBadRequest, w.Body)
+			}
+			var response struct {
+				Violations []utils.FieldViolation `json:"violations"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(response.Violations, tt.want) {
+				t.Fatalf("violations = %+v, want %+v", response.Violations, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"nft-marketplace/db"
+	"nft-marketplace/services"
+	"nft-marketplace/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetEvents returns the marketplace events stored by the indexer, oldest
+// first.
+//
+// The optional "type" query parameter is a comma separated list of event names
+// such as "ListingCreated,PurchaseCompleted"; "from" and "to" bound the block
+// number, inclusively. "limit" caps the page size (by default and at most the
+// page limits set with utils.SetPageLimits) and "cursor" resumes after the last
+// event of a previous page. "next_cursor" is the position of the last event returned, or
+// the given cursor on an empty page, so clients can keep polling with it for new
+// events; "has_more" tells whether more events are available right away. It
+// responds with a bad request error for malformed parameters.
+func GetEvents() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defaultLimit, maxLimit := utils.PageLimits()
+		filter := db.EventFilter{Limit: defaultLimit}
+
+		if value := c.Query("type"); value != "" {
+			for _, name := range strings.Split(value, ",") {
+				eventType, ok := indexedEventType(strings.TrimSpace(name))
+				if !ok {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type: " + name})
+					return
+				}
+				filter.Types = append(filter.Types, eventType)
+			}
+		}
+
+		var err error
+		if filter.FromBlock, err = parseBlockNumber(c, "from"); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if filter.ToBlock, err = parseBlockNumber(c, "to"); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if filter.FromBlock != nil && filter.ToBlock != nil && *filter.FromBlock > *filter.ToBlock {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from is greater than to"})
+			return
+		}
+
+		if value := c.Query("limit"); value != "" {
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit: " + value})
+				return
+			}
+			filter.Limit = min(limit, maxLimit)
+		}
+
+		var next string
+		if value := c.Query("cursor"); value != "" {
+			cursor, err := db.ParseEventCursor(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+				return
+			}
+			filter.After, next = &cursor, value
+		}
+
+		// Fetch one extra event to tell whether another page follows.
+		limit := filter.Limit
+		filter.Limit++
+		found, err := db.GetEvents(filter)
+		if err != nil {
+			log.Printf("Error fetching events: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch events"})
+			return
+		}
+
+		hasMore := len(found) > limit
+		if hasMore {
+			found = found[:limit]
+		}
+		if len(found) > 0 {
+			next = found[len(found)-1].Cursor().String()
+		}
+
+		utils.Write(c, http.StatusOK, gin.H{"data": found, "next_cursor": next, "has_more": hasMore})
+	}
+}
+
+// parseBlockNumber reads an optional block number query parameter, returning
+// nil when it is absent.
+func parseBlockNumber(c *gin.Context, param string) (*uint64, error) {
+	value := c.Query(param)
+	if value == "" {
+		return nil, nil
+	}
+
+	block, err := strconv.ParseUint(value, 10, 63)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", param, value)
+	}
+	return &block, nil
+}
+
+// indexedEventType returns the indexed event type matching name,
+// case-insensitively.
+func indexedEventType(name string) (string, bool) {
+	for _, eventType := range services.IndexedEvents {
+		if strings.EqualFold(eventType, name) {
+			return eventType, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"log"
+	"net/http"
+	"nft-marketplace/db"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+)
+
+// exportFlushRows is how many CSV rows ExportSellerHistory writes between
+// flushes to the client.
+const exportFlushRows = 100
+
+// sellerExportHeader is the header line of the CSV seller export.
+var sellerExportHeader = []string{"tokenId", "price", "status", "created", "sold_to", "sold_at"}
+
+// ExportSellerHistory streams the history of the seller whose address is in
+// the path as a CSV attachment: one line per NFT they listed, with status
+// "listed" or "unlisted", then one per sale, with status "sold", the buyer and
+// the sale time. Prices are in wei and times in RFC 3339, empty when unknown.
+//
+// The "format" query parameter must be "csv", the default. Rows are written as
+// they are read from the database, so a failure halfway through cuts the file
+// short rather than turning into an error response; it responds with a bad
+// request error for malformed parameters and with an internal server error if
+// the history cannot be read at all.
+func ExportSellerHistory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		address := c.Param("address")
+		if !common.IsHexAddress(address) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid seller address"})
+			return
+		}
+		seller := common.HexToAddress(address)
+
+		if format := c.DefaultQuery("format", "csv"); format != "csv" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format, expected \"csv\""})
+			return
+		}
+
+		var writer *csv.Writer
+		written := 0
+		err := db.StreamSellerHistory(seller.Hex(), func(row db.SellerHistoryRow) error {
+			if writer == nil {
+				writer = startSellerExport(c, seller)
+			}
+			if err := writer.Write([]string{row.TokenID, row.Price, row.Status, formatExportTime(row.Created), row.SoldTo, formatExportTime(row.SoldAt)}); err != nil {
+				return err
+			}
+			written++
+			if written%exportFlushRows == 0 {
+				writer.Flush()
+				c.Writer.Flush()
+			}
+			return writer.Error()
+		})
+		if err != nil && writer == nil {
+			log.Printf("Error exporting history of seller %s: %v", seller.Hex(), err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export seller history"})
+			return
+		}
+		if err != nil {
+			log.Printf("Export of seller %s cut short after %d rows: %v", seller.Hex(), written, err)
+		}
+		if writer == nil {
+			writer = startSellerExport(c, seller)
+		}
+		writer.Flush()
+	}
+}
+
+// startSellerExport sends the headers of the CSV export of seller and its
+// header line, returning the writer of the following rows.
+func startSellerExport(c *gin.Context, seller common.Address) *csv.Writer {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="seller-`+seller.Hex()+`.csv"`)
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	_ = writer.Write(sellerExportHeader)
+	return writer
+}
+
+func formatExportTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
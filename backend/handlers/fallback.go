@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/http"
+	"nft-marketplace/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NotFound answers requests for unknown routes with a JSON 404 error, in the
+// same shape as the other errors of the API. Register it with
+// gin.Engine.NoRoute.
+func NotFound() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		utils.WriteError(c.Writer, http.StatusNotFound, "Route not found")
+	}
+}
+
+// MethodNotAllowed answers requests using a method a known route does not
+// accept with a JSON 405 error. Register it with gin.Engine.NoMethod after
+// setting HandleMethodNotAllowed; gin sets the Allow header with the methods
+// the route accepts.
+func MethodNotAllowed() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		utils.WriteError(c.Writer, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
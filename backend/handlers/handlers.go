@@ -1,14 +1,21 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math/big"
 	"net/http"
 	"nft-marketplace/db"
 	"nft-marketplace/services"
 	"nft-marketplace/utils"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
@@ -21,29 +28,31 @@ func NewServers(db *gorm.DB) *DB_Server {
 	return &DB_Server{db: db}
 }
 
-// GetNFTs returns a list of NFTs from the database in the following format:
-// [
+// GetNFTs returns a page of the seller's listings in the following format:
 //
 //	{
-//	  "id": 1,
-//	  "token_id": "1",
-//	  "owner_address": "0x1234567890123456789012345678901234567890"
-//	},
-//	...
+//	  "data": [{"ListingID": 3, "Seller": "0x...", "TokenID": 1, "Price": "100000000000000000", "IsActive": true}, ...],
+//	  "pagination": {"total": 42, "limit": 20, "offset": 0, "hasNext": true}
+//	}
 //
-// ]
+// Price is in wei, encoded as a decimal string so that browser clients keep
+// its precision, or as a number when WEI_JSON_NUMBERS is set.
 //
-// The function handles database errors by returning an error response with status
-// code 500. If the database query is successful, it returns the list of NFTs with
-// status code 200.
+// The page is selected with the "limit" (default 20, at most 100) and "offset"
+// query parameters.
+//
+// A body that is not JSON is answered with 415 Unsupported Media Type and an
+// invalid one with 400 Bad Request. The function handles database errors by
+// returning an error response with status code 500. If the database query is
+// successful, it returns the list of NFTs with status code 200.
 func GetNFTs(ethService *services.EthereumService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var request struct {
 			Accounts string `json:"accounts"`
 		}
 
-		if err := c.ShouldBindJSON(&request); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		if err := utils.ParseJSON(c, &request); err != nil {
+			c.JSON(utils.ParseStatus(err), gin.H{"error": "Invalid request: " + err.Error()})
 			return
 		}
 
@@ -63,48 +72,89 @@ func GetNFTs(ethService *services.EthereumService) gin.HandlerFunc {
 			return
 		}
 
-		nfts, err := ethService.GetNFTs(accounts)
+		limit, offset, err := utils.ParsePagination(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		nfts, total, err := ethService.GetListingsBySellerPage(c.Request.Context(), accounts, limit, offset)
 		if err != nil {
 			log.Printf("Error fetching NFTs: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to fetch NFTs: %v", err)})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"data": nfts})
+		utils.WritePaged(c, http.StatusOK, nfts, total, limit, offset)
 	}
 }
 
 // MintNFT is a handler function that mints a new NFT with the given token ID and recipient address.
 // The function expects a JSON request with the following fields:
 //
-// - recipient: the Ethereum address of the recipient
+// - recipient: the Ethereum address of the recipient, optional when a treasury address is configured
 // - token_id: the token ID of the NFT to be minted
+// - price: the listing price, in wei unless price_unit says otherwise
+// - price_unit: optional, "wei" (default) or "ether" to give the price as a decimal ether amount such as "0.05"
+// - metadata: optional, the token's metadata JSON, checked with services.ValidateMetadata
 //
 // If the request is invalid or the recipient address is invalid, it responds with a bad request error.
+// If the token is already listed, it responds with a conflict error.
 // If there is an error during the smart contract call, it responds with an internal server error.
 // If the database query fails, it responds with an internal server error.
-// If the operation is successful, it responds with a success message with status code 200.
+// If the operation is successful, it responds with status code 200 and a "result" holding
+// the transaction hash and the ID of the created listing. If the transaction is still
+// pending after the confirmation timeout, it responds with status code 202 and a result
+// without listing ID. When an IPFS node is configured, the metadata is queued to be
+// pinned and "metadata_pin" holds the pending pin job, see GetPinJob; a full queue is
+// answered with 503 before anything is minted.
 func (s *DB_Server) MintNFT(ethService *services.EthereumService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var request struct {
-			TokenID     string `json:"token_id"`
-			Name        string `json:"name"`
-			Symbol      string `json:"symbol"`
-			Description string `json:"description"`
-			Price       string `json:"price"`
-			Recipient   string `json:"recipient"`
+			TokenID     string             `json:"token_id"`
+			Name        string             `json:"name"`
+			Symbol      string             `json:"symbol"`
+			Description string             `json:"description"`
+			Price       string             `json:"price"`
+			PriceUnit   string             `json:"price_unit"`
+			Recipient   string             `json:"recipient"`
+			Metadata    *services.Metadata `json:"metadata"`
 		}
 
-		if err := c.ShouldBindJSON(&request); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if err := utils.ParseJSON(c, &request); err != nil {
+			c.JSON(utils.ParseStatus(err), gin.H{"error": err.Error()})
 			return
 		}
 
-		if err := utils.ValidateEthereumAddress(request.Recipient); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recipient address"})
+		if request.Metadata != nil {
+			var verr *utils.ValidationError
+			if err := services.ValidateMetadata(*request.Metadata); errors.As(err, &verr) {
+				utils.WriteValidationError(c, verr)
+				return
+			}
+		}
+
+		switch request.PriceUnit {
+		case "", "wei":
+		case "ether":
+			wei, err := utils.EtherToWei(request.Price)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid price: " + err.Error()})
+				return
+			}
+			request.Price = wei.String()
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid price unit, expected \"wei\" or \"ether\""})
 			return
 		}
 
+		if request.Recipient != "" {
+			if err := utils.ValidateEthereumAddress(request.Recipient); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recipient address"})
+				return
+			}
+		}
+
 		if err := utils.ValidatePrice(request.Price); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid price"})
 			return
@@ -122,26 +172,87 @@ func (s *DB_Server) MintNFT(ethService *services.EthereumService) gin.HandlerFun
 			return
 		}
 
+		// Metadata is pinned in the background; the response only carries
+		// the pin job, whose CID GetPinJob tells once it is pinned.
+		var pin *services.PinJob
+		if request.Metadata != nil && ethService.PinQueue != nil {
+			data, err := json.Marshal(request.Metadata)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid metadata: " + err.Error()})
+				return
+			}
+			job, err := ethService.PinQueue.Submit(data)
+			if errors.Is(err, services.ErrPinQueueFull) {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Too many metadata pins in progress, try again later"})
+				return
+			}
+			if err != nil {
+				log.Printf("Error queueing metadata pin: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue metadata pin"})
+				return
+			}
+			pin = &job
+		}
+
 		nfts := db.Nfts{
 			Name:        request.Name,
 			Symbol:      request.Symbol,
 			Description: request.Description,
 			Price:       request.Price,
+			TokenID:     request.TokenID,
+			IsActive:    true,
+		}
+
+		// With confirmations required, the row is only written once the
+		// listing is deep enough not to be reorged out.
+		record := func(result services.MintResult) error {
+			nfts := nfts
+			nfts.Seller = result.Recipient.Hex()
+			nfts.ListingID = result.ListingID
+			if err := s.db.Create(&nfts).Error; err != nil {
+				return err
+			}
+			ethService.InvalidateQueries()
+			return nil
 		}
 
-		err := ethService.MintNFT(request.TokenID, request.Price, request.Recipient)
+		var result services.MintResult
+		var err error
+		deferred := ethService.MintConfirmations > 0
+		if deferred {
+			result, err = ethService.MintNFTAfterConfirmations(request.TokenID, request.Price, request.Recipient, record)
+		} else {
+			result, err = ethService.MintNFT(request.TokenID, request.Price, request.Recipient)
+		}
+		if errors.Is(err, services.ErrInvalidRecipient) || errors.Is(err, services.ErrZeroRecipient) || errors.Is(err, services.ErrBlockedRecipient) || errors.Is(err, services.ErrInvalidNumber) ||
+			errors.Is(err, services.ErrPriceTooHigh) || errors.Is(err, services.ErrPriceTooLow) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrTokenAlreadyListed) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		if err != nil {
 			log.Printf("MintNFT error: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mint NFT on blockchain: " + err.Error()})
 			return
 		}
 
-		if err := s.db.Create(&nfts).Error; err != nil {
+		if deferred {
+			c.JSON(http.StatusAccepted, gin.H{"message": "NFT listing submitted, recorded once confirmed", "result": result, "metadata_pin": pin})
+			return
+		}
+		if err := record(result); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create NFT: " + err.Error()})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "NFT minted successfully"})
+		if result.Pending {
+			c.JSON(http.StatusAccepted, gin.H{"message": "NFT listing submitted, transaction pending", "result": result, "metadata_pin": pin})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "NFT minted successfully", "result": result, "metadata_pin": pin})
 	}
 }
 
@@ -153,7 +264,8 @@ func (s *DB_Server) MintNFT(ethService *services.EthereumService) gin.HandlerFun
 // 3. Transfers the NFT using the EthereumService.
 // 4. Updates the database with the new owner address.
 // 5. Returns appropriate error responses if any step fails, including validation, database, or transfer errors.
-// If successful, it responds with a status code 200 and a success message.func BuyNFT(ethService *services.EthereumService) gin.HandlerFunc {
+// If successful, it responds with a status code 200, a success message and the hash of the
+// purchase transaction, which is not waited for.
 func BuyNFT(ethService *services.EthereumService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var request struct {
@@ -161,8 +273,8 @@ func BuyNFT(ethService *services.EthereumService) gin.HandlerFunc {
 			Buyer   string `json:"buyer"`
 		}
 
-		if err := c.ShouldBindJSON(&request); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if err := utils.ParseJSON(c, &request); err != nil {
+			c.JSON(utils.ParseStatus(err), gin.H{"error": err.Error()})
 			return
 		}
 
@@ -171,41 +283,86 @@ func BuyNFT(ethService *services.EthereumService) gin.HandlerFunc {
 			return
 		}
 
-		err := ethService.TransferNFT(request.TokenID, request.Buyer)
+		txHash, err := ethService.TransferNFT(request.TokenID, request.Buyer)
+		if errors.Is(err, services.ErrInvalidNumber) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrListingNotActive) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Listing is not active"})
+			return
+		}
 		if err != nil {
 			log.Printf("Error during NFT transfer: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transfer NFT: " + err.Error()})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "NFT purchased successfully"})
+		c.JSON(http.StatusOK, gin.H{"message": "NFT purchased successfully", "tx_hash": txHash.Hex()})
 	}
 }
 
 // Search is a handler function that searches for NFTs with the given name.
 // The function expects a JSON request with a single field "name" containing the search query.
 // It returns a list of NFTs with the given name, or an error if the search fails.
-// The response is a JSON object with a single field "data" containing the list of NFTs.
-// If the search is successful, it returns a status code 200.
-// If the request is invalid or the search fails, it returns an appropriate error response.
+// The response is a page of the matching NFTs, selected with the limit and offset
+// query parameters, in the "data" and "pagination" fields.
+// If the search is successful, it returns a status code 200, with an empty list when
+// nothing matches. If the request is invalid or the database query fails, it returns
+// an appropriate error response, 504 when the query ran longer than the statement timeout.
+// When degraded reads are enabled and the database query fails, a search without a
+// name, which matches every NFT, is answered with the active on-chain listings instead
+// and "degraded" set to true in the response. The chain does not record names, so a
+// search by name gets 503 Service Unavailable then.
 func SearchNFTs(ethService *services.EthereumService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var request struct {
 			Name string `json:"name"`
 		}
 
-		if err := c.ShouldBindJSON(&request); err != nil {
+		if err := utils.ParseJSON(c, &request); err != nil {
+			c.JSON(utils.ParseStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		limit, offset, err := utils.ParsePagination(c)
+		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		nfts, err := ethService.SearchNFTs(request.Name)
+		nfts, err := ethService.SearchNFTs(c.Request.Context(), request.Name)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch NFTs: " + err.Error()})
+			if !ethService.DegradedReads {
+				if errors.Is(err, db.ErrQueryTimeout) {
+					c.JSON(http.StatusGatewayTimeout, gin.H{"error": "Search timed out, try a more specific name"})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch NFTs: " + err.Error()})
+				return
+			}
+
+			// Names are only recorded in the database, so the chain can
+			// only stand in for a search matching every NFT.
+			if request.Name != "" {
+				log.Printf("Database unavailable, refusing search by name: %v", err)
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Search by name is unavailable, try again later", "degraded": true})
+				return
+			}
+
+			log.Printf("Database unavailable, serving on-chain listings: %v", err)
+			listings, chainErr := ethService.GetChainListings(c.Request.Context())
+			if chainErr != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch NFTs: " + chainErr.Error()})
+				return
+			}
+
+			page := utils.Page(listings, limit, offset)
+			utils.WritePagedWith(c, http.StatusOK, page, int64(len(listings)), limit, offset, gin.H{"degraded": true})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"data": nfts})
+		utils.WritePaged(c, http.StatusOK, utils.Page(nfts, limit, offset), int64(len(nfts)), limit, offset)
 	}
 }
 
@@ -215,8 +372,9 @@ func DeleteNFT(ethService *services.EthereumService) gin.HandlerFunc {
 			TokenID string `json:"token_id"`
 		}
 
-		if err := c.ShouldBindJSON(&request); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		if err := utils.ParseJSON(c, &request); err != nil {
+			c.JSON(utils.ParseStatus(err), gin.H{"error": "Invalid request: " + err.Error()})
+			return
 		}
 
 		if err := ethService.DeleteNFT(request.TokenID); err != nil {
@@ -227,3 +385,762 @@ func DeleteNFT(ethService *services.EthereumService) gin.HandlerFunc {
 		c.JSON(http.StatusOK, gin.H{"message": "NFT deleted successfully"})
 	}
 }
+
+const (
+	defaultTrendingWindow = 24 * time.Hour
+	maxTrendingWindow     = 30 * 24 * time.Hour
+	defaultTrendingLimit  = 10
+)
+
+// GetTrendingNFTs returns the NFTs ranked by their sales within a recent window,
+// most sales first and then by sale volume. The optional "window" query
+// parameter is a duration such as "6h" (default 24h, at most 30 days) and
+// "limit" caps the number of NFTs returned (default 10). It responds with a bad
+// request error for malformed parameters and with an internal server error if
+// the sales cannot be read.
+func GetTrendingNFTs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		window := defaultTrendingWindow
+		if value := c.Query("window"); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil || parsed <= 0 || parsed > maxTrendingWindow {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid window: " + value})
+				return
+			}
+			window = parsed
+		}
+
+		limit := defaultTrendingLimit
+		if value := c.Query("limit"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit: " + value})
+				return
+			}
+			_, maxLimit := utils.PageLimits()
+			limit = min(parsed, maxLimit)
+		}
+
+		trending, err := db.GetTrendingNfts(window, limit)
+		if err != nil {
+			log.Printf("Error fetching trending NFTs: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch trending NFTs"})
+			return
+		}
+
+		utils.Write(c, http.StatusOK, gin.H{"data": trending, "window": window.String()})
+	}
+}
+
+// OwnedNFT is a token held by a user, with its database record when there is one.
+type OwnedNFT struct {
+	TokenID string   `json:"token_id"`
+	NFT     *db.Nfts `json:"nft,omitempty"`
+}
+
+// GetUserNFTs returns the NFTs of the user given in the URL, either by user ID or
+// by wallet address, paginated with the "limit" and "offset" query parameters.
+// The list of tokens is read from the chain and each token is enriched with its
+// database record. If the chain cannot be read, the NFTs recorded in the database
+// for the user are returned instead and "stale" is set to true in the response.
+// It responds with a bad request error for malformed parameters, with a not found
+// error for unknown users or users without a verified wallet, and with an internal server
+// error if neither the chain nor the database can be read.
+func GetUserNFTs(ethService *services.EthereumService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, offset, err := utils.ParsePagination(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		id := c.Param("id")
+		var owner common.Address
+		switch {
+		case common.IsHexAddress(id):
+			owner = common.HexToAddress(id)
+		default:
+			uid, err := strconv.ParseUint(id, 10, 32)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID or address"})
+				return
+			}
+			user, err := db.GetUserById(uint(uid))
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				return
+			}
+			if !user.WalletVerified || !common.IsHexAddress(user.WalletAddress) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "No wallet address associated with the user"})
+				return
+			}
+			owner = common.HexToAddress(user.WalletAddress)
+		}
+
+		tokenIDs, total, chainErr := ethService.GetOwnedTokenIDs(c.Request.Context(), owner, limit, offset)
+		if chainErr != nil {
+			log.Printf("Chain unavailable, serving NFTs of %s from the database: %v", owner.Hex(), chainErr)
+
+			nfts, total, err := ethService.GetSellerNFTsPage(c.Request.Context(), owner, limit, offset)
+			if err != nil {
+				log.Printf("Error fetching NFTs of %s: %v", owner.Hex(), err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch NFTs"})
+				return
+			}
+
+			page := make([]OwnedNFT, 0, len(nfts))
+			for i := range nfts {
+				page = append(page, OwnedNFT{TokenID: nfts[i].TokenID, NFT: &nfts[i]})
+			}
+			utils.WritePagedWith(c, http.StatusOK, page, total, limit, offset, gin.H{"stale": true})
+			return
+		}
+
+		ids := make([]string, 0, len(tokenIDs))
+		for _, tokenID := range tokenIDs {
+			ids = append(ids, tokenID.String())
+		}
+
+		records := make(map[string]*db.Nfts)
+		if len(ids) > 0 {
+			nfts, err := db.GetNFTsByTokenIDs(ids)
+			if err != nil {
+				log.Printf("Error fetching NFT metadata: %v", err)
+			}
+			for i := range nfts {
+				records[nfts[i].TokenID] = &nfts[i]
+			}
+		}
+
+		owned := make([]OwnedNFT, 0, len(ids))
+		for _, tokenID := range ids {
+			owned = append(owned, OwnedNFT{TokenID: tokenID, NFT: records[tokenID]})
+		}
+
+		utils.WritePaged(c, http.StatusOK, owned, total, limit, offset)
+	}
+}
+
+// maxOwnershipClaims caps the claims VerifyOwnership checks per request, and
+// ownershipClaimBytes bounds the request body to about that many claims.
+const (
+	maxOwnershipClaims  = 100
+	ownershipClaimBytes = 256
+)
+
+// OwnershipCheck is the outcome of one claim checked by VerifyOwnership.
+type OwnershipCheck struct {
+	Address string `json:"address"`
+	TokenID string `json:"token_id"`
+	Owned   bool   `json:"owned"`
+}
+
+// VerifyOwnership checks many ownership claims at once, for airdrop tooling. The
+// function expects a JSON array of objects with an "address" and a decimal
+// "token_id", at most 100 of them, and responds with status code 200 and, under
+// "data", each claim in order with "owned" telling whether the address owns the
+// token. All claims are checked against the same block. It responds with a
+// request entity too large error for bigger batches, with a bad request error
+// listing the invalid claims, and with an internal server error if the chain
+// cannot be read.
+func VerifyOwnership(ethService *services.EthereumService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxOwnershipClaims*ownershipClaimBytes)
+
+		var request []struct {
+			Address string `json:"address"`
+			TokenID string `json:"token_id"`
+		}
+
+		if err := utils.ParseJSON(c, &request); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("At most %d claims can be checked at once", maxOwnershipClaims)})
+				return
+			}
+			c.JSON(utils.ParseStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		if len(request) > maxOwnershipClaims {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("At most %d claims can be checked at once", maxOwnershipClaims)})
+			return
+		}
+
+		var verr utils.ValidationError
+		claims := make([]services.OwnershipClaim, 0, len(request))
+		for i, claim := range request {
+			if !common.IsHexAddress(claim.Address) {
+				verr.Add(fmt.Sprintf("[%d].address", i), "must be a valid address")
+			}
+			tokenID, ok := new(big.Int).SetString(claim.TokenID, 10)
+			if !ok || tokenID.Sign() < 0 {
+				verr.Add(fmt.Sprintf("[%d].token_id", i), "must be a non-negative decimal integer")
+			}
+			claims = append(claims, services.OwnershipClaim{Address: common.HexToAddress(claim.Address), TokenID: tokenID})
+		}
+		if verr.Err() != nil {
+			utils.WriteValidationError(c, &verr)
+			return
+		}
+
+		owned, err := ethService.BatchCheckOwnership(c.Request.Context(), claims)
+		if err != nil {
+			log.Printf("Error checking token ownership: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check ownership"})
+			return
+		}
+
+		checks := make([]OwnershipCheck, 0, len(claims))
+		for i, claim := range claims {
+			checks = append(checks, OwnershipCheck{Address: claim.Address.Hex(), TokenID: claim.TokenID.String(), Owned: owned[i]})
+		}
+		utils.Write(c, http.StatusOK, gin.H{"data": checks})
+	}
+}
+
+// GetOwner returns the owner of the token given in the URL. The optional
+// "collection" query parameter selects the collection by ID or contract
+// address and defaults to the default collection. It responds with a bad
+// request error if the token ID is not a number, with a not found error if the
+// collection is unknown and with an internal server error if the chain cannot
+// be read.
+func GetOwner(ethService *services.EthereumService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		collection := c.Query("collection")
+		owner, err := ethService.OwnerOf(c.Request.Context(), collection, c.Param("id"))
+		switch {
+		case errors.Is(err, services.ErrInvalidNumber):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, services.ErrUnknownCollection):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			log.Printf("Error fetching owner: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch owner: " + err.Error()})
+			return
+		}
+
+		utils.Write(c, http.StatusOK, gin.H{"collection": collection, "token_id": c.Param("id"), "owner": owner.Hex()})
+	}
+}
+
+// TokenDetail is a token snapshot along with the token's view and favorite
+// counts, which are left out when they cannot be read.
+type TokenDetail struct {
+	services.TokenSnapshot
+	*db.TokenStats
+}
+
+// GetTokenSnapshot returns the owner and listing state of the token given in the
+// URL, all read at the same block (see services.TokenSnapshot), and its views
+// and favorites in a TokenDetail. It responds with a
+// bad request error if the token ID is not a number, with a not found error if the
+// token does not exist and with an internal server error if the chain cannot be
+// read.
+func GetTokenSnapshot(ethService *services.EthereumService, engagement *services.Engagement) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		snapshot, err := ethService.GetTokenSnapshot(c.Request.Context(), c.Param("id"))
+		switch {
+		case errors.Is(err, services.ErrInvalidNumber):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, services.ErrTokenNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			log.Printf("Error fetching token snapshot: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch token snapshot"})
+			return
+		}
+
+		detail := TokenDetail{TokenSnapshot: snapshot}
+		if stats, err := engagement.Stats(snapshot.TokenID); err != nil {
+			log.Printf("Error fetching token stats: %v", err)
+		} else {
+			detail.TokenStats = &stats
+		}
+
+		utils.Write(c, http.StatusOK, detail)
+	}
+}
+
+// ViewCount is the response of CountTokenView. Counted is false for views
+// debounced as repeats of a recent one.
+type ViewCount struct {
+	Counted bool `json:"counted"`
+	db.TokenStats
+}
+
+// CountTokenView counts a view of the token given in the URL and returns its
+// counts in a ViewCount. Views are attributed to the authenticated user, or to
+// the client IP for anonymous requests, and counted once per viewer and
+// services.Engagement.ViewDebounce. The client IP is only taken from
+// X-Forwarded-For behind the router's trusted proxies. It responds with a bad
+// request error if the token ID is not a number, with a not found error if the
+// token does not exist and with an internal server error if the counts cannot
+// be updated.
+func CountTokenView(ethService *services.EthereumService, engagement *services.Engagement) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !checkTokenExists(c, ethService) {
+			return
+		}
+
+		viewer := ""
+		if err := utils.SetAuthenticatedUser(c); err == nil {
+			if id, ok := utils.AuthenticatedUserID(c); ok {
+				viewer = "user:" + strconv.FormatUint(uint64(id), 10)
+			}
+		}
+		if viewer == "" {
+			viewer = "ip:" + c.ClientIP()
+		}
+
+		counted, err := engagement.IncrementViews(c.Param("id"), viewer)
+		if err != nil {
+			log.Printf("Error counting token view: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count view"})
+			return
+		}
+
+		stats, err := engagement.Stats(c.Param("id"))
+		if err != nil {
+			log.Printf("Error fetching token stats: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch token stats"})
+			return
+		}
+
+		utils.Write(c, http.StatusOK, ViewCount{Counted: counted, TokenStats: stats})
+	}
+}
+
+// FavoriteToggle is the response of ToggleFavorite. Favorited tells whether
+// the token is now among the user's favorites.
+type FavoriteToggle struct {
+	Favorited bool `json:"favorited"`
+	db.TokenStats
+}
+
+// ToggleFavorite adds the token given in the URL to the authenticated user's
+// favorites, or removes it if it already was one, and returns its counts in a
+// FavoriteToggle. The request must be authenticated, see
+// middleware.JwtAuthMiddleware. It responds with a bad request error if the
+// token ID is not a number, with a not found error if the token does not exist
+// and with an internal server error if the favorites cannot be updated.
+func ToggleFavorite(ethService *services.EthereumService, engagement *services.Engagement) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := utils.AuthenticatedUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+		if !checkTokenExists(c, ethService) {
+			return
+		}
+
+		favorited, err := engagement.ToggleFavorite(userID, c.Param("id"))
+		if err != nil {
+			log.Printf("Error toggling favorite: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to toggle favorite"})
+			return
+		}
+
+		stats, err := engagement.Stats(c.Param("id"))
+		if err != nil {
+			log.Printf("Error fetching token stats: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch token stats"})
+			return
+		}
+
+		utils.Write(c, http.StatusOK, FavoriteToggle{Favorited: favorited, TokenStats: stats})
+	}
+}
+
+// checkTokenExists responds with a bad request error if the token ID given in
+// the URL is not a number, with a not found error if the token does not exist
+// and with an internal server error if that cannot be checked, so that counts
+// are not kept for made-up tokens. It reports whether the token exists.
+func checkTokenExists(c *gin.Context, ethService *services.EthereumService) bool {
+	err := ethService.CheckTokenExists(c.Request.Context(), c.Param("id"))
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, services.ErrInvalidNumber):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTokenNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Error checking token %s exists: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check token"})
+	}
+	return false
+}
+
+// GetPurchaseCost returns the estimated cost of buying the listing given in the
+// URL: its price, the gas the purchase needs and the resulting total, along with
+// the marketplace commission taken from the price. It responds with a bad
+// request error if the listing ID is not a number, with a not found error if
+// the listing is not active and with an internal server error if the chain
+// cannot be read.
+func GetPurchaseCost(ethService *services.EthereumService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		estimate, err := ethService.EstimatePurchaseCost(c.Request.Context(), c.Param("id"))
+		switch {
+		case errors.Is(err, services.ErrInvalidNumber):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, services.ErrListingNotActive):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Listing is not active"})
+			return
+		case err != nil:
+			log.Printf("Error estimating purchase cost: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to estimate purchase cost: " + err.Error()})
+			return
+		}
+
+		utils.Write(c, http.StatusOK, estimate)
+	}
+}
+
+// ListingLookup is an entry of GetListings' response: the listing with the
+// requested ID, or why it could not be resolved.
+type ListingLookup struct {
+	ID      string               `json:"id"`
+	Listing *services.NFTListing `json:"listing,omitempty"`
+	Error   string               `json:"error,omitempty"`
+}
+
+// GetListings returns the listings whose IDs are given, comma separated, in the
+// "ids" query parameter, in the same order, so that a cart can be resolved in
+// one request. IDs that are not numbers or have no listing get an error in
+// their entry rather than failing the request. It responds with a bad request
+// error when no IDs or more than the maximum page size are given, and with an
+// internal server error if the chain cannot be read.
+func GetListings(ethService *services.EthereumService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ids []string
+		for _, id := range strings.Split(c.Query("ids"), ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				ids = append(ids, id)
+			}
+		}
+		_, maxIDs := utils.PageLimits()
+		if len(ids) == 0 || len(ids) > maxIDs {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Expected 1 to %d comma separated listing IDs", maxIDs)})
+			return
+		}
+
+		listings, err := ethService.GetListings(c.Request.Context(), ids)
+		var lookupErr *services.ListingsError
+		if err != nil && !errors.As(err, &lookupErr) {
+			log.Printf("Error fetching listings: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch listings: " + err.Error()})
+			return
+		}
+
+		lookups := make([]ListingLookup, len(ids))
+		for i, id := range ids {
+			lookups[i].ID = id
+			if lookupErr != nil && lookupErr.Failed[i] != nil {
+				lookups[i].Error = lookupErr.Failed[i].Error()
+				continue
+			}
+			lookups[i].Listing = &listings[i]
+		}
+
+		utils.Write(c, http.StatusOK, gin.H{"data": lookups})
+	}
+}
+
+// GetListingHistory returns the marketplace history of the token given in the URL:
+// every listing, sale and cancellation ordered from oldest to newest.
+// It responds with a bad request error if the token ID is not a number and with an
+// internal server error if the events cannot be read from the chain.
+func GetListingHistory(ethService *services.EthereumService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenID := c.Param("id")
+		if err := utils.ValidateAmount(tokenID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+			return
+		}
+
+		history, err := ethService.GetListingHistory(c.Request.Context(), tokenID)
+		if err != nil {
+			log.Printf("Error fetching listing history: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch listing history: " + err.Error()})
+			return
+		}
+
+		utils.Write(c, http.StatusOK, gin.H{"data": history})
+	}
+}
+
+// GetContractOwner returns the owner of the marketplace contract, the account that
+// may update the commission, so that UIs can show admin controls to it. It responds
+// with a not found error if the contract has no owner and with an internal server
+// error if the owner cannot be read from the chain.
+func GetContractOwner(ethService *services.EthereumService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		owner, err := ethService.GetContractOwner(c.Request.Context())
+		if errors.Is(err, services.ErrNoContractOwner) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Contract has no owner"})
+			return
+		}
+		if err != nil {
+			log.Printf("Error fetching contract owner: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch contract owner"})
+			return
+		}
+
+		utils.Write(c, http.StatusOK, gin.H{"owner": owner.Hex()})
+	}
+}
+
+// GetCommission returns the marketplace's current commission and the maximum
+// commission allowed by the contract, both in basis points. When a "price" query
+// parameter in wei is given, the response also contains the commission charged on that
+// price and what the seller receives, see services.ComputeCommission.
+// It responds with a bad request error if the price is not an integer and with an
+// internal server error if the commission cannot be read from the chain.
+func GetCommission(ethService *services.EthereumService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		info, err := ethService.GetCommission(c.Request.Context())
+		if err != nil {
+			log.Printf("Error fetching commission: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch commission: " + err.Error()})
+			return
+		}
+
+		response := gin.H{
+			"commissionPercent": info.CommissionPercent.String(),
+			"maxCommission":     info.MaxCommission.String(),
+		}
+
+		if priceStr := c.Query("price"); priceStr != "" {
+			price, ok := new(big.Int).SetString(priceStr, 10)
+			if !ok || price.Sign() < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid price"})
+				return
+			}
+
+			fee, sellerProceeds := services.ComputeCommission(price, info.CommissionPercent)
+			response["price"] = price.String()
+			response["fee"] = fee.String()
+			response["sellerProceeds"] = sellerProceeds.String()
+		}
+
+		utils.Write(c, http.StatusOK, response)
+	}
+}
+
+// CancelListing cancels the listing given in the URL on behalf of the authenticated
+// user. It must run behind JwtAuthMiddleware.
+// It responds with a forbidden error if the user has no wallet address or does not own
+// the listing, with a conflict error if the listing is no longer active, and with an
+// internal server error if the transaction fails. On success it returns the transaction
+// hash with status code 200.
+func CancelListing(ethService *services.EthereumService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		caller, ok := utils.AuthenticatedAddress(c)
+		if !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "No wallet address associated with the user"})
+			return
+		}
+
+		txHash, err := ethService.CancelListing(c.Request.Context(), caller, c.Param("id"))
+		switch {
+		case errors.Is(err, services.ErrInvalidNumber):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, services.ErrNotListingOwner):
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only cancel your own listings"})
+			return
+		case errors.Is(err, services.ErrListingNotActive):
+			c.JSON(http.StatusConflict, gin.H{"error": "Listing is not active"})
+			return
+		case err != nil:
+			log.Printf("Error cancelling listing: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel listing: " + err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Listing cancelled successfully", "tx_hash": txHash.Hex()})
+	}
+}
+
+// CancelAllListings cancels every active listing of the authenticated user. It must
+// run behind JwtAuthMiddleware. Failing cancellations do not stop the others: the
+// response lists the outcome of each listing, with its transaction hash or error,
+// along with the number of listings cancelled and failed, and status code 200. It
+// responds with a forbidden error if the user has no wallet address and with an
+// internal server error if the listings cannot be read.
+func CancelAllListings(ethService *services.EthereumService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		caller, ok := utils.AuthenticatedAddress(c)
+		if !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "No wallet address associated with the user"})
+			return
+		}
+
+		results, err := ethService.CancelAllListings(c.Request.Context(), caller)
+		if err != nil {
+			log.Printf("Error cancelling listings of %s: %v", caller.Hex(), err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel listings"})
+			return
+		}
+
+		failed := 0
+		for _, result := range results {
+			if result.Error != "" {
+				log.Printf("Failed to cancel listing of token %s for %s: %s", result.TokenID, caller.Hex(), result.Error)
+				failed++
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": results, "cancelled": len(results) - failed, "failed": failed})
+	}
+}
+
+// RelayListing lists a token for a user without them paying gas, from a signed
+// listing intent (see services.ListingIntent). It must run behind
+// JwtAuthMiddleware, and the intent must be signed by the wallet the user
+// verified. The function expects a JSON request with the decimal strings
+// "token_id", "price" (in wei), "nonce" and "deadline" (Unix seconds) that were
+// signed and the hex "signature". It responds with the transaction hash and
+// status code 202, with a bad request error listing the invalid fields or if the
+// price is out of bounds, with a forbidden error if the user has no verified
+// wallet or the signature is not theirs and the token owner's, with a conflict
+// error if the intent is expired, used or its token is not held by the platform,
+// and with an internal server error if the transaction fails.
+func RelayListing(ethService *services.EthereumService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		caller, ok := utils.AuthenticatedAddress(c)
+		if !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "No wallet address associated with the user"})
+			return
+		}
+
+		var request struct {
+			TokenID   string `json:"token_id"`
+			Price     string `json:"price"`
+			Nonce     string `json:"nonce"`
+			Deadline  string `json:"deadline"`
+			Signature string `json:"signature"`
+		}
+
+		if err := utils.ParseJSON(c, &request); err != nil {
+			c.JSON(utils.ParseStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		var verr utils.ValidationError
+		number := func(field, value string) *big.Int {
+			n, ok := new(big.Int).SetString(value, 10)
+			if !ok || n.Sign() < 0 {
+				verr.Add(field, "must be a non-negative decimal integer")
+				return nil
+			}
+			return n
+		}
+		intent := services.ListingIntent{
+			TokenID:  number("token_id", request.TokenID),
+			Price:    number("price", request.Price),
+			Nonce:    number("nonce", request.Nonce),
+			Deadline: number("deadline", request.Deadline),
+		}
+		signature, err := hexutil.Decode(request.Signature)
+		if err != nil {
+			verr.Add("signature", "must be 0x-prefixed hex")
+		}
+		if verr.Err() != nil {
+			utils.WriteValidationError(c, &verr)
+			return
+		}
+
+		txHash, err := ethService.RelayListing(c.Request.Context(), caller, intent, signature)
+		switch {
+		case errors.Is(err, services.ErrInvalidNumber), errors.Is(err, services.ErrPriceTooHigh), errors.Is(err, services.ErrPriceTooLow):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, services.ErrInvalidSignature):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, services.ErrIntentExpired), errors.Is(err, services.ErrIntentUsed), errors.Is(err, services.ErrRelayUnsupported):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			log.Printf("Error relaying listing: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to relay listing"})
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{"message": "Listing submitted", "tx_hash": txHash.Hex()})
+	}
+}
+
+// GetFees returns fee suggestions, in wei per gas, for clients building their own
+// transactions: the base fee, the suggested priority fee and a recommended max fee
+// on EIP-1559 chains, or only a gas price on legacy chains (see
+// services.FeeSuggestion). It responds with an internal server error if the chain
+// cannot be read.
+func GetFees(ethService *services.EthereumService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		fees, err := ethService.SuggestFees(c.Request.Context())
+		if err != nil {
+			log.Printf("Error suggesting fees: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to suggest fees"})
+			return
+		}
+
+		utils.Write(c, http.StatusOK, fees)
+	}
+}
+
+// ResolveENS returns the address the ENS name in the path points to, for example
+// GET /ens/vitalik.eth. It responds with the name and its address and status code
+// 200, with a bad request error if the name is malformed, with a not found error
+// if the name is not registered or has no address, and with an internal server
+// error if the chain cannot be read.
+func ResolveENS(ethService *services.EthereumService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+
+		address, err := ethService.ResolveName(c.Request.Context(), name)
+		switch {
+		case errors.Is(err, services.ErrInvalidENSName):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, services.ErrENSNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			log.Printf("Error resolving ENS name %q: %v", name, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve ENS name"})
+			return
+		}
+
+		utils.Write(c, http.StatusOK, gin.H{"name": strings.ToLower(name), "address": address.Hex()})
+	}
+}
+
+// GetPinJob returns the pin job at the ":id" path parameter, as queued by
+// MintNFT, with its status ("pending", "pinning", "pinned" or "failed") and,
+// once pinned, its CID. It responds with status code 200, with a not found
+// error for unknown jobs and jobs finished over an hour ago, and with a
+// service unavailable error when no IPFS node is configured.
+func GetPinJob(ethService *services.EthereumService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ethService.PinQueue == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "IPFS pinning is not configured"})
+			return
+		}
+
+		job, ok := ethService.PinQueue.Status(c.Param("id"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pin job not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"pin": job})
+	}
+}
@@ -0,0 +1,387 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"nft-marketplace/blockchain/chaintest"
+	"nft-marketplace/db/dbtest"
+	"nft-marketplace/services"
+	"nft-marketplace/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetCommission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	node := chaintest.NewNode(t)
+	node.HandleCall("commissionPercent", chaintest.Outputs(big.NewInt(250)))
+	node.HandleCall("MAX_COMMISSION", chaintest.Outputs(big.NewInt(1000)))
+	ethService := &services.EthereumService{Client: node.Client, ContractAddress: chaintest.Contract}
+
+	router := gin.New()
+	router.GET("/commission", GetCommission(ethService))
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		want       map[string]string
+	}{
+		{
+			name:       "commission only",
+			wantStatus: http.StatusOK,
+			want:       map[string]string{"commissionPercent": "250", "maxCommission": "1000"},
+		},
+		{
+			name:       "fee in basis points",
+			query:      "?price=1000000000000000000",
+			wantStatus: http.StatusOK,
+			want: map[string]string{
+				"commissionPercent": "250",
+				"price":             "1000000000000000000",
+				"fee":               "25000000000000000",
+				"sellerProceeds":    "975000000000000000",
+			},
+		},
+		{
+			name:       "remainder goes to seller",
+			query:      "?price=999",
+			wantStatus: http.StatusOK,
+			want:       map[string]string{"fee": "24", "sellerProceeds": "975"},
+		},
+		{name: "negative price", query: "?price=-1", wantStatus: http.StatusBadRequest},
+		{name: "non-integer price", query: "?price=1.5", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/commission"+tt.query, nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			var got map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			for key, want := range tt.want {
+				if got[key] != want {
+					t.Errorf("%s = %#v, want %q", key, got[key], want)
+				}
+			}
+		})
+	}
+}
+
+// tokenNode is a fake node on which only token 42 exists.
+func tokenNode(t *testing.T) *services.EthereumService {
+	t.Helper()
+
+	erc721, err := abi.JSON(strings.NewReader(services.ERC721ABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := chaintest.NewNode(t)
+	node.AddABI(&erc721)
+	node.HandleCall("nftContract", chaintest.Outputs(common.HexToAddress("0x0000000000000000000000000000000000000721")))
+	node.HandleCall("ownerOf", func(args []any) ([]any, error) {
+		if args[0].(*big.Int).Int64() != 42 {
+			return nil, errors.New("execution reverted: ERC721: invalid token ID")
+		}
+		return []any{common.HexToAddress("0x00000000000000000000000000000000000000Aa")}, nil
+	})
+	return &services.EthereumService{Client: node.Client, ContractAddress: chaintest.Contract}
+}
+
+// expectTokenStats expects the stats of token 42 to be read.
+func expectTokenStats(mock sqlmock.Sqlmock, views, favorites int64) {
+	mock.ExpectQuery(`SELECT views FROM token_stats WHERE token_id = \$1`).WithArgs("42").
+		WillReturnRows(sqlmock.NewRows([]string{"views"}).AddRow(views))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "token_favorites" WHERE token_id = \$1`).WithArgs("42").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(favorites))
+}
+
+func TestCountTokenView(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ethService := tokenNode(t)
+
+	tests := []struct {
+		name         string
+		token        string
+		forwardedFor string
+		// counted is whether the view is not a repeat; nil expects the
+		// token to be rejected before counting.
+		counted    *bool
+		wantStatus int
+	}{
+		{name: "first view", token: "42", counted: ptr(true), wantStatus: http.StatusOK},
+		{name: "repeated view", token: "42", counted: ptr(false), wantStatus: http.StatusOK},
+		// The router trusts no proxy, so the view is the peer's however
+		// X-Forwarded-For is set.
+		{name: "spoofed forwarded for", token: "42", forwardedFor: "192.0.2.1", counted: ptr(false), wantStatus: http.StatusOK},
+		{name: "unknown token", token: "7", wantStatus: http.StatusNotFound},
+		{name: "invalid token ID", token: "abc", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := dbtest.Mock(t)
+			views := int64(3)
+			if tt.counted != nil {
+				affected := int64(0)
+				if *tt.counted {
+					affected, views = 1, 4
+				}
+				mock.ExpectBegin()
+				mock.ExpectExec(`INSERT INTO token_views`).WithArgs("ip:198.51.100.7", "42", sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, affected))
+				if *tt.counted {
+					mock.ExpectExec(`INSERT INTO token_stats`).WithArgs("42").WillReturnResult(sqlmock.NewResult(0, 1))
+				}
+				mock.ExpectCommit()
+				expectTokenStats(mock, views, 1)
+			}
+
+			r := gin.New()
+			if err := r.SetTrustedProxies(nil); err != nil {
+				t.Fatal(err)
+			}
+			r.POST("/nfts/:id/views", CountTokenView(ethService, services.NewEngagement(time.Minute)))
+			req := httptest.NewRequest(http.MethodPost, "/nfts/"+tt.token+"/views", nil)
+			req.RemoteAddr = "198.51.100.7:4000"
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.counted == nil {
+				return
+			}
+			var got ViewCount
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Counted != *tt.counted || got.Views != views || got.Favorites != 1 {
+				t.Fatalf("got %+v, want counted %t with %d views and 1 favorite", got, *tt.counted, views)
+			}
+		})
+	}
+}
+
+func TestToggleFavorite(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ethService := tokenNode(t)
+
+	tests := []struct {
+		name        string
+		token       string
+		wasFavorite bool
+		wantStatus  int
+	}{
+		{name: "favorite", token: "42", wantStatus: http.StatusOK},
+		{name: "unfavorite", token: "42", wasFavorite: true, wantStatus: http.StatusOK},
+		{name: "unknown token", token: "7", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := dbtest.Mock(t)
+			favorites := int64(1)
+			if tt.wantStatus == http.StatusOK {
+				deleted := int64(0)
+				if tt.wasFavorite {
+					deleted, favorites = 1, 0
+				}
+				mock.ExpectBegin()
+				mock.ExpectExec(`DELETE FROM "token_favorites"`).WithArgs(7, "42").WillReturnResult(sqlmock.NewResult(0, deleted))
+				if !tt.wasFavorite {
+					mock.ExpectExec(`INSERT INTO "token_favorites"`).WillReturnResult(sqlmock.NewResult(0, 1))
+				}
+				mock.ExpectCommit()
+				expectTokenStats(mock, 0, favorites)
+			}
+
+			r := gin.New()
+			authenticate := func(c *gin.Context) { c.Set(utils.UserIDKey, uint(7)) }
+			r.POST("/nfts/:id/favorite", authenticate, ToggleFavorite(ethService, services.NewEngagement(time.Minute)))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/nfts/"+tt.token+"/favorite", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var got FavoriteToggle
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Favorited == tt.wasFavorite || got.Favorites != favorites {
+				t.Fatalf("got %+v, want favorited %t with %d favorites", got, !tt.wasFavorite, favorites)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestRelayListingRequiresVerifiedWallet(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	node := chaintest.NewNode(t)
+	ethService := &services.EthereumService{Client: node.Client, ContractAddress: chaintest.Contract}
+
+	tests := []struct {
+		name       string
+		wallet     common.Address // verified wallet of the user, zero for none
+		body       string
+		wantStatus int
+	}{
+		{name: "no verified wallet", body: `{"token_id":"7","price":"1","nonce":"1","deadline":"1","signature":"0x00"}`, wantStatus: http.StatusForbidden},
+		{name: "invalid fields", wallet: common.HexToAddress("0x00000000000000000000000000000000000000Aa"), body: `{"token_id":"x","price":"1","nonce":"1","deadline":"1","signature":"zz"}`, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			authenticate := func(c *gin.Context) {
+				c.Set(utils.UserIDKey, uint(7))
+				if tt.wallet != (common.Address{}) {
+					c.Set(utils.UserAddressKey, tt.wallet)
+				}
+			}
+			r.POST("/listings/relay", authenticate, RelayListing(ethService))
+			req := httptest.NewRequest(http.MethodPost, "/listings/relay", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if node.Count("eth_chainId") != 0 {
+				t.Fatal("rejected relay reached the chain")
+			}
+		})
+	}
+}
+
+func TestGetNFTsInvalidBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/nfts", GetNFTs(&services.EthereumService{ContractAddress: chaintest.Contract}))
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+	}{
+		{name: "not JSON", contentType: "text/plain", body: `{"accounts":"0x0"}`, wantStatus: http.StatusUnsupportedMediaType},
+		{name: "malformed", contentType: "application/json", body: `{"accounts":`, wantStatus: http.StatusBadRequest},
+		{name: "missing account", contentType: "application/json", body: `{}`, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/nfts", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
+
+func TestSearchNFTs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mock := dbtest.Mock(t)
+	mock.ExpectQuery(`SELECT \* FROM "nfts" WHERE name LIKE \$1`).WithArgs("%ape%").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "ape 1").AddRow(2, "ape 2").AddRow(3, "ape 3"))
+
+	r := gin.New()
+	r.GET("/Search", SearchNFTs(&services.EthereumService{}))
+	req := httptest.NewRequest(http.MethodGet, "/Search?limit=2", strings.NewReader(`{"name":"ape"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	// The envelope is the one the degraded fallback answers in.
+	var got struct {
+		Data       []json.RawMessage `json:"data"`
+		Degraded   bool              `json:"degraded"`
+		Pagination utils.Pagination  `json:"pagination"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := utils.Pagination{Total: 3, Limit: 2, HasNext: true}
+	if len(got.Data) != 2 || got.Degraded || got.Pagination != want {
+		t.Fatalf("got %d NFTs, degraded %v, %+v, want 2 NFTs and %+v", len(got.Data), got.Degraded, got.Pagination, want)
+	}
+}
+
+func TestSearchNFTsDegraded(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// No database is set up, so the search fails and falls back to the
+	// chain, which has no listings.
+	node := chaintest.NewNode(t)
+	node.Handle("eth_getBlockByNumber", chaintest.Returns(&types.Header{Number: big.NewInt(10), Difficulty: big.NewInt(0)}))
+	node.Handle("eth_getLogs", chaintest.Returns([]types.Log{}))
+	ethService := &services.EthereumService{Client: node.Client, ContractAddress: chaintest.Contract, DegradedReads: true}
+
+	tests := []struct {
+		name       string
+		query      string
+		body       string
+		wantStatus int
+	}{
+		{name: "first page", query: "?limit=5", body: `{"name":""}`, wantStatus: http.StatusOK},
+		{name: "invalid limit", query: "?limit=-1", body: `{}`, wantStatus: http.StatusBadRequest},
+		// The chain cannot filter by name, and every listing is not a match.
+		{name: "search by name", query: "?limit=5", body: `{"name":"ape"}`, wantStatus: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/Search", SearchNFTs(ethService))
+			req := httptest.NewRequest(http.MethodGet, "/Search"+tt.query, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var got struct {
+				Degraded   bool             `json:"degraded"`
+				Pagination utils.Pagination `json:"pagination"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !got.Degraded || got.Pagination != (utils.Pagination{Limit: 5}) {
+				t.Fatalf("got %+v, want a degraded empty page of 5", got)
+			}
+		})
+	}
+}
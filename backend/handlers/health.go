@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/http"
+	"nft-marketplace/breaker"
+	"nft-marketplace/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Health reports whether the service can reach its dependencies. The status is
+// "degraded" while the RPC circuit breaker is not closed, that is while blockchain
+// calls are failing fast. It always responds with 200 OK, since endpoints served
+// from the database keep working meanwhile.
+func Health() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		state := utils.RPCBreakerState()
+
+		status := "ok"
+		if state != breaker.Closed {
+			status = "degraded"
+		}
+
+		c.JSON(http.StatusOK, gin.H{"status": status, "rpc_breaker": state.String()})
+	}
+}
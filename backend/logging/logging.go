@@ -0,0 +1,160 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Level is the severity of a log message.
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+const redacted = "[REDACTED]"
+
+var (
+	level atomic.Int32
+
+	secretsMu sync.RWMutex
+	secrets   []string
+
+	// jwtRegexp matches anything shaped like a JWT, whether or not it was
+	// registered as a secret.
+	jwtRegexp = regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+)
+
+func init() {
+	level.Store(int32(LevelInfo))
+}
+
+// ParseLevel parses "debug", "info", "warn" or "error", case-insensitively.
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return LevelDebug, nil
+	case "", "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return LevelInfo, fmt.Errorf("unknown log level: %q", s)
+	}
+}
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	default:
+		return "ERROR"
+	}
+}
+
+// SetLevel sets the minimum level of the messages that get logged.
+func SetLevel(l Level) {
+	level.Store(int32(l))
+}
+
+// Configure sets the level parsed from level and registers secrets, so every
+// later message is redacted, including those logged with the standard logger
+// directly (see SetOutput). An invalid level is reported and leaves the level
+// at info.
+func Configure(level string, secrets ...string) error {
+	for _, secret := range secrets {
+		RegisterSecret(secret)
+	}
+	if _, ok := log.Writer().(redactingWriter); !ok {
+		SetOutput(log.Writer())
+	}
+
+	l, err := ParseLevel(level)
+	SetLevel(l)
+	return err
+}
+
+// SetOutput sets where log messages are written. Messages go through the
+// standard logger, whose output is set to a writer redacting them, so that
+// the log.Printf calls that do not use this package are redacted too. They
+// have no level and are always logged.
+func SetOutput(w io.Writer) {
+	log.SetOutput(redactingWriter{out: w})
+}
+
+// redactingWriter writes to out what the standard logger writes to it, with
+// the secrets redacted.
+type redactingWriter struct {
+	out io.Writer
+}
+
+func (w redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.out, Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// RegisterSecret makes every later log message replace secret, and its
+// 0x-prefixed form, with [REDACTED]. Register private keys, passwords and API
+// secrets as soon as they are loaded.
+func RegisterSecret(secret string) {
+	secret = strings.TrimPrefix(strings.TrimSpace(secret), "0x")
+	if len(secret) < 4 {
+		return
+	}
+
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	secrets = append(secrets, secret)
+}
+
+// Redact replaces the registered secrets, the given extra secrets and
+// anything looking like a JWT in s with [REDACTED].
+func Redact(s string, extra ...string) string {
+	secretsMu.RLock()
+	all := append(append([]string(nil), secrets...), extra...)
+	secretsMu.RUnlock()
+
+	for _, secret := range all {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, redacted)
+		}
+	}
+
+	return jwtRegexp.ReplaceAllString(s, redacted)
+}
+
+func logf(l Level, format string, args ...any) {
+	if l < Level(level.Load()) {
+		return
+	}
+
+	_ = log.Output(3, l.String()+" "+Redact(fmt.Sprintf(format, args...)))
+}
+
+// Debugf logs a debug message.
+func Debugf(format string, args ...any) { logf(LevelDebug, format, args...) }
+
+// Infof logs an informational message.
+func Infof(format string, args ...any) { logf(LevelInfo, format, args...) }
+
+// Warnf logs a warning.
+func Warnf(format string, args ...any) { logf(LevelWarn, format, args...) }
+
+// Errorf logs an error.
+func Errorf(format string, args ...any) { logf(LevelError, format, args...) }
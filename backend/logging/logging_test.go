@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestStandardLoggerRedacted(t *testing.T) {
+	const secret = "5ecret-api-key"
+	jwt := "eyJhbGciOiJIUzI1NiJ9.eyJpZCI6MX0.c2lnbmF0dXJl"
+
+	previous := log.Writer()
+	t.Cleanup(func() { log.SetOutput(previous) })
+	if err := Configure("info", secret); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	SetOutput(&buf)
+
+	tests := []struct {
+		name    string
+		log     func()
+		want    string
+		wantNot []string
+	}{
+		{
+			name:    "log.Printf",
+			log:     func() { log.Printf("connecting with key %s", secret) },
+			want:    "connecting with key [REDACTED]",
+			wantNot: []string{secret},
+		},
+		{
+			name:    "log.Printf with 0x prefix and token",
+			log:     func() { log.Printf("key 0x%s, token %s", secret, jwt) },
+			want:    "key 0x[REDACTED], token [REDACTED]",
+			wantNot: []string{secret, jwt},
+		},
+		{
+			name: "Warnf",
+			log:  func() { Warnf("bad key %s", secret) },
+			want: "WARN bad key [REDACTED]",
+		},
+		{
+			name:    "Debugf below level",
+			log:     func() { Debugf("debug %s", secret) },
+			wantNot: []string{"debug"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			tt.log()
+			got := buf.String()
+			if !strings.Contains(got, tt.want) {
+				t.Fatalf("logged %q, want it to contain %q", got, tt.want)
+			}
+			for _, s := range tt.wantNot {
+				if strings.Contains(got, s) {
+					t.Fatalf("logged %q, want no %q", got, s)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefBuckets are the default histogram buckets, in seconds, suited to request
+// latencies.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Counter is a monotonically increasing value exported at /metrics.
+type Counter struct {
+	name  string
+	help  string
+	value atomic.Uint64
+}
+
+// Gauge is a value that can go up and down, exported at /metrics.
+type Gauge struct {
+	name  string
+	help  string
+	value atomic.Int64
+}
+
+// Histogram counts observations into cumulative buckets, along with their sum
+// and count.
+type Histogram struct {
+	buckets []float64
+	counts  []atomic.Uint64
+	count   atomic.Uint64
+	sum     atomic.Uint64 // float64 bits
+}
+
+// CounterVec is a family of counters told apart by the values of its labels,
+// exported at /metrics under one name.
+type CounterVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]*Counter
+}
+
+// HistogramVec is a family of histograms told apart by the values of its
+// labels, exported at /metrics under one name.
+type HistogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	values map[string]*Histogram
+}
+
+var (
+	mu            sync.Mutex
+	counters      = make(map[string]*Counter)
+	gauges        = make(map[string]*Gauge)
+	counterVecs   = make(map[string]*CounterVec)
+	histogramVecs = make(map[string]*HistogramVec)
+)
+
+// NewCounter returns the counter registered under name, registering it if
+// needed. name must be a valid Prometheus metric name.
+func NewCounter(name, help string) *Counter {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if c, ok := counters[name]; ok {
+		return c
+	}
+
+	c := &Counter{name: name, help: help}
+	counters[name] = c
+	return c
+}
+
+// Inc increments the counter by one.
+func (c *Counter) Inc() { c.value.Add(1) }
+
+// Add increments the counter by n.
+func (c *Counter) Add(n uint64) { c.value.Add(n) }
+
+// Value returns the current value of the counter.
+func (c *Counter) Value() uint64 { return c.value.Load() }
+
+// NewGauge returns the gauge registered under name, registering it if needed.
+// name must be a valid Prometheus metric name.
+func NewGauge(name, help string) *Gauge {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if g, ok := gauges[name]; ok {
+		return g
+	}
+
+	g := &Gauge{name: name, help: help}
+	gauges[name] = g
+	return g
+}
+
+// Set sets the gauge to v.
+func (g *Gauge) Set(v int64) { g.value.Store(v) }
+
+// Value returns the current value of the gauge.
+func (g *Gauge) Value() int64 { return g.value.Load() }
+
+// NewCounterVec returns the counter family registered under name, registering
+// it with the given label names if needed. name and labels must be valid
+// Prometheus names.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if v, ok := counterVecs[name]; ok {
+		return v
+	}
+
+	v := &CounterVec{name: name, help: help, labels: labels, values: make(map[string]*Counter)}
+	counterVecs[name] = v
+	return v
+}
+
+// With returns the counter of the family with the given label values, in the
+// order of the family's label names.
+func (v *CounterVec) With(values ...string) *Counter {
+	key := labelPairs(v.labels, values)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	c, ok := v.values[key]
+	if !ok {
+		c = &Counter{}
+		v.values[key] = c
+	}
+	return c
+}
+
+// NewHistogramVec returns the histogram family registered under name,
+// registering it with the given buckets, DefBuckets when nil, and label names
+// if needed. name and labels must be valid Prometheus names.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if v, ok := histogramVecs[name]; ok {
+		return v
+	}
+
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	v := &HistogramVec{name: name, help: help, labels: labels, buckets: buckets, values: make(map[string]*Histogram)}
+	histogramVecs[name] = v
+	return v
+}
+
+// With returns the histogram of the family with the given label values, in
+// the order of the family's label names.
+func (v *HistogramVec) With(values ...string) *Histogram {
+	key := labelPairs(v.labels, values)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	h, ok := v.values[key]
+	if !ok {
+		h = &Histogram{buckets: v.buckets, counts: make([]atomic.Uint64, len(v.buckets))}
+		v.values[key] = h
+	}
+	return h
+}
+
+// Observe adds value to the histogram.
+func (h *Histogram) Observe(value float64) {
+	for i, upper := range h.buckets {
+		if value <= upper {
+			h.counts[i].Add(1)
+		}
+	}
+	h.count.Add(1)
+	for {
+		old := h.sum.Load()
+		if h.sum.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+value)) {
+			return
+		}
+	}
+}
+
+// Count returns the number of observations.
+func (h *Histogram) Count() uint64 { return h.count.Load() }
+
+// labelPairs renders labels and values as the body of a Prometheus label set,
+// such as `method="GET",route="/nfts"`. Missing values are empty.
+func labelPairs(labels, values []string) string {
+	pairs := make([]string, len(labels))
+	for i, label := range labels {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs[i] = label + `="` + escapeLabel(value) + `"`
+	}
+	return strings.Join(pairs, ",")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+// withLabel appends the label pair extra to the label set body labels.
+func withLabel(labels, extra string) string {
+	if labels == "" {
+		return extra
+	}
+	return labels + "," + extra
+}
+
+// metric is a registered metric, rendered in the text format.
+type metric struct {
+	name, help, kind string
+	samples          []string
+}
+
+// Handler serves every registered metric in the Prometheus text format.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		all := make([]metric, 0, len(counters)+len(gauges)+len(counterVecs)+len(histogramVecs))
+		for _, c := range counters {
+			all = append(all, metric{c.name, c.help, "counter", []string{fmt.Sprintf("%s %d", c.name, c.Value())}})
+		}
+		for _, g := range gauges {
+			all = append(all, metric{g.name, g.help, "gauge", []string{fmt.Sprintf("%s %d", g.name, g.Value())}})
+		}
+		for _, v := range counterVecs {
+			all = append(all, v.metric())
+		}
+		for _, v := range histogramVecs {
+			all = append(all, v.metric())
+		}
+		mu.Unlock()
+		sort.Slice(all, func(i, j int) bool { return all[i].name < all[j].name })
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		for _, m := range all {
+			fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+			for _, sample := range m.samples {
+				fmt.Fprintln(w, sample)
+			}
+		}
+	})
+}
+
+func (v *CounterVec) metric() metric {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	keys := sortedKeys(v.values)
+	samples := make([]string, 0, len(keys))
+	for _, key := range keys {
+		samples = append(samples, fmt.Sprintf("%s{%s} %d", v.name, key, v.values[key].Value()))
+	}
+	return metric{v.name, v.help, "counter", samples}
+}
+
+func (v *HistogramVec) metric() metric {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	keys := sortedKeys(v.values)
+	samples := make([]string, 0, len(keys)*(len(v.buckets)+3))
+	for _, key := range keys {
+		h := v.values[key]
+		for i, upper := range h.buckets {
+			le := `le="` + strconv.FormatFloat(upper, 'g', -1, 64) + `"`
+			samples = append(samples, fmt.Sprintf("%s_bucket{%s} %d", v.name, withLabel(key, le), h.counts[i].Load()))
+		}
+		samples = append(samples,
+			fmt.Sprintf("%s_bucket{%s} %d", v.name, withLabel(key, `le="+Inf"`), h.Count()),
+			fmt.Sprintf("%s_sum{%s} %s", v.name, key, strconv.FormatFloat(math.Float64frombits(h.sum.Load()), 'g', -1, 64)),
+			fmt.Sprintf("%s_count{%s} %d", v.name, key, h.Count()),
+		)
+	}
+	return metric{v.name, v.help, "histogram", samples}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
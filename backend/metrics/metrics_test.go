@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// scrape returns the metrics Handler serves.
+func scrape(t *testing.T) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Fatalf("Content-Type = %q, want the text format", ct)
+	}
+	return rec.Body.String()
+}
+
+func TestHandler(t *testing.T) {
+	counter := NewCounter("test_handler_counter_total", "A test counter.")
+	counter.Add(2)
+	counter.Inc()
+	NewGauge("test_handler_gauge", "A test gauge.").Set(-4)
+	requests := NewCounterVec("test_handler_requests_total", "Test requests.", "method", "route")
+	requests.With("GET", "/nfts").Inc()
+	requests.With("POST", `/say "hi"`).Add(2)
+	latency := NewHistogramVec("test_handler_seconds", "Test latency.", []float64{1, 0.1}, "route")
+	latency.With("/nfts").Observe(0.05)
+	latency.With("/nfts").Observe(0.5)
+	latency.With("/nfts").Observe(3)
+
+	body := scrape(t)
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "counter help", want: "# HELP test_handler_counter_total A test counter.\n# TYPE test_handler_counter_total counter\n"},
+		{name: "counter", want: "test_handler_counter_total 3\n"},
+		{name: "gauge", want: "# TYPE test_handler_gauge gauge\ntest_handler_gauge -4\n"},
+		{name: "labelled counter", want: `test_handler_requests_total{method="GET",route="/nfts"} 1` + "\n"},
+		{name: "escaped label", want: `test_handler_requests_total{method="POST",route="/say \"hi\""} 2` + "\n"},
+		{name: "sorted buckets", want: `test_handler_seconds_bucket{route="/nfts",le="0.1"} 1` + "\n" + `test_handler_seconds_bucket{route="/nfts",le="1"} 2` + "\n"},
+		{name: "overflow bucket", want: `test_handler_seconds_bucket{route="/nfts",le="+Inf"} 3` + "\n"},
+		{name: "sum", want: `test_handler_seconds_sum{route="/nfts"} 3.55` + "\n"},
+		{name: "count", want: `test_handler_seconds_count{route="/nfts"} 3` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(body, tt.want) {
+				t.Fatalf("metrics missing %q:\n%s", tt.want, body)
+			}
+		})
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	tests := []struct {
+		name string
+		same func() bool
+	}{
+		{name: "counter", same: func() bool {
+			return NewCounter("test_twice_total", "") == NewCounter("test_twice_total", "")
+		}},
+		{name: "gauge", same: func() bool {
+			return NewGauge("test_twice_gauge", "") == NewGauge("test_twice_gauge", "")
+		}},
+		{name: "counter family", same: func() bool {
+			return NewCounterVec("test_twice_vec_total", "", "a") == NewCounterVec("test_twice_vec_total", "", "a")
+		}},
+		{name: "counter of a family", same: func() bool {
+			v := NewCounterVec("test_twice_with_total", "", "a")
+			return v.With("x") == v.With("x")
+		}},
+		{name: "histogram family", same: func() bool {
+			return NewHistogramVec("test_twice_seconds", "", nil, "a") == NewHistogramVec("test_twice_seconds", "", nil, "a")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.same() {
+				t.Fatal("registering the same name twice returned another metric")
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"errors"
+	"math"
+	"net/http"
+	"nft-marketplace/clock"
+	"nft-marketplace/logging"
+	"nft-marketplace/services"
+	"nft-marketplace/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const AdminTokenHeader = "X-Admin-Token"
+
+// AdminAuthMiddleware only lets through requests whose X-Admin-Token header
+// matches the configured admin token. An empty admin token disables the admin
+// endpoints altogether.
+func AdminAuthMiddleware(adminToken string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.GetHeader(AdminTokenHeader)
+		if adminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": ErrorUnauthorized})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// MintingEnabled rejects requests with 503 Service Unavailable while minting is
+// paused, before any blockchain call is made.
+func MintingEnabled(sw *services.MintingSwitch) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if sw.Paused() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Minting is temporarily paused"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// MintAllowlisted rejects mints by callers missing from the allowlist, before
+// any blockchain call is made. While the allowlist is enabled the request must
+// carry a valid token: it responds with 401 Unauthorized without one, and with
+// 403 Forbidden when the user has no verified wallet or it is not allowlisted.
+// Only the wallet the user proved control of is checked, see
+// utils.AuthenticatedAddress, so that registering with an allowlisted address
+// is not enough. A disabled allowlist lets every request through.
+func MintAllowlisted(list *services.MintAllowlist) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !list.Enabled() {
+			c.Next()
+			return
+		}
+
+		if err := utils.SetAuthenticatedUser(c); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"Unauthorized": "Authentication required"})
+			logging.Debugf("Authentication failed: %v", err)
+			c.Abort()
+			return
+		}
+
+		caller, ok := utils.AuthenticatedAddress(c)
+		if !ok || !list.Allowed(caller) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Minting is restricted to approved creators"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// MintQuotaLimited rejects mints by callers who already minted their daily
+// quota, before any blockchain call is made, with 429 Too Many Requests, a
+// Retry-After header and the time the quota resets at. Callers are counted by
+// user ID. The request must carry a valid token while the quota is enabled: it
+// responds with 401 Unauthorized without one. Mints rejected with a 4xx
+// status, which never reach the chain, are not counted. A disabled quota lets
+// every request through.
+func MintQuotaLimited(quota *services.MintQuota) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !quota.Enabled() {
+			c.Next()
+			return
+		}
+
+		if err := utils.SetAuthenticatedUser(c); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"Unauthorized": "Authentication required"})
+			logging.Debugf("Authentication failed: %v", err)
+			c.Abort()
+			return
+		}
+		userID, _ := utils.AuthenticatedUserID(c)
+
+		day, reset, err := quota.Take(userID)
+		if errors.Is(err, services.ErrMintQuotaExceeded) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(reset.Sub(clock.OrReal(quota.Clock).Now()).Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Daily mint quota exceeded", "reset_at": reset})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			logging.Warnf("Failed to check mint quota of user %d: %v", userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check mint quota"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+
+		if status := c.Writer.Status(); status >= 400 && status < 500 {
+			if err := quota.Release(userID, day); err != nil {
+				logging.Warnf("Failed to release mint quota of user %d: %v", userID, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"nft-marketplace/clock"
+	"nft-marketplace/db/dbtest"
+	"nft-marketplace/services"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const testSecret = "test-secret"
+
+// bearer returns an Authorization header value with a token carrying claims.
+// Tokens without a JTI skip the revocation check.
+func bearer(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	t.Setenv("API_SECRET", testSecret)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "Bearer " + token
+}
+
+// serve runs a request with the given Authorization header through handlers
+// and returns the response.
+func serve(t *testing.T, authorization string, handlers ...gin.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/", append(handlers, func(c *gin.Context) { c.Status(http.StatusOK) })...)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestMintingEnabled(t *testing.T) {
+	mock := dbtest.Mock(t)
+	mock.ExpectQuery(`SELECT \* FROM "settings"`).WithArgs("minting_paused", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"key", "value"}).AddRow("minting_paused", "true"))
+	sw := services.NewMintingSwitch(false)
+
+	tests := []struct {
+		name   string
+		paused *bool // state to switch to first, nil keeping the stored one
+		want   int
+	}{
+		{name: "stored pause", want: http.StatusServiceUnavailable},
+		{name: "resumed", paused: ptr(false), want: http.StatusOK},
+		{name: "paused", paused: ptr(true), want: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.paused != nil {
+				mock.ExpectBegin()
+				mock.ExpectExec(`INSERT INTO "settings"`).WithArgs("minting_paused", strconv.FormatBool(*tt.paused), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				if err := sw.SetPaused(*tt.paused); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := serve(t, "", MintingEnabled(sw)).Code; got != tt.want {
+				t.Fatalf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMintAllowlisted(t *testing.T) {
+	const allowlisted = "0x00000000000000000000000000000000000000Aa"
+
+	mock := dbtest.Mock(t)
+	mock.ExpectQuery(`SELECT \* FROM "mint_allowlist"`).
+		WillReturnRows(sqlmock.NewRows([]string{"address", "created_at"}).AddRow(allowlisted, time.Now()))
+	list := services.NewMintAllowlist(true)
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		want   int
+	}{
+		{name: "verified allowlisted wallet", claims: jwt.MapClaims{"id": 1, "wallet": allowlisted}, want: http.StatusOK},
+		{name: "verified other wallet", claims: jwt.MapClaims{"id": 1, "wallet": "0x00000000000000000000000000000000000000bb"}, want: http.StatusForbidden},
+		// An allowlisted address the user merely registered with is not
+		// trusted.
+		{name: "unverified allowlisted address", claims: jwt.MapClaims{"id": 1, "user_address": allowlisted}, want: http.StatusForbidden},
+		{name: "no token", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authorization := ""
+			if tt.claims != nil {
+				authorization = bearer(t, tt.claims)
+			}
+			if got := serve(t, authorization, MintAllowlisted(list)).Code; got != tt.want {
+				t.Fatalf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMintQuotaLimited(t *testing.T) {
+	now := time.Date(2026, 10, 14, 15, 0, 0, 0, time.UTC)
+	day := time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		claims  jwt.MapClaims
+		counted bool
+		status  int // status of the mint handler
+		want    int
+		release bool
+	}{
+		{name: "within quota", claims: jwt.MapClaims{"id": 7}, counted: true, status: http.StatusOK, want: http.StatusOK},
+		// The count is keyed on the user, whatever wallet the token names.
+		{name: "quota used with another wallet", claims: jwt.MapClaims{"id": 7, "wallet": "0x00000000000000000000000000000000000000bb"}, want: http.StatusTooManyRequests},
+		{name: "rejected mint released", claims: jwt.MapClaims{"id": 7}, counted: true, status: http.StatusBadRequest, want: http.StatusBadRequest, release: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := dbtest.Mock(t)
+			insert := mock.ExpectQuery(`INSERT INTO mint_quotas \(user_id, day, count\)`).WithArgs(7, day, 1)
+			if tt.counted {
+				insert.WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+			} else {
+				insert.WillReturnRows(sqlmock.NewRows([]string{"count"}))
+			}
+			if tt.release {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "mint_quotas" SET "count"=count - 1 WHERE user_id = \$1 AND day = \$2 AND count > 0`).
+					WithArgs(7, day).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			quota := services.NewMintQuota(1)
+			quota.Clock = clock.NewFake(now)
+			w := serve(t, bearer(t, tt.claims), MintQuotaLimited(quota), func(c *gin.Context) {
+				c.AbortWithStatus(tt.status)
+			})
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			if tt.want == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "32400" {
+				t.Fatalf("Retry-After = %q, want 32400", w.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
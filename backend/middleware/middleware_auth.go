@@ -4,8 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"nft-marketplace/logging"
 	"nft-marketplace/utils"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
@@ -24,7 +26,12 @@ const (
 	ErrorInsufficientFunds = "Insufficient funds"
 )
 
+func init() {
+	jwt.TimeFunc = func() time.Time { return utils.Clock.Now() }
+}
+
 // parseToken parses the given token string and returns the underlying claims.
+// Revoked tokens are rejected, see utils.CheckRevoked.
 func parseToken(tokenStr string) (jwt.MapClaims, error) {
 	if err := godotenv.Load(); err != nil {
 		return nil, errors.New("error loading .env file")
@@ -44,19 +51,23 @@ func parseToken(tokenStr string) (jwt.MapClaims, error) {
 	if !ok {
 		return nil, errors.New("invalid claims")
 	}
+	if err := utils.CheckRevoked(claims); err != nil {
+		return nil, err
+	}
 
 	return claims, nil
 }
 
 // JwtAuthMiddleware is a middleware function that validates the JWT token in the
 // Authorization header. If the token is invalid or missing, it responds with a 401
-// Unauthorized response. Otherwise, it calls the next handler in the chain.
+// Unauthorized response. Otherwise, it stores the authenticated user in the context
+// (see utils.AuthenticatedAddress) and calls the next handler in the chain.
 func JwtAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		err := utils.ValidateToken(c)
+		err := utils.SetAuthenticatedUser(c)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"Unauthorized": "Authentication required"})
-			fmt.Println(err)
+			logging.Debugf("Authentication failed: %v", err)
 			c.Abort()
 			return
 		}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"nft-marketplace/logging"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultBodyLogLimit = 2048
+
+// sensitiveFieldRegexp matches JSON members whose name mentions a password,
+// token, secret or private key, along with their value. The closing quote of
+// a string value is optional so that values cut off by the size cap are
+// redacted too.
+var sensitiveFieldRegexp = regexp.MustCompile(`(?i)("[^"]*(?:password|token|secret|private_?key)[^"]*"\s*:\s*)("(?:[^"\\]|\\.)*"?|[^,}\]\s]+)`)
+
+// LogBodies logs the request and response bodies of every request along with
+// its method, route and status, for debugging clients. It is meant to be
+// enabled temporarily: bodies are cut to limit bytes (2048 when limit is not
+// positive), members named like passwords, tokens, secrets and private keys
+// are redacted, and so is everything logging.Redact recognises. The request
+// body still reaches the handler in full.
+func LogBodies(limit int) gin.HandlerFunc {
+	if limit <= 0 {
+		limit = defaultBodyLogLimit
+	}
+
+	return func(c *gin.Context) {
+		var request []byte
+		if c.Request.Body != nil {
+			head, err := io.ReadAll(io.LimitReader(c.Request.Body, int64(limit)+1))
+			if err != nil {
+				logging.Warnf("Failed to read request body for logging: %v", err)
+			}
+			request = head
+			c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(head), c.Request.Body), c.Request.Body}
+		}
+
+		writer := &bodyLogWriter{ResponseWriter: c.Writer, limit: limit}
+		c.Writer = writer
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		logging.Infof("%s %s -> %d: request=%s response=%s", c.Request.Method, route, writer.Status(),
+			formatBody(request, limit), formatBody(writer.body.Bytes(), limit))
+	}
+}
+
+// formatBody redacts body and cuts it to limit bytes, marking the cut.
+func formatBody(body []byte, limit int) string {
+	if len(body) == 0 {
+		return "-"
+	}
+
+	truncated := len(body) > limit
+	if truncated {
+		body = body[:limit]
+	}
+	s := sensitiveFieldRegexp.ReplaceAllString(string(body), `$1"[REDACTED]"`)
+	s = logging.Redact(s)
+	if truncated {
+		s += "...(truncated)"
+	}
+	return s
+}
+
+// readCloser reads from Reader and closes Closer.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// bodyLogWriter keeps the first limit+1 bytes written to the response, enough
+// to tell whether it was truncated.
+type bodyLogWriter struct {
+	gin.ResponseWriter
+	limit int
+	body  bytes.Buffer
+}
+
+func (w *bodyLogWriter) capture(b []byte) {
+	if room := w.limit + 1 - w.body.Len(); room > 0 {
+		w.body.Write(b[:min(len(b), room)])
+	}
+}
+
+func (w *bodyLogWriter) Write(b []byte) (int, error) {
+	w.capture(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyLogWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
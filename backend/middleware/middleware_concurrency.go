@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// concurrencyRetryAfter is the Retry-After, in seconds, of rejected requests.
+const concurrencyRetryAfter = "1"
+
+// LimitConcurrency lets at most limit requests through the rest of the chain
+// at once, so that a traffic spike cannot exhaust the node and database
+// connections. A non-positive limit disables it.
+//
+// A request beyond the limit waits up to queueTimeout for another to complete;
+// with a non-positive queueTimeout it does not wait at all. Requests that get
+// no slot in time, or whose client goes away while waiting, are answered with
+// 503 Service Unavailable and a Retry-After header.
+func LimitConcurrency(limit int, queueTimeout time.Duration) gin.HandlerFunc {
+	if limit <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	slots := make(chan struct{}, limit)
+	return func(c *gin.Context) {
+		select {
+		case slots <- struct{}{}:
+		default:
+			if !acquire(c, slots, queueTimeout) {
+				c.Header("Retry-After", concurrencyRetryAfter)
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Too many requests in progress, try again later"})
+				c.Abort()
+				return
+			}
+		}
+		defer func() { <-slots }()
+
+		c.Next()
+	}
+}
+
+// acquire waits up to timeout for a slot, and reports whether it got one.
+func acquire(c *gin.Context, slots chan struct{}, timeout time.Duration) bool {
+	if timeout <= 0 {
+		return false
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-c.Request.Context().Done():
+		return false
+	}
+}
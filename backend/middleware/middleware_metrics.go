@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"nft-marketplace/metrics"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unmatchedRoute labels requests no route matched, so that arbitrary paths do
+// not each get their own series.
+const unmatchedRoute = "unmatched"
+
+var (
+	requestsTotal = metrics.NewCounterVec("http_requests_total",
+		"HTTP requests served, by method, route and status code.", "method", "route", "status")
+	requestDuration = metrics.NewHistogramVec("http_request_duration_seconds",
+		"Time taken to serve HTTP requests, by method, route and status class.", nil, "method", "route", "status")
+)
+
+// Metrics records the number of requests and their latency per route at
+// /metrics.
+//
+// Routes are labelled with their pattern, such as /nfts/:id, rather than the
+// requested path, which keeps the number of series bounded. Requests are
+// counted per status code and their durations bucketed per status class
+// (2xx, 4xx, ...).
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		status := c.Writer.Status()
+		method := c.Request.Method
+
+		requestsTotal.With(method, route, strconv.Itoa(status)).Inc()
+		requestDuration.With(method, route, strconv.Itoa(status/100)+"xx").Observe(time.Since(start).Seconds())
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"math/big"
 	"net/http"
 	"nft-marketplace/services"
+	"nft-marketplace/utils"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -34,14 +35,14 @@ func GetNFTs(ethService *services.EthereumService) gin.HandlerFunc {
 			return
 		}
 
-		userAddress, ok := claims["user_address"].(string)
-		if !ok || !common.IsHexAddress(userAddress) {
+		userAddress, ok := utils.WalletClaim(claims)
+		if !ok {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or missing user address"})
 			c.Abort()
 			return
 		}
 
-		nfts, err := ethService.GetNFTs(common.HexToAddress(userAddress))
+		nfts, err := ethService.GetListingsBySeller(c.Request.Context(), userAddress)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch NFTs: " + err.Error()})
 			return
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"nft-marketplace/clock"
+	"nft-marketplace/utils"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type rateWindow struct {
+	count int
+	reset time.Time
+}
+
+// RateLimiter lets each client make at most Limit requests per Window through
+// the rest of the chain. A non-positive Limit or Window disables it.
+//
+// Windows are fixed: a client's first request starts one, and its count is
+// reset once it elapses. Requests beyond the limit are answered with 429 Too
+// Many Requests and a Retry-After header telling when the window ends. Counts
+// are kept in memory, so each process limits on its own.
+type RateLimiter struct {
+	Limit  int
+	Window time.Duration
+	// Key identifies the client a request is counted against. Nil means the
+	// client IP, which is only the real one behind the proxies the router
+	// trusts, see gin.Engine.SetTrustedProxies. Requests it returns an
+	// empty key for are not limited.
+	Key func(c *gin.Context) string
+	// Clock measures the windows. Nil means the wall clock.
+	Clock clock.Clock
+}
+
+// RateLimit lets each client IP make at most limit requests per window through
+// the rest of the chain, so that endpoints such as login cannot be brute
+// forced. See RateLimiter.
+func RateLimit(limit int, window time.Duration) gin.HandlerFunc {
+	return (&RateLimiter{Limit: limit, Window: window}).Handler()
+}
+
+// UserKey is a RateLimiter Key counting requests by authenticated user, for
+// routes behind JwtAuthMiddleware. Anonymous requests are not limited.
+func UserKey(c *gin.Context) string {
+	id, ok := utils.AuthenticatedUserID(c)
+	if !ok {
+		return ""
+	}
+	return "user:" + strconv.FormatUint(uint64(id), 10)
+}
+
+// Handler returns the middleware enforcing the limit.
+func (l *RateLimiter) Handler() gin.HandlerFunc {
+	if l.Limit <= 0 || l.Window <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	key := l.Key
+	if key == nil {
+		key = (*gin.Context).ClientIP
+	}
+	var (
+		mu      sync.Mutex
+		windows = make(map[string]*rateWindow)
+		pruned  time.Time
+	)
+	return func(c *gin.Context) {
+		client := key(c)
+		if client == "" {
+			c.Next()
+			return
+		}
+		now := clock.OrReal(l.Clock).Now()
+
+		mu.Lock()
+		// Forget elapsed windows at most once per window, so that clients
+		// that went away do not pile up.
+		if now.Sub(pruned) >= l.Window {
+			for k, w := range windows {
+				if !now.Before(w.reset) {
+					delete(windows, k)
+				}
+			}
+			pruned = now
+		}
+		w, ok := windows[client]
+		if !ok || !now.Before(w.reset) {
+			w = &rateWindow{reset: now.Add(l.Window)}
+			windows[client] = w
+		}
+		w.count++
+		allowed, reset := w.count <= l.Limit, w.reset
+		mu.Unlock()
+
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(reset.Sub(now).Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests, try again later"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
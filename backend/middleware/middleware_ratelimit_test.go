@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"nft-marketplace/clock"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestRateLimiter(t *testing.T) {
+	type request struct {
+		// advance moves the clock before the request.
+		advance        time.Duration
+		remoteAddr     string
+		forwardedFor   string
+		wantStatus     int
+		wantRetryAfter string
+	}
+	const client, other = "198.51.100.7:4000", "203.0.113.9:4000"
+
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		requests       []request
+	}{
+		{
+			name: "limited per window",
+			requests: []request{
+				{remoteAddr: client, wantStatus: http.StatusOK},
+				{remoteAddr: client, wantStatus: http.StatusOK},
+				{remoteAddr: client, wantStatus: http.StatusTooManyRequests, wantRetryAfter: "60"},
+				{advance: 45 * time.Second, remoteAddr: client, wantStatus: http.StatusTooManyRequests, wantRetryAfter: "15"},
+				{advance: 15 * time.Second, remoteAddr: client, wantStatus: http.StatusOK},
+			},
+		},
+		{
+			name: "clients limited separately",
+			requests: []request{
+				{remoteAddr: client, wantStatus: http.StatusOK},
+				{remoteAddr: client, wantStatus: http.StatusOK},
+				{remoteAddr: other, wantStatus: http.StatusOK},
+				{remoteAddr: client, wantStatus: http.StatusTooManyRequests, wantRetryAfter: "60"},
+			},
+		},
+		{
+			// Without trusted proxies a spoofed X-Forwarded-For does not
+			// make a new client.
+			name: "forwarded for ignored from untrusted peers",
+			requests: []request{
+				{remoteAddr: client, forwardedFor: "192.0.2.1", wantStatus: http.StatusOK},
+				{remoteAddr: client, forwardedFor: "192.0.2.2", wantStatus: http.StatusOK},
+				{remoteAddr: client, forwardedFor: "192.0.2.3", wantStatus: http.StatusTooManyRequests, wantRetryAfter: "60"},
+			},
+		},
+		{
+			name:           "forwarded for used behind a trusted proxy",
+			trustedProxies: []string{"198.51.100.0/24"},
+			requests: []request{
+				{remoteAddr: client, forwardedFor: "192.0.2.1", wantStatus: http.StatusOK},
+				{remoteAddr: client, forwardedFor: "192.0.2.1", wantStatus: http.StatusOK},
+				{remoteAddr: client, forwardedFor: "192.0.2.2", wantStatus: http.StatusOK},
+				{remoteAddr: client, forwardedFor: "192.0.2.1", wantStatus: http.StatusTooManyRequests, wantRetryAfter: "60"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			fake := clock.NewFake(time.Unix(1_700_000_000, 0))
+			r := gin.New()
+			if err := r.SetTrustedProxies(tt.trustedProxies); err != nil {
+				t.Fatal(err)
+			}
+			limiter := &RateLimiter{Limit: 2, Window: time.Minute, Clock: fake}
+			r.POST("/", limiter.Handler(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			for i, req := range tt.requests {
+				fake.Advance(req.advance)
+				httpReq := httptest.NewRequest(http.MethodPost, "/", nil)
+				httpReq.RemoteAddr = req.remoteAddr
+				if req.forwardedFor != "" {
+					httpReq.Header.Set("X-Forwarded-For", req.forwardedFor)
+				}
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, httpReq)
+
+				if w.Code != req.wantStatus || w.Header().Get("Retry-After") != req.wantRetryAfter {
+					t.Fatalf("request %d: %d with Retry-After %q, want %d with %q",
+						i, w.Code, w.Header().Get("Retry-After"), req.wantStatus, req.wantRetryAfter)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterKey(t *testing.T) {
+	fake := clock.NewFake(time.Unix(1_700_000_000, 0))
+	limiter := &RateLimiter{
+		Limit:  1,
+		Window: time.Minute,
+		Key:    func(c *gin.Context) string { return c.GetHeader("X-User") },
+		Clock:  fake,
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/", limiter.Handler(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		user       string
+		wantStatus int
+	}{
+		{user: "alice", wantStatus: http.StatusOK},
+		{user: "alice", wantStatus: http.StatusTooManyRequests},
+		{user: "bob", wantStatus: http.StatusOK},
+		// Requests without a key are not limited.
+		{user: "", wantStatus: http.StatusOK},
+		{user: "", wantStatus: http.StatusOK},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("X-User", tt.user)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.wantStatus {
+			t.Fatalf("request %d by %q: status = %d, want %d", i, tt.user, w.Code, tt.wantStatus)
+		}
+	}
+}
+
+func TestUserKey(t *testing.T) {
+	limiter := &RateLimiter{Limit: 1, Window: time.Minute, Key: UserKey, Clock: clock.NewFake(time.Unix(1_700_000_000, 0))}
+	handler := limiter.Handler()
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		want   int
+	}{
+		{name: "first request", claims: jwt.MapClaims{"id": 7}, want: http.StatusOK},
+		// Users are counted whatever wallet their token names.
+		{name: "same user, other wallet", claims: jwt.MapClaims{"id": 7, "wallet": "0x00000000000000000000000000000000000000bb"}, want: http.StatusTooManyRequests},
+		{name: "other user", claims: jwt.MapClaims{"id": 8}, want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(t, bearer(t, tt.claims), JwtAuthMiddleware(), handler).Code; got != tt.want {
+				t.Fatalf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"nft-marketplace/utils"
+	"runtime/debug"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// Recover turns a panic in next into a 500 Internal Server Error JSON response
+// instead of crashing the server.
+//
+// The panic value and stack are logged with the request ID, taken from the
+// X-Request-ID header or generated when missing and echoed back in the
+// response. The client only gets a generic error message. If next already
+// started writing its response, the connection is left as is since the status
+// cannot be changed anymore. http.ErrAbortHandler is re-panicked so that the
+// server aborts the response as intended.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if requestID == "" {
+			requestID = newRequestID()
+			r.Header.Set(RequestIDHeader, requestID)
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		rw := &recoverWriter{ResponseWriter: w}
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if err, ok := p.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(p)
+			}
+
+			log.Printf("Panic serving %s %s (request %s): %v\n%s", r.Method, r.URL.Path, requestID, p, debug.Stack())
+			if rw.wroteHeader {
+				return
+			}
+			utils.WriteError(w, http.StatusInternalServerError, "Internal server error")
+		}()
+
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// recoverWriter records whether the response was started.
+type recoverWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *recoverWriter) WriteHeader(status int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recoverWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush lets streaming handlers flush through the wrapper.
+func (w *recoverWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wroteHeader = true
+		flusher.Flush()
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecover(t *testing.T) {
+	tests := []struct {
+		name      string
+		requestID string
+		handler   http.HandlerFunc
+		wantCode  int
+		wantBody  string
+	}{
+		{
+			name:     "nil pointer",
+			handler:  func(http.ResponseWriter, *http.Request) { var m map[string]*int; _ = *m["missing"] },
+			wantCode: http.StatusInternalServerError,
+			wantBody: `{"error":"Internal server error"}`,
+		},
+		{
+			name:      "request ID echoed",
+			requestID: "req-42",
+			handler:   func(http.ResponseWriter, *http.Request) { panic("type assertion failed") },
+			wantCode:  http.StatusInternalServerError,
+			wantBody:  `{"error":"Internal server error"}`,
+		},
+		{
+			// The status is already sent, so the response is left as is.
+			name: "after writing",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				_, _ = w.Write([]byte("partial"))
+				panic("late failure")
+			},
+			wantCode: http.StatusAccepted,
+			wantBody: "partial",
+		},
+		{
+			name:     "no panic",
+			handler:  func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write([]byte("ok")) },
+			wantCode: http.StatusOK,
+			wantBody: "ok",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/nfts", nil)
+			if tt.requestID != "" {
+				req.Header.Set(RequestIDHeader, tt.requestID)
+			}
+			w := httptest.NewRecorder()
+			Recover(tt.handler).ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantCode)
+			}
+			if got := strings.TrimSpace(w.Body.String()); got != tt.wantBody {
+				t.Fatalf("body = %q, want %q", got, tt.wantBody)
+			}
+			id := w.Header().Get(RequestIDHeader)
+			if id == "" || (tt.requestID != "" && id != tt.requestID) {
+				t.Fatalf("request ID = %q, want %q or a generated one", id, tt.requestID)
+			}
+		})
+	}
+}
+
+func TestRecoverRepanicsAbort(t *testing.T) {
+	defer func() {
+		p := recover()
+		if err, ok := p.(error); !ok || !errors.Is(err, http.ErrAbortHandler) {
+			t.Fatalf("recovered %v, want %v", p, http.ErrAbortHandler)
+		}
+	}()
+
+	handler := Recover(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic(http.ErrAbortHandler) }))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	t.Fatal("ServeHTTP returned, want it to panic")
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"nft-marketplace/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VerifySignedURL only lets through requests whose URL was signed with
+// utils.SignURL and has not expired. Tampered, unsigned and expired links are
+// rejected with 403 Forbidden.
+func VerifySignedURL() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := utils.VerifySignedURL(c.Request.URL); err != nil {
+			message := "Invalid link"
+			if errors.Is(err, utils.ErrSignedURLExpired) {
+				message = "Link expired"
+			}
+			c.JSON(http.StatusForbidden, gin.H{"error": message})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
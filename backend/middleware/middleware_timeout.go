@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"nft-marketplace/utils"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Timeout bounds the time the rest of the chain has to answer a request to d,
+// like http.TimeoutHandler. A non-positive d disables it.
+//
+// The request context is given a deadline of d, so handlers that pass it on to
+// the services have their RPC calls and queries cancelled when it expires.
+// Handlers run with a buffered response writer; once the deadline passes the
+// client gets a 503 Service Unavailable JSON error straight away and whatever
+// the handler writes afterwards is discarded. Timeout still waits for the
+// handler to return before releasing the request, as gin reuses its context.
+// Panics are re-raised on the serving goroutine for Recover to handle.
+func Timeout(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if d <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		original := c.Writer
+		tw := &timeoutWriter{ResponseWriter: original, header: original.Header().Clone()}
+		c.Writer = tw
+
+		done := make(chan struct{})
+		var panicked any
+		go func() {
+			defer close(done)
+			defer func() { panicked = recover() }()
+			c.Next()
+		}()
+
+		timedOut := false
+		select {
+		case <-done:
+		case <-ctx.Done():
+			tw.mu.Lock()
+			timedOut = errors.Is(ctx.Err(), context.DeadlineExceeded)
+			tw.timedOut = timedOut
+			tw.mu.Unlock()
+
+			if timedOut {
+				utils.WriteError(original, http.StatusServiceUnavailable, "Request timed out")
+				original.Flush()
+			}
+			<-done
+		}
+
+		c.Writer = original
+		if panicked != nil {
+			panic(panicked)
+		}
+		if !timedOut {
+			tw.writeTo(original)
+		}
+	}
+}
+
+// timeoutWriter buffers a response until the handler returns, and drops it
+// once the request timed out.
+type timeoutWriter struct {
+	gin.ResponseWriter
+	mu       sync.Mutex
+	header   http.Header
+	body     bytes.Buffer
+	status   int
+	timedOut bool
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(status int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timedOut || w.status != 0 {
+		return
+	}
+	w.status = status
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	w.WriteHeader(http.StatusOK)
+}
+
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(data)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *timeoutWriter) Status() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *timeoutWriter) Size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.status == 0 {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *timeoutWriter) Written() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.status != 0
+}
+
+// Flush is a no-op while the response is buffered: streaming handlers are
+// not meant to run behind Timeout.
+func (w *timeoutWriter) Flush() {}
+
+// writeTo sends the buffered response to dst.
+func (w *timeoutWriter) writeTo(dst gin.ResponseWriter) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for key, values := range w.header {
+		dst.Header()[key] = values
+	}
+	if w.status != 0 {
+		dst.WriteHeader(w.status)
+	}
+	if w.body.Len() > 0 {
+		_, _ = dst.Write(w.body.Bytes())
+	}
+}
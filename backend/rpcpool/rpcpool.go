@@ -0,0 +1,115 @@
+// Package rpcpool bounds how many RPC calls are in flight at once. Batch
+// features hand their calls to a shared Pool instead of spawning a goroutine
+// per call, so that a large batch, or many batches at once, cannot flood the
+// node.
+package rpcpool
+
+import (
+	"context"
+	"sync"
+)
+
+const defaultLimit = 8
+
+// Task is a call run by a Pool. It should stop early once ctx is done.
+type Task[T any] func(ctx context.Context) (T, error)
+
+// Pool runs tasks with at most Limit of them in flight at once, across every
+// concurrent call to Do and DoAll. It is safe for concurrent use.
+type Pool struct {
+	slots chan struct{}
+}
+
+// New returns a pool running up to limit tasks at once, eight when limit is
+// not positive.
+func New(limit int) *Pool {
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	return &Pool{slots: make(chan struct{}, limit)}
+}
+
+// Limit returns how many tasks the pool runs at once.
+func (p *Pool) Limit() int {
+	return cap(p.slots)
+}
+
+// Do runs tasks on p and returns their results in the order of tasks. The
+// first task to fail cancels the context of the others and its error is
+// returned, once every started task has returned; tasks not started by then
+// are skipped. Do returns ctx's error when ctx is done before every task got
+// to run.
+func Do[T any](ctx context.Context, p *Pool, tasks []Task[T]) ([]T, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		once     sync.Once
+		firstErr error
+	)
+	results, _ := run(ctx, p, tasks, func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	})
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return results, nil
+}
+
+// DoAll runs every task on p and returns their results and errors, both in
+// the order of tasks, so that one failure does not hide the others. Tasks that
+// could not start because ctx was done get ctx's error.
+func DoAll[T any](ctx context.Context, p *Pool, tasks []Task[T]) ([]T, []error) {
+	return run(ctx, p, tasks, func(error) {})
+}
+
+// run starts each task once p has a free slot, calling failed with the error
+// of each task that fails, and waits for them all.
+func run[T any](ctx context.Context, p *Pool, tasks []Task[T], failed func(error)) ([]T, []error) {
+	results := make([]T, len(tasks))
+	errs := make([]error, len(tasks))
+
+	var wg sync.WaitGroup
+	for i, task := range tasks {
+		if err := p.acquire(ctx); err != nil {
+			for j := i; j < len(tasks); j++ {
+				errs[j] = err
+			}
+			failed(err)
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer p.release()
+
+			results[i], errs[i] = task(ctx)
+			if errs[i] != nil {
+				failed(errs[i])
+			}
+		}()
+	}
+	wg.Wait()
+	return results, errs
+}
+
+// acquire waits for a free slot, unless ctx is done first.
+func (p *Pool) acquire(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) release() {
+	<-p.slots
+}
@@ -0,0 +1,147 @@
+package rpcpool
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// counting returns a task returning value, or err when set, that tracks how
+// many tasks are in flight in inFlight and the most seen at once in peak.
+func counting(inFlight, peak *atomic.Int32, value int, err error) Task[int] {
+	return func(ctx context.Context) (int, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			old := peak.Load()
+			if n <= old || peak.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		return value, err
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		limit int
+		want  int
+	}{
+		{limit: 3, want: 3},
+		{limit: 0, want: defaultLimit},
+		{limit: -1, want: defaultLimit},
+	}
+	for _, tt := range tests {
+		if got := New(tt.limit).Limit(); got != tt.want {
+			t.Errorf("New(%d).Limit() = %d, want %d", tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestDo(t *testing.T) {
+	failure := errors.New("execution reverted")
+
+	tests := []struct {
+		name    string
+		limit   int
+		tasks   int
+		failAt  int // index of the failing task, or -1
+		want    []int
+		wantErr error
+	}{
+		{name: "results in order", limit: 2, tasks: 6, failAt: -1, want: []int{0, 1, 2, 3, 4, 5}},
+		{name: "one at a time", limit: 1, tasks: 3, failAt: -1, want: []int{0, 1, 2}},
+		{name: "no tasks", limit: 2, failAt: -1, want: []int{}},
+		{name: "first failure returned", limit: 2, tasks: 6, failAt: 1, wantErr: failure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var inFlight, peak atomic.Int32
+			tasks := make([]Task[int], tt.tasks)
+			for i := range tasks {
+				var err error
+				if i == tt.failAt {
+					err = failure
+				}
+				tasks[i] = counting(&inFlight, &peak, i, err)
+			}
+
+			got, err := Do(context.Background(), New(tt.limit), tasks)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Do() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Do() = %v, want %v", got, tt.want)
+			}
+			if p := int(peak.Load()); p > tt.limit {
+				t.Fatalf("%d tasks in flight, want at most %d", p, tt.limit)
+			}
+		})
+	}
+}
+
+func TestDoCancelsOthers(t *testing.T) {
+	failure := errors.New("execution reverted")
+	// The first task only returns once the failure of the second cancels it.
+	tasks := []Task[int]{
+		func(ctx context.Context) (int, error) {
+			<-ctx.Done()
+			return 0, ctx.Err()
+		},
+		func(ctx context.Context) (int, error) {
+			return 0, failure
+		},
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := Do(context.Background(), New(2), tasks)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, failure) {
+			t.Fatalf("Do() error = %v, want %v", err, failure)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the failure did not cancel the other task")
+	}
+}
+
+func TestDoAll(t *testing.T) {
+	failure := errors.New("execution reverted")
+	var inFlight, peak atomic.Int32
+	tasks := []Task[int]{
+		counting(&inFlight, &peak, 0, nil),
+		counting(&inFlight, &peak, 0, failure),
+		counting(&inFlight, &peak, 2, nil),
+	}
+
+	got, errs := DoAll(context.Background(), New(2), tasks)
+	if want := []int{0, 0, 2}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("DoAll() = %v, want %v", got, want)
+	}
+	if want := []error{nil, failure, nil}; !reflect.DeepEqual(errs, want) {
+		t.Fatalf("DoAll() errors = %v, want %v", errs, want)
+	}
+}
+
+func TestDoAllCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var inFlight, peak atomic.Int32
+	_, errs := DoAll(ctx, New(2), []Task[int]{counting(&inFlight, &peak, 0, nil), counting(&inFlight, &peak, 1, nil)})
+	for i, err := range errs {
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("task %d error = %v, want %v", i, err, context.Canceled)
+		}
+	}
+	if peak.Load() != 0 {
+		t.Fatal("a task ran after the context was done")
+	}
+}
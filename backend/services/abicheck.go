@@ -0,0 +1,95 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	marketplace "nft-marketplace/blockchain"
+	"os"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// marketplaceABIPath is the ABI file read by the methods that do not use the
+// generated binding.
+const marketplaceABIPath = "./blockchain/Marketplace.json"
+
+// ErrABIMismatch is returned by VerifyABICompatibility when the marketplace ABI
+// does not describe the contract deployed at ContractAddress.
+var ErrABIMismatch = errors.New("marketplace ABI does not match the deployed contract")
+
+// abiProbes are views every marketplace deployment answers without arguments.
+var abiProbes = []string{"commissionPercent", "MAX_COMMISSION"}
+
+// VerifyABICompatibility checks that the marketplace ABIs the service decodes
+// with, the generated binding's and the one in blockchain/Marketplace.json,
+// match the contract deployed at ContractAddress, so that a misconfiguration
+// fails loudly instead of as decoding errors later on.
+//
+// Each ABI must declare the commissionPercent and MAX_COMMISSION views, and
+// calling them on the contract must return data that decodes with it.
+// Mismatches fail with ErrABIMismatch, naming the ABI and the view; addresses
+// without code fail with ErrNoContractCode.
+func (es *EthereumService) VerifyABICompatibility(ctx context.Context) error {
+	if err := es.ready(); err != nil {
+		return err
+	}
+
+	code, err := es.backend().CodeAt(ctx, es.ContractAddress, nil)
+	if err != nil {
+		return fmt.Errorf("failed to check contract code: %w", err)
+	}
+	if len(code) == 0 {
+		return fmt.Errorf("%w: %s", ErrNoContractCode, es.ContractAddress.Hex())
+	}
+
+	bindingABI, err := marketplace.MarketplaceMetaData.GetAbi()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrABIParse, err)
+	}
+	if err := es.verifyABI(ctx, "generated binding", *bindingABI); err != nil {
+		return err
+	}
+
+	contractABI, err := os.ReadFile(marketplaceABIPath)
+	if err != nil {
+		return fmt.Errorf("failed to read contract ABI: %w", err)
+	}
+	fileABI, err := abi.JSON(bytes.NewReader(contractABI))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrABIParse, err)
+	}
+	return es.verifyABI(ctx, marketplaceABIPath, fileABI)
+}
+
+// verifyABI calls the probe views as described by parsedABI, reporting
+// mismatches under name.
+func (es *EthereumService) verifyABI(ctx context.Context, name string, parsedABI abi.ABI) error {
+	to := es.ContractAddress
+	for _, view := range abiProbes {
+		method, ok := parsedABI.Methods[view]
+		if !ok {
+			return fmt.Errorf("%w: %s has no %s method", ErrABIMismatch, name, view)
+		}
+		if len(method.Inputs) != 0 {
+			return fmt.Errorf("%w: %s declares %s with arguments", ErrABIMismatch, name, view)
+		}
+
+		out, err := es.backend().CallContract(ctx, ethereum.CallMsg{To: &to, Data: method.ID}, nil)
+		if err != nil {
+			if isRevert(err) {
+				return fmt.Errorf("%w: %s reverted when called as described by %s", ErrABIMismatch, view, name)
+			}
+			return fmt.Errorf("failed to call %s: %w", view, err)
+		}
+		if len(out) == 0 {
+			return fmt.Errorf("%w: %s returned no data when called as described by %s", ErrABIMismatch, view, name)
+		}
+		if _, err := method.Outputs.Unpack(out); err != nil {
+			return fmt.Errorf("%w: %s does not decode with %s: %w", ErrABIMismatch, view, name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"nft-marketplace/db"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// MintAllowlist restricts minting to approved creators. The addresses are kept
+// in memory for the mint handlers and persisted in the mint_allowlist table so
+// that they survive restarts. A disabled allowlist lets everyone mint.
+type MintAllowlist struct {
+	enabled bool
+
+	mu        sync.RWMutex
+	addresses map[common.Address]struct{}
+}
+
+// NewMintAllowlist returns the allowlist, enabled or not, loaded from the
+// database. When the addresses cannot be read, nobody is allowlisted until
+// some are added again.
+func NewMintAllowlist(enabled bool) *MintAllowlist {
+	list := &MintAllowlist{enabled: enabled, addresses: make(map[common.Address]struct{})}
+
+	entries, err := db.GetMintAllowlist()
+	if err != nil {
+		log.Printf("Failed to load mint allowlist: %v", err)
+		return list
+	}
+	for _, entry := range entries {
+		if !common.IsHexAddress(entry.Address) {
+			log.Printf("Skipping invalid address %q in mint allowlist", entry.Address)
+			continue
+		}
+		list.addresses[common.HexToAddress(entry.Address)] = struct{}{}
+	}
+
+	return list
+}
+
+// Enabled reports whether minting is restricted to the allowlist.
+func (l *MintAllowlist) Enabled() bool {
+	return l.enabled
+}
+
+// Allowed reports whether address may mint: always while the allowlist is
+// disabled, otherwise only when it is allowlisted.
+func (l *MintAllowlist) Allowed(address common.Address) bool {
+	if !l.enabled {
+		return true
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	_, ok := l.addresses[address]
+	return ok
+}
+
+// Addresses returns the allowlisted addresses, sorted.
+func (l *MintAllowlist) Addresses() []common.Address {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	addresses := make([]common.Address, 0, len(l.addresses))
+	for address := range l.addresses {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return bytes.Compare(addresses[i][:], addresses[j][:]) < 0
+	})
+	return addresses
+}
+
+// Add persists address in the allowlist and then applies it.
+func (l *MintAllowlist) Add(address common.Address) error {
+	if err := db.AddToMintAllowlist(address.Hex()); err != nil {
+		return fmt.Errorf("failed to persist mint allowlist entry: %w", err)
+	}
+
+	l.mu.Lock()
+	l.addresses[address] = struct{}{}
+	l.mu.Unlock()
+	log.Printf("Added %s to the mint allowlist", address.Hex())
+	return nil
+}
+
+// Remove removes address from the allowlist, persisted first. The boolean is
+// false when it was not allowlisted.
+func (l *MintAllowlist) Remove(address common.Address) (bool, error) {
+	removed, err := db.RemoveFromMintAllowlist(address.Hex())
+	if err != nil {
+		return false, fmt.Errorf("failed to remove mint allowlist entry: %w", err)
+	}
+
+	l.mu.Lock()
+	_, listed := l.addresses[address]
+	delete(l.addresses, address)
+	l.mu.Unlock()
+	if removed || listed {
+		log.Printf("Removed %s from the mint allowlist", address.Hex())
+	}
+	return removed || listed, nil
+}
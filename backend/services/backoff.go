@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"nft-marketplace/clock"
+	"time"
+)
+
+// backoffDelay returns how long to wait before retrying after the given failed
+// attempt, the first being 1: min, doubled after each attempt up to max.
+func backoffDelay(min, max time.Duration, attempt int) time.Duration {
+	if attempt <= 1 {
+		return min
+	}
+	delay := min << (attempt - 1)
+	if attempt > 62 || delay <= 0 || delay > max {
+		return max
+	}
+	return delay
+}
+
+// backoff counts the failed attempts of a retry loop and returns the delays
+// backoffDelay gives for them. The zero value is ready to use once min and max
+// are set.
+type backoff struct {
+	min, max time.Duration
+	attempt  int
+}
+
+// next counts a failed attempt and returns how long to wait before the next.
+func (b *backoff) next() time.Duration {
+	b.attempt++
+	return backoffDelay(b.min, b.max, b.attempt)
+}
+
+// reset starts counting attempts again, after progress was made.
+func (b *backoff) reset() {
+	b.attempt = 0
+}
+
+// sleep waits for d on c, nil meaning the wall clock. It returns the cause of
+// ctx if ctx is cancelled first.
+func sleep(ctx context.Context, c clock.Clock, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-clock.OrReal(c).After(d):
+		return nil
+	}
+}
+
+// throttle spaces out the calls that wait lets through, for loops that must
+// not make more than a given number of calls per second.
+type throttle struct {
+	clock    clock.Clock
+	interval time.Duration
+	next     time.Time
+}
+
+// newThrottle returns a throttle letting perSecond calls through per second,
+// measured on c, nil meaning the wall clock. A non-positive perSecond returns
+// nil, which does not throttle.
+func newThrottle(c clock.Clock, perSecond int) *throttle {
+	if perSecond <= 0 {
+		return nil
+	}
+	return &throttle{clock: clock.OrReal(c), interval: time.Second / time.Duration(perSecond)}
+}
+
+// wait returns once a call is allowed, at once for the first one. It returns
+// the cause of ctx if ctx is cancelled first.
+func (t *throttle) wait(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	now := t.clock.Now()
+	if delay := t.next.Sub(now); delay > 0 {
+		if err := sleep(ctx, t.clock, delay); err != nil {
+			return err
+		}
+		now = t.next
+	}
+	t.next = now.Add(t.interval)
+	return nil
+}
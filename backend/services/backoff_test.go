@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"nft-marketplace/clock"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		attempt int
+		want    time.Duration
+	}{
+		{name: "first attempt", attempt: 1, want: time.Second},
+		{name: "doubled", attempt: 3, want: 4 * time.Second},
+		{name: "capped", attempt: 6, want: 30 * time.Second},
+		{name: "overflowing shift", attempt: 80, want: 30 * time.Second},
+		{name: "no attempt yet", attempt: 0, want: time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := backoffDelay(time.Second, 30*time.Second, tt.attempt); got != tt.want {
+				t.Fatalf("backoffDelay(%d) = %s, want %s", tt.attempt, got, tt.want)
+			}
+		})
+	}
+
+	retry := backoff{min: time.Second, max: 30 * time.Second}
+	retry.next()
+	retry.next()
+	retry.reset()
+	if got := retry.next(); got != time.Second {
+		t.Fatalf("delay after reset = %s, want %s", got, time.Second)
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	tests := []struct {
+		name      string
+		perSecond int
+		// elapsed is how long after the first call the second is made.
+		elapsed  time.Duration
+		wantWait bool
+	}{
+		{name: "second call too soon", perSecond: 5, elapsed: 100 * time.Millisecond, wantWait: true},
+		{name: "second call on time", perSecond: 5, elapsed: 200 * time.Millisecond},
+		{name: "disabled", perSecond: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := clock.NewFake(time.Unix(1_700_000_000, 0))
+			throttle := newThrottle(fake, tt.perSecond)
+			if err := throttle.wait(context.Background()); err != nil {
+				t.Fatalf("first call waited: %v", err)
+			}
+			fake.Advance(tt.elapsed)
+
+			// A cancelled context makes any wait fail at once, telling
+			// whether the call had to wait.
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			err := throttle.wait(ctx)
+			if waited := errors.Is(err, context.Canceled); waited != tt.wantWait {
+				t.Fatalf("waited = %t, want %t", waited, tt.wantWait)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nft-marketplace/rpcpool"
+	"nft-marketplace/utils"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrListingNotFound is reported for listing IDs the marketplace never
+// assigned.
+var ErrListingNotFound = errors.New("listing not found")
+
+// ListingsError reports the IDs GetListings could not resolve. Failed maps
+// their positions in the request to why: ErrInvalidNumber or
+// ErrListingNotFound.
+type ListingsError struct {
+	Failed map[int]error
+}
+
+func (e *ListingsError) Error() string {
+	positions := make([]int, 0, len(e.Failed))
+	for i := range e.Failed {
+		positions = append(positions, i)
+	}
+	sort.Ints(positions)
+
+	messages := make([]string, 0, len(positions))
+	for _, i := range positions {
+		messages = append(messages, e.Failed[i].Error())
+	}
+	return fmt.Sprintf("%d listings not resolved: %s", len(positions), strings.Join(messages, "; "))
+}
+
+// Unwrap returns the errors of every failed ID, so that errors.Is finds
+// ErrListingNotFound and ErrInvalidNumber.
+func (e *ListingsError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failed))
+	for _, err := range e.Failed {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// GetListings returns the listings with the given IDs, in the order of ids,
+// so that a cart can be resolved in one call rather than a listings(id) call
+// per item.
+//
+// Every listing is read as of the same block, the latest one when the call
+// starts, the reads running on RPCPool. IDs that are not numbers
+// or that no listing has leave a zero NFTListing in their place and are
+// reported in a *ListingsError, returned along with the listings that were
+// found. Any other error fails the whole call.
+func (es *EthereumService) GetListings(ctx context.Context, ids []string) ([]NFTListing, error) {
+	if len(ids) == 0 {
+		return []NFTListing{}, nil
+	}
+
+	contract, err := es.marketplaceContract()
+	if err != nil {
+		return nil, err
+	}
+	header, err := es.Client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest header: %w", err)
+	}
+
+	failed := make([]error, len(ids))
+	tasks := make([]rpcpool.Task[NFTListing], len(ids))
+	for i, id := range ids {
+		tasks[i] = func(ctx context.Context) (NFTListing, error) {
+			listingID, err := parseBigInt("listing ID", id)
+			if err != nil {
+				failed[i] = err
+				return NFTListing{}, nil
+			}
+			listing, err := contract.Listings(&bind.CallOpts{Context: ctx, BlockNumber: header.Number}, listingID)
+			if err != nil {
+				return NFTListing{}, fmt.Errorf("failed to get listing %s: %w", listingID, err)
+			}
+			// Listings that were never created read back as zero values.
+			if listing.Seller == (common.Address{}) {
+				failed[i] = fmt.Errorf("%w: %s", ErrListingNotFound, listingID)
+				return NFTListing{}, nil
+			}
+			return NFTListing{
+				ListingID: listingID,
+				Seller:    listing.Seller,
+				TokenID:   listing.TokenId,
+				Price:     utils.NewWei(listing.Price),
+				IsActive:  listing.IsActive,
+			}, nil
+		}
+	}
+	listings, err := rpcpool.Do(ctx, es.rpcPool(), tasks)
+	if err != nil {
+		return nil, err
+	}
+
+	lookupErr := &ListingsError{Failed: make(map[int]error)}
+	for i, err := range failed {
+		if err != nil {
+			lookupErr.Failed[i] = err
+		}
+	}
+	if len(lookupErr.Failed) > 0 {
+		return listings, lookupErr
+	}
+	return listings, nil
+}
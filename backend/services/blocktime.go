@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// blockTimeCacheSize caps the block times kept by BlockTime. The cache is
+// emptied when it is full, which is rare enough not to need an LRU.
+const blockTimeCacheSize = 100_000
+
+// blockTimeCache keeps the timestamps of the blocks BlockTime looked up.
+type blockTimeCache struct {
+	mu    sync.RWMutex
+	times map[uint64]time.Time
+}
+
+// BlockTime returns the timestamp of the block at height number, in UTC.
+//
+// Timestamps are cached without expiry: a block's number and time do not
+// change once it is final, and a reorg replaces blocks with ones of nearly the
+// same time. Only the first lookup of a block reads its header from the chain.
+func (es *EthereumService) BlockTime(ctx context.Context, number uint64) (time.Time, error) {
+	es.blockTimes.mu.RLock()
+	t, ok := es.blockTimes.times[number]
+	es.blockTimes.mu.RUnlock()
+	if ok {
+		return t, nil
+	}
+
+	if err := es.ready(); err != nil {
+		return time.Time{}, err
+	}
+	header, err := es.Client.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get block %d: %w", number, err)
+	}
+	t = time.Unix(int64(header.Time), 0).UTC()
+
+	es.blockTimes.mu.Lock()
+	if es.blockTimes.times == nil || len(es.blockTimes.times) >= blockTimeCacheSize {
+		es.blockTimes.times = make(map[uint64]time.Time)
+	}
+	es.blockTimes.times[number] = t
+	es.blockTimes.mu.Unlock()
+	return t, nil
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+)
+
+// ErrServiceClosed is returned by the service's methods once Close was called.
+var ErrServiceClosed = errors.New("ethereum service closed")
+
+// Close releases the service's connection to the node and stops the event
+// indexer and commission watcher, which then return ErrServiceClosed, as does
+// every later call. Calling Close again does nothing.
+func (es *EthereumService) Close() error {
+	es.lifeMu.Lock()
+	defer es.lifeMu.Unlock()
+
+	if es.closed {
+		return nil
+	}
+	es.closed = true
+	if es.done != nil {
+		close(es.done)
+	}
+	if es.Client != nil {
+		es.Client.Close()
+	}
+	return nil
+}
+
+// ready returns ErrServiceClosed after Close and ErrServiceNotInitialized when
+// the service has no client, nil when the client can be used.
+func (es *EthereumService) ready() error {
+	es.lifeMu.Lock()
+	closed := es.closed
+	es.lifeMu.Unlock()
+
+	if closed {
+		return ErrServiceClosed
+	}
+	if es.Client == nil {
+		return fmt.Errorf("%w: no client", ErrServiceNotInitialized)
+	}
+	return nil
+}
+
+// readySigner is ready for methods that sign, returning the current signing
+// key, and fails with ErrServiceNotInitialized when there is none.
+func (es *EthereumService) readySigner() (*ecdsa.PrivateKey, error) {
+	if err := es.ready(); err != nil {
+		return nil, err
+	}
+	key := es.signer()
+	if key == nil {
+		return nil, fmt.Errorf("%w: no private key", ErrServiceNotInitialized)
+	}
+	return key, nil
+}
+
+// untilClosed returns a copy of ctx that Close cancels too, with
+// ErrServiceClosed as its cause.
+func (es *EthereumService) untilClosed(ctx context.Context) (context.Context, context.CancelFunc) {
+	es.lifeMu.Lock()
+	if es.done == nil {
+		es.done = make(chan struct{})
+		if es.closed {
+			close(es.done)
+		}
+	}
+	done := es.done
+	es.lifeMu.Unlock()
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	go func() {
+		select {
+		case <-done:
+			cancel(ErrServiceClosed)
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() { cancel(context.Canceled) }
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"errors"
+	"math/big"
+	"nft-marketplace/blockchain/chaintest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestServiceNotInitialized(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	buyer := common.HexToAddress("0x00000000000000000000000000000000000b0b01")
+
+	calls := []struct {
+		name string
+		call func(es *EthereumService) error
+		// signs is whether the method needs the PrivateKey.
+		signs bool
+	}{
+		{name: "GetBalance", call: func(es *EthereumService) error {
+			_, err := es.GetBalance(buyer)
+			return err
+		}},
+		{name: "TransferNFT", signs: true, call: func(es *EthereumService) error {
+			_, err := es.TransferNFT("7", buyer.Hex())
+			return err
+		}},
+		{name: "MintNFT", signs: true, call: func(es *EthereumService) error {
+			_, err := es.MintNFT("7", "1000", buyer.Hex())
+			return err
+		}},
+		{name: "DeleteNFT", signs: true, call: func(es *EthereumService) error {
+			return es.DeleteNFT("7")
+		}},
+	}
+	partial := []struct {
+		name    string
+		service func(node *chaintest.Node) *EthereumService
+		// missing reports whether a method needing the PrivateKey or not
+		// fails with ErrServiceNotInitialized.
+		missing func(signs bool) bool
+	}{
+		{
+			name: "nil Client",
+			service: func(*chaintest.Node) *EthereumService {
+				return &EthereumService{ContractAddress: chaintest.Contract, PrivateKey: key}
+			},
+			missing: func(bool) bool { return true },
+		},
+		{
+			name: "nil Contract",
+			service: func(node *chaintest.Node) *EthereumService {
+				return &EthereumService{Client: node.Client, ContractAddress: chaintest.Contract, PrivateKey: key}
+			},
+			missing: func(bool) bool { return false },
+		},
+		{
+			name: "nil PrivateKey",
+			service: func(node *chaintest.Node) *EthereumService {
+				return &EthereumService{Client: node.Client, ContractAddress: chaintest.Contract}
+			},
+			missing: func(signs bool) bool { return signs },
+		},
+	}
+	for _, svc := range partial {
+		for _, tt := range calls {
+			t.Run(svc.name+"/"+tt.name, func(t *testing.T) {
+				node := chaintest.NewNode(t)
+				node.Handle("eth_getBalance", chaintest.Returns(hexutil.Big(*big.NewInt(1e18))))
+				// Past the guards, the calls stop at the listing checks.
+				node.HandleCall("isTokenListed", chaintest.Outputs(false))
+
+				err := tt.call(svc.service(node))
+				if want := svc.missing(tt.signs); errors.Is(err, ErrServiceNotInitialized) != want {
+					t.Fatalf("err = %v, want ErrServiceNotInitialized: %t", err, want)
+				}
+			})
+		}
+	}
+}
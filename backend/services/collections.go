@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ERC721ABI is the subset of the ERC-721 interface, with its enumerable
+// extension, used to read collections.
+const ERC721ABI = `[
+	{"type":"function","name":"tokenOfOwnerByIndex","stateMutability":"view","inputs":[{"name":"owner","type":"address"},{"name":"index","type":"uint256"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"ownerOf","stateMutability":"view","inputs":[{"name":"tokenId","type":"uint256"}],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"tokenURI","stateMutability":"view","inputs":[{"name":"tokenId","type":"uint256"}],"outputs":[{"name":"","type":"string"}]}
+]`
+
+// Errors returned by CollectionRegistry.
+var (
+	ErrUnknownCollection   = errors.New("unknown collection")
+	ErrDuplicateCollection = errors.New("collection already registered")
+)
+
+// Collection is an NFT contract known to the marketplace.
+type Collection struct {
+	ID       string
+	Address  common.Address
+	ABI      abi.ABI
+	Contract *bind.BoundContract
+}
+
+// CollectionRegistry maps collection IDs and addresses to their bound
+// contracts. It is safe for concurrent use.
+type CollectionRegistry struct {
+	mu        sync.RWMutex
+	byID      map[string]*Collection
+	byAddress map[common.Address]*Collection
+	defaultID string
+}
+
+// NewCollectionRegistry returns an empty registry.
+func NewCollectionRegistry() *CollectionRegistry {
+	return &CollectionRegistry{
+		byID:      make(map[string]*Collection),
+		byAddress: make(map[common.Address]*Collection),
+	}
+}
+
+// Register binds the contract at address with contractABI and registers it
+// under id. The first collection registered becomes the default one.
+func (r *CollectionRegistry) Register(id string, address common.Address, contractABI abi.ABI, backend bind.ContractBackend) (*Collection, error) {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if id == "" {
+		return nil, fmt.Errorf("collection ID is required")
+	}
+	if address == (common.Address{}) {
+		return nil, fmt.Errorf("collection %s: address is required", id)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.byID[id]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrDuplicateCollection, id)
+	}
+	if existing, ok := r.byAddress[address]; ok {
+		return nil, fmt.Errorf("%w: %s is %s", ErrDuplicateCollection, address.Hex(), existing.ID)
+	}
+
+	collection := &Collection{
+		ID:       id,
+		Address:  address,
+		ABI:      contractABI,
+		Contract: bind.NewBoundContract(address, contractABI, backend, backend, backend),
+	}
+	r.byID[id] = collection
+	r.byAddress[address] = collection
+	if r.defaultID == "" {
+		r.defaultID = id
+	}
+
+	return collection, nil
+}
+
+// SetDefault makes the collection registered under id the default one.
+func (r *CollectionRegistry) SetDefault(id string) error {
+	id = strings.ToLower(strings.TrimSpace(id))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.byID[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownCollection, id)
+	}
+	r.defaultID = id
+	return nil
+}
+
+// Get returns the collection identified by key, which is either a collection
+// ID or a contract address. An empty key returns the default collection.
+func (r *CollectionRegistry) Get(key string) (*Collection, error) {
+	key = strings.ToLower(strings.TrimSpace(key))
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if key == "" {
+		key = r.defaultID
+	}
+	if collection, ok := r.byID[key]; ok {
+		return collection, nil
+	}
+	if common.IsHexAddress(key) {
+		if collection, ok := r.byAddress[common.HexToAddress(key)]; ok {
+			return collection, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownCollection, key)
+}
+
+// List returns every registered collection.
+func (r *CollectionRegistry) List() []*Collection {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	collections := make([]*Collection, 0, len(r.byID))
+	for _, collection := range r.byID {
+		collections = append(collections, collection)
+	}
+	return collections
+}
+
+// collection resolves key with the service's registry. Without a registry, or
+// when no collection is registered and key is empty, the contract at the
+// service's own ContractAddress is used as the default ERC-721 collection for
+// backward compatibility.
+func (es *EthereumService) collection(key string) (*Collection, error) {
+	if es.Collections != nil {
+		collection, err := es.Collections.Get(key)
+		if err == nil || key != "" {
+			return collection, err
+		}
+	} else if key != "" {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCollection, key)
+	}
+
+	if err := es.ready(); err != nil {
+		return nil, err
+	}
+
+	parsedABI, err := abi.JSON(strings.NewReader(ERC721ABI))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrABIParse, err)
+	}
+	backend := es.backend()
+	contract := bind.NewBoundContract(es.ContractAddress, parsedABI, backend, backend, backend)
+
+	return &Collection{ID: "default", Address: es.ContractAddress, ABI: parsedABI, Contract: contract}, nil
+}
+
+// OwnerOf returns the owner of tokenID in the given collection, an ID or an
+// address; an empty collection means the default one.
+func (es *EthereumService) OwnerOf(ctx context.Context, collection, tokenID string) (common.Address, error) {
+	token, err := parseBigInt("token ID", tokenID)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	c, err := es.collection(collection)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	// Rebind through the service's backend so that concurrent identical reads
+	// share one RPC call.
+	backend := es.backend()
+	contract := bind.NewBoundContract(c.Address, c.ABI, backend, backend, backend)
+
+	var out []interface{}
+	if err := contract.Call(&bind.CallOpts{Context: ctx}, &out, "ownerOf", token); err != nil {
+		return common.Address{}, fmt.Errorf("failed to get owner of token %s: %w", tokenID, err)
+	}
+	if len(out) != 1 {
+		return common.Address{}, fmt.Errorf("unexpected ownerOf result: %v", out)
+	}
+
+	owner, ok := out[0].(common.Address)
+	if !ok {
+		return common.Address{}, fmt.Errorf("unexpected ownerOf result type %T", out[0])
+	}
+
+	return owner, nil
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	marketplace "nft-marketplace/blockchain"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"golang.org/x/sync/singleflight"
+)
+
+const defaultCommissionCacheTTL = 30 * time.Second
+
+// commissionDenominator is what the contract divides commissions by: they are
+// expressed in basis points, 100 being 1%.
+var commissionDenominator = big.NewInt(10000)
+
+// CommissionInfo holds the marketplace's current and maximum commission, both
+// in basis points.
+type CommissionInfo struct {
+	CommissionPercent *big.Int `json:"commissionPercent"`
+	MaxCommission     *big.Int `json:"maxCommission"`
+}
+
+// commissionCache keeps the last commission read from the chain. As with
+// feeCache, its mutex only guards the cached value and concurrent refreshes
+// share a single read through refresh. generation counts invalidations, so
+// that a read started before one does not store what it read.
+type commissionCache struct {
+	mu         sync.Mutex
+	info       CommissionInfo
+	expires    time.Time
+	generation uint64
+	refresh    singleflight.Group
+}
+
+// ComputeCommission returns the marketplace fee charged on a purchase at price
+// and what the seller is credited, exactly as purchaseListing computes them:
+//
+//	fee = price * commissionPercent / 10000
+//	sellerProceeds = price - fee
+//
+// commissionPercent is in basis points and the division truncates, as
+// Solidity's does, so any remainder goes to the seller. With a 2.5% commission
+// (250), a price of 999 wei yields a fee of 24 wei and 975 wei of proceeds.
+func ComputeCommission(price, commissionPercent *big.Int) (fee, sellerProceeds *big.Int) {
+	fee = new(big.Int).Mul(price, commissionPercent)
+	fee.Quo(fee, commissionDenominator)
+
+	return fee, new(big.Int).Sub(price, fee)
+}
+
+// GetCommission returns the marketplace's commissionPercent and MAX_COMMISSION.
+//
+// Values are cached for CommissionCacheTTL (30 seconds by default) and dropped
+// early when the contract emits CommissionUpdated, see WatchCommissionUpdates.
+func (es *EthereumService) GetCommission(ctx context.Context) (CommissionInfo, error) {
+	es.commission.mu.Lock()
+	info, fresh := es.commission.info, es.clock().Now().Before(es.commission.expires)
+	generation := es.commission.generation
+	es.commission.mu.Unlock()
+	if fresh {
+		return info, nil
+	}
+
+	contract, err := es.marketplaceContract()
+	if err != nil {
+		return CommissionInfo{}, err
+	}
+
+	results := es.commission.refresh.DoChan("commission", func() (interface{}, error) {
+		shared, cancel := context.WithTimeout(context.WithoutCancel(ctx), sharedCallTimeout)
+		defer cancel()
+		info, err := readCommission(shared, contract)
+		if err != nil {
+			return nil, err
+		}
+
+		ttl := es.CommissionCacheTTL
+		if ttl <= 0 {
+			ttl = defaultCommissionCacheTTL
+		}
+		es.commission.mu.Lock()
+		if es.commission.generation == generation {
+			es.commission.info = info
+			es.commission.expires = es.clock().Now().Add(ttl)
+		}
+		es.commission.mu.Unlock()
+		return info, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return CommissionInfo{}, ctx.Err()
+	case res := <-results:
+		if res.Err != nil {
+			return CommissionInfo{}, res.Err
+		}
+		return res.Val.(CommissionInfo), nil
+	}
+}
+
+// readCommission reads the commission from the chain.
+func readCommission(ctx context.Context, contract *marketplace.Marketplace) (CommissionInfo, error) {
+	opts := &bind.CallOpts{Context: ctx}
+	percent, err := contract.CommissionPercent(opts)
+	if err != nil {
+		return CommissionInfo{}, fmt.Errorf("failed to get commission percent: %w", err)
+	}
+
+	maxCommission, err := contract.MAXCOMMISSION(opts)
+	if err != nil {
+		return CommissionInfo{}, fmt.Errorf("failed to get max commission: %w", err)
+	}
+	return CommissionInfo{CommissionPercent: percent, MaxCommission: maxCommission}, nil
+}
+
+// InvalidateCommission drops the cached commission so the next GetCommission
+// reads it from the chain, rather than sharing a read already in flight.
+func (es *EthereumService) InvalidateCommission() {
+	es.commission.mu.Lock()
+	es.commission.expires = time.Time{}
+	es.commission.generation++
+	es.commission.mu.Unlock()
+
+	es.commission.refresh.Forget("commission")
+}
+
+// WatchCommissionUpdates invalidates the commission cache every time the
+// contract emits CommissionUpdated. It blocks until ctx is cancelled or the
+// subscription fails, and requires a websocket RPC endpoint.
+func (es *EthereumService) WatchCommissionUpdates(ctx context.Context) error {
+	contract, err := es.marketplaceContract()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := es.untilClosed(ctx)
+	defer cancel()
+
+	sink := make(chan *marketplace.MarketplaceCommissionUpdated)
+	sub, err := contract.WatchCommissionUpdated(&bind.WatchOpts{Context: ctx}, sink)
+	if err != nil {
+		return fmt.Errorf("failed to watch CommissionUpdated events: %w", err)
+	}
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case err := <-sub.Err():
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
+			return fmt.Errorf("CommissionUpdated subscription failed: %w", err)
+		case event := <-sink:
+			log.Printf("Commission updated to %s basis points", event.NewPercent)
+			es.InvalidateCommission()
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"nft-marketplace/blockchain/chaintest"
+	"nft-marketplace/clock"
+	"testing"
+	"time"
+)
+
+func TestComputeCommission(t *testing.T) {
+	tests := []struct {
+		name              string
+		price             int64
+		commissionPercent int64
+		wantFee           int64
+		wantProceeds      int64
+	}{
+		{name: "2.5%", price: 1000, commissionPercent: 250, wantFee: 25, wantProceeds: 975},
+		{name: "remainder goes to seller", price: 999, commissionPercent: 250, wantFee: 24, wantProceeds: 975},
+		{name: "one basis point", price: 1_000_000, commissionPercent: 1, wantFee: 100, wantProceeds: 999_900},
+		{name: "no commission", price: 1000, commissionPercent: 0, wantFee: 0, wantProceeds: 1000},
+		{name: "whole price", price: 1000, commissionPercent: 10000, wantFee: 1000, wantProceeds: 0},
+		{name: "free listing", price: 0, commissionPercent: 250, wantFee: 0, wantProceeds: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fee, proceeds := ComputeCommission(big.NewInt(tt.price), big.NewInt(tt.commissionPercent))
+			if fee.Int64() != tt.wantFee || proceeds.Int64() != tt.wantProceeds {
+				t.Fatalf("ComputeCommission(%d, %d) = %s, %s, want %d, %d",
+					tt.price, tt.commissionPercent, fee, proceeds, tt.wantFee, tt.wantProceeds)
+			}
+		})
+	}
+}
+
+func TestGetCommissionCaches(t *testing.T) {
+	node := chaintest.NewNode(t)
+	var onChain int64
+	node.HandleCall("commissionPercent", func([]any) ([]any, error) { return []any{big.NewInt(onChain)}, nil })
+	node.HandleCall("MAX_COMMISSION", chaintest.Outputs(big.NewInt(1000)))
+
+	fake := clock.NewFake(time.Unix(1_700_000_000, 0))
+	es := &EthereumService{Client: node.Client, ContractAddress: chaintest.Contract, Clock: fake, CommissionCacheTTL: time.Minute}
+
+	tests := []struct {
+		name        string
+		onChain     int64
+		advance     time.Duration
+		invalidate  bool
+		wantPercent int64
+		wantCalls   int
+	}{
+		{name: "first read hits the chain", onChain: 250, wantPercent: 250, wantCalls: 1},
+		{name: "cached within the TTL", onChain: 300, advance: 59 * time.Second, wantPercent: 250, wantCalls: 1},
+		{name: "refreshed after the TTL", onChain: 300, advance: time.Second, wantPercent: 300, wantCalls: 2},
+		{name: "refreshed after CommissionUpdated", onChain: 400, invalidate: true, wantPercent: 400, wantCalls: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			onChain = tt.onChain
+			fake.Advance(tt.advance)
+			if tt.invalidate {
+				es.InvalidateCommission()
+			}
+
+			info, err := es.GetCommission(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.CommissionPercent.Int64() != tt.wantPercent || info.MaxCommission.Int64() != 1000 {
+				t.Fatalf("commission = %s of at most %s, want %d of at most 1000", info.CommissionPercent, info.MaxCommission, tt.wantPercent)
+			}
+			if got := node.Count("commissionPercent"); got != tt.wantCalls {
+				t.Fatalf("commissionPercent called %d times, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestGetCommissionSlowNode(t *testing.T) {
+	node := chaintest.NewNode(t)
+	release := make(chan struct{})
+	node.HandleCall("commissionPercent", func([]any) ([]any, error) {
+		<-release
+		return []any{big.NewInt(250)}, nil
+	})
+	node.HandleCall("MAX_COMMISSION", chaintest.Outputs(big.NewInt(1000)))
+	es := &EthereumService{Client: node.Client, ContractAddress: chaintest.Contract}
+
+	// A caller giving up on a slow node gets its own error at once instead
+	// of waiting behind the read.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	waiting := make(chan error)
+	go func() {
+		_, err := es.GetCommission(context.Background())
+		waiting <- err
+	}()
+	if _, err := es.GetCommission(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// CommissionUpdated while the read is in flight: what it read may be
+	// stale, so it is not cached.
+	es.InvalidateCommission()
+	close(release)
+	if err := <-waiting; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := es.GetCommission(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := node.Count("commissionPercent"); n != 2 {
+		t.Fatalf("commissionPercent called %d times, want 2", n)
+	}
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	mintConfirmPollInterval = 3 * time.Second
+	// mintWatchTimeout is how long a confirmation watcher waits for its
+	// transaction before giving up on it.
+	mintWatchTimeout = time.Hour
+)
+
+// MintNFTAfterConfirmations mints like MintNFT, but returns as soon as the
+// listing transaction is sent, with a pending result carrying its hash.
+//
+// A watcher in the background waits for the transaction to be
+// MintConfirmations blocks deep, its own block included, and then calls record
+// with the result and the listing ID. A transaction reorged out meanwhile is
+// waited for again in its new block, so that only listings that made it past
+// the threshold are recorded. Reverted transactions, transactions not
+// confirmed within an hour and a Close of the service are logged and never
+// recorded.
+func (es *EthereumService) MintNFTAfterConfirmations(tokenID, price, recipient string, record func(MintResult) error) (MintResult, error) {
+	mint, err := es.submitMint(tokenID, price, recipient)
+	if err != nil {
+		return MintResult{}, err
+	}
+
+	result := mint.result
+	result.Pending = true
+
+	ctx, cancel := es.untilClosed(context.Background())
+	go func() {
+		defer cancel()
+		ctx, cancelWatch := context.WithTimeout(ctx, mintWatchTimeout)
+		defer cancelWatch()
+
+		confirmed, err := es.awaitConfirmations(ctx, mint)
+		if err != nil {
+			log.Printf("Not recording mint of token %s: %v", mint.token, err)
+			return
+		}
+		if err := record(confirmed); err != nil {
+			log.Printf("Failed to record mint of token %s: %v", mint.token, err)
+		}
+	}()
+	return result, nil
+}
+
+// awaitConfirmations polls for the receipt of the mint until it is
+// MintConfirmations blocks deep in the canonical chain, and returns the
+// confirmed result.
+func (es *EthereumService) awaitConfirmations(ctx context.Context, mint *submittedMint) (MintResult, error) {
+	hash := mint.tx.Hash()
+	for {
+		receipt, err := es.Client.TransactionReceipt(ctx, hash)
+		if err != nil && !errors.Is(err, ethereum.NotFound) {
+			log.Printf("Failed to get receipt of listing transaction %s: %v", hash.Hex(), err)
+		}
+		if err == nil {
+			confirmed, err := es.confirmed(ctx, receipt)
+			if err != nil {
+				log.Printf("Failed to check confirmations of listing transaction %s: %v", hash.Hex(), err)
+			}
+			if confirmed {
+				listingID, err := mint.listingID(ctx, receipt)
+				if err != nil {
+					return MintResult{}, err
+				}
+				result := mint.result
+				result.ListingID = listingID
+				return result, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return MintResult{}, fmt.Errorf("listing transaction %s not confirmed: %w", hash.Hex(), context.Cause(ctx))
+		case <-es.clock().After(mintConfirmPollInterval):
+		}
+	}
+}
+
+// confirmed reports whether receipt is MintConfirmations blocks deep and its
+// block is still the canonical one at its height.
+func (es *EthereumService) confirmed(ctx context.Context, receipt *types.Receipt) (bool, error) {
+	head, err := es.Client.BlockNumber(ctx)
+	if err != nil {
+		return false, err
+	}
+	mined := receipt.BlockNumber.Uint64()
+	if head < mined || head-mined+1 < es.MintConfirmations {
+		return false, nil
+	}
+
+	header, err := es.Client.HeaderByNumber(ctx, receipt.BlockNumber)
+	if err != nil {
+		return false, err
+	}
+	if header.Hash() != receipt.BlockHash {
+		log.Printf("Listing transaction %s was reorged out of block %d", receipt.TxHash.Hex(), mined)
+		return false, nil
+	}
+	return true, nil
+}
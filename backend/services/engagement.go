@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"nft-marketplace/clock"
+	"nft-marketplace/db"
+	"time"
+)
+
+// Engagement counts the views and favorites of tokens, kept in the database so
+// that they are shared by every worker. A viewer's repeated views of a token
+// count once per ViewDebounce, so that reloading a page does not inflate them;
+// a non-positive ViewDebounce counts every view.
+type Engagement struct {
+	ViewDebounce time.Duration
+	// Clock tells when views are made. Nil means the wall clock.
+	Clock clock.Clock
+}
+
+// NewEngagement returns counters debouncing views by viewDebounce.
+func NewEngagement(viewDebounce time.Duration) *Engagement {
+	return &Engagement{ViewDebounce: viewDebounce}
+}
+
+// IncrementViews counts a view of tokenID by viewer, which identifies a user
+// or client, unless viewer already viewed it within ViewDebounce. It reports
+// whether the view was counted.
+func (e *Engagement) IncrementViews(tokenID, viewer string) (bool, error) {
+	token, err := parseBigInt("token ID", tokenID)
+	if err != nil {
+		return false, err
+	}
+
+	now := clock.OrReal(e.Clock).Now()
+	counted, err := db.IncrementViews(token.String(), viewer, now, now.Add(-max(e.ViewDebounce, 0)))
+	if err != nil {
+		return false, fmt.Errorf("failed to count view of token %s: %w", token, err)
+	}
+	return counted, nil
+}
+
+// ToggleFavorite favorites tokenID for the user with userID, or removes the
+// favorite the user had. It reports whether the token is now favorited.
+func (e *Engagement) ToggleFavorite(userID uint, tokenID string) (bool, error) {
+	token, err := parseBigInt("token ID", tokenID)
+	if err != nil {
+		return false, err
+	}
+
+	favorited, err := db.ToggleFavorite(userID, token.String())
+	if err != nil {
+		return false, fmt.Errorf("failed to toggle favorite of token %s: %w", token, err)
+	}
+	return favorited, nil
+}
+
+// Stats returns the view and favorite counts of tokenID.
+func (e *Engagement) Stats(tokenID string) (db.TokenStats, error) {
+	token, err := parseBigInt("token ID", tokenID)
+	if err != nil {
+		return db.TokenStats{}, err
+	}
+
+	stats, err := db.GetTokenStats(token.String())
+	if err != nil {
+		return db.TokenStats{}, fmt.Errorf("failed to get stats of token %s: %w", token, err)
+	}
+	return stats, nil
+}
+
+// RunViewCleanup removes, every interval until ctx is cancelled, the views
+// made longer than ViewDebounce ago, which no longer hold back any.
+func (e *Engagement) RunViewCleanup(ctx context.Context, interval time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-clock.OrReal(e.Clock).After(interval):
+		}
+
+		removed, err := db.DeleteViewsBefore(clock.OrReal(e.Clock).Now().Add(-max(e.ViewDebounce, 0)))
+		if err != nil {
+			log.Printf("Failed to remove old token views: %v", err)
+			continue
+		}
+		if removed > 0 {
+			log.Printf("Removed %d old token views", removed)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ENSRegistryAddress is the address of the ENS registry on mainnet and the
+// public testnets.
+var ENSRegistryAddress = common.HexToAddress("0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e")
+
+const defaultENSCacheTTL = 5 * time.Minute
+
+// ensABI holds the registry's resolver(node) and the resolver's addr(node).
+const ensABI = `[
+	{"type":"function","name":"resolver","stateMutability":"view","inputs":[{"name":"node","type":"bytes32"}],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"addr","stateMutability":"view","inputs":[{"name":"node","type":"bytes32"}],"outputs":[{"name":"","type":"address"}]}
+]`
+
+// Errors returned by ResolveName.
+var (
+	ErrInvalidENSName = errors.New("invalid ENS name")
+	ErrENSNotFound    = errors.New("ENS name not registered")
+)
+
+// ensCache keeps the addresses names resolved to.
+type ensCache struct {
+	mu      sync.Mutex
+	entries map[string]ensEntry
+}
+
+type ensEntry struct {
+	address common.Address
+	expires time.Time
+}
+
+// ResolveName returns the address the ENS name, such as "vitalik.eth", points
+// to, looked up through the registry at ENSRegistry (ENSRegistryAddress when
+// unset).
+//
+// Names are only lowercased before hashing, not fully UTS-46 normalised, so
+// names with non-ASCII characters must be given in normalised form. Names
+// without a resolver or an address record fail with ErrENSNotFound. Resolved
+// addresses are cached for ENSCacheTTL (5 minutes by default).
+func (es *EthereumService) ResolveName(ctx context.Context, name string) (common.Address, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	node, err := namehash(name)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	now := es.clock().Now()
+	es.ens.mu.Lock()
+	entry, ok := es.ens.entries[name]
+	es.ens.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.address, nil
+	}
+
+	if err := es.ready(); err != nil {
+		return common.Address{}, err
+	}
+	parsedABI, err := abi.JSON(strings.NewReader(ensABI))
+	if err != nil {
+		return common.Address{}, fmt.Errorf("%w: %w", ErrABIParse, err)
+	}
+
+	registry := es.ENSRegistry
+	if registry == (common.Address{}) {
+		registry = ENSRegistryAddress
+	}
+
+	resolver, err := es.ensLookup(ctx, parsedABI, registry, "resolver", node)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to get resolver of %s: %w", name, err)
+	}
+	if resolver == (common.Address{}) {
+		return common.Address{}, fmt.Errorf("%w: %s", ErrENSNotFound, name)
+	}
+
+	address, err := es.ensLookup(ctx, parsedABI, resolver, "addr", node)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to resolve %s: %w", name, err)
+	}
+	if address == (common.Address{}) {
+		return common.Address{}, fmt.Errorf("%w: %s has no address", ErrENSNotFound, name)
+	}
+
+	ttl := es.ENSCacheTTL
+	if ttl <= 0 {
+		ttl = defaultENSCacheTTL
+	}
+	es.ens.mu.Lock()
+	if es.ens.entries == nil {
+		es.ens.entries = make(map[string]ensEntry)
+	}
+	es.ens.entries[name] = ensEntry{address: address, expires: now.Add(ttl)}
+	es.ens.mu.Unlock()
+
+	return address, nil
+}
+
+// ensLookup calls method(node) on the contract at address and returns the
+// address it answers.
+func (es *EthereumService) ensLookup(ctx context.Context, parsedABI abi.ABI, address common.Address, method string, node [32]byte) (common.Address, error) {
+	backend := es.backend()
+	contract := bind.NewBoundContract(address, parsedABI, backend, backend, backend)
+
+	var out []interface{}
+	if err := contract.Call(&bind.CallOpts{Context: ctx}, &out, method, node); err != nil {
+		return common.Address{}, err
+	}
+	if len(out) != 1 {
+		return common.Address{}, fmt.Errorf("unexpected %s result: %v", method, out)
+	}
+
+	result, ok := out[0].(common.Address)
+	if !ok {
+		return common.Address{}, fmt.Errorf("unexpected %s result type %T", method, out[0])
+	}
+	return result, nil
+}
+
+// namehash computes the ENS node of name, as specified by EIP-137.
+func namehash(name string) ([32]byte, error) {
+	var node [32]byte
+	if name == "" {
+		return node, fmt.Errorf("%w: name is empty", ErrInvalidENSName)
+	}
+
+	labels := strings.Split(name, ".")
+	if len(labels) < 2 {
+		return node, fmt.Errorf("%w: %q has no top-level domain", ErrInvalidENSName, name)
+	}
+	for i := len(labels) - 1; i >= 0; i-- {
+		if labels[i] == "" {
+			return node, fmt.Errorf("%w: %q has an empty label", ErrInvalidENSName, name)
+		}
+		copy(node[:], crypto.Keccak256(node[:], crypto.Keccak256([]byte(labels[i]))))
+	}
+
+	return node, nil
+}
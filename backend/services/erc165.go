@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ERC-165 interface IDs of the standards the marketplace relies on.
+var (
+	InterfaceIDERC165           = [4]byte{0x01, 0xff, 0xc9, 0xa7}
+	InterfaceIDERC721           = [4]byte{0x80, 0xac, 0x58, 0xcd}
+	InterfaceIDERC721Enumerable = [4]byte{0x78, 0x0e, 0x9d, 0x63}
+)
+
+// ErrNotEnumerable is returned when enumerating the tokens of a contract that
+// does not implement ERC721Enumerable.
+var ErrNotEnumerable = errors.New("contract does not implement ERC721Enumerable")
+
+const erc165ABI = `[{"type":"function","name":"supportsInterface","stateMutability":"view","inputs":[{"name":"interfaceId","type":"bytes4"}],"outputs":[{"name":"","type":"bool"}]}]`
+
+// SupportsInterface reports whether the contract at addr implements the
+// interface identified by interfaceID, as answered by its ERC-165
+// supportsInterface view.
+//
+// As ERC-165 prescribes, a contract that reverts or returns anything but a
+// single bool, including an address without code, does not support it. An
+// error is only returned when the call itself could not be made.
+func (es *EthereumService) SupportsInterface(ctx context.Context, addr common.Address, interfaceID [4]byte) (bool, error) {
+	if err := es.ready(); err != nil {
+		return false, err
+	}
+
+	parsedABI, err := abi.JSON(strings.NewReader(erc165ABI))
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrABIParse, err)
+	}
+	data, err := parsedABI.Pack("supportsInterface", interfaceID)
+	if err != nil {
+		return false, fmt.Errorf("failed to pack supportsInterface: %w", err)
+	}
+
+	out, err := es.backend().CallContract(ctx, ethereum.CallMsg{To: &addr, Data: data}, nil)
+	if err != nil {
+		if isRevert(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to call supportsInterface on %s: %w", addr.Hex(), err)
+	}
+
+	if len(out) != 32 {
+		return false, nil
+	}
+	for _, b := range out[:31] {
+		if b != 0 {
+			return false, nil
+		}
+	}
+	return out[31] == 1, nil
+}
+
+// SupportsERC721 reports whether the contract at addr is an ERC-721 contract.
+// It follows the ERC-165 detection procedure, which also checks that the
+// contract rejects the invalid 0xffffffff interface ID.
+func (es *EthereumService) SupportsERC721(ctx context.Context, addr common.Address) (bool, error) {
+	return es.supportsAll(ctx, addr, InterfaceIDERC721)
+}
+
+// SupportsERC721Enumerable reports whether the contract at addr is an ERC-721
+// contract implementing the enumeration extension.
+func (es *EthereumService) SupportsERC721Enumerable(ctx context.Context, addr common.Address) (bool, error) {
+	return es.supportsAll(ctx, addr, InterfaceIDERC721, InterfaceIDERC721Enumerable)
+}
+
+// supportsAll reports whether the contract at addr implements ERC-165 and each
+// of the given interfaces.
+func (es *EthereumService) supportsAll(ctx context.Context, addr common.Address, interfaceIDs ...[4]byte) (bool, error) {
+	ok, err := es.SupportsInterface(ctx, addr, InterfaceIDERC165)
+	if err != nil || !ok {
+		return false, err
+	}
+	invalid, err := es.SupportsInterface(ctx, addr, [4]byte{0xff, 0xff, 0xff, 0xff})
+	if err != nil || invalid {
+		return false, err
+	}
+
+	for _, id := range interfaceIDs {
+		ok, err := es.SupportsInterface(ctx, addr, id)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// isRevert reports whether err is a node's answer that the call reverted, as
+// opposed to a failure to reach the node.
+func isRevert(err error) bool {
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == 3 {
+		return true
+	}
+	return strings.Contains(err.Error(), "execution reverted")
+}
+
+// revertsWith reports whether err is a revert with the custom error named
+// errorName in contractABI, as seen from the revert data nodes attach to the
+// error or, failing that, the selector they quote in its message.
+func revertsWith(err error, contractABI abi.ABI, errorName string) bool {
+	if err == nil || !isRevert(err) {
+		return false
+	}
+	abiErr, ok := contractABI.Errors[errorName]
+	if !ok {
+		return false
+	}
+	selector := hexutil.Encode(abiErr.ID[:4])
+
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		if data, ok := dataErr.ErrorData().(string); ok {
+			return strings.HasPrefix(strings.ToLower(data), selector)
+		}
+	}
+	return strings.Contains(strings.ToLower(err.Error()), selector)
+}
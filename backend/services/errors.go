@@ -0,0 +1,33 @@
+package services
+
+import "errors"
+
+// Errors returned by this package wrap their cause with %w, so callers can
+// inspect it with errors.Is and errors.As. They are not logged here: whoever
+// handles an error logs it.
+
+// Errors returned by NewEthereumService. They are wrapped together with the
+// underlying cause, so use errors.Is to check for them.
+var (
+	ErrInvalidPrivateKey = errors.New("invalid private key")
+	ErrRPCDialFailed     = errors.New("failed to connect to Ethereum client")
+	ErrABIParse          = errors.New("failed to parse contract ABI")
+	ErrNoContractCode    = errors.New("no contract code at address")
+)
+
+// ErrServiceNotInitialized is returned by methods of an EthereumService that
+// lacks a field they need: its Client, or its PrivateKey for methods that
+// sign. It is wrapped together with the name of the missing field.
+var ErrServiceNotInitialized = errors.New("ethereum service not initialized")
+
+// Errors returned when acting on a listing.
+var (
+	ErrNotListingOwner  = errors.New("caller does not own the listing")
+	ErrListingNotActive = errors.New("listing is not active")
+	// ErrTokenAlreadyListed is returned when listing a token that already
+	// has an active listing, which createListing would revert on.
+	ErrTokenAlreadyListed = errors.New("token already listed")
+	// ErrOwnershipMismatch is returned when a mined purchase did not leave
+	// the token with its buyer.
+	ErrOwnershipMismatch = errors.New("token not owned by buyer after purchase")
+)
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	marketplace "nft-marketplace/blockchain"
+	"nft-marketplace/utils"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// defaultPurchaseGasLimit is assumed for purchaseListing when the node cannot
+// estimate it, typically because the estimating account cannot pay the price.
+const defaultPurchaseGasLimit = uint64(150000)
+
+// PurchaseEstimate is the cost breakdown of buying a listing. Amounts are in
+// wei, with the total also given in ether.
+type PurchaseEstimate struct {
+	ListingID         *big.Int   `json:"listing_id"`
+	Price             *utils.Wei `json:"price"`
+	CommissionPercent *big.Int   `json:"commission_percent"`
+	// Commission is the part of the price the marketplace keeps. It is taken
+	// from the seller's proceeds and does not add to the buyer's total.
+	Commission *utils.Wei `json:"commission"`
+	GasLimit   uint64     `json:"gas_limit"`
+	// GasEstimated is false when GasLimit is the default purchase gas limit
+	// rather than the node's estimate.
+	GasEstimated bool       `json:"gas_estimated"`
+	GasPrice     *utils.Wei `json:"gas_price"`
+	GasCost      *utils.Wei `json:"gas_cost"`
+	Total        *utils.Wei `json:"total"`
+	TotalEther   string     `json:"total_ether"`
+}
+
+// EstimatePurchaseCost returns what buying the given listing would cost: the
+// listing price plus the estimated gas limit times the current gas price.
+//
+// The gas price is the base fee plus the suggested tip on EIP-1559 chains and
+// the suggested gas price otherwise. The marketplace commission is reported
+// alongside, but it is paid out of the price and is not added to the total.
+// ErrListingNotActive is returned for listings that cannot be bought.
+func (es *EthereumService) EstimatePurchaseCost(ctx context.Context, listingID string) (PurchaseEstimate, error) {
+	id, err := parseBigInt("listing ID", listingID)
+	if err != nil {
+		return PurchaseEstimate{}, err
+	}
+
+	contract, err := es.marketplaceContract()
+	if err != nil {
+		return PurchaseEstimate{}, err
+	}
+
+	listing, err := contract.Listings(&bind.CallOpts{Context: ctx}, id)
+	if err != nil {
+		return PurchaseEstimate{}, fmt.Errorf("failed to get listing %s: %w", listingID, err)
+	}
+	if !listing.IsActive {
+		return PurchaseEstimate{}, fmt.Errorf("%w: %s", ErrListingNotActive, listingID)
+	}
+
+	commission, err := es.GetCommission(ctx)
+	if err != nil {
+		return PurchaseEstimate{}, err
+	}
+
+	gasPrice, err := es.currentGasPrice(ctx)
+	if err != nil {
+		return PurchaseEstimate{}, err
+	}
+
+	gasLimit, estimated := defaultPurchaseGasLimit, false
+	parsedABI, err := marketplace.MarketplaceMetaData.GetAbi()
+	if err != nil {
+		return PurchaseEstimate{}, fmt.Errorf("%w: %w", ErrABIParse, err)
+	}
+	data, err := parsedABI.Pack("purchaseListing", id)
+	if err != nil {
+		return PurchaseEstimate{}, fmt.Errorf("failed to pack purchaseListing: %w", err)
+	}
+	to := es.ContractAddress
+	if gas, err := es.Client.EstimateGas(ctx, ethereum.CallMsg{To: &to, Value: listing.Price, Data: data}); err == nil {
+		gasLimit, estimated = gas, true
+	} else {
+		log.Printf("Failed to estimate gas of purchasing listing %s, assuming %d: %v", listingID, defaultPurchaseGasLimit, err)
+	}
+
+	gasCost := new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(gasLimit))
+	total := new(big.Int).Add(listing.Price, gasCost)
+
+	fee, _ := ComputeCommission(listing.Price, commission.CommissionPercent)
+
+	return PurchaseEstimate{
+		ListingID:         id,
+		Price:             utils.NewWei(listing.Price),
+		CommissionPercent: commission.CommissionPercent,
+		Commission:        utils.NewWei(fee),
+		GasLimit:          gasLimit,
+		GasEstimated:      estimated,
+		GasPrice:          utils.NewWei(gasPrice),
+		GasCost:           utils.NewWei(gasCost),
+		Total:             utils.NewWei(total),
+		TotalEther:        utils.WeiToEther(total),
+	}, nil
+}
+
+// EstimateMintCost returns, in wei, what MintNFT would pay in gas to list
+// tokenID at price for recipient, without sending anything: the gas the node
+// estimates for createListing from the service's account times the price per
+// gas newTransactor would pay under the gas strategy of ctx.
+//
+// On EIP-1559 chains the price per gas is the latest base fee plus the tip;
+// the transaction pays more if the base fee rises before it is mined, up to
+// its fee cap. The arguments are validated as MintNFT
+// validates them; a createListing that would revert fails the estimate.
+func (es *EthereumService) EstimateMintCost(ctx context.Context, tokenID, price, recipient string) (*big.Int, error) {
+	if strings.TrimSpace(recipient) == "" && es.TreasuryAddress != (common.Address{}) {
+		recipient = es.TreasuryAddress.Hex()
+	}
+	if _, err := es.validateRecipient(recipient); err != nil {
+		return nil, err
+	}
+
+	tokenIDBigInt, err := parseBigInt("token ID", tokenID)
+	if err != nil {
+		return nil, err
+	}
+	priceBigInt, err := parseBigInt("price", price)
+	if err != nil {
+		return nil, err
+	}
+	if err := es.validateListingPrice(priceBigInt); err != nil {
+		return nil, err
+	}
+
+	key, err := es.readySigner()
+	if err != nil {
+		return nil, err
+	}
+	preset, err := es.gasPresetFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedABI, err := marketplace.MarketplaceMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrABIParse, err)
+	}
+	data, err := parsedABI.Pack("createListing", tokenIDBigInt, priceBigInt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack createListing: %w", err)
+	}
+	to := es.ContractAddress
+	gas, err := es.Client.EstimateGas(ctx, ethereum.CallMsg{From: crypto.PubkeyToAddress(key.PublicKey), To: &to, Data: data})
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate gas of listing token %s: %w", tokenID, err)
+	}
+
+	var opts bind.TransactOpts
+	baseFee, err := es.setGasPrice(ctx, &opts, preset)
+	if err != nil {
+		return nil, err
+	}
+	gasPrice := opts.GasPrice
+	if baseFee != nil {
+		gasPrice = new(big.Int).Add(baseFee, opts.GasTipCap)
+	}
+
+	return new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(gas)), nil
+}
+
+// currentGasPrice returns the price per gas a transaction sent now would pay.
+func (es *EthereumService) currentGasPrice(ctx context.Context) (*big.Int, error) {
+	header, err := es.Client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest header: %w", err)
+	}
+
+	if header.BaseFee != nil {
+		tip, err := es.Client.SuggestGasTipCap(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to suggest gas tip cap: %w", err)
+		}
+		return new(big.Int).Add(header.BaseFee, tip), nil
+	}
+
+	gasPrice, err := es.Client.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to suggest gas price: %w", err)
+	}
+	return gasPrice, nil
+}
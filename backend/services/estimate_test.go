@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"nft-marketplace/blockchain/chaintest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestEstimatePurchaseCost(t *testing.T) {
+	oneEther := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	seller := common.HexToAddress("0x0000000000000000000000000000000000005e11")
+
+	tests := []struct {
+		name           string
+		active         bool
+		baseFee        *big.Int
+		estimateFails  bool
+		wantErr        error
+		wantCommission string
+		wantGasLimit   uint64
+		wantGasPrice   string
+		wantTotal      string
+		wantTotalEther string
+	}{
+		{
+			name:           "EIP-1559 chain",
+			active:         true,
+			baseFee:        big.NewInt(30_000_000_000),
+			wantCommission: "25000000000000000",
+			wantGasLimit:   100_000,
+			wantGasPrice:   "32000000000",
+			wantTotal:      "1003200000000000000",
+			wantTotalEther: "1.0032",
+		},
+		{
+			name:           "legacy chain",
+			active:         true,
+			wantCommission: "25000000000000000",
+			wantGasLimit:   100_000,
+			wantGasPrice:   "10000000000",
+			wantTotal:      "1001000000000000000",
+			wantTotalEther: "1.001",
+		},
+		{
+			name:           "estimate fails",
+			active:         true,
+			estimateFails:  true,
+			wantCommission: "25000000000000000",
+			wantGasLimit:   defaultPurchaseGasLimit,
+			wantGasPrice:   "10000000000",
+			wantTotal:      "1001500000000000000",
+			wantTotalEther: "1.0015",
+		},
+		{name: "inactive listing", wantErr: ErrListingNotActive},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := chaintest.NewNode(t)
+			node.HandleCall("listings", chaintest.Outputs(seller, big.NewInt(7), oneEther, tt.active))
+			node.HandleCall("commissionPercent", chaintest.Outputs(big.NewInt(250)))
+			node.HandleCall("MAX_COMMISSION", chaintest.Outputs(big.NewInt(1000)))
+			node.Handle("eth_getBlockByNumber", chaintest.Returns(&types.Header{
+				Number:     big.NewInt(100),
+				Difficulty: big.NewInt(0),
+				BaseFee:    tt.baseFee,
+			}))
+			node.Handle("eth_maxPriorityFeePerGas", chaintest.Returns(hexutil.Big(*big.NewInt(2_000_000_000))))
+			node.Handle("eth_gasPrice", chaintest.Returns(hexutil.Big(*big.NewInt(10_000_000_000))))
+			node.Handle("eth_estimateGas", func([]json.RawMessage) (any, error) {
+				if tt.estimateFails {
+					return nil, errors.New("insufficient funds for gas * price + value")
+				}
+				return hexutil.Uint64(100_000), nil
+			})
+			es := &EthereumService{Client: node.Client, ContractAddress: chaintest.Contract}
+
+			got, err := es.EstimatePurchaseCost(context.Background(), "1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if got.Price.String() != oneEther.String() || got.CommissionPercent.Int64() != 250 {
+				t.Fatalf("price %s at %s basis points, want %s at 250", got.Price, got.CommissionPercent, oneEther)
+			}
+			if got.Commission.String() != tt.wantCommission {
+				t.Errorf("commission = %s, want %s", got.Commission, tt.wantCommission)
+			}
+			if got.GasLimit != tt.wantGasLimit || got.GasEstimated == tt.estimateFails {
+				t.Errorf("gas limit = %d (estimated %t), want %d", got.GasLimit, got.GasEstimated, tt.wantGasLimit)
+			}
+			if got.GasPrice.String() != tt.wantGasPrice {
+				t.Errorf("gas price = %s, want %s", got.GasPrice, tt.wantGasPrice)
+			}
+			if got.Total.String() != tt.wantTotal || got.TotalEther != tt.wantTotalEther {
+				t.Errorf("total = %s (%s ether), want %s (%s ether)", got.Total, got.TotalEther, tt.wantTotal, tt.wantTotalEther)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"nft-marketplace/db"
+	"nft-marketplace/metrics"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+)
+
+var (
+	staleListingsChecked = metrics.NewCounter("nft_stale_listings_checked_total",
+		"Stale DB listings re-verified on-chain.")
+	staleListingsExpired = metrics.NewCounter("nft_stale_listings_expired_total",
+		"Stale DB listings marked inactive because they are no longer active on-chain.")
+	staleListingsFailed = metrics.NewCounter("nft_stale_listings_failed_total",
+		"Stale DB listings whose on-chain status could not be checked.")
+)
+
+// ExpireStaleListings re-verifies up to batchSize DB listings that are active
+// but were not updated for maxAge, and marks inactive the ones no longer active
+// on-chain, for instance because their ListingCancelled event was missed.
+//
+// A listing with a recorded listing ID is checked with listings(id), which
+// must be active and still hold the same token; one without is checked with
+// isTokenListed. Rows that are still active have their updated_at refreshed so
+// they are not checked again before maxAge elapses. Checks are throttled to
+// ReconcileRPS per second and a failed check does not abort the run.
+func (es *EthereumService) ExpireStaleListings(ctx context.Context, maxAge time.Duration, batchSize int) (ReconcileReport, error) {
+	var report ReconcileReport
+
+	contract, err := es.marketplaceContract()
+	if err != nil {
+		return report, err
+	}
+
+	nfts, err := db.GetStaleActiveNFTs(es.clock().Now().Add(-maxAge), batchSize)
+	if err != nil {
+		return report, fmt.Errorf("failed to load stale listings: %w", err)
+	}
+
+	throttle := newThrottle(es.clock(), es.ReconcileRPS)
+	for _, nft := range nfts {
+		if err := throttle.wait(ctx); err != nil {
+			return report, err
+		}
+
+		report.Checked++
+		staleListingsChecked.Inc()
+
+		tokenID, err := parseBigInt("token ID", nft.TokenID)
+		if err != nil {
+			log.Printf("Skipping NFT %d: %v", nft.ID, err)
+			report.Failed++
+			staleListingsFailed.Inc()
+			continue
+		}
+
+		opts := &bind.CallOpts{Context: ctx}
+		var active bool
+		if listingID, ok := new(big.Int).SetString(nft.ListingID, 10); ok {
+			listing, err := contract.Listings(opts, listingID)
+			if err != nil {
+				log.Printf("Failed to get listing %s: %v", nft.ListingID, err)
+				report.Failed++
+				staleListingsFailed.Inc()
+				continue
+			}
+			active = listing.IsActive && listing.TokenId.Cmp(tokenID) == 0
+		} else {
+			active, err = contract.IsTokenListed(opts, tokenID)
+			if err != nil {
+				log.Printf("Failed to check listing status of token %s: %v", nft.TokenID, err)
+				report.Failed++
+				staleListingsFailed.Inc()
+				continue
+			}
+		}
+
+		listingID := nft.ListingID
+		if !active {
+			listingID = ""
+		}
+		if err := db.UpdateNFTStatus(nft.ID, active, listingID); err != nil {
+			log.Printf("Failed to update NFT %d: %v", nft.ID, err)
+			report.Failed++
+			staleListingsFailed.Inc()
+			continue
+		}
+
+		if !active {
+			es.InvalidateQueries()
+			log.Printf("Expired stale listing of token %s", nft.TokenID)
+			report.Fixed++
+			staleListingsExpired.Inc()
+		}
+	}
+
+	return report, nil
+}
+
+// RunListingExpirer calls ExpireStaleListings every interval until ctx is
+// cancelled.
+func (es *EthereumService) RunListingExpirer(ctx context.Context, interval, maxAge time.Duration, batchSize int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := es.ExpireStaleListings(ctx, maxAge, batchSize)
+			if err != nil {
+				log.Printf("Stale listing expiry failed: %v", err)
+				continue
+			}
+			log.Printf("Stale listing expiry: checked=%d expired=%d failed=%d", report.Checked, report.Fixed, report.Failed)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"nft-marketplace/utils"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const defaultFeeCacheTTL = 5 * time.Second
+
+// FeeSuggestion is what a transaction sent now should pay, in wei per gas.
+//
+// On EIP-1559 chains BaseFee is the latest block's base fee, MaxPriorityFee the
+// suggested tip and MaxFee the recommended fee cap, twice the base fee plus the
+// tip, which survives several consecutive full blocks. On legacy chains only
+// GasPrice is set.
+type FeeSuggestion struct {
+	BaseFee        *utils.Wei `json:"base_fee,omitempty"`
+	MaxPriorityFee *utils.Wei `json:"max_priority_fee,omitempty"`
+	MaxFee         *utils.Wei `json:"max_fee,omitempty"`
+	GasPrice       *utils.Wei `json:"gas_price,omitempty"`
+	BlockNumber    uint64     `json:"block_number"`
+	Legacy         bool       `json:"legacy"`
+}
+
+// feeCache keeps the last fee suggestion read from the chain. Its mutex only
+// guards the cached value; concurrent refreshes share a single read through
+// refresh, so that a slow node does not hold up every caller behind the
+// lock.
+type feeCache struct {
+	mu         sync.Mutex
+	suggestion FeeSuggestion
+	expires    time.Time
+	refresh    singleflight.Group
+}
+
+// SuggestFees returns fee suggestions for transactions built by clients.
+// Suggestions are cached for FeeCacheTTL (5 seconds by default).
+func (es *EthereumService) SuggestFees(ctx context.Context) (FeeSuggestion, error) {
+	es.fees.mu.Lock()
+	suggestion, fresh := es.fees.suggestion, es.clock().Now().Before(es.fees.expires)
+	es.fees.mu.Unlock()
+	if fresh {
+		return suggestion, nil
+	}
+
+	if err := es.ready(); err != nil {
+		return FeeSuggestion{}, err
+	}
+
+	// As with dedupBackend, the shared read runs detached from the context
+	// of the caller that started it, and each caller stops waiting once its
+	// own context is done.
+	results := es.fees.refresh.DoChan("fees", func() (interface{}, error) {
+		shared, cancel := context.WithTimeout(context.WithoutCancel(ctx), sharedCallTimeout)
+		defer cancel()
+		suggestion, err := es.readFees(shared)
+		if err != nil {
+			return nil, err
+		}
+
+		ttl := es.FeeCacheTTL
+		if ttl <= 0 {
+			ttl = defaultFeeCacheTTL
+		}
+		es.fees.mu.Lock()
+		es.fees.suggestion = suggestion
+		es.fees.expires = es.clock().Now().Add(ttl)
+		es.fees.mu.Unlock()
+		return suggestion, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return FeeSuggestion{}, ctx.Err()
+	case res := <-results:
+		if res.Err != nil {
+			return FeeSuggestion{}, res.Err
+		}
+		return res.Val.(FeeSuggestion), nil
+	}
+}
+
+// readFees reads a fee suggestion from the chain.
+func (es *EthereumService) readFees(ctx context.Context) (FeeSuggestion, error) {
+	header, err := es.Client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return FeeSuggestion{}, fmt.Errorf("failed to get latest header: %w", err)
+	}
+
+	suggestion := FeeSuggestion{BlockNumber: header.Number.Uint64()}
+	if header.BaseFee != nil {
+		tip, err := es.Client.SuggestGasTipCap(ctx)
+		if err != nil {
+			return FeeSuggestion{}, fmt.Errorf("failed to suggest gas tip cap: %w", err)
+		}
+		suggestion.BaseFee = utils.NewWei(header.BaseFee)
+		suggestion.MaxPriorityFee = utils.NewWei(tip)
+		suggestion.MaxFee = utils.NewWei(new(big.Int).Add(new(big.Int).Mul(header.BaseFee, big.NewInt(2)), tip))
+	} else {
+		gasPrice, err := es.Client.SuggestGasPrice(ctx)
+		if err != nil {
+			return FeeSuggestion{}, fmt.Errorf("failed to suggest gas price: %w", err)
+		}
+		suggestion.GasPrice = utils.NewWei(gasPrice)
+		suggestion.Legacy = true
+	}
+	return suggestion, nil
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"nft-marketplace/blockchain/chaintest"
+	"nft-marketplace/clock"
+	"nft-marketplace/utils"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestSuggestFees(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseFee *big.Int
+		// advance is how long after the first suggestion the second is asked
+		// for.
+		advance     time.Duration
+		wantHeaders int
+		want        FeeSuggestion
+	}{
+		{
+			name: "dynamic fees", baseFee: big.NewInt(10), wantHeaders: 1,
+			want: FeeSuggestion{BaseFee: utils.NewWei(big.NewInt(10)), MaxPriorityFee: utils.NewWei(big.NewInt(2)), MaxFee: utils.NewWei(big.NewInt(22)), BlockNumber: 100},
+		},
+		{
+			name: "legacy", wantHeaders: 1,
+			want: FeeSuggestion{GasPrice: utils.NewWei(big.NewInt(20)), BlockNumber: 100, Legacy: true},
+		},
+		{
+			name: "expired", baseFee: big.NewInt(10), advance: defaultFeeCacheTTL, wantHeaders: 2,
+			want: FeeSuggestion{BaseFee: utils.NewWei(big.NewInt(10)), MaxPriorityFee: utils.NewWei(big.NewInt(2)), MaxFee: utils.NewWei(big.NewInt(22)), BlockNumber: 100},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := chaintest.NewNode(t)
+			node.Handle("eth_getBlockByNumber", chaintest.Returns(&types.Header{Number: big.NewInt(100), Difficulty: big.NewInt(0), BaseFee: tt.baseFee}))
+			node.Handle("eth_gasPrice", chaintest.Returns(hexutil.Big(*big.NewInt(20))))
+			node.Handle("eth_maxPriorityFeePerGas", chaintest.Returns(hexutil.Big(*big.NewInt(2))))
+
+			fake := clock.NewFake(time.Unix(1_800_000_000, 0))
+			es := &EthereumService{Client: node.Client, ContractAddress: chaintest.Contract, Clock: fake}
+			if _, err := es.SuggestFees(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			fake.Advance(tt.advance)
+			got, err := es.SuggestFees(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(tt.want)
+			if string(gotJSON) != string(wantJSON) {
+				t.Fatalf("suggestion = %s, want %s", gotJSON, wantJSON)
+			}
+			if n := node.Count("eth_getBlockByNumber"); n != tt.wantHeaders {
+				t.Fatalf("read %d headers, want %d", n, tt.wantHeaders)
+			}
+		})
+	}
+}
+
+func TestSuggestFeesSlowNode(t *testing.T) {
+	node := chaintest.NewNode(t)
+	release := make(chan struct{})
+	node.Handle("eth_getBlockByNumber", func([]json.RawMessage) (any, error) {
+		<-release
+		return &types.Header{Number: big.NewInt(100), Difficulty: big.NewInt(0)}, nil
+	})
+	node.Handle("eth_gasPrice", chaintest.Returns(hexutil.Big(*big.NewInt(20))))
+	es := &EthereumService{Client: node.Client, ContractAddress: chaintest.Contract}
+
+	// A caller giving up on a slow node gets its own error at once instead
+	// of waiting behind the read.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	waiting := make(chan error)
+	go func() {
+		_, err := es.SuggestFees(context.Background())
+		waiting <- err
+	}()
+	if _, err := es.SuggestFees(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// The read it gave up on still completes for the callers waiting on it,
+	// and both were served by a single one.
+	close(release)
+	if err := <-waiting; err != nil {
+		t.Fatal(err)
+	}
+	if n := node.Count("eth_getBlockByNumber"); n != 1 {
+		t.Fatalf("read %d headers, want 1", n)
+	}
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"nft-marketplace/events"
+	"nft-marketplace/utils"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	HistoryListed    = "listed"
+	HistorySold      = "sold"
+	HistoryCancelled = "cancelled"
+)
+
+// HistoryEntry is a single step in a token's marketplace history.
+type HistoryEntry struct {
+	Type        string         `json:"type"`
+	ListingID   *big.Int       `json:"listing_id"`
+	Account     common.Address `json:"account"`
+	Price       *utils.Wei     `json:"price,omitempty"`
+	BlockNumber uint64         `json:"block_number"`
+	TxHash      common.Hash    `json:"tx_hash"`
+	Timestamp   time.Time      `json:"timestamp"`
+}
+
+// GetListingHistory returns every listing, sale and cancellation of the given
+// token, oldest first.
+//
+// Account is the seller for listings and cancellations and the buyer for sales.
+// The history is rebuilt from the marketplace's ListingCreated,
+// PurchaseCompleted and ListingCancelled events; timestamps are those of the
+// blocks the events were emitted in, as returned by BlockTime.
+func (es *EthereumService) GetListingHistory(ctx context.Context, tokenID string) ([]HistoryEntry, error) {
+	token, err := parseBigInt("token ID", tokenID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := es.ready(); err != nil {
+		return nil, err
+	}
+
+	topics := make([]common.Hash, 0, 3)
+	for _, name := range []string{events.ListingCreatedEvent, events.PurchaseCompletedEvent, events.ListingCancelledEvent} {
+		topic, err := events.Topic(name)
+		if err != nil {
+			return nil, err
+		}
+		topics = append(topics, topic)
+	}
+
+	logs, err := es.Client.FilterLogs(ctx, ethereum.FilterQuery{
+		Addresses: []common.Address{es.ContractAddress},
+		Topics:    [][]common.Hash{topics},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to filter listing events: %w", err)
+	}
+
+	sort.SliceStable(logs, func(i, j int) bool {
+		if logs[i].BlockNumber != logs[j].BlockNumber {
+			return logs[i].BlockNumber < logs[j].BlockNumber
+		}
+		return logs[i].Index < logs[j].Index
+	})
+
+	// Sales and cancellations only carry the listing ID, so remember which
+	// listings belong to the token as their ListingCreated events go by.
+	listings := make(map[string]bool)
+	history := make([]HistoryEntry, 0)
+	for _, l := range logs {
+		entry, ok := historyEntry(l, token, listings)
+		if ok {
+			history = append(history, entry)
+		}
+	}
+
+	for i := range history {
+		history[i].Timestamp, err = es.BlockTime(ctx, history[i].BlockNumber)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return history, nil
+}
+
+// historyEntry converts l into a history entry if it concerns token.
+func historyEntry(l types.Log, token *big.Int, listings map[string]bool) (HistoryEntry, bool) {
+	entry := HistoryEntry{BlockNumber: l.BlockNumber, TxHash: l.TxHash}
+
+	if created, err := events.ParseListingCreated(l); err == nil {
+		if created.TokenID.Cmp(token) != 0 {
+			return entry, false
+		}
+		listings[created.ID.String()] = true
+		entry.Type, entry.ListingID, entry.Account, entry.Price = HistoryListed, created.ID, created.Seller, utils.NewWei(created.Price)
+		return entry, true
+	}
+
+	if purchased, err := events.ParsePurchaseCompleted(l); err == nil {
+		if purchased.TokenID.Cmp(token) != 0 {
+			return entry, false
+		}
+		entry.Type, entry.ListingID, entry.Account, entry.Price = HistorySold, purchased.ID, purchased.Buyer, utils.NewWei(purchased.Price)
+		return entry, true
+	}
+
+	if cancelled, err := events.ParseListingCancelled(l); err == nil {
+		if !listings[cancelled.ID.String()] {
+			return entry, false
+		}
+		entry.Type, entry.ListingID, entry.Account = HistoryCancelled, cancelled.ID, cancelled.Seller
+		return entry, true
+	}
+
+	log.Printf("Skipping undecodable log %d in tx %s", l.Index, l.TxHash.Hex())
+	return entry, false
+}
@@ -0,0 +1,402 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"nft-marketplace/db"
+	"nft-marketplace/events"
+	"sort"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// defaultMaxReorgDepth is the deepest reorg the indexer rolls back when
+// MaxReorgDepth is zero.
+const defaultMaxReorgDepth = uint64(64)
+
+// ErrReorgTooDeep is returned by RunIndexer when the stored events diverge from
+// the chain further back than MaxReorgDepth blocks.
+var ErrReorgTooDeep = errors.New("reorg deeper than the maximum rollback depth")
+
+// IndexedEvents are the marketplace events stored by the indexer.
+var IndexedEvents = []string{
+	events.ListingCreatedEvent,
+	events.PurchaseCompletedEvent,
+	events.ListingCancelledEvent,
+	events.FundsWithdrawnEvent,
+	events.CommissionUpdatedEvent,
+}
+
+// RunIndexer stores every marketplace event into the events table until ctx is
+// cancelled, subscribing through wsURL.
+//
+// Indexing resumes at the block of the last stored event, or at startBlock on
+// an empty store, so events emitted while the indexer was down are backfilled.
+// Events already stored are skipped and events dropped by a reorg are deleted.
+// Every PurchaseCompleted is also recorded in the sales ledger, see recordSale.
+// Events that fail to be stored are retried with backoff before any later one
+// is indexed, so a database outage delays indexing but leaves no gap.
+// Cached query results are invalidated whenever events are stored or removed,
+// since they announce listing changes.
+//
+// Reorgs the subscription does not report as removed logs are caught by
+// comparing the hash of the latest stored block with the chain's whenever
+// events of a new block arrive, and once on startup. On a mismatch the store
+// is rolled back to the last block both still agree on and the events after
+// it are indexed again from the canonical chain. RunIndexer fails with
+// ErrReorgTooDeep rather than roll back more than MaxReorgDepth blocks.
+func (es *EthereumService) RunIndexer(ctx context.Context, wsURL string, startBlock uint64) error {
+	if err := es.ready(); err != nil {
+		return err
+	}
+	ctx, cancel := es.untilClosed(ctx)
+	defer cancel()
+
+	from := startBlock
+	latest, found, err := db.GetLatestEventBlock()
+	if err != nil {
+		return fmt.Errorf("failed to get latest indexed block: %w", err)
+	}
+	if found {
+		header, err := es.Client.HeaderByNumber(ctx, new(big.Int).SetUint64(latest))
+		if err != nil {
+			return fmt.Errorf("failed to get header of block %d: %w", latest, err)
+		}
+		ancestor, err := es.checkIndexedBlocks(ctx, latest, header.Hash())
+		if err != nil {
+			return err
+		}
+		from = min(latest, ancestor+1)
+	}
+
+	topics, err := indexedTopics()
+	if err != nil {
+		return err
+	}
+
+	subscriber := NewLogSubscriber(wsURL, ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(from),
+		Addresses: []common.Address{es.ContractAddress},
+		Topics:    [][]common.Hash{topics},
+	})
+
+	log.Printf("Indexing marketplace events from block %d", from)
+	var checked common.Hash
+	for l := range subscriber.Subscribe(ctx) {
+		if l.Removed {
+			err := es.retryIndexing(ctx, fmt.Sprintf("delete removed event %d in tx %s", l.Index, l.TxHash.Hex()), func() error {
+				if err := db.DeleteEvent(l.BlockNumber, l.Index, l.BlockHash.Hex()); err != nil {
+					return err
+				}
+				return db.DeleteSale(l.TxHash.Hex(), l.Index)
+			})
+			if err != nil {
+				return err
+			}
+			es.InvalidateQueries()
+			continue
+		}
+
+		if l.BlockHash != checked {
+			ancestor, err := es.checkIndexedBlocks(ctx, l.BlockNumber, l.BlockHash)
+			if err != nil {
+				if errors.Is(err, ErrReorgTooDeep) {
+					return err
+				}
+				log.Printf("Failed to check block %d for a reorg: %v", l.BlockNumber, err)
+			} else if ancestor < l.BlockNumber {
+				// The events up to this block were indexed again; later
+				// ones still arrive through the subscription.
+				if err := es.reindexEvents(ctx, topics, ancestor+1, l.BlockNumber); err != nil {
+					if ctx.Err() != nil {
+						return context.Cause(ctx)
+					}
+					log.Printf("Skipped undecodable logs reindexing blocks %d-%d after a reorg: %v", ancestor+1, l.BlockNumber, err)
+				}
+				checked = l.BlockHash
+				continue
+			}
+			checked = l.BlockHash
+		}
+
+		if err := es.storeEvent(ctx, l); err != nil {
+			return err
+		}
+	}
+
+	return context.Cause(ctx)
+}
+
+// checkIndexedBlocks compares the stored events with the chain, whose block at
+// height has the given hash, and rolls back those a reorg dropped.
+//
+// It returns the last stored block still on the chain, or height itself when
+// nothing had to be rolled back. Only blocks events were stored from are
+// compared, from the newest down, as long as they are within MaxReorgDepth
+// blocks of height.
+func (es *EthereumService) checkIndexedBlocks(ctx context.Context, height uint64, hash common.Hash) (uint64, error) {
+	depth := es.MaxReorgDepth
+	if depth == 0 {
+		depth = defaultMaxReorgDepth
+	}
+
+	blocks, err := db.GetEventBlocks(height+1, int(depth)+2)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get indexed blocks: %w", err)
+	}
+	if len(blocks) == 0 {
+		return height, nil
+	}
+
+	for i, block := range blocks {
+		canonical := hash
+		if block.Number != height {
+			header, err := es.Client.HeaderByNumber(ctx, new(big.Int).SetUint64(block.Number))
+			if err != nil {
+				return 0, fmt.Errorf("failed to get header of block %d: %w", block.Number, err)
+			}
+			canonical = header.Hash()
+		}
+
+		if canonical.Hex() == block.Hash {
+			if i == 0 {
+				return height, nil
+			}
+			return block.Number, es.rollbackEvents(block.Number, height)
+		}
+		if block.Number+depth < height {
+			return 0, fmt.Errorf("%w: block %d differs from the chain, %d blocks below %d", ErrReorgTooDeep, block.Number, height-block.Number, height)
+		}
+	}
+
+	// No stored block is on the chain any more, and all of them are within
+	// reach: roll them all back.
+	ancestor := blocks[len(blocks)-1].Number
+	if ancestor > 0 {
+		ancestor--
+	}
+	return ancestor, es.rollbackEvents(ancestor, height)
+}
+
+// rollbackEvents deletes the events stored above ancestor after a reorg was
+// detected at height.
+func (es *EthereumService) rollbackEvents(ancestor, height uint64) error {
+	log.Printf("Reorg detected at block %d, rolling indexed events back to block %d", height, ancestor)
+	if err := db.DeleteEventsAfter(ancestor); err != nil {
+		return fmt.Errorf("failed to roll back events after block %d: %w", ancestor, err)
+	}
+	if err := db.DeleteSalesAfter(ancestor); err != nil {
+		return fmt.Errorf("failed to roll back sales after block %d: %w", ancestor, err)
+	}
+	es.InvalidateQueries()
+	return nil
+}
+
+// reindexEvents stores the marketplace events of the canonical chain from
+// block from to block to. Logs that fail to decode are skipped, the others
+// still being stored, and reported together in the returned error. Failures to
+// fetch or store events are retried, see retryIndexing; the only other error
+// returned is the cause of ctx.
+func (es *EthereumService) reindexEvents(ctx context.Context, topics []common.Hash, from, to uint64) error {
+	var logs []types.Log
+	err := es.retryIndexing(ctx, fmt.Sprintf("filter events in blocks %d-%d", from, to), func() error {
+		var err error
+		logs, err = es.Client.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(from),
+			ToBlock:   new(big.Int).SetUint64(to),
+			Addresses: []common.Address{es.ContractAddress},
+			Topics:    [][]common.Hash{topics},
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.SliceStable(logs, func(i, j int) bool {
+		if logs[i].BlockNumber != logs[j].BlockNumber {
+			return logs[i].BlockNumber < logs[j].BlockNumber
+		}
+		return logs[i].Index < logs[j].Index
+	})
+	decoded, decodeErr := decodeEvents(logs)
+	for i := range decoded {
+		if err := es.saveEventRetrying(ctx, decoded[i].log, decoded[i].event); err != nil {
+			return err
+		}
+	}
+	return decodeErr
+}
+
+// storeEvent decodes and stores l, and records the sale of purchases.
+//
+// Logs that fail to decode are logged and skipped, so that one bad log does
+// not stop the indexer. Failures to store are retried until they succeed:
+// skipping the event would let later ones move the indexer past it for good.
+// The error is the cause of ctx when it is cancelled in the meantime.
+func (es *EthereumService) storeEvent(ctx context.Context, l types.Log) error {
+	event, err := decodeEvent(l)
+	if err != nil {
+		log.Printf("Skipping undecodable log %d in tx %s: %v", l.Index, l.TxHash.Hex(), err)
+		return nil
+	}
+	return es.saveEventRetrying(ctx, l, event)
+}
+
+// saveEventRetrying is saveEvent retried with retryIndexing.
+func (es *EthereumService) saveEventRetrying(ctx context.Context, l types.Log, event db.Event) error {
+	return es.retryIndexing(ctx, fmt.Sprintf("store event %d in tx %s", l.Index, l.TxHash.Hex()), func() error {
+		return es.saveEvent(ctx, l, event)
+	})
+}
+
+// saveEvent stores event, decoded from l, and records the sale of purchases.
+// Both are skipped when already stored, so it can be retried.
+func (es *EthereumService) saveEvent(ctx context.Context, l types.Log, event db.Event) error {
+	if err := db.SaveEvents([]db.Event{event}); err != nil {
+		return err
+	}
+	if event.Type == events.PurchaseCompletedEvent {
+		if err := es.recordSale(ctx, l); err != nil {
+			return fmt.Errorf("failed to record sale: %w", err)
+		}
+	}
+	es.InvalidateQueries()
+	return nil
+}
+
+// retryIndexing runs fn until it succeeds, logging its failures to do what
+// and waiting with exponential backoff, from defaultMinBackoff to
+// defaultMaxBackoff, in between. It returns the cause of ctx once it is
+// cancelled.
+func (es *EthereumService) retryIndexing(ctx context.Context, what string, fn func() error) error {
+	retry := backoff{min: defaultMinBackoff, max: defaultMaxBackoff}
+	for {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+
+		delay := retry.next()
+		log.Printf("Failed to %s, retrying in %s: %v", what, delay, err)
+		if err := sleep(ctx, es.clock(), delay); err != nil {
+			return err
+		}
+	}
+}
+
+// indexedTopics returns the topics of IndexedEvents.
+func indexedTopics() ([]common.Hash, error) {
+	topics := make([]common.Hash, 0, len(IndexedEvents))
+	for _, name := range IndexedEvents {
+		topic, err := events.Topic(name)
+		if err != nil {
+			return nil, err
+		}
+		topics = append(topics, topic)
+	}
+	return topics, nil
+}
+
+// decodedLog is a log of a batch along with its stored form.
+type decodedLog struct {
+	log   types.Log
+	event db.Event
+}
+
+// decodeEvents decodes a batch of marketplace logs, keeping their order.
+//
+// A log that fails to decode does not stop the batch: it is logged with its
+// index and topic and left out, and its error is joined to those of the other
+// failed logs in the returned error. The logs that decoded are returned even
+// when the error is not nil.
+func decodeEvents(logs []types.Log) ([]decodedLog, error) {
+	decoded := make([]decodedLog, 0, len(logs))
+	var errs []error
+	for _, l := range logs {
+		event, err := decodeEvent(l)
+		if err != nil {
+			topic := "none"
+			if len(l.Topics) > 0 {
+				topic = l.Topics[0].Hex()
+			}
+			log.Printf("Skipping undecodable log %d with topic %s in tx %s: %v", l.Index, topic, l.TxHash.Hex(), err)
+			errs = append(errs, fmt.Errorf("log %d with topic %s in tx %s: %w", l.Index, topic, l.TxHash.Hex(), err))
+			continue
+		}
+		decoded = append(decoded, decodedLog{log: l, event: event})
+	}
+	return decoded, errors.Join(errs...)
+}
+
+// decodeEvent converts a marketplace log into its stored form.
+func decodeEvent(l types.Log) (db.Event, error) {
+	event := db.Event{BlockNumber: l.BlockNumber, LogIndex: l.Index, BlockHash: l.BlockHash.Hex(), TxHash: l.TxHash.Hex()}
+
+	if len(l.Topics) == 0 {
+		return event, fmt.Errorf("%w: log has no topics", events.ErrUnexpectedEvent)
+	}
+	for _, name := range IndexedEvents {
+		topic, err := events.Topic(name)
+		if err != nil {
+			return event, err
+		}
+		if topic == l.Topics[0] {
+			event.Type = name
+			break
+		}
+	}
+
+	switch event.Type {
+	case events.ListingCreatedEvent:
+		created, err := events.ParseListingCreated(l)
+		if err != nil {
+			return event, err
+		}
+		event.ListingID, event.TokenID, event.Account = created.ID.String(), created.TokenID.String(), created.Seller.Hex()
+		event.Value = bigString(created.Price)
+	case events.PurchaseCompletedEvent:
+		purchased, err := events.ParsePurchaseCompleted(l)
+		if err != nil {
+			return event, err
+		}
+		event.ListingID, event.TokenID, event.Account = purchased.ID.String(), purchased.TokenID.String(), purchased.Buyer.Hex()
+		event.Value = bigString(purchased.Price)
+	case events.ListingCancelledEvent:
+		cancelled, err := events.ParseListingCancelled(l)
+		if err != nil {
+			return event, err
+		}
+		event.ListingID, event.Account = cancelled.ID.String(), cancelled.Seller.Hex()
+	case events.FundsWithdrawnEvent:
+		withdrawn, err := events.ParseFundsWithdrawn(l)
+		if err != nil {
+			return event, err
+		}
+		event.Account = withdrawn.Recipient.Hex()
+		event.Value = bigString(withdrawn.Amount)
+	case events.CommissionUpdatedEvent:
+		updated, err := events.ParseCommissionUpdated(l)
+		if err != nil {
+			return event, err
+		}
+		event.Value = bigString(updated.NewPercent)
+	default:
+		return event, fmt.Errorf("%w: unknown topic %s", events.ErrUnexpectedEvent, l.Topics[0].Hex())
+	}
+
+	return event, nil
+}
+
+func bigString(n *big.Int) *string {
+	s := n.String()
+	return &s
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"nft-marketplace/blockchain/chaintest"
+	"nft-marketplace/db/dbtest"
+	"nft-marketplace/events"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// instantClock is a clock whose waits are over at once.
+type instantClock struct{}
+
+func (instantClock) Now() time.Time { return time.Unix(1_700_000_000, 0) }
+
+func (instantClock) After(time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	ch <- instantClock{}.Now()
+	return ch
+}
+
+func commissionUpdatedLog(t *testing.T, percent int64) types.Log {
+	t.Helper()
+
+	topic, err := events.Topic(events.CommissionUpdatedEvent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uint256, err := abi.NewType("uint256", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := abi.Arguments{{Type: uint256}}.Pack(big.NewInt(percent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return types.Log{
+		Topics:      []common.Hash{topic},
+		Data:        data,
+		BlockNumber: 42,
+		BlockHash:   common.HexToHash("0xb10c"),
+		TxHash:      common.HexToHash("0x7e"),
+		Index:       3,
+	}
+}
+
+func TestStoreEvent(t *testing.T) {
+	errDown := errors.New("connection refused")
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		log  func(t *testing.T) types.Log
+		// failures is how many inserts fail before one succeeds; -1 means
+		// none is expected.
+		failures int
+		wantErr  error
+	}{
+		{name: "stored", ctx: context.Background(), failures: 0},
+		{name: "retried until stored", ctx: context.Background(), failures: 3},
+		{name: "stops when cancelled", ctx: cancelled, failures: 1, wantErr: context.Canceled},
+		{
+			name:     "undecodable log skipped",
+			ctx:      context.Background(),
+			log:      func(*testing.T) types.Log { return types.Log{Topics: []common.Hash{common.HexToHash("0x1")}} },
+			failures: -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := dbtest.Mock(t)
+			for i := 0; i < tt.failures; i++ {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`INSERT INTO "events"`).WillReturnError(errDown)
+				mock.ExpectRollback()
+			}
+			if tt.failures >= 0 && tt.wantErr == nil {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`INSERT INTO "events"`).
+					WithArgs(events.CommissionUpdatedEvent, 42, 3, common.HexToHash("0xb10c").Hex(), common.HexToHash("0x7e").Hex(), "", "", "", "250", sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mock.ExpectCommit()
+			}
+
+			l := commissionUpdatedLog(t, 250)
+			if tt.log != nil {
+				l = tt.log(t)
+			}
+			es := &EthereumService{Clock: instantClock{}}
+			if err := es.storeEvent(tt.ctx, l); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("storeEvent() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckIndexedBlocks(t *testing.T) {
+	canonical := func(number uint64) *types.Header {
+		return &types.Header{Number: new(big.Int).SetUint64(number), Difficulty: big.NewInt(0)}
+	}
+	stale := common.HexToHash("0xdead").Hex()
+	const height = 42
+
+	tests := []struct {
+		name     string
+		maxDepth uint64
+		// order lists the blocks events were stored from, newest first, and
+		// stored whether each was stored with its canonical hash.
+		order        []uint64
+		stored       map[uint64]bool
+		wantAncestor uint64
+		// rollback is whether the events after wantAncestor are deleted.
+		rollback bool
+		wantErr  error
+	}{
+		{name: "no reorg", stored: map[uint64]bool{42: true, 40: true}, order: []uint64{42, 40}, wantAncestor: height},
+		{name: "rolled back to the common block", stored: map[uint64]bool{42: false, 40: true}, order: []uint64{42, 40}, wantAncestor: 40, rollback: true},
+		{name: "every stored block dropped", stored: map[uint64]bool{42: false, 41: false}, order: []uint64{42, 41}, wantAncestor: 40, rollback: true},
+		{name: "too deep", maxDepth: 1, stored: map[uint64]bool{42: false, 40: false}, order: []uint64{42, 40}, wantErr: ErrReorgTooDeep},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := chaintest.NewNode(t)
+			node.Handle("eth_getBlockByNumber", func(params []json.RawMessage) (any, error) {
+				var number hexutil.Big
+				if err := json.Unmarshal(params[0], &number); err != nil {
+					return nil, err
+				}
+				return canonical(number.ToInt().Uint64()), nil
+			})
+
+			depth := tt.maxDepth
+			if depth == 0 {
+				depth = defaultMaxReorgDepth
+			}
+			rows := sqlmock.NewRows([]string{"number", "hash"})
+			for _, number := range tt.order {
+				hash := stale
+				if tt.stored[number] {
+					hash = canonical(number).Hash().Hex()
+				}
+				rows.AddRow(number, hash)
+			}
+			mock := dbtest.Mock(t)
+			mock.ExpectQuery(`SELECT DISTINCT block_number AS number,block_hash AS hash FROM "events" WHERE block_number < \$1 AND block_hash <> ''`).
+				WithArgs(height+1, depth+2).WillReturnRows(rows)
+			if tt.rollback {
+				for _, table := range []string{"events", "sales"} {
+					mock.ExpectBegin()
+					mock.ExpectExec(`DELETE FROM "` + table + `" WHERE block_number > \$1`).WithArgs(tt.wantAncestor).
+						WillReturnResult(sqlmock.NewResult(0, 2))
+					mock.ExpectCommit()
+				}
+			}
+
+			es := &EthereumService{Client: node.Client, ContractAddress: chaintest.Contract, MaxReorgDepth: tt.maxDepth}
+			ancestor, err := es.checkIndexedBlocks(context.Background(), height, canonical(height).Hash())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("checkIndexedBlocks() = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && ancestor != tt.wantAncestor {
+				t.Fatalf("checkIndexedBlocks() = %d, want %d", ancestor, tt.wantAncestor)
+			}
+		})
+	}
+}
@@ -0,0 +1,368 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	marketplace "nft-marketplace/blockchain"
+	"nft-marketplace/db"
+	"nft-marketplace/events"
+	"nft-marketplace/utils"
+	"sort"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// defaultLogScanChunkSize is the number of blocks scanned per eth_getLogs call
+// when LogScanChunkSize is zero. Many providers reject larger ranges.
+const defaultLogScanChunkSize = uint64(5000)
+
+// marketplaceContract returns a typed binding of the marketplace contract backed by the
+// service's client.
+func (es *EthereumService) marketplaceContract() (*marketplace.Marketplace, error) {
+	if err := es.ready(); err != nil {
+		return nil, err
+	}
+
+	contract, err := marketplace.NewMarketplace(es.ContractAddress, es.backend())
+	if err != nil {
+		return nil, fmt.Errorf("failed to bind marketplace contract: %w", err)
+	}
+
+	return contract, nil
+}
+
+// GetChainListings returns the listings that are currently active on-chain,
+// replaying the marketplace's events from DeployBlock. It does not touch
+// the database, which makes it usable as a fallback source when the database
+// is unavailable.
+func (es *EthereumService) GetChainListings(ctx context.Context) ([]NFTListing, error) {
+	return es.GetAllActiveListings(ctx, nil)
+}
+
+// GetSellerNFTs returns the NFTs the database records as listed by seller,
+// cached in QueryCache.
+func (es *EthereumService) GetSellerNFTs(ctx context.Context, seller common.Address) ([]db.Nfts, error) {
+	nfts, err := cachedQuery(es, "seller:"+seller.Hex(), func() ([]db.Nfts, error) {
+		return db.GetNFTsBySeller(ctx, seller.Hex())
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get NFTs of seller %s: %w", seller.Hex(), err)
+	}
+	return nfts, nil
+}
+
+// sellerNFTsPage is a page of GetSellerNFTsPage, as cached.
+type sellerNFTsPage struct {
+	nfts  []db.Nfts
+	total int64
+}
+
+// GetSellerNFTsPage returns up to limit of the NFTs the database records as
+// listed by seller, skipping the first offset, and how many there are in
+// total. Only the page is read from the database; it is cached in QueryCache.
+// A non-positive limit returns all of them.
+func (es *EthereumService) GetSellerNFTsPage(ctx context.Context, seller common.Address, limit, offset int) ([]db.Nfts, int64, error) {
+	key := fmt.Sprintf("seller-page:%s:%d:%d", seller.Hex(), limit, offset)
+	page, err := cachedQuery(es, key, func() (sellerNFTsPage, error) {
+		nfts, total, err := db.GetNFTsBySellerPage(ctx, seller.Hex(), limit, offset)
+		return sellerNFTsPage{nfts: nfts, total: total}, err
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get NFTs of seller %s: %w", seller.Hex(), err)
+	}
+	return page.nfts, page.total, nil
+}
+
+// GetAllActiveListings reconstructs the marketplace's active listings from its
+// events, without needing to know the sellers.
+//
+// The ListingCreated, ListingCancelled and PurchaseCompleted events emitted
+// from fromBlock (DeployBlock when nil) to the latest block are scanned
+// in chunks of LogScanChunkSize blocks and replayed in order: created listings
+// enter the set and cancelled or purchased ones leave it. Each remaining
+// listing is then verified with a listings(id) read, which also provides its
+// current price, and dropped if it is no longer active. Listings are returned
+// ordered by ID.
+//
+// Logs that fail to decode are logged and skipped rather than failing the
+// scan, so that one malformed log does not lose the listings of the others.
+func (es *EthereumService) GetAllActiveListings(ctx context.Context, fromBlock *big.Int) ([]NFTListing, error) {
+	contract, err := es.marketplaceContract()
+	if err != nil {
+		return nil, err
+	}
+
+	topics := make([]common.Hash, 0, 3)
+	for _, name := range []string{events.ListingCreatedEvent, events.ListingCancelledEvent, events.PurchaseCompletedEvent} {
+		topic, err := events.Topic(name)
+		if err != nil {
+			return nil, err
+		}
+		topics = append(topics, topic)
+	}
+
+	header, err := es.Client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest header: %w", err)
+	}
+	latest := header.Number.Uint64()
+
+	chunk := es.LogScanChunkSize
+	if chunk == 0 {
+		chunk = defaultLogScanChunkSize
+	}
+
+	start := es.DeployBlock
+	if fromBlock != nil {
+		start = fromBlock.Uint64()
+	}
+
+	active := make(map[string]*big.Int)
+	var decodeErrs []error
+	for from := start; from <= latest; from += chunk {
+		to := min(from+chunk-1, latest)
+
+		logs, err := es.Client.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(from),
+			ToBlock:   new(big.Int).SetUint64(to),
+			Addresses: []common.Address{es.ContractAddress},
+			Topics:    [][]common.Hash{topics},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to filter listing events in blocks %d-%d: %w", from, to, err)
+		}
+
+		sort.SliceStable(logs, func(i, j int) bool {
+			if logs[i].BlockNumber != logs[j].BlockNumber {
+				return logs[i].BlockNumber < logs[j].BlockNumber
+			}
+			return logs[i].Index < logs[j].Index
+		})
+
+		decoded, err := decodeEvents(logs)
+		if err != nil {
+			decodeErrs = append(decodeErrs, err)
+		}
+		for _, d := range decoded {
+			switch d.event.Type {
+			case events.ListingCreatedEvent:
+				id, ok := new(big.Int).SetString(d.event.ListingID, 10)
+				if ok {
+					active[d.event.ListingID] = id
+				}
+			case events.ListingCancelledEvent, events.PurchaseCompletedEvent:
+				delete(active, d.event.ListingID)
+			}
+		}
+	}
+	if err := errors.Join(decodeErrs...); err != nil {
+		log.Printf("Rebuilt active listings without some undecodable events: %v", err)
+	}
+
+	ids := make([]*big.Int, 0, len(active))
+	for _, id := range active {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].Cmp(ids[j]) < 0 })
+
+	listings := make([]NFTListing, 0, len(ids))
+	for _, id := range ids {
+		listing, err := contract.Listings(&bind.CallOpts{Context: ctx}, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get listing %s: %w", id, err)
+		}
+		if !listing.IsActive {
+			continue
+		}
+
+		listings = append(listings, NFTListing{
+			ListingID: id,
+			Seller:    listing.Seller,
+			TokenID:   listing.TokenId,
+			Price:     utils.NewWei(listing.Price),
+			IsActive:  listing.IsActive,
+		})
+	}
+
+	return listings, nil
+}
+
+// CancelListing cancels the listing with the given ID on behalf of caller and
+// returns the transaction hash.
+//
+// Only the owner of the listing may cancel it, otherwise ErrNotListingOwner is
+// returned before anything is sent. The owner is the listing's on-chain
+// seller, except for listings the platform created on a user's behalf in
+// MintNFT: their on-chain seller is the service's own account, so the owner is
+// the seller recorded in the database for the token.
+func (es *EthereumService) CancelListing(ctx context.Context, caller common.Address, listingID string) (common.Hash, error) {
+	id, err := parseBigInt("listing ID", listingID)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	contract, err := es.marketplaceContract()
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	return es.cancelListing(ctx, contract, caller, id, nil)
+}
+
+// cancelListing cancels listing id on behalf of caller, sending the
+// transaction with the given nonce, or the account's pending one when nil.
+func (es *EthereumService) cancelListing(ctx context.Context, contract *marketplace.Marketplace, caller common.Address, id, nonce *big.Int) (common.Hash, error) {
+	listingID := id.String()
+	listing, err := contract.Listings(&bind.CallOpts{Context: ctx}, id)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("failed to get listing %s: %w", listingID, err)
+	}
+	if !listing.IsActive {
+		return common.Hash{}, ErrListingNotActive
+	}
+
+	owner, err := es.listingOwner(listing.Seller, listing.TokenId)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if !utils.SameAddress(owner, caller) {
+		log.Printf("Refusing to cancel listing %s: caller %s is not the owner", listingID, caller.Hex())
+		return common.Hash{}, ErrNotListingOwner
+	}
+
+	auth, err := es.newTransactor(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	// Durable nonces are reserved when signing, whatever the caller gave.
+	if !es.DurableNonces {
+		auth.Nonce = nonce
+	}
+
+	tx, err := contract.CancelListing(auth, id)
+	if err != nil {
+		es.releaseUnsentNonce(ctx, auth)
+		return common.Hash{}, fmt.Errorf("failed to cancel listing: %w", err)
+	}
+
+	log.Printf("Listing %s cancelled! Transaction hash: %s", listingID, tx.Hash().Hex())
+	return tx.Hash(), nil
+}
+
+// ListingCancellation is the outcome of cancelling one listing in
+// CancelAllListings. Error is set instead of TxHash when it failed.
+type ListingCancellation struct {
+	ListingID string      `json:"listing_id"`
+	TokenID   string      `json:"token_id"`
+	TxHash    common.Hash `json:"tx_hash"`
+	Error     string      `json:"error,omitempty"`
+}
+
+// CancelAllListings cancels every active listing owned by seller, as defined
+// by CancelListing, and returns the outcome of each.
+//
+// Listings are enumerated with getListingsBySeller for seller and for the
+// service's own account, keeping those owned by seller. They are then
+// cancelled one after another with consecutive nonces: the pending nonce is
+// read once and only advanced by transactions that were sent, so a failed
+// cancellation neither stops the others nor leaves a nonce gap. The error is
+// only set when the listings cannot be enumerated.
+func (es *EthereumService) CancelAllListings(ctx context.Context, seller common.Address) ([]ListingCancellation, error) {
+	contract, err := es.marketplaceContract()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := es.readySigner()
+	if err != nil {
+		return nil, err
+	}
+	account := crypto.PubkeyToAddress(key.PublicKey)
+
+	sellers := []common.Address{seller}
+	if account != seller {
+		sellers = append(sellers, account)
+	}
+
+	opts := &bind.CallOpts{Context: ctx}
+	results := make([]ListingCancellation, 0)
+	var ids []*big.Int
+	for _, s := range sellers {
+		listings, err := contract.GetListingsBySeller(opts, s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get listings of %s: %w", s.Hex(), err)
+		}
+
+		for _, listing := range listings {
+			if !listing.IsActive {
+				continue
+			}
+			result := ListingCancellation{TokenID: listing.TokenId.String()}
+
+			owner, err := es.listingOwner(listing.Seller, listing.TokenId)
+			if err != nil {
+				result.Error = err.Error()
+				results, ids = append(results, result), append(ids, nil)
+				continue
+			}
+			if !utils.SameAddress(owner, seller) {
+				continue
+			}
+
+			id, err := contract.GetListingId(opts, listing.TokenId)
+			if err != nil {
+				result.Error = fmt.Sprintf("failed to get listing ID of token %s: %v", listing.TokenId, err)
+				results, ids = append(results, result), append(ids, nil)
+				continue
+			}
+			result.ListingID = id.String()
+			results, ids = append(results, result), append(ids, id)
+		}
+	}
+
+	nonce, err := es.Client.PendingNonceAt(ctx, account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending nonce: %w", err)
+	}
+
+	for i, id := range ids {
+		if id == nil {
+			continue
+		}
+
+		txHash, err := es.cancelListing(ctx, contract, seller, id, new(big.Int).SetUint64(nonce))
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].TxHash = txHash
+		nonce++
+	}
+
+	return results, nil
+}
+
+// listingOwner resolves the user owning a listing with the given on-chain
+// seller and token.
+func (es *EthereumService) listingOwner(seller common.Address, tokenID *big.Int) (common.Address, error) {
+	key := es.signer()
+	if key == nil || seller != crypto.PubkeyToAddress(key.PublicKey) {
+		return seller, nil
+	}
+
+	nft, err := db.GetNFTByTokenID(tokenID.String())
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to get NFT %s from database: %w", tokenID, err)
+	}
+	if !common.IsHexAddress(nft.Seller) {
+		return common.Address{}, fmt.Errorf("no seller recorded for token %s", tokenID)
+	}
+
+	return common.HexToAddress(nft.Seller), nil
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"nft-marketplace/blockchain/chaintest"
+	"nft-marketplace/events"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// listingCreatedLog returns a ListingCreated log of listing id in block.
+func listingCreatedLog(t *testing.T, id, block uint64) types.Log {
+	t.Helper()
+
+	topic, err := events.Topic(events.ListingCreatedEvent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uint256, err := abi.NewType("uint256", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := abi.Arguments{{Type: uint256}, {Type: uint256}, {Type: uint256}}.
+		Pack(big.NewInt(int64(id+100)), big.NewInt(1e18), big.NewInt(1_700_000_000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return types.Log{
+		Address:     chaintest.Contract,
+		Topics:      []common.Hash{topic, common.BigToHash(new(big.Int).SetUint64(id)), common.HexToHash("0x5e11")},
+		Data:        data,
+		BlockNumber: block,
+		BlockHash:   common.HexToHash("0xb10c"),
+		TxHash:      common.BigToHash(new(big.Int).SetUint64(id)),
+	}
+}
+
+func TestGetChainListings(t *testing.T) {
+	tests := []struct {
+		name        string
+		deployBlock uint64
+		wantRanges  [][2]uint64
+	}{
+		{name: "from genesis", deployBlock: 0, wantRanges: [][2]uint64{{0, 9_999}, {10_000, 19_999}, {20_000, 24_000}}},
+		{name: "from deploy block", deployBlock: 18_000, wantRanges: [][2]uint64{{18_000, 24_000}}},
+		{name: "deployed at the head", deployBlock: 24_000, wantRanges: [][2]uint64{{24_000, 24_000}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := chaintest.NewNode(t)
+			node.Handle("eth_getBlockByNumber", chaintest.Returns(&types.Header{Number: big.NewInt(24_000), Difficulty: big.NewInt(0)}))
+			var ranges [][2]uint64
+			node.Handle("eth_getLogs", func(params []json.RawMessage) (any, error) {
+				var query struct {
+					FromBlock hexutil.Uint64 `json:"fromBlock"`
+					ToBlock   hexutil.Uint64 `json:"toBlock"`
+				}
+				if err := json.Unmarshal(params[0], &query); err != nil {
+					return nil, err
+				}
+				ranges = append(ranges, [2]uint64{uint64(query.FromBlock), uint64(query.ToBlock)})
+				var logs []types.Log
+				if query.FromBlock <= 24_000 && 24_000 <= query.ToBlock {
+					logs = append(logs, listingCreatedLog(t, 1, 24_000), listingCreatedLog(t, 2, 24_000))
+				}
+				return logs, nil
+			})
+			node.HandleCall("listings", func(args []any) ([]any, error) {
+				id := args[0].(*big.Int).Int64()
+				// Listing 2 was bought since.
+				return []any{common.HexToAddress("0x5e11"), big.NewInt(id + 100), big.NewInt(1e18), id == 1}, nil
+			})
+			es := &EthereumService{Client: node.Client, ContractAddress: chaintest.Contract, LogScanChunkSize: 10_000, DeployBlock: tt.deployBlock}
+
+			listings, err := es.GetChainListings(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(listings) != 1 || listings[0].ListingID.Int64() != 1 || listings[0].TokenID.Int64() != 101 {
+				t.Fatalf("listings = %+v, want only listing 1 of token 101", listings)
+			}
+			if len(ranges) != len(tt.wantRanges) {
+				t.Fatalf("scanned %v, want %v", ranges, tt.wantRanges)
+			}
+			for i := range ranges {
+				if ranges[i] != tt.wantRanges[i] {
+					t.Fatalf("scanned %v, want %v", ranges, tt.wantRanges)
+				}
+			}
+		})
+	}
+}
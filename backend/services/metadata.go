@@ -0,0 +1,99 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"nft-marketplace/utils"
+	"strings"
+)
+
+// ErrInvalidMetadata is returned by ValidateMetadata. It is wrapped together
+// with a *utils.ValidationError listing every invalid field.
+var ErrInvalidMetadata = errors.New("invalid NFT metadata")
+
+// imageSchemes are the URL schemes accepted for metadata images.
+var imageSchemes = []string{"ipfs", "https", "http", "ar"}
+
+// Metadata is the ERC-721 metadata JSON of a token, as pinned to IPFS and
+// served from its tokenURI.
+type Metadata struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Image       string      `json:"image"`
+	ExternalURL string      `json:"external_url,omitempty"`
+	Attributes  []Attribute `json:"attributes,omitempty"`
+}
+
+// Attribute is a trait of a token, following the OpenSea metadata standard.
+// Value is a string, a number or a bool.
+type Attribute struct {
+	TraitType   string `json:"trait_type"`
+	Value       any    `json:"value"`
+	DisplayType string `json:"display_type,omitempty"`
+}
+
+// ValidateMetadata checks that m can be rendered by marketplaces: name,
+// description and image are required, image must be an ipfs, ar, https or
+// http URL, and every attribute needs a unique trait_type and a string, number
+// or bool value. All violations are reported at once.
+func ValidateMetadata(m Metadata) error {
+	var verr utils.ValidationError
+
+	if strings.TrimSpace(m.Name) == "" {
+		verr.Add("name", "is required")
+	}
+	if strings.TrimSpace(m.Description) == "" {
+		verr.Add("description", "is required")
+	}
+	if strings.TrimSpace(m.Image) == "" {
+		verr.Add("image", "is required")
+	} else if !validURL(m.Image, imageSchemes) {
+		verr.Add("image", "must be an ipfs, ar, https or http URL")
+	}
+	if m.ExternalURL != "" && !validURL(m.ExternalURL, []string{"https", "http"}) {
+		verr.Add("external_url", "must be an https or http URL")
+	}
+
+	traits := make(map[string]bool, len(m.Attributes))
+	for i, attribute := range m.Attributes {
+		field := fmt.Sprintf("attributes[%d]", i)
+
+		traitType := strings.TrimSpace(attribute.TraitType)
+		switch {
+		case traitType == "":
+			verr.Add(field+".trait_type", "is required")
+		case traits[strings.ToLower(traitType)]:
+			verr.Add(field+".trait_type", "duplicates trait "+traitType)
+		default:
+			traits[strings.ToLower(traitType)] = true
+		}
+
+		switch attribute.Value.(type) {
+		case string, float64, float32, int, int64, uint64, bool:
+		case nil:
+			verr.Add(field+".value", "is required")
+		default:
+			verr.Add(field+".value", "must be a string, a number or a bool")
+		}
+	}
+
+	if err := verr.Err(); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidMetadata, err)
+	}
+	return nil
+}
+
+// validURL reports whether s is an absolute URL with one of schemes.
+func validURL(s string, schemes []string) bool {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" && u.Opaque == "" {
+		return false
+	}
+	for _, scheme := range schemes {
+		if strings.EqualFold(u.Scheme, scheme) {
+			return true
+		}
+	}
+	return false
+}
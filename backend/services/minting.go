@@ -0,0 +1,57 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"nft-marketplace/db"
+	"strconv"
+	"sync/atomic"
+)
+
+const mintingPausedSetting = "minting_paused"
+
+// MintingSwitch is the operator kill switch for minting. Its state is kept in
+// memory for the mint handlers and persisted in the settings table so that it
+// survives restarts.
+type MintingSwitch struct {
+	paused atomic.Bool
+}
+
+// NewMintingSwitch returns a switch initialized from the persisted setting, or
+// from defaultPaused when the setting was never stored or cannot be read.
+func NewMintingSwitch(defaultPaused bool) *MintingSwitch {
+	sw := &MintingSwitch{}
+	sw.paused.Store(defaultPaused)
+
+	value, ok, err := db.GetSetting(mintingPausedSetting)
+	if err != nil {
+		log.Printf("Failed to load minting switch, using default paused=%t: %v", defaultPaused, err)
+		return sw
+	}
+	if ok {
+		paused, err := strconv.ParseBool(value)
+		if err != nil {
+			log.Printf("Invalid stored minting switch %q, using default paused=%t", value, defaultPaused)
+			return sw
+		}
+		sw.paused.Store(paused)
+	}
+
+	return sw
+}
+
+// Paused reports whether minting is currently paused.
+func (sw *MintingSwitch) Paused() bool {
+	return sw.paused.Load()
+}
+
+// SetPaused persists the new state and then applies it.
+func (sw *MintingSwitch) SetPaused(paused bool) error {
+	if err := db.SetSetting(mintingPausedSetting, strconv.FormatBool(paused)); err != nil {
+		return fmt.Errorf("failed to persist minting switch: %w", err)
+	}
+
+	sw.paused.Store(paused)
+	log.Printf("Minting paused set to %t", paused)
+	return nil
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nft-marketplace/utils"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrCollectionMismatch is returned by CheckCollections when the configured
+// default collection is not the NFT contract the marketplace trades.
+var ErrCollectionMismatch = errors.New("default collection is not the marketplace's NFT contract")
+
+// nftAddressCache keeps the NFT contract addresses read from the marketplace.
+// They are set once in its constructor, so they are kept for good once read,
+// along with whether the contracts implement ERC721Enumerable. As with
+// feeCache, its mutex only guards the cached values and concurrent reads of
+// one value are shared through refresh.
+type nftAddressCache struct {
+	mu                 sync.Mutex
+	nft                *common.Address
+	enumerable         *common.Address
+	supportsEnumerable map[common.Address]bool
+	refresh            singleflight.Group
+}
+
+// GetNFTContractAddress returns the ERC-721 contract whose tokens the
+// marketplace trades, as returned by its nftContract view. The address is
+// read once and then cached.
+func (es *EthereumService) GetNFTContractAddress(ctx context.Context) (common.Address, error) {
+	return es.cachedNFTAddress(ctx, &es.nftAddresses.nft, "nftContract", func(opts *bind.CallOpts) (common.Address, error) {
+		market, err := es.marketplaceContract()
+		if err != nil {
+			return common.Address{}, err
+		}
+		return market.NftContract(opts)
+	})
+}
+
+// GetEnumerableAddress returns the contract the marketplace enumerates tokens
+// with, as returned by its nftEnumerable view. The address is read once and
+// then cached.
+func (es *EthereumService) GetEnumerableAddress(ctx context.Context) (common.Address, error) {
+	return es.cachedNFTAddress(ctx, &es.nftAddresses.enumerable, "nftEnumerable", func(opts *bind.CallOpts) (common.Address, error) {
+		market, err := es.marketplaceContract()
+		if err != nil {
+			return common.Address{}, err
+		}
+		return market.NftEnumerable(opts)
+	})
+}
+
+// cachedNFTAddress returns *cached, reading it with read first when it is not
+// set yet. Failed reads are not cached.
+func (es *EthereumService) cachedNFTAddress(ctx context.Context, cached **common.Address, view string, read func(opts *bind.CallOpts) (common.Address, error)) (common.Address, error) {
+	es.nftAddresses.mu.Lock()
+	address := *cached
+	es.nftAddresses.mu.Unlock()
+	if address != nil {
+		return *address, nil
+	}
+
+	results := es.nftAddresses.refresh.DoChan(view, func() (interface{}, error) {
+		shared, cancel := context.WithTimeout(context.WithoutCancel(ctx), sharedCallTimeout)
+		defer cancel()
+		address, err := read(&bind.CallOpts{Context: shared})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", view, err)
+		}
+
+		es.nftAddresses.mu.Lock()
+		*cached = &address
+		es.nftAddresses.mu.Unlock()
+		return address, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return common.Address{}, ctx.Err()
+	case res := <-results:
+		if res.Err != nil {
+			return common.Address{}, res.Err
+		}
+		return res.Val.(common.Address), nil
+	}
+}
+
+// CheckCollections checks that the default collection of Collections is the
+// NFT contract the marketplace trades, so that the configuration cannot drift
+// from the deployed contracts. It returns ErrCollectionMismatch when it is
+// not, and nil when no collection is registered.
+func (es *EthereumService) CheckCollections(ctx context.Context) error {
+	if es.Collections == nil || len(es.Collections.List()) == 0 {
+		return nil
+	}
+	collection, err := es.Collections.Get("")
+	if err != nil {
+		return err
+	}
+
+	nftAddress, err := es.GetNFTContractAddress(ctx)
+	if err != nil {
+		return err
+	}
+	if !utils.SameAddress(collection.Address, nftAddress) {
+		return fmt.Errorf("%w: collection %s is %s, the marketplace trades %s", ErrCollectionMismatch, collection.ID, collection.Address.Hex(), nftAddress.Hex())
+	}
+	return nil
+}
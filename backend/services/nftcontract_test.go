@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"nft-marketplace/blockchain/chaintest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestGetNFTContractAddress(t *testing.T) {
+	nft := common.HexToAddress("0x0000000000000000000000000000000000000721")
+	enumerable := common.HexToAddress("0x0000000000000000000000000000000000000e21")
+
+	tests := []struct {
+		name string
+		get  func(es *EthereumService, ctx context.Context) (common.Address, error)
+		view string
+		want common.Address
+	}{
+		{name: "NFT contract", get: (*EthereumService).GetNFTContractAddress, view: "nftContract", want: nft},
+		{name: "enumerable contract", get: (*EthereumService).GetEnumerableAddress, view: "nftEnumerable", want: enumerable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := chaintest.NewNode(t)
+			fail := true
+			node.HandleCall("nftContract", func([]any) ([]any, error) {
+				if fail {
+					return nil, errors.New("header not found")
+				}
+				return []any{nft}, nil
+			})
+			node.HandleCall("nftEnumerable", func([]any) ([]any, error) {
+				if fail {
+					return nil, errors.New("header not found")
+				}
+				return []any{enumerable}, nil
+			})
+			es := &EthereumService{Client: node.Client, ContractAddress: chaintest.Contract}
+
+			// A failed read is not cached.
+			if _, err := tt.get(es, context.Background()); err == nil {
+				t.Fatal("failed read returned an address")
+			}
+			fail = false
+			for range 2 {
+				got, err := tt.get(es, context.Background())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != tt.want {
+					t.Fatalf("address = %s, want %s", got.Hex(), tt.want.Hex())
+				}
+			}
+			if n := node.Count(tt.view); n != 2 {
+				t.Fatalf("%s called %d times, want 2", tt.view, n)
+			}
+		})
+	}
+}
+
+func TestGetNFTContractAddressSlowNode(t *testing.T) {
+	node := chaintest.NewNode(t)
+	release := make(chan struct{})
+	node.HandleCall("nftContract", func([]any) ([]any, error) {
+		<-release
+		return []any{common.HexToAddress("0x0000000000000000000000000000000000000721")}, nil
+	})
+	es := &EthereumService{Client: node.Client, ContractAddress: chaintest.Contract}
+
+	// A caller giving up on a slow node gets its own error at once instead
+	// of waiting behind the read the other caller shares.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	waiting := make(chan error)
+	go func() {
+		_, err := es.GetNFTContractAddress(context.Background())
+		waiting <- err
+	}()
+	if _, err := es.GetNFTContractAddress(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	close(release)
+	if err := <-waiting; err != nil {
+		t.Fatal(err)
+	}
+	if n := node.Count("nftContract"); n != 1 {
+		t.Fatalf("nftContract called %d times, want 1", n)
+	}
+}
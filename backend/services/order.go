@@ -0,0 +1,78 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ErrInvalidOrder is returned by OrderHash for orders with missing or
+// out-of-range fields.
+var ErrInvalidOrder = errors.New("invalid order")
+
+// Order is an off-chain order book listing: its maker offers tokenId for
+// price until expiry, a Unix time in seconds. Nonce makes otherwise identical
+// orders distinct.
+type Order struct {
+	Maker   common.Address
+	TokenID *big.Int
+	Price   *big.Int
+	Expiry  *big.Int
+	Nonce   *big.Int
+}
+
+// orderArguments is the ABI layout orders are hashed with.
+var orderArguments = abi.Arguments{
+	{Name: "maker", Type: mustABIType("address")},
+	{Name: "tokenId", Type: mustABIType("uint256")},
+	{Name: "price", Type: mustABIType("uint256")},
+	{Name: "expiry", Type: mustABIType("uint256")},
+	{Name: "nonce", Type: mustABIType("uint256")},
+}
+
+func mustABIType(name string) abi.Type {
+	t, err := abi.NewType(name, "", nil)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// OrderHash returns the canonical hash of order, which clients and the server
+// agree on to identify it:
+//
+//	keccak256(abi.encode(maker, tokenId, price, expiry, nonce))
+//
+// with maker an address and the other fields uint256, each encoded as a
+// 32-byte word in that order, the same as Solidity's abi.encode of the order
+// struct. The numeric fields must be set, non-negative and fit in 256 bits,
+// otherwise it fails with ErrInvalidOrder.
+func OrderHash(order Order) ([32]byte, error) {
+	fields := []struct {
+		name  string
+		value *big.Int
+	}{
+		{"token ID", order.TokenID},
+		{"price", order.Price},
+		{"expiry", order.Expiry},
+		{"nonce", order.Nonce},
+	}
+	for _, field := range fields {
+		if field.value == nil {
+			return [32]byte{}, fmt.Errorf("%w: %s is missing", ErrInvalidOrder, field.name)
+		}
+		if field.value.Sign() < 0 || field.value.BitLen() > 256 {
+			return [32]byte{}, fmt.Errorf("%w: %s is out of the uint256 range", ErrInvalidOrder, field.name)
+		}
+	}
+
+	encoded, err := orderArguments.Pack(order.Maker, order.TokenID, order.Price, order.Expiry, order.Nonce)
+	if err != nil {
+		return [32]byte{}, fmt.Errorf("failed to encode order: %w", err)
+	}
+	return crypto.Keccak256Hash(encoded), nil
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"nft-marketplace/rpcpool"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// GetOwnedTokenIDs returns the IDs of up to limit of the tokens of the
+// marketplace's NFT contract held by owner, skipping the first offset, in the
+// contract's enumeration order, along with the number of tokens owner holds.
+//
+// The NFT contract is the one the marketplace trades, read from its
+// nftContract view. Its ERC721Enumerable support is checked through ERC-165
+// the first time, returning ErrNotEnumerable without it. The page is then
+// read with balanceOf followed by one tokenOfOwnerByIndex call per token of
+// the page, run on RPCPool.
+func (es *EthereumService) GetOwnedTokenIDs(ctx context.Context, owner common.Address, limit, offset int) ([]*big.Int, int64, error) {
+	nftAddress, err := es.GetNFTContractAddress(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	enumerable, err := es.nftEnumerable(ctx, nftAddress)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !enumerable {
+		return nil, 0, fmt.Errorf("%w: %s", ErrNotEnumerable, nftAddress.Hex())
+	}
+
+	parsedABI, err := abi.JSON(strings.NewReader(ERC721ABI))
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %w", ErrABIParse, err)
+	}
+	backend := es.backend()
+	nft := bind.NewBoundContract(nftAddress, parsedABI, backend, backend, backend)
+
+	var out []interface{}
+	if err := nft.Call(&bind.CallOpts{Context: ctx}, &out, "balanceOf", owner); err != nil {
+		return nil, 0, fmt.Errorf("failed to get balance of %s: %w", owner.Hex(), err)
+	}
+	balance, ok := out[0].(*big.Int)
+	if !ok || !balance.IsInt64() {
+		return nil, 0, fmt.Errorf("unexpected balanceOf result: %v", out)
+	}
+	total := balance.Int64()
+
+	start := min(int64(max(offset, 0)), total)
+	end := min(start+int64(max(limit, 0)), total)
+	tasks := make([]rpcpool.Task[*big.Int], end-start)
+	for i := range tasks {
+		index := start + int64(i)
+		tasks[i] = func(ctx context.Context) (*big.Int, error) {
+			var out []interface{}
+			if err := nft.Call(&bind.CallOpts{Context: ctx}, &out, "tokenOfOwnerByIndex", owner, big.NewInt(index)); err != nil {
+				return nil, fmt.Errorf("failed to get token %d of %s: %w", index, owner.Hex(), err)
+			}
+			tokenID, ok := out[0].(*big.Int)
+			if !ok {
+				return nil, fmt.Errorf("unexpected tokenOfOwnerByIndex result: %v", out)
+			}
+			return tokenID, nil
+		}
+	}
+	tokenIDs, err := rpcpool.Do(ctx, es.rpcPool(), tasks)
+	if err != nil {
+		return nil, 0, err
+	}
+	return tokenIDs, total, nil
+}
+
+// nftEnumerable reports whether the NFT contract at nftAddress implements
+// ERC721Enumerable. The answer is read once and then cached, since the
+// contract cannot change it; failed checks are not cached.
+func (es *EthereumService) nftEnumerable(ctx context.Context, nftAddress common.Address) (bool, error) {
+	es.nftAddresses.mu.Lock()
+	enumerable, ok := es.nftAddresses.supportsEnumerable[nftAddress]
+	es.nftAddresses.mu.Unlock()
+	if ok {
+		return enumerable, nil
+	}
+
+	results := es.nftAddresses.refresh.DoChan("enumerable:"+nftAddress.Hex(), func() (interface{}, error) {
+		shared, cancel := context.WithTimeout(context.WithoutCancel(ctx), sharedCallTimeout)
+		defer cancel()
+		enumerable, err := es.SupportsERC721Enumerable(shared, nftAddress)
+		if err != nil {
+			return nil, err
+		}
+
+		es.nftAddresses.mu.Lock()
+		if es.nftAddresses.supportsEnumerable == nil {
+			es.nftAddresses.supportsEnumerable = make(map[common.Address]bool)
+		}
+		es.nftAddresses.supportsEnumerable[nftAddress] = enumerable
+		es.nftAddresses.mu.Unlock()
+		return enumerable, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case res := <-results:
+		if res.Err != nil {
+			return false, res.Err
+		}
+		return res.Val.(bool), nil
+	}
+}
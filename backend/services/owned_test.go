@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"nft-marketplace/blockchain/chaintest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestGetOwnedTokenIDs(t *testing.T) {
+	owner := common.HexToAddress("0x00000000000000000000000000000000000000Aa")
+	var erc721, erc165 abi.ABI
+	for _, parse := range []struct {
+		into *abi.ABI
+		json string
+	}{{&erc721, ERC721ABI}, {&erc165, erc165ABI}} {
+		parsed, err := abi.JSON(strings.NewReader(parse.json))
+		if err != nil {
+			t.Fatal(err)
+		}
+		*parse.into = parsed
+	}
+
+	tests := []struct {
+		name          string
+		balance       int64
+		limit, offset int
+		notEnumerable bool
+		wantIDs       []int64
+		wantErr       error
+	}{
+		{name: "first page", balance: 5, limit: 2, offset: 0, wantIDs: []int64{100, 101}},
+		{name: "last partial page", balance: 5, limit: 2, offset: 4, wantIDs: []int64{104}},
+		{name: "past the end", balance: 5, limit: 2, offset: 9, wantIDs: []int64{}},
+		{name: "no tokens", balance: 0, limit: 20, wantIDs: []int64{}},
+		{name: "not enumerable", balance: 5, limit: 2, notEnumerable: true, wantErr: ErrNotEnumerable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := chaintest.NewNode(t)
+			node.AddABI(&erc721)
+			node.AddABI(&erc165)
+			node.HandleCall("nftContract", chaintest.Outputs(common.HexToAddress("0x0000000000000000000000000000000000000721")))
+			node.HandleCall("supportsInterface", func(args []any) ([]any, error) {
+				switch args[0].([4]byte) {
+				case InterfaceIDERC165, InterfaceIDERC721:
+					return []any{true}, nil
+				case InterfaceIDERC721Enumerable:
+					return []any{!tt.notEnumerable}, nil
+				}
+				return []any{false}, nil
+			})
+			node.HandleCall("balanceOf", chaintest.Outputs(big.NewInt(tt.balance)))
+			node.HandleCall("tokenOfOwnerByIndex", func(args []any) ([]any, error) {
+				index := args[1].(*big.Int).Int64()
+				if index >= tt.balance {
+					return nil, errors.New("execution reverted: owner index out of bounds")
+				}
+				return []any{big.NewInt(100 + index)}, nil
+			})
+			es := &EthereumService{Client: node.Client, ContractAddress: chaintest.Contract}
+
+			for i := 0; i < 2; i++ {
+				ids, total, err := es.GetOwnedTokenIDs(context.Background(), owner, tt.limit, tt.offset)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				if tt.wantErr != nil {
+					continue
+				}
+				if total != tt.balance {
+					t.Fatalf("total = %d, want %d", total, tt.balance)
+				}
+				got := make([]int64, len(ids))
+				for i, id := range ids {
+					got[i] = id.Int64()
+				}
+				if len(got) != len(tt.wantIDs) {
+					t.Fatalf("ids = %v, want %v", got, tt.wantIDs)
+				}
+				for i := range got {
+					if got[i] != tt.wantIDs[i] {
+						t.Fatalf("ids = %v, want %v", got, tt.wantIDs)
+					}
+				}
+			}
+			// Only the page is read, and the interface check only once.
+			if got, want := node.Count("tokenOfOwnerByIndex"), 2*len(tt.wantIDs); got != want {
+				t.Fatalf("tokenOfOwnerByIndex called %d times, want %d", got, want)
+			}
+			if got := node.Count("supportsInterface"); got > 4 {
+				t.Fatalf("supportsInterface called %d times over two reads, want at most 4", got)
+			}
+		})
+	}
+}
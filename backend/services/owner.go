@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"golang.org/x/sync/singleflight"
+)
+
+const defaultOwnerCacheTTL = 5 * time.Minute
+
+// ErrNoContractOwner is returned by GetContractOwner when the marketplace
+// reports the zero address as its owner, as it does once ownership is
+// renounced.
+var ErrNoContractOwner = errors.New("marketplace has no owner")
+
+// ownerCache keeps the last owner read from the chain. Like commissionCache,
+// it reads through refresh without holding its mutex and counts
+// invalidations in generation.
+type ownerCache struct {
+	mu         sync.Mutex
+	owner      common.Address
+	expires    time.Time
+	generation uint64
+	refresh    singleflight.Group
+}
+
+// GetContractOwner returns the owner of the marketplace contract, the account
+// allowed to update the commission.
+//
+// The owner is cached for OwnerCacheTTL (5 minutes by default) or until
+// InvalidateContractOwner is called. Whenever an error is returned the address
+// is the zero address, including ErrNoContractOwner when the contract has no
+// owner, so callers cannot mistake a failure for an owner.
+func (es *EthereumService) GetContractOwner(ctx context.Context) (common.Address, error) {
+	es.owner.mu.Lock()
+	owner, fresh := es.owner.owner, es.clock().Now().Before(es.owner.expires)
+	generation := es.owner.generation
+	es.owner.mu.Unlock()
+	if fresh {
+		return owner, nil
+	}
+
+	contract, err := es.marketplaceContract()
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	results := es.owner.refresh.DoChan("owner", func() (interface{}, error) {
+		shared, cancel := context.WithTimeout(context.WithoutCancel(ctx), sharedCallTimeout)
+		defer cancel()
+		owner, err := contract.Owner(&bind.CallOpts{Context: shared})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get contract owner: %w", err)
+		}
+		if owner == (common.Address{}) {
+			return nil, ErrNoContractOwner
+		}
+
+		ttl := es.OwnerCacheTTL
+		if ttl <= 0 {
+			ttl = defaultOwnerCacheTTL
+		}
+		es.owner.mu.Lock()
+		if es.owner.generation == generation {
+			es.owner.owner = owner
+			es.owner.expires = es.clock().Now().Add(ttl)
+		}
+		es.owner.mu.Unlock()
+		return owner, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return common.Address{}, ctx.Err()
+	case res := <-results:
+		if res.Err != nil {
+			return common.Address{}, res.Err
+		}
+		return res.Val.(common.Address), nil
+	}
+}
+
+// InvalidateContractOwner drops the cached owner so the next GetContractOwner
+// reads it from the chain, for instance after ownership was transferred.
+func (es *EthereumService) InvalidateContractOwner() {
+	es.owner.mu.Lock()
+	es.owner.expires = time.Time{}
+	es.owner.generation++
+	es.owner.mu.Unlock()
+
+	es.owner.refresh.Forget("owner")
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"nft-marketplace/blockchain/chaintest"
+	"nft-marketplace/clock"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestGetContractOwner(t *testing.T) {
+	node := chaintest.NewNode(t)
+	var onChain common.Address
+	node.HandleCall("owner", func([]any) ([]any, error) { return []any{onChain}, nil })
+
+	fake := clock.NewFake(time.Unix(1_700_000_000, 0))
+	es := &EthereumService{Client: node.Client, ContractAddress: chaintest.Contract, Clock: fake, OwnerCacheTTL: time.Minute}
+	first := common.HexToAddress("0x0000000000000000000000000000000000000a11")
+	second := common.HexToAddress("0x0000000000000000000000000000000000000b22")
+
+	tests := []struct {
+		name       string
+		onChain    common.Address
+		advance    time.Duration
+		invalidate bool
+		want       common.Address
+		wantErr    error
+		wantCalls  int
+	}{
+		{name: "first read hits the chain", onChain: first, want: first, wantCalls: 1},
+		{name: "cached within the TTL", onChain: second, advance: 59 * time.Second, want: first, wantCalls: 1},
+		{name: "refreshed after the TTL", onChain: second, advance: time.Second, want: second, wantCalls: 2},
+		{name: "ownership transferred", onChain: first, invalidate: true, want: first, wantCalls: 3},
+		{name: "ownership renounced", invalidate: true, wantErr: ErrNoContractOwner, wantCalls: 4},
+		{name: "renounced owner not cached", onChain: second, want: second, wantCalls: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			onChain = tt.onChain
+			fake.Advance(tt.advance)
+			if tt.invalidate {
+				es.InvalidateContractOwner()
+			}
+
+			owner, err := es.GetContractOwner(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if owner != tt.want {
+				t.Fatalf("owner = %s, want %s", owner.Hex(), tt.want.Hex())
+			}
+			if got := node.Count("owner"); got != tt.wantCalls {
+				t.Fatalf("owner called %d times, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestGetContractOwnerSlowNode(t *testing.T) {
+	node := chaintest.NewNode(t)
+	release := make(chan struct{})
+	node.HandleCall("owner", func([]any) ([]any, error) {
+		<-release
+		return []any{common.HexToAddress("0x0000000000000000000000000000000000000a11")}, nil
+	})
+	es := &EthereumService{Client: node.Client, ContractAddress: chaintest.Contract}
+
+	// A caller giving up on a slow node gets its own error at once instead
+	// of waiting behind the read the other caller shares.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	waiting := make(chan error)
+	go func() {
+		_, err := es.GetContractOwner(context.Background())
+		waiting <- err
+	}()
+	if _, err := es.GetContractOwner(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	close(release)
+	if err := <-waiting; err != nil {
+		t.Fatal(err)
+	}
+	if n := node.Count("owner"); n != 1 {
+		t.Fatalf("owner called %d times, want 1", n)
+	}
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"nft-marketplace/rpcpool"
+	"nft-marketplace/utils"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// OwnershipClaim claims that Address owns TokenID in the marketplace's NFT
+// contract.
+type OwnershipClaim struct {
+	Address common.Address
+	TokenID *big.Int
+}
+
+// BatchCheckOwnership reports, for each claim in order, whether its address
+// owns its token in the marketplace's NFT contract.
+//
+// Every claim is checked against the same block, the latest one when the
+// batch starts, so that a transfer in the middle of the batch cannot make
+// claims disagree. Tokens that do not exist, whose ownerOf reverts, are owned
+// by nobody. The ownerOf calls run on RPCPool. An error is only returned when
+// the chain cannot be read.
+func (es *EthereumService) BatchCheckOwnership(ctx context.Context, claims []OwnershipClaim) ([]bool, error) {
+	if len(claims) == 0 {
+		return []bool{}, nil
+	}
+
+	contract, err := es.marketplaceContract()
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := es.Client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest header: %w", err)
+	}
+	nftAddress, err := contract.NftContract(&bind.CallOpts{Context: ctx, BlockNumber: header.Number})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get NFT contract address: %w", err)
+	}
+
+	tasks := make([]rpcpool.Task[bool], len(claims))
+	for i, claim := range claims {
+		tasks[i] = func(ctx context.Context) (bool, error) {
+			owner, err := es.ownerOf(&bind.CallOpts{Context: ctx, BlockNumber: header.Number}, nftAddress, claim.TokenID)
+			if err != nil {
+				if isRevert(err) {
+					return false, nil
+				}
+				return false, err
+			}
+			return utils.SameAddress(owner, claim.Address), nil
+		}
+	}
+	return rpcpool.Do(ctx, es.rpcPool(), tasks)
+}
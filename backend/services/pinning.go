@@ -0,0 +1,287 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"nft-marketplace/clock"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Statuses of a PinJob.
+const (
+	PinPending = "pending"
+	PinPinning = "pinning"
+	PinPinned  = "pinned"
+	PinFailed  = "failed"
+)
+
+const (
+	defaultPinConcurrency = 4
+	defaultPinQueueSize   = 100
+	defaultPinAttempts    = 5
+	defaultPinMinBackoff  = time.Second
+	defaultPinMaxBackoff  = time.Minute
+	// pinJobRetention is how long finished jobs can still be looked up.
+	pinJobRetention = time.Hour
+)
+
+// ErrPinQueueFull is returned by PinQueue.Submit when the queue has no room
+// left for another job.
+var ErrPinQueueFull = errors.New("pin queue is full")
+
+// Pinner adds data to IPFS and pins it, returning its CID.
+type Pinner interface {
+	Pin(ctx context.Context, data []byte) (string, error)
+}
+
+// IPFSPinner pins through the HTTP RPC API of an IPFS node.
+type IPFSPinner struct {
+	// URL is the node's API address, such as http://localhost:5001.
+	URL    string
+	Client *http.Client
+}
+
+// NewIPFSPinner returns a pinner for the node at address, an URL or a bare
+// host:port, which is reached over http.
+func NewIPFSPinner(address string) *IPFSPinner {
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	return &IPFSPinner{URL: strings.TrimSuffix(address, "/"), Client: &http.Client{Timeout: 2 * time.Minute}}
+}
+
+// Pin adds data to the node with /api/v0/add, pinned, and returns its CID.
+func (p *IPFSPinner) Pin(ctx context.Context, data []byte) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "data")
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(data); err != nil {
+		return "", err
+	}
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL+"/api/v0/add?pin=true&cid-version=1", &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach IPFS node: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("IPFS node answered %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	var added struct {
+		Hash string
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&added); err != nil {
+		return "", fmt.Errorf("failed to decode IPFS add response: %w", err)
+	}
+	if added.Hash == "" {
+		return "", fmt.Errorf("IPFS add response has no CID")
+	}
+	return added.Hash, nil
+}
+
+// PinJob is the state of data submitted to a PinQueue. CID is set once the
+// data is pinned; Error holds the last failure.
+type PinJob struct {
+	ID        string    `json:"id"`
+	Status    string    `json:"status"`
+	CID       string    `json:"cid,omitempty"`
+	Attempts  int       `json:"attempts"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type pinTask struct {
+	job  PinJob
+	data []byte
+}
+
+// PinQueue pins data in the background, so that handlers need not wait for
+// IPFS. At most Concurrency pins are in flight at once; a failed pin is
+// retried with exponential backoff from MinBackoff to MaxBackoff until
+// MaxAttempts attempts failed. Jobs are kept in memory, and finished ones
+// can be looked up for an hour.
+type PinQueue struct {
+	Pinner      Pinner
+	Concurrency int
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	// Clock measures the backoff and the retention. Nil means the wall
+	// clock.
+	Clock clock.Clock
+
+	queue chan *pinTask
+
+	mu    sync.Mutex
+	tasks map[string]*pinTask
+}
+
+// NewPinQueue returns a queue of up to size jobs pinned with pinner by
+// concurrency workers. Non-positive values use the defaults of 4 workers and
+// 100 jobs. Nothing is pinned until Run is called.
+func NewPinQueue(pinner Pinner, concurrency, size int) *PinQueue {
+	if concurrency <= 0 {
+		concurrency = defaultPinConcurrency
+	}
+	if size <= 0 {
+		size = defaultPinQueueSize
+	}
+	return &PinQueue{
+		Pinner:      pinner,
+		Concurrency: concurrency,
+		MaxAttempts: defaultPinAttempts,
+		MinBackoff:  defaultPinMinBackoff,
+		MaxBackoff:  defaultPinMaxBackoff,
+		queue:       make(chan *pinTask, size),
+		tasks:       make(map[string]*pinTask),
+	}
+}
+
+// Submit queues data to be pinned and returns its pending job. It fails with
+// ErrPinQueueFull rather than wait for room.
+func (q *PinQueue) Submit(data []byte) (PinJob, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return PinJob{}, fmt.Errorf("failed to generate pin job ID: %w", err)
+	}
+	now := clock.OrReal(q.Clock).Now()
+	task := &pinTask{job: PinJob{ID: hex.EncodeToString(id), Status: PinPending, CreatedAt: now, UpdatedAt: now}, data: data}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.prune(now)
+	select {
+	case q.queue <- task:
+	default:
+		return PinJob{}, ErrPinQueueFull
+	}
+	q.tasks[task.job.ID] = task
+	return task.job, nil
+}
+
+// Status returns the job with the given ID. The boolean is false for unknown
+// jobs and jobs finished more than an hour ago.
+func (q *PinQueue) Status(id string) (PinJob, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	task, ok := q.tasks[id]
+	if !ok {
+		return PinJob{}, false
+	}
+	return task.job, true
+}
+
+// Run pins the queued jobs until ctx is cancelled. Jobs still queued or
+// waiting for a retry by then are left pending.
+func (q *PinQueue) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for range q.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case task := <-q.queue:
+					q.pin(ctx, task)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// pin makes an attempt at pinning task, scheduling a retry if it fails.
+func (q *PinQueue) pin(ctx context.Context, task *pinTask) {
+	attempt := q.update(task, func(job *PinJob) {
+		job.Status = PinPinning
+		job.Attempts++
+	}).Attempts
+
+	cid, err := q.Pinner.Pin(ctx, task.data)
+	if err == nil {
+		q.update(task, func(job *PinJob) {
+			job.Status, job.CID, job.Error = PinPinned, cid, ""
+		})
+		task.data = nil
+		return
+	}
+	if ctx.Err() != nil {
+		q.update(task, func(job *PinJob) { job.Status = PinPending })
+		return
+	}
+
+	if attempt >= q.MaxAttempts {
+		log.Printf("Giving up pinning job %s after %d attempts: %v", task.job.ID, attempt, err)
+		q.update(task, func(job *PinJob) {
+			job.Status, job.Error = PinFailed, err.Error()
+		})
+		task.data = nil
+		return
+	}
+
+	delay := backoffDelay(q.MinBackoff, q.MaxBackoff, attempt)
+	log.Printf("Pinning job %s failed, retrying in %s: %v", task.job.ID, delay, err)
+	q.update(task, func(job *PinJob) {
+		job.Status, job.Error = PinPending, err.Error()
+	})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-clock.OrReal(q.Clock).After(delay):
+			select {
+			case q.queue <- task:
+			case <-ctx.Done():
+			}
+		}
+	}()
+}
+
+// update applies change to the job of task and returns the result.
+func (q *PinQueue) update(task *pinTask, change func(job *PinJob)) PinJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	change(&task.job)
+	task.job.UpdatedAt = clock.OrReal(q.Clock).Now()
+	return task.job
+}
+
+// prune forgets the jobs finished more than pinJobRetention before now. The
+// lock must be held.
+func (q *PinQueue) prune(now time.Time) {
+	for id, task := range q.tasks {
+		finished := task.job.Status == PinPinned || task.job.Status == PinFailed
+		if finished && now.Sub(task.job.UpdatedAt) > pinJobRetention {
+			delete(q.tasks, id)
+		}
+	}
+}
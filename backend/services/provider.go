@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"nft-marketplace/clock"
+	"sync"
+	"time"
+)
+
+// Provider lazily constructs a single EthereumService shared by all callers.
+//
+// The first Get dials the service; concurrent callers wait for that attempt
+// instead of dialing themselves. A successful result is cached forever. A
+// failed one is cached for Cooldown, after which the next Get tries again.
+// The zero value is ready to use once New is set.
+type Provider struct {
+	New      func(ctx context.Context) (*EthereumService, error)
+	Cooldown time.Duration
+	// Clock measures the cooldown. Nil means the wall clock.
+	Clock clock.Clock
+
+	// lock is a one-slot semaphore so waiting callers can give up when their
+	// context is cancelled. It is made by the first Get.
+	lockOnce sync.Once
+	lock     chan struct{}
+	service  *EthereumService
+	err      error
+	failedAt time.Time
+}
+
+// NewProvider returns a Provider that builds the service with newService.
+func NewProvider(newService func(ctx context.Context) (*EthereumService, error), cooldown time.Duration) *Provider {
+	return &Provider{
+		New:      newService,
+		Cooldown: cooldown,
+	}
+}
+
+// Get returns the shared EthereumService, constructing it if needed.
+func (p *Provider) Get(ctx context.Context) (*EthereumService, error) {
+	p.lockOnce.Do(func() { p.lock = make(chan struct{}, 1) })
+	select {
+	case p.lock <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-p.lock }()
+
+	if p.service != nil {
+		return p.service, nil
+	}
+	if p.err != nil && clock.OrReal(p.Clock).Now().Sub(p.failedAt) < p.Cooldown {
+		return nil, p.err
+	}
+
+	service, err := p.New(ctx)
+	if err != nil {
+		p.err, p.failedAt = err, clock.OrReal(p.Clock).Now()
+		return nil, err
+	}
+
+	p.service, p.err = service, nil
+	return service, nil
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"nft-marketplace/clock"
+	"testing"
+	"time"
+)
+
+func TestProvider(t *testing.T) {
+	errDial := errors.New("dial failed")
+
+	tests := []struct {
+		name string
+		// results are what New returns on each call, nil for a service.
+		results []error
+		// advance is how long passes before each Get.
+		advance   []time.Duration
+		wantErrs  []error
+		wantCalls int
+	}{
+		{name: "success cached", results: []error{nil}, advance: []time.Duration{0, time.Hour}, wantErrs: []error{nil, nil}, wantCalls: 1},
+		{name: "failure cached during cooldown", results: []error{errDial}, advance: []time.Duration{0, 30 * time.Second}, wantErrs: []error{errDial, errDial}, wantCalls: 1},
+		{name: "retried after cooldown", results: []error{errDial, nil}, advance: []time.Duration{0, time.Minute}, wantErrs: []error{errDial, nil}, wantCalls: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := clock.NewFake(time.Unix(1_700_000_000, 0))
+			calls := 0
+			// A zero value Provider must work without NewProvider.
+			p := &Provider{
+				New: func(context.Context) (*EthereumService, error) {
+					err := tt.results[calls]
+					calls++
+					if err != nil {
+						return nil, err
+					}
+					return &EthereumService{}, nil
+				},
+				Cooldown: time.Minute,
+				Clock:    fake,
+			}
+
+			for i, want := range tt.wantErrs {
+				fake.Advance(tt.advance[i])
+				service, err := p.Get(context.Background())
+				if !errors.Is(err, want) {
+					t.Fatalf("Get %d: err = %v, want %v", i, err, want)
+				}
+				if want == nil && service == nil {
+					t.Fatalf("Get %d returned no service", i)
+				}
+			}
+			if calls != tt.wantCalls {
+				t.Fatalf("New called %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestProviderWaitCancelled(t *testing.T) {
+	dialing, release := make(chan struct{}), make(chan struct{})
+	p := &Provider{New: func(context.Context) (*EthereumService, error) {
+		close(dialing)
+		<-release
+		return &EthereumService{}, nil
+	}}
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.Get(context.Background())
+		done <- err
+	}()
+	<-dialing
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.Get(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("waiting Get: err = %v, want %v", err, context.Canceled)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("dialing Get: %v", err)
+	}
+}
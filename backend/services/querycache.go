@@ -0,0 +1,149 @@
+package services
+
+import (
+	"container/list"
+	"fmt"
+	"nft-marketplace/clock"
+	"sync"
+	"time"
+)
+
+const (
+	defaultQueryCacheTTL  = 10 * time.Second
+	defaultQueryCacheSize = 1000
+)
+
+// QueryCache caches the results of database queries by key. Implementations
+// must be safe for concurrent use. Cached values are shared between callers,
+// who must not modify them.
+type QueryCache interface {
+	// Get returns the value cached under key, if any.
+	Get(key string) (any, bool)
+	// Set caches value under key.
+	Set(key string, value any)
+	// Invalidate drops every cached value.
+	Invalidate()
+}
+
+// MemoryQueryCache is an in-memory QueryCache keeping at most Size values,
+// each for TTL. When full, the least recently used value is evicted.
+type MemoryQueryCache struct {
+	TTL  time.Duration
+	Size int
+	// Clock measures the TTL. Nil means the wall clock.
+	Clock clock.Clock
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type queryCacheEntry struct {
+	key     string
+	value   any
+	expires time.Time
+}
+
+// NewMemoryQueryCache returns an empty cache. Non-positive values use the
+// defaults of a 10 second TTL and 1000 values.
+func NewMemoryQueryCache(ttl time.Duration, size int) *MemoryQueryCache {
+	if ttl <= 0 {
+		ttl = defaultQueryCacheTTL
+	}
+	if size <= 0 {
+		size = defaultQueryCacheSize
+	}
+	return &MemoryQueryCache{TTL: ttl, Size: size}
+}
+
+// Get returns the value cached under key unless it expired.
+func (c *MemoryQueryCache) Get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*queryCacheEntry)
+	if !clock.OrReal(c.Clock).Now().Before(entry.expires) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return entry.value, true
+}
+
+// Set caches value under key for TTL, evicting the least recently used value
+// when the cache is full.
+func (c *MemoryQueryCache) Set(key string, value any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.order = list.New()
+		c.entries = make(map[string]*list.Element)
+	}
+
+	expires := clock.OrReal(c.Clock).Now().Add(c.TTL)
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*queryCacheEntry)
+		entry.value, entry.expires = value, expires
+		c.order.MoveToFront(element)
+		return
+	}
+
+	for c.order.Len() >= max(c.Size, 1) {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*queryCacheEntry).key)
+	}
+	c.entries[key] = c.order.PushFront(&queryCacheEntry{key: key, value: value, expires: expires})
+}
+
+// Invalidate drops every cached value.
+func (c *MemoryQueryCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order, c.entries = nil, nil
+}
+
+// cachedQuery returns the value cached under key in QueryCache, or runs query
+// and caches its result. Failed queries are not cached, and without a
+// QueryCache every call runs query.
+//
+// Keys are prefixed with the generation of the cache, which InvalidateQueries
+// bumps, so that a query still running when the cache is invalidated cannot
+// store its outdated result where later calls would find it.
+func cachedQuery[T any](es *EthereumService, key string, query func() (T, error)) (T, error) {
+	if es.QueryCache == nil {
+		return query()
+	}
+
+	key = fmt.Sprintf("%d/%s", es.queryGeneration.Load(), key)
+	if value, ok := es.QueryCache.Get(key); ok {
+		if result, ok := value.(T); ok {
+			return result, nil
+		}
+	}
+
+	result, err := query()
+	if err != nil {
+		return result, err
+	}
+	es.QueryCache.Set(key, result)
+	return result, nil
+}
+
+// InvalidateQueries drops the cached query results, so that the next queries
+// read the database. The indexer calls it whenever it stores or removes
+// events, and so do the service's own writes to the nfts table.
+func (es *EthereumService) InvalidateQueries() {
+	if es.QueryCache == nil {
+		return
+	}
+	es.queryGeneration.Add(1)
+	es.QueryCache.Invalidate()
+}
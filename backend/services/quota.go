@@ -0,0 +1,65 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"nft-marketplace/clock"
+	"nft-marketplace/db"
+	"time"
+)
+
+// ErrMintQuotaExceeded is returned by MintQuota.Take when the address already
+// minted its daily quota.
+var ErrMintQuotaExceeded = errors.New("daily mint quota exceeded")
+
+// MintQuota caps how many mints each user may make per UTC day. The counts
+// are kept in the mint_quotas table, so they are shared by every worker and
+// survive restarts. A quota with a non-positive limit lets everyone mint.
+type MintQuota struct {
+	Limit int
+	// Clock tells the day. Nil means the wall clock.
+	Clock clock.Clock
+}
+
+// NewMintQuota returns a quota of limit mints per user and day.
+func NewMintQuota(limit int) *MintQuota {
+	return &MintQuota{Limit: limit}
+}
+
+// Enabled reports whether mints are counted.
+func (q *MintQuota) Enabled() bool {
+	return q.Limit > 0
+}
+
+// Take counts a mint of the user with userID today. It returns the start of
+// today, to be handed to Release should the mint fail, and tomorrow's, when
+// the count is reset. Users who already minted Limit times today get
+// ErrMintQuotaExceeded. Users are counted by ID rather than wallet, which
+// they could switch to reset their count.
+func (q *MintQuota) Take(userID uint) (day, reset time.Time, err error) {
+	day = clock.OrReal(q.Clock).Now().UTC().Truncate(24 * time.Hour)
+	reset = day.Add(24 * time.Hour)
+	if !q.Enabled() {
+		return day, reset, nil
+	}
+
+	_, counted, err := db.IncrementMintCount(userID, day, q.Limit)
+	if err != nil {
+		return day, reset, fmt.Errorf("failed to count mint: %w", err)
+	}
+	if !counted {
+		return day, reset, fmt.Errorf("%w: %d mints per day", ErrMintQuotaExceeded, q.Limit)
+	}
+	return day, reset, nil
+}
+
+// Release gives back a mint of the user with userID counted by Take on day.
+func (q *MintQuota) Release(userID uint, day time.Time) error {
+	if !q.Enabled() {
+		return nil
+	}
+	if err := db.DecrementMintCount(userID, day); err != nil {
+		return fmt.Errorf("failed to release mint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	marketplace "nft-marketplace/blockchain"
+	"nft-marketplace/db"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+)
+
+// ReconcileReport summarizes a ReconcileListings run.
+type ReconcileReport struct {
+	Checked int `json:"checked"`
+	Fixed   int `json:"fixed"`
+	Failed  int `json:"failed"`
+}
+
+// ReconcileListings compares the cached listing status of up to batchSize DB
+// rows with the chain and corrects the rows that diverged.
+//
+// Rows are taken least recently verified first. For each row the marketplace's
+// isTokenListed and getListingId views are read, at most ReconcileRPS checks
+// per second. A row whose on-chain check fails is counted in Failed and left
+// untouched; it does not abort the run.
+func (es *EthereumService) ReconcileListings(ctx context.Context, batchSize int) (ReconcileReport, error) {
+	var report ReconcileReport
+
+	contract, err := es.marketplaceContract()
+	if err != nil {
+		return report, err
+	}
+
+	nfts, err := db.GetNFTsForReconcile(batchSize)
+	if err != nil {
+		return report, fmt.Errorf("failed to load NFTs for reconciliation: %w", err)
+	}
+
+	throttle := newThrottle(es.clock(), es.ReconcileRPS)
+	for _, nft := range nfts {
+		if err := throttle.wait(ctx); err != nil {
+			return report, err
+		}
+
+		report.Checked++
+
+		diverged, err := es.reconcileNFT(ctx, contract, nft)
+		if err != nil {
+			log.Printf("Failed to reconcile NFT %d: %v", nft.ID, err)
+			report.Failed++
+			continue
+		}
+
+		if diverged {
+			report.Fixed++
+		}
+	}
+
+	return report, nil
+}
+
+// ReconcileToken re-checks the listing status of the DB row of tokenID against
+// the chain, as ReconcileListings does for a batch.
+func (es *EthereumService) ReconcileToken(ctx context.Context, tokenID string) error {
+	contract, err := es.marketplaceContract()
+	if err != nil {
+		return err
+	}
+
+	nft, err := db.GetNFTByTokenID(tokenID)
+	if err != nil {
+		return fmt.Errorf("failed to get NFT %s from database: %w", tokenID, err)
+	}
+
+	_, err = es.reconcileNFT(ctx, contract, nft)
+	return err
+}
+
+// reconcileNFT reads the listing status of nft's token and stores it in its
+// row, reporting and logging whether the row diverged from the chain.
+func (es *EthereumService) reconcileNFT(ctx context.Context, contract *marketplace.Marketplace, nft db.Nfts) (bool, error) {
+	tokenID, err := parseBigInt("token ID", nft.TokenID)
+	if err != nil {
+		return false, err
+	}
+
+	opts := &bind.CallOpts{Context: ctx}
+	listed, err := contract.IsTokenListed(opts, tokenID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check listing status of token %s: %w", nft.TokenID, err)
+	}
+
+	listingID := ""
+	if listed {
+		id, err := contract.GetListingId(opts, tokenID)
+		if err != nil {
+			return false, fmt.Errorf("failed to get listing ID of token %s: %w", nft.TokenID, err)
+		}
+		listingID = id.String()
+	}
+
+	diverged := nft.IsActive != listed || (listed && nft.ListingID != listingID)
+	if err := db.UpdateNFTStatus(nft.ID, listed, listingID); err != nil {
+		return false, fmt.Errorf("failed to update NFT %d: %w", nft.ID, err)
+	}
+
+	if diverged {
+		es.InvalidateQueries()
+		log.Printf("Reconciled token %s: is_active %t -> %t", nft.TokenID, nft.IsActive, listed)
+	}
+	return diverged, nil
+}
+
+// RunReconciler calls ReconcileListings every interval until ctx is cancelled.
+func (es *EthereumService) RunReconciler(ctx context.Context, interval time.Duration, batchSize int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := es.ReconcileListings(ctx, batchSize)
+			if err != nil {
+				log.Printf("Listing reconciliation failed: %v", err)
+				continue
+			}
+			log.Printf("Listing reconciliation: checked=%d fixed=%d failed=%d", report.Checked, report.Fixed, report.Failed)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"nft-marketplace/blockchain/chaintest"
+	"nft-marketplace/clock"
+	"nft-marketplace/db/dbtest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestReconcileListings(t *testing.T) {
+	tests := []struct {
+		name      string
+		active    bool
+		listingID string
+		listed    bool
+		chainID   int64
+		chainErr  error
+		// wantUpdate is whether the row is written, with the status read
+		// on chain.
+		wantUpdate bool
+		want       ReconcileReport
+	}{
+		{name: "cancelled on chain", active: true, listingID: "3", wantUpdate: true, want: ReconcileReport{Checked: 1, Fixed: 1}},
+		{name: "listed on chain", listed: true, chainID: 4, wantUpdate: true, want: ReconcileReport{Checked: 1, Fixed: 1}},
+		{name: "relisted under another ID", active: true, listingID: "3", listed: true, chainID: 4, wantUpdate: true, want: ReconcileReport{Checked: 1, Fixed: 1}},
+		{name: "in sync", active: true, listingID: "3", listed: true, chainID: 3, wantUpdate: true, want: ReconcileReport{Checked: 1}},
+		{name: "chain unreachable", active: true, listingID: "3", chainErr: errors.New("connection refused"), want: ReconcileReport{Checked: 1, Failed: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := chaintest.NewNode(t)
+			node.HandleCall("isTokenListed", func([]any) ([]any, error) {
+				if tt.chainErr != nil {
+					return nil, tt.chainErr
+				}
+				return []any{tt.listed}, nil
+			})
+			node.HandleCall("getListingId", chaintest.Outputs(big.NewInt(tt.chainID)))
+
+			mock := dbtest.Mock(t)
+			mock.ExpectQuery(`SELECT \* FROM "nfts" WHERE token_id <> \$1 ORDER BY updated_at ASC`).
+				WithArgs("", 10).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "token_id", "is_active", "listing_id"}).AddRow(1, "7", tt.active, tt.listingID))
+			if tt.wantUpdate {
+				wantID := ""
+				if tt.listed {
+					wantID = big.NewInt(tt.chainID).String()
+				}
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "nfts" SET "is_active"=\$1,"listing_id"=\$2,"updated_at"=\$3 WHERE id = \$4`).
+					WithArgs(tt.listed, wantID, sqlmock.AnyArg(), 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			es := &EthereumService{Client: node.Client, ContractAddress: chaintest.Contract}
+			report, err := es.ReconcileListings(context.Background(), 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report != tt.want {
+				t.Fatalf("report = %+v, want %+v", report, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpireStaleListings(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	seller := common.HexToAddress("0x0000000000000000000000000000000000005e11")
+
+	tests := []struct {
+		name      string
+		listingID string
+		listed    bool
+		// tokenID is the token the listing holds on chain.
+		tokenID  int64
+		chainErr error
+		want     ReconcileReport
+	}{
+		{name: "cancelled on chain", listingID: "3", tokenID: 7, want: ReconcileReport{Checked: 1, Fixed: 1}},
+		{name: "still listed", listingID: "3", listed: true, tokenID: 7, want: ReconcileReport{Checked: 1}},
+		{name: "listing reused for another token", listingID: "3", listed: true, tokenID: 8, want: ReconcileReport{Checked: 1, Fixed: 1}},
+		{name: "no listing ID, checked by token", listed: true, want: ReconcileReport{Checked: 1}},
+		{name: "chain unreachable", listingID: "3", chainErr: errors.New("connection refused"), want: ReconcileReport{Checked: 1, Failed: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := chaintest.NewNode(t)
+			node.HandleCall("listings", func([]any) ([]any, error) {
+				if tt.chainErr != nil {
+					return nil, tt.chainErr
+				}
+				return []any{seller, big.NewInt(tt.tokenID), big.NewInt(1e18), tt.listed}, nil
+			})
+			node.HandleCall("isTokenListed", chaintest.Outputs(tt.listed))
+
+			mock := dbtest.Mock(t)
+			mock.ExpectQuery(`SELECT \* FROM "nfts" WHERE is_active = \$1 AND token_id <> \$2 AND updated_at < \$3 ORDER BY updated_at ASC`).
+				WithArgs(true, "", now.Add(-time.Hour), 10).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "token_id", "is_active", "listing_id"}).AddRow(1, "7", true, tt.listingID))
+			if tt.chainErr == nil {
+				stillActive := tt.listed && (tt.listingID == "" || tt.tokenID == 7)
+				wantID := ""
+				if stillActive {
+					wantID = tt.listingID
+				}
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "nfts" SET "is_active"=\$1,"listing_id"=\$2,"updated_at"=\$3 WHERE id = \$4`).
+					WithArgs(stillActive, wantID, sqlmock.AnyArg(), 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			es := &EthereumService{Client: node.Client, ContractAddress: chaintest.Contract, Clock: clock.NewFake(now)}
+			report, err := es.ExpireStaleListings(context.Background(), time.Hour, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report != tt.want {
+				t.Fatalf("report = %+v, want %+v", report, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileListingsThrottled(t *testing.T) {
+	node := chaintest.NewNode(t)
+	node.HandleCall("isTokenListed", chaintest.Outputs(false))
+
+	mock := dbtest.Mock(t)
+	mock.ExpectQuery(`SELECT \* FROM "nfts"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "token_id", "is_active"}).AddRow(1, "7", false).AddRow(2, "8", false))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "nfts"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// The fake clock never moves, so the second check waits until the run
+	// is cancelled.
+	ctx, cancel := context.WithCancel(context.Background())
+	es := &EthereumService{Client: node.Client, ContractAddress: chaintest.Contract, ReconcileRPS: 1, Clock: clock.NewFake(time.Unix(1_800_000_000, 0))}
+	done := make(chan error)
+	go func() {
+		_, err := es.ReconcileListings(ctx, 10)
+		done <- err
+	}()
+	// The first row is written once its check is done.
+	for mock.ExpectationsWereMet() != nil {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want %v", err, context.Canceled)
+	}
+	if got := node.Count("isTokenListed"); got != 1 {
+		t.Fatalf("made %d checks, want 1 within the first second", got)
+	}
+}
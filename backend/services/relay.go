@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	marketplace "nft-marketplace/blockchain"
+	"nft-marketplace/db"
+	"nft-marketplace/utils"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// EIP-712 domain of listing intents.
+const (
+	ListingIntentDomainName    = "Azmolo Marketplace"
+	ListingIntentDomainVersion = "1"
+)
+
+// Errors returned by RelayListing.
+var (
+	ErrInvalidSignature = errors.New("signature does not match the token owner")
+	ErrIntentExpired    = errors.New("listing intent expired")
+	ErrIntentUsed       = errors.New("listing intent already used")
+	// ErrRelayUnsupported is returned for tokens the user holds in their own
+	// wallet: the marketplace only lets the token owner list and has no
+	// forwarder or permit, so only tokens held by the service's account can
+	// be listed on a user's behalf.
+	ErrRelayUnsupported = errors.New("token is not held by the platform, list it from the owner's wallet")
+)
+
+// ListingIntent is a user's signed request to list a token at a price, which
+// the service relays with its own account so that the user pays no gas.
+//
+// It is signed as the EIP-712 typed data
+//
+//	ListingIntent(uint256 tokenId,uint256 price,uint256 nonce,uint256 deadline)
+//
+// in the domain {name: ListingIntentDomainName, version:
+// ListingIntentDomainVersion, chainId, verifyingContract: the marketplace}.
+// Nonce only makes otherwise identical intents distinct; each signed intent is
+// relayed at most once. Deadline is a Unix time in seconds.
+type ListingIntent struct {
+	TokenID  *big.Int
+	Price    *big.Int
+	Nonce    *big.Int
+	Deadline *big.Int
+}
+
+// listingIntentTypedData returns the EIP-712 typed data of the intent on chainID.
+func (es *EthereumService) listingIntentTypedData(intent ListingIntent, chainID *big.Int) apitypes.TypedData {
+	return apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+				{Name: "verifyingContract", Type: "address"},
+			},
+			"ListingIntent": {
+				{Name: "tokenId", Type: "uint256"},
+				{Name: "price", Type: "uint256"},
+				{Name: "nonce", Type: "uint256"},
+				{Name: "deadline", Type: "uint256"},
+			},
+		},
+		PrimaryType: "ListingIntent",
+		Domain: apitypes.TypedDataDomain{
+			Name:              ListingIntentDomainName,
+			Version:           ListingIntentDomainVersion,
+			ChainId:           (*math.HexOrDecimal256)(chainID),
+			VerifyingContract: es.ContractAddress.Hex(),
+		},
+		Message: apitypes.TypedDataMessage{
+			"tokenId":  (*math.HexOrDecimal256)(intent.TokenID),
+			"price":    (*math.HexOrDecimal256)(intent.Price),
+			"nonce":    (*math.HexOrDecimal256)(intent.Nonce),
+			"deadline": (*math.HexOrDecimal256)(intent.Deadline),
+		},
+	}
+}
+
+// recoverIntentSigner returns the account that signed intent, along with the
+// intent's EIP-712 digest.
+func (es *EthereumService) recoverIntentSigner(intent ListingIntent, signature []byte, chainID *big.Int) (common.Address, common.Hash, error) {
+	digest, _, err := apitypes.TypedDataAndHash(es.listingIntentTypedData(intent, chainID))
+	if err != nil {
+		return common.Address{}, common.Hash{}, fmt.Errorf("failed to hash listing intent: %w", err)
+	}
+
+	if len(signature) != crypto.SignatureLength {
+		return common.Address{}, common.Hash{}, fmt.Errorf("%w: signature must be %d bytes", ErrInvalidSignature, crypto.SignatureLength)
+	}
+	sig := append([]byte(nil), signature...)
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+
+	pub, err := crypto.SigToPub(digest, sig)
+	if err != nil {
+		return common.Address{}, common.Hash{}, fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+	return crypto.PubkeyToAddress(*pub), common.BytesToHash(digest), nil
+}
+
+// RelayListing lists a token for caller, who signed intent, sending
+// createListing from the service's account and paying its gas, and returns the
+// transaction hash.
+//
+// Caller must be the wallet the authenticated user proved control of, so that
+// the service only pays gas for verified users. The signer must be caller and
+// own the token the way CancelListing defines it: since the marketplace only
+// accepts listings from the token's holder, this works for tokens the
+// service's account holds for a user, whose owner is the seller recorded in
+// the database. Forged or mismatched signatures fail with ErrInvalidSignature,
+// tokens held elsewhere with ErrRelayUnsupported, and intents past their
+// deadline or relayed before with ErrIntentExpired and ErrIntentUsed.
+func (es *EthereumService) RelayListing(ctx context.Context, caller common.Address, intent ListingIntent, signature hexutil.Bytes) (common.Hash, error) {
+	if intent.TokenID == nil || intent.Price == nil || intent.Nonce == nil || intent.Deadline == nil {
+		return common.Hash{}, fmt.Errorf("%w: token ID, price, nonce and deadline are required", ErrInvalidNumber)
+	}
+	if intent.Price.Sign() <= 0 {
+		return common.Hash{}, fmt.Errorf("%w: price must be positive", ErrInvalidNumber)
+	}
+	if err := es.validateListingPrice(intent.Price); err != nil {
+		return common.Hash{}, err
+	}
+	if intent.Deadline.Cmp(big.NewInt(es.clock().Now().Unix())) <= 0 {
+		return common.Hash{}, ErrIntentExpired
+	}
+
+	contract, err := es.marketplaceContract()
+	if err != nil {
+		return common.Hash{}, err
+	}
+	chainID, err := es.Client.ChainID(ctx)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("failed to get chain ID: %w", err)
+	}
+
+	signer, digest, err := es.recoverIntentSigner(intent, signature, chainID)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if signer != caller {
+		return common.Hash{}, fmt.Errorf("%w: signed by %s, not the verified wallet %s", ErrInvalidSignature, signer.Hex(), caller.Hex())
+	}
+
+	key, err := es.readySigner()
+	if err != nil {
+		return common.Hash{}, err
+	}
+	account := crypto.PubkeyToAddress(key.PublicKey)
+
+	holder, err := es.tokenOwner(&bind.CallOpts{Context: ctx}, contract, intent.TokenID)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	owner, err := es.listingOwner(holder, intent.TokenID)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if !utils.SameAddress(owner, signer) {
+		return common.Hash{}, fmt.Errorf("%w: token %s is owned by %s, signed by %s", ErrInvalidSignature, intent.TokenID, owner.Hex(), signer.Hex())
+	}
+	if holder != account {
+		return common.Hash{}, ErrRelayUnsupported
+	}
+
+	// Claim the intent before sending so that concurrent relays of it cannot
+	// both go through.
+	err = db.UseListingIntent(digest.Hex(), signer.Hex(), intent.TokenID.String())
+	if errors.Is(err, db.ErrIntentUsed) {
+		return common.Hash{}, ErrIntentUsed
+	}
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("failed to record listing intent: %w", err)
+	}
+
+	txHash, err := es.sendListing(ctx, contract, intent.TokenID, intent.Price)
+	if err != nil {
+		if releaseErr := db.ReleaseListingIntent(digest.Hex()); releaseErr != nil {
+			log.Printf("Failed to release listing intent %s: %v", digest.Hex(), releaseErr)
+		}
+		return common.Hash{}, err
+	}
+
+	log.Printf("Relayed listing of token %s for %s! Transaction hash: %s", intent.TokenID, signer.Hex(), txHash.Hex())
+	return txHash, nil
+}
+
+// sendListing sends createListing for tokenID at price from the service's
+// account.
+func (es *EthereumService) sendListing(ctx context.Context, contract *marketplace.Marketplace, tokenID, price *big.Int) (common.Hash, error) {
+	auth, err := es.newTransactor(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	tx, err := contract.CreateListing(auth, tokenID, price)
+	if err != nil {
+		es.releaseUnsentNonce(ctx, auth)
+		return common.Hash{}, fmt.Errorf("failed to create listing: %w", err)
+	}
+	return tx.Hash(), nil
+}
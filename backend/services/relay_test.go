@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"nft-marketplace/blockchain/chaintest"
+	"nft-marketplace/clock"
+	"nft-marketplace/db/dbtest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// signIntent signs intent for the chaintest marketplace with key.
+func signIntent(t *testing.T, es *EthereumService, intent ListingIntent, key *ecdsa.PrivateKey) []byte {
+	t.Helper()
+	digest, _, err := apitypes.TypedDataAndHash(es.listingIntentTypedData(intent, big.NewInt(chaintest.ChainID)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := crypto.Sign(digest, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signature
+}
+
+func TestRelayListing(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	service, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	serviceAccount := crypto.PubkeyToAddress(service.PublicKey)
+	userWallet := crypto.PubkeyToAddress(user.PublicKey)
+	otherWallet := crypto.PubkeyToAddress(other.PublicKey)
+
+	erc721, err := abi.JSON(strings.NewReader(ERC721ABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		caller   common.Address
+		signer   *ecdsa.PrivateKey
+		deadline int64
+		holder   common.Address
+		// seller is the owner recorded in the database for tokens the
+		// service holds.
+		seller  common.Address
+		used    bool
+		wantErr error
+	}{
+		{name: "valid intent", caller: userWallet, signer: user, holder: serviceAccount, seller: userWallet},
+		// A signature proves nothing about who is asking for the relay: it
+		// must come from the wallet the caller verified.
+		{name: "signed by another wallet than the caller's", caller: userWallet, signer: other, holder: serviceAccount, seller: otherWallet, wantErr: ErrInvalidSignature},
+		{name: "forged for the token owner", caller: otherWallet, signer: other, holder: serviceAccount, seller: userWallet, wantErr: ErrInvalidSignature},
+		{name: "token in the user's wallet", caller: userWallet, signer: user, holder: userWallet, wantErr: ErrRelayUnsupported},
+		{name: "expired", caller: userWallet, signer: user, deadline: now.Unix(), wantErr: ErrIntentExpired},
+		{name: "already relayed", caller: userWallet, signer: user, holder: serviceAccount, seller: userWallet, used: true, wantErr: ErrIntentUsed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := chaintest.NewNode(t)
+			node.AddABI(&erc721)
+			node.HandleCall("nftContract", chaintest.Outputs(common.HexToAddress("0x0000000000000000000000000000000000000721")))
+			node.HandleCall("ownerOf", chaintest.Outputs(tt.holder))
+			node.Handle("eth_getBlockByNumber", chaintest.Returns(&types.Header{Number: big.NewInt(100), Difficulty: big.NewInt(0)}))
+			node.Handle("eth_gasPrice", chaintest.Returns(hexutil.Big(*big.NewInt(10_000_000_000))))
+			node.Handle("eth_getTransactionCount", chaintest.Returns(hexutil.Uint64(3)))
+			node.Handle("eth_getCode", chaintest.Returns(hexutil.Bytes{0x60, 0x80}))
+			node.Handle("eth_estimateGas", chaintest.Returns(hexutil.Uint64(150_000)))
+			var sent common.Hash
+			var gas uint64
+			node.Handle("eth_sendRawTransaction", func(params []json.RawMessage) (any, error) {
+				var raw hexutil.Bytes
+				if err := json.Unmarshal(params[0], &raw); err != nil {
+					return nil, err
+				}
+				tx := new(types.Transaction)
+				if err := tx.UnmarshalBinary(raw); err != nil {
+					return nil, err
+				}
+				sent, gas = tx.Hash(), tx.Gas()
+				return sent, nil
+			})
+
+			mock := dbtest.Mock(t)
+			// Only intents signed by the caller get as far as the owner.
+			if tt.holder == serviceAccount && crypto.PubkeyToAddress(tt.signer.PublicKey) == tt.caller {
+				mock.ExpectQuery(`SELECT \* FROM "nfts"`).WithArgs("7", 1).
+					WillReturnRows(sqlmock.NewRows([]string{"token_id", "seller"}).AddRow("7", tt.seller.Hex()))
+			}
+			if tt.wantErr == nil || tt.used {
+				affected := int64(1)
+				if tt.used {
+					affected = 0
+				}
+				mock.ExpectBegin()
+				mock.ExpectExec(`INSERT INTO "listing_intents" .* ON CONFLICT DO NOTHING`).
+					WillReturnResult(sqlmock.NewResult(0, affected))
+				mock.ExpectCommit()
+			}
+
+			es := &EthereumService{Client: node.Client, ContractAddress: chaintest.Contract, PrivateKey: service, Clock: clock.NewFake(now)}
+			deadline := tt.deadline
+			if deadline == 0 {
+				deadline = now.Add(time.Hour).Unix()
+			}
+			intent := ListingIntent{TokenID: big.NewInt(7), Price: big.NewInt(1e18), Nonce: big.NewInt(1), Deadline: big.NewInt(deadline)}
+
+			txHash, err := es.RelayListing(context.Background(), tt.caller, intent, signIntent(t, es, intent, tt.signer))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (txHash == (common.Hash{}) || txHash != sent) {
+				t.Fatalf("tx hash = %s, want the sent %s", txHash.Hex(), sent.Hex())
+			}
+			if tt.wantErr == nil && gas != 180_000 {
+				t.Fatalf("gas limit = %d, want the estimate plus %d%%", gas, gasLimitMarginPercent)
+			}
+			if tt.wantErr != nil && node.Count("eth_sendRawTransaction") != 0 {
+				t.Fatal("rejected intent was sent")
+			}
+		})
+	}
+}
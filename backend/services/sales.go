@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"nft-marketplace/db"
+	"nft-marketplace/events"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// recordSale records the PurchaseCompleted log l in the sales ledger.
+//
+// The event does not name the seller, which is taken from the stored
+// ListingCreated event of the listing, or else read from the listing on chain
+// as of the purchase's block, as is the commission rate the sale was charged.
+// Recording a log twice keeps a single sale.
+func (es *EthereumService) recordSale(ctx context.Context, l types.Log) error {
+	purchased, err := events.ParsePurchaseCompleted(l)
+	if err != nil {
+		return err
+	}
+
+	seller, found, err := db.GetListingSeller(purchased.ID.String())
+	if err != nil {
+		return fmt.Errorf("failed to get seller of listing %s: %w", purchased.ID, err)
+	}
+	if !found {
+		contract, err := es.marketplaceContract()
+		if err != nil {
+			return err
+		}
+		opts := &bind.CallOpts{Context: ctx, BlockNumber: new(big.Int).SetUint64(l.BlockNumber)}
+		listing, err := contract.Listings(opts, purchased.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get listing %s: %w", purchased.ID, err)
+		}
+		if listing.Seller != (common.Address{}) {
+			seller = listing.Seller.Hex()
+		}
+	}
+
+	// The rate is read as of the purchase so that later changes do not
+	// rewrite the revenue of past sales. Nodes without the state of old
+	// blocks cannot tell it, and the sale is recorded without one.
+	var rate *int64
+	contract, err := es.marketplaceContract()
+	if err != nil {
+		return err
+	}
+	percent, err := contract.CommissionPercent(&bind.CallOpts{Context: ctx, BlockNumber: new(big.Int).SetUint64(l.BlockNumber)})
+	if err != nil {
+		log.Printf("Failed to get commission of sale %s at block %d, recording it without: %v", l.TxHash.Hex(), l.BlockNumber, err)
+	} else if percent.IsInt64() {
+		value := percent.Int64()
+		rate = &value
+	}
+
+	return db.RecordSale(db.Sale{
+		ListingID:      purchased.ID.String(),
+		TokenID:        purchased.TokenID.String(),
+		Buyer:          purchased.Buyer.Hex(),
+		Seller:         seller,
+		Price:          purchased.Price.String(),
+		CommissionRate: rate,
+		SoldAt:         time.Unix(purchased.Timestamp.Int64(), 0).UTC(),
+		BlockNumber:    l.BlockNumber,
+		TxHash:         l.TxHash.Hex(),
+		LogIndex:       l.Index,
+	})
+}
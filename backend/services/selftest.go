@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Errors returned by RunSelfTest.
+var (
+	ErrSelfTestFailed = errors.New("self-test failed")
+	// ErrSelfTestMainnet is returned on production chains, where the
+	// self-test would spend real funds on gas.
+	ErrSelfTestMainnet = errors.New("self-test refused on a mainnet chain")
+)
+
+// mainnetChainIDs are the production chains RunSelfTest refuses to run on:
+// Ethereum, Optimism, BNB Smart Chain, Polygon, Base and Arbitrum One.
+var mainnetChainIDs = map[uint64]bool{1: true, 10: true, 56: true, 137: true, 8453: true, 42161: true}
+
+// SelfTestReport describes a self-test run, as far as it got.
+type SelfTestReport struct {
+	ChainID   *big.Int
+	Account   common.Address
+	TokenID   *big.Int
+	ListingID *big.Int
+	ListTx    common.Hash
+	CancelTx  common.Hash
+	// Completed lists the steps that succeeded, in order.
+	Completed []string
+}
+
+// RunSelfTest checks that the service can transact with the marketplace by
+// listing tokenID at price from the service's account, reading the listing
+// back, cancelling it and reading it again, each transaction being sent with
+// SendAndConfirm and waited for.
+//
+// The token must be held by the service's account and approved for the
+// marketplace, and must not be listed already. It refuses to run on the
+// mainnets in mainnetChainIDs with ErrSelfTestMainnet. Any failing step fails
+// with ErrSelfTestFailed and the step's name; a listing created by a run that
+// failed to cancel it is left active, its ID being in the report.
+func (es *EthereumService) RunSelfTest(ctx context.Context, tokenID, price *big.Int) (SelfTestReport, error) {
+	report := SelfTestReport{TokenID: tokenID}
+	fail := func(step string, err error) (SelfTestReport, error) {
+		return report, fmt.Errorf("%w at step %q: %w", ErrSelfTestFailed, step, err)
+	}
+
+	key, err := es.readySigner()
+	if err != nil {
+		return report, err
+	}
+	report.Account = crypto.PubkeyToAddress(key.PublicKey)
+
+	chainID, err := es.Client.ChainID(ctx)
+	if err != nil {
+		return fail("chain", fmt.Errorf("failed to get chain ID: %w", err))
+	}
+	report.ChainID = chainID
+	if chainID.IsUint64() && mainnetChainIDs[chainID.Uint64()] {
+		return report, fmt.Errorf("%w: chain ID %s", ErrSelfTestMainnet, chainID)
+	}
+	report.Completed = append(report.Completed, "chain")
+
+	contract, err := es.marketplaceContract()
+	if err != nil {
+		return fail("ownership", err)
+	}
+	owner, err := es.tokenOwner(&bind.CallOpts{Context: ctx}, contract, tokenID)
+	if err != nil {
+		return fail("ownership", err)
+	}
+	if owner != report.Account {
+		return fail("ownership", fmt.Errorf("token %s is owned by %s, not the service's account %s", tokenID, owner.Hex(), report.Account.Hex()))
+	}
+	report.Completed = append(report.Completed, "ownership")
+
+	receipt, err := es.sendMarketplaceTx(ctx, func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		return contract.CreateListing(auth, tokenID, price)
+	})
+	if err != nil {
+		return fail("list", err)
+	}
+	report.ListTx = receipt.TxHash
+	report.Completed = append(report.Completed, "list")
+
+	opts := &bind.CallOpts{Context: ctx, BlockNumber: receipt.BlockNumber}
+	listingID, err := contract.GetListingId(opts, tokenID)
+	if err != nil {
+		return fail("read listing", fmt.Errorf("failed to get listing ID of token %s: %w", tokenID, err))
+	}
+	report.ListingID = listingID
+	listing, err := contract.Listings(opts, listingID)
+	if err != nil {
+		return fail("read listing", fmt.Errorf("failed to get listing %s: %w", listingID, err))
+	}
+	if !listing.IsActive || listing.Seller != report.Account || listing.Price.Cmp(price) != 0 {
+		return fail("read listing", fmt.Errorf("listing %s reads back as seller %s, price %s, active %t", listingID, listing.Seller.Hex(), listing.Price, listing.IsActive))
+	}
+	report.Completed = append(report.Completed, "read listing")
+
+	receipt, err = es.sendMarketplaceTx(ctx, func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		return contract.CancelListing(auth, listingID)
+	})
+	if err != nil {
+		return fail("cancel", err)
+	}
+	report.CancelTx = receipt.TxHash
+	report.Completed = append(report.Completed, "cancel")
+
+	listing, err = contract.Listings(&bind.CallOpts{Context: ctx, BlockNumber: receipt.BlockNumber}, listingID)
+	if err != nil {
+		return fail("read cancellation", fmt.Errorf("failed to get listing %s: %w", listingID, err))
+	}
+	if listing.IsActive {
+		return fail("read cancellation", fmt.Errorf("listing %s is still active after cancelling it", listingID))
+	}
+	report.Completed = append(report.Completed, "read cancellation")
+
+	return report, nil
+}
+
+// sendMarketplaceTx sends the marketplace transaction built by call with
+// SendAndConfirm and checks that it succeeded. call gets transaction options
+// that build the transaction without signing or sending it.
+func (es *EthereumService) sendMarketplaceTx(ctx context.Context, call func(auth *bind.TransactOpts) (*types.Transaction, error)) (*types.Receipt, error) {
+	receipt, err := es.SendAndConfirm(ctx, func(nonce uint64) (*types.Transaction, error) {
+		auth, err := es.newTransactor(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// SendAndConfirm signs and sends the transaction itself.
+		auth.Signer = func(_ common.Address, tx *types.Transaction) (*types.Transaction, error) { return tx, nil }
+		auth.NoSend = true
+		auth.Nonce = new(big.Int).SetUint64(nonce)
+		return call(auth)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return receipt, fmt.Errorf("transaction %s reverted", receipt.TxHash.Hex())
+	}
+	return receipt, nil
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	defaultResubmitInterval = time.Minute
+	receiptPollInterval     = 2 * time.Second
+	// gasBumpPercent is how much each replacement raises the fees. Nodes only
+	// accept a replacement paying at least 10% more than the transaction it
+	// replaces.
+	gasBumpPercent = 20
+)
+
+// ErrGasPriceCapReached is returned when a stuck transaction cannot be bumped
+// any further without exceeding MaxGasPrice.
+var ErrGasPriceCapReached = errors.New("gas price cap reached")
+
+// ErrTransactionPending is returned, along with ErrGasPriceCapReached, when a
+// transaction paying MaxGasPrice is still not mined after another
+// ResubmitInterval. It keeps its nonce, so the account's later transactions
+// queue behind it until it is mined or replaced.
+var ErrTransactionPending = errors.New("transaction still pending")
+
+// SendAndConfirm sends a transaction and waits until it is mined, replacing it
+// with a better paying one whenever it stays pending for ResubmitInterval.
+//
+// build is called once with the account's next nonce (see nextNonce) and
+// returns the unsigned transaction to send; the service signs it. Replacements
+// reuse the same nonce and raise the tip and fee cap (or the gas price of a legacy
+// transaction) by 20%, never beyond MaxGasPrice when it is set. Because every
+// attempt shares the nonce, at most one of them can be mined, and the receipt
+// of whichever one is returned. It gives up when ctx is done, or with
+// ErrTransactionPending once the fees cannot be raised any further and the
+// last attempt stays pending for another ResubmitInterval. A durable nonce is
+// released when nothing could be sent with it, see releaseNonce.
+func (es *EthereumService) SendAndConfirm(ctx context.Context, build func(nonce uint64) (*types.Transaction, error)) (*types.Receipt, error) {
+	key, err := es.readySigner()
+	if err != nil {
+		return nil, err
+	}
+
+	chainID, err := es.Client.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chain ID: %w", err)
+	}
+	signer := types.LatestSignerForChainID(chainID)
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	nonce, err := es.nextNonce(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := build(nonce)
+	if err != nil {
+		es.releaseNonce(ctx, from, nonce)
+		return nil, fmt.Errorf("failed to build transaction: %w", err)
+	}
+	if tx.Nonce() != nonce {
+		es.releaseNonce(ctx, from, nonce)
+		return nil, fmt.Errorf("built transaction has nonce %d, expected %d", tx.Nonce(), nonce)
+	}
+
+	interval := es.ResubmitInterval
+	if interval <= 0 {
+		interval = defaultResubmitInterval
+	}
+
+	var sent []common.Hash
+	for {
+		signed, err := types.SignTx(tx, signer, key)
+		if err != nil {
+			if len(sent) == 0 {
+				es.releaseNonce(ctx, from, nonce)
+			}
+			return nil, fmt.Errorf("failed to sign transaction: %w", err)
+		}
+
+		if err := es.Client.SendTransaction(ctx, signed); err != nil {
+			// The previous attempt may have been mined in the meantime, in
+			// which case the replacement is rejected; keep waiting on it.
+			if len(sent) == 0 {
+				es.releaseNonce(ctx, from, nonce)
+				return nil, fmt.Errorf("failed to send transaction: %w", err)
+			}
+			log.Printf("Failed to send replacement transaction: %v", err)
+		} else {
+			sent = append(sent, signed.Hash())
+			log.Printf("Transaction sent: %s (nonce %d, attempt %d)", signed.Hash().Hex(), nonce, len(sent))
+		}
+
+		receipt, err := es.waitForAny(ctx, sent, interval)
+		if err != nil {
+			return nil, err
+		}
+		if receipt != nil {
+			return receipt, nil
+		}
+
+		bumped, err := bumpFees(tx, es.MaxGasPrice)
+		if err != nil {
+			// Nothing left to bump, give what was already sent one more
+			// interval rather than holding the caller until ctx is done.
+			log.Printf("Not replacing transaction with nonce %d: %v", nonce, err)
+			receipt, waitErr := es.waitForAny(ctx, sent, interval)
+			if waitErr != nil {
+				return nil, errors.Join(err, waitErr)
+			}
+			if receipt == nil {
+				return nil, fmt.Errorf("%w: nonce %d after %d attempts: %w", ErrTransactionPending, nonce, len(sent), err)
+			}
+			return receipt, nil
+		}
+		tx = bumped
+	}
+}
+
+// waitForAny polls the receipts of hashes until one of them is mined. It
+// returns a nil receipt if none is mined within timeout; a zero timeout waits
+// until ctx is done.
+func (es *EthereumService) waitForAny(ctx context.Context, hashes []common.Hash, timeout time.Duration) (*types.Receipt, error) {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	ticker := time.NewTicker(receiptPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, hash := range hashes {
+			receipt, err := es.Client.TransactionReceipt(ctx, hash)
+			if err == nil {
+				return receipt, nil
+			}
+			if !errors.Is(err, ethereum.NotFound) {
+				log.Printf("Failed to get receipt of %s: %v", hash.Hex(), err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-expired:
+			return nil, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// bumpFees returns a copy of tx paying gasBumpPercent more, capped at maxGasPrice
+// when it is not nil.
+func bumpFees(tx *types.Transaction, maxGasPrice *big.Int) (*types.Transaction, error) {
+	bump := func(v *big.Int) *big.Int {
+		bumped := new(big.Int).Mul(v, big.NewInt(100+gasBumpPercent))
+		bumped.Div(bumped, big.NewInt(100))
+		if bumped.Cmp(v) <= 0 {
+			bumped.Add(v, big.NewInt(1))
+		}
+		return bumped
+	}
+	capped := func(v *big.Int) bool {
+		return maxGasPrice != nil && v.Cmp(maxGasPrice) > 0
+	}
+
+	switch tx.Type() {
+	case types.DynamicFeeTxType:
+		feeCap, tipCap := bump(tx.GasFeeCap()), bump(tx.GasTipCap())
+		if capped(feeCap) {
+			return nil, ErrGasPriceCapReached
+		}
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:    tx.ChainId(),
+			Nonce:      tx.Nonce(),
+			GasTipCap:  tipCap,
+			GasFeeCap:  feeCap,
+			Gas:        tx.Gas(),
+			To:         tx.To(),
+			Value:      tx.Value(),
+			Data:       tx.Data(),
+			AccessList: tx.AccessList(),
+		}), nil
+	case types.LegacyTxType:
+		gasPrice := bump(tx.GasPrice())
+		if capped(gasPrice) {
+			return nil, ErrGasPriceCapReached
+		}
+		return types.NewTx(&types.LegacyTx{
+			Nonce:    tx.Nonce(),
+			GasPrice: gasPrice,
+			Gas:      tx.Gas(),
+			To:       tx.To(),
+			Value:    tx.Value(),
+			Data:     tx.Data(),
+		}), nil
+	default:
+		return nil, fmt.Errorf("cannot bump fees of transaction type %d", tx.Type())
+	}
+}
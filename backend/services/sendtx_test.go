@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"nft-marketplace/blockchain/chaintest"
+	"nft-marketplace/db/dbtest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestSendAndConfirmReleasesUnsentNonce(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	account := crypto.PubkeyToAddress(key.PublicKey).Hex()
+	errBuild := errors.New("gas estimation failed")
+
+	tests := []struct {
+		name    string
+		durable bool
+		// buildErr fails building the transaction instead of sending it.
+		buildErr error
+		// laterReserved is true when another nonce was reserved after the
+		// failed one, so it cannot simply be handed back.
+		laterReserved bool
+	}{
+		{name: "send fails", durable: true},
+		{name: "build fails", durable: true, buildErr: errBuild},
+		{name: "later nonce reserved", durable: true, laterReserved: true},
+		{name: "pending nonces", durable: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := chaintest.NewNode(t)
+			node.Handle("eth_getTransactionCount", chaintest.Returns(hexutil.Uint64(5)))
+			node.Handle("eth_sendRawTransaction", func([]json.RawMessage) (any, error) {
+				return nil, errors.New("insufficient funds for gas * price + value")
+			})
+
+			mock := dbtest.Mock(t)
+			if tt.durable {
+				mock.ExpectQuery(`INSERT INTO signer_nonces`).WithArgs(account, 6, sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"next_nonce"}).AddRow(5))
+				affected := int64(1)
+				if tt.laterReserved {
+					affected = 0
+				}
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "signer_nonces" SET "next_nonce"=\$1,"updated_at"=\$2 WHERE address = \$3 AND next_nonce = \$4`).
+					WithArgs(5, sqlmock.AnyArg(), account, 6).
+					WillReturnResult(sqlmock.NewResult(0, affected))
+				mock.ExpectCommit()
+				if tt.laterReserved {
+					mock.ExpectBegin()
+					mock.ExpectExec(`INSERT INTO "signer_nonces" .* ON CONFLICT \("address"\) DO UPDATE`).
+						WithArgs(account, 5, sqlmock.AnyArg()).
+						WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectCommit()
+				}
+			}
+
+			es := &EthereumService{Client: node.Client, ContractAddress: chaintest.Contract, PrivateKey: key, DurableNonces: tt.durable}
+			_, err := es.SendAndConfirm(context.Background(), func(nonce uint64) (*types.Transaction, error) {
+				if tt.buildErr != nil {
+					return nil, tt.buildErr
+				}
+				to := common.HexToAddress("0x01")
+				return types.NewTx(&types.LegacyTx{Nonce: nonce, GasPrice: big.NewInt(1), Gas: 21000, To: &to}), nil
+			})
+			if err == nil {
+				t.Fatal("SendAndConfirm() succeeded, want an error")
+			}
+			if tt.buildErr != nil && !errors.Is(err, tt.buildErr) {
+				t.Fatalf("err = %v, want %v", err, tt.buildErr)
+			}
+			wantCounts := 1
+			if tt.laterReserved {
+				wantCounts = 2
+			}
+			if got := node.Count("eth_getTransactionCount"); got != wantCounts {
+				t.Fatalf("pending nonce read %d times, want %d", got, wantCounts)
+			}
+		})
+	}
+}
+
+func TestSendAndConfirmGivesUpAtGasPriceCap(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The node accepts every attempt but never mines one.
+	node := chaintest.NewNode(t)
+	node.Handle("eth_getTransactionCount", chaintest.Returns(hexutil.Uint64(5)))
+	node.Handle("eth_sendRawTransaction", func(params []json.RawMessage) (any, error) {
+		var raw hexutil.Bytes
+		if err := json.Unmarshal(params[0], &raw); err != nil {
+			return nil, err
+		}
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(raw); err != nil {
+			return nil, err
+		}
+		return tx.Hash(), nil
+	})
+	node.Handle("eth_getTransactionReceipt", chaintest.Returns(nil))
+
+	es := &EthereumService{
+		Client:           node.Client,
+		ContractAddress:  chaintest.Contract,
+		PrivateKey:       key,
+		MaxGasPrice:      big.NewInt(100),
+		ResubmitInterval: 10 * time.Millisecond,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = es.SendAndConfirm(ctx, func(nonce uint64) (*types.Transaction, error) {
+		to := common.HexToAddress("0x01")
+		return types.NewTx(&types.LegacyTx{Nonce: nonce, GasPrice: big.NewInt(80), Gas: 21000, To: &to}), nil
+	})
+	if !errors.Is(err, ErrTransactionPending) || !errors.Is(err, ErrGasPriceCapReached) {
+		t.Fatalf("err = %v, want %v and %v", err, ErrTransactionPending, ErrGasPriceCapReached)
+	}
+	// 80 is bumped to 96, and 115 would exceed the cap.
+	if got := node.Count("eth_sendRawTransaction"); got != 2 {
+		t.Fatalf("sent %d attempts, want 2", got)
+	}
+}
+
+func TestSendAndConfirmReplacesStuckTransaction(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The node only mines the second attempt, as it would once the fees are
+	// raised enough.
+	var mu sync.Mutex
+	var sent []*types.Transaction
+	node := chaintest.NewNode(t)
+	node.Handle("eth_getTransactionCount", chaintest.Returns(hexutil.Uint64(5)))
+	node.Handle("eth_sendRawTransaction", func(params []json.RawMessage) (any, error) {
+		var raw hexutil.Bytes
+		if err := json.Unmarshal(params[0], &raw); err != nil {
+			return nil, err
+		}
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(raw); err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		sent = append(sent, tx)
+		return tx.Hash(), nil
+	})
+	node.Handle("eth_getTransactionReceipt", func(params []json.RawMessage) (any, error) {
+		var hash common.Hash
+		if err := json.Unmarshal(params[0], &hash); err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if len(sent) < 2 || hash != sent[1].Hash() {
+			return nil, nil
+		}
+		return &types.Receipt{Status: types.ReceiptStatusSuccessful, TxHash: hash, Logs: []*types.Log{}, BlockNumber: big.NewInt(11)}, nil
+	})
+
+	es := &EthereumService{Client: node.Client, ContractAddress: chaintest.Contract, PrivateKey: key, ResubmitInterval: 10 * time.Millisecond}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	receipt, err := es.SendAndConfirm(ctx, func(nonce uint64) (*types.Transaction, error) {
+		to := common.HexToAddress("0x01")
+		return types.NewTx(&types.DynamicFeeTx{ChainID: big.NewInt(chaintest.ChainID), Nonce: nonce, GasTipCap: big.NewInt(10), GasFeeCap: big.NewInt(100), Gas: 21000, To: &to}), nil
+	})
+	if err != nil {
+		t.Fatalf("SendAndConfirm() = %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sent) != 2 {
+		t.Fatalf("sent %d attempts, want 2", len(sent))
+	}
+	replacement := sent[1]
+	if receipt.TxHash != replacement.Hash() {
+		t.Fatalf("receipt of %s, want the replacement %s", receipt.TxHash.Hex(), replacement.Hash().Hex())
+	}
+	// The replacement reuses the nonce and pays 20% more.
+	if replacement.Nonce() != 5 || replacement.GasTipCap().Int64() != 12 || replacement.GasFeeCap().Int64() != 120 {
+		t.Fatalf("replacement has nonce %d, tip %s and fee cap %s, want 5, 12 and 120",
+			replacement.Nonce(), replacement.GasTipCap(), replacement.GasFeeCap())
+	}
+}
@@ -1,28 +1,166 @@
 package services
 
 import (
-	"bytes"
 	"context"
 	"crypto/ecdsa"
+	"errors"
 	"fmt"
 	"log"
 	"math/big"
+	marketplace "nft-marketplace/blockchain"
+	"nft-marketplace/clock"
+	"nft-marketplace/config"
 	"nft-marketplace/db"
-	"os"
+	"nft-marketplace/logging"
+	"nft-marketplace/rpcpool"
+	"nft-marketplace/utils"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"golang.org/x/sync/singleflight"
 )
 
 type EthereumService struct {
 	Client          *ethclient.Client
 	ContractAddress common.Address
 	PrivateKey      *ecdsa.PrivateKey
-	Contract        *bind.BoundContract
+	// Contract is the marketplace binding built by NewEthereumService from
+	// the ABI it is given. The service's methods call the generated binding
+	// instead, so it may be nil.
+	Contract *bind.BoundContract
+
+	// DegradedReads enables the on-chain fallback for read endpoints when the
+	// database is unavailable.
+	DegradedReads bool
+	// ReconcileRPS caps the on-chain checks made per second while reconciling
+	// DB listings. Zero means no limit.
+	ReconcileRPS int
+	// CommissionCacheTTL is how long GetCommission caches the on-chain
+	// commission. Zero uses the default of 30 seconds.
+	CommissionCacheTTL time.Duration
+	// FeeCacheTTL is how long SuggestFees caches its suggestion. Zero uses
+	// the default of 5 seconds.
+	FeeCacheTTL time.Duration
+	// OwnerCacheTTL is how long GetContractOwner caches the marketplace
+	// owner. Zero uses the default of 5 minutes.
+	OwnerCacheTTL time.Duration
+	// ENSRegistry is the ENS registry ResolveName looks names up in. The zero
+	// address uses ENSRegistryAddress.
+	ENSRegistry common.Address
+	// ENSCacheTTL is how long ResolveName caches resolved addresses. Zero
+	// uses the default of 5 minutes.
+	ENSCacheTTL time.Duration
+	// MaxGasPrice caps the fees SendAndConfirm bumps stuck transactions to.
+	// Nil means no cap.
+	MaxGasPrice *big.Int
+	// ResubmitInterval is how long SendAndConfirm waits for a transaction to
+	// be mined before replacing it. Zero uses the default of one minute.
+	ResubmitInterval time.Duration
+	// DurableNonces hands out nonces from the database's nonce store rather
+	// than the node's pending nonce, so that concurrent sends and sends
+	// across restarts do not collide. Call SyncNonce on startup with it.
+	DurableNonces bool
+	// GasStrategy prices the transactions built by newTransactor. The empty
+	// strategy is the standard one. WithGasStrategy overrides it per call.
+	GasStrategy config.GasStrategy
+	// RecipientBlocklist lists addresses MintNFT refuses to mint to, such as
+	// well-known burn addresses. The zero address is always refused.
+	RecipientBlocklist []common.Address
+	// MinListingPrice and MaxListingPrice bound, in wei, the price MintNFT
+	// lists tokens at. Nil or zero leaves that side unbounded.
+	MinListingPrice *big.Int
+	MaxListingPrice *big.Int
+	// TreasuryAddress is the recipient MintNFT uses when none is given. The
+	// zero address leaves the recipient required.
+	TreasuryAddress common.Address
+	// Collections holds the NFT contracts the marketplace trades. Methods
+	// taking a collection fall back to ContractAddress when it is nil or
+	// empty.
+	Collections *CollectionRegistry
+	// LogScanChunkSize is the number of blocks requested per eth_getLogs call
+	// when replaying events. Zero uses the default of 5000.
+	LogScanChunkSize uint64
+	// DeployBlock is the block the marketplace was deployed at. Replays of
+	// its events start there rather than at the genesis block.
+	DeployBlock uint64
+	// WebsocketRPC is the websocket endpoint subscriptions such as
+	// WatchTransfersTo go through.
+	WebsocketRPC string
+	// MaxReorgDepth bounds how many blocks the event indexer rolls back on a
+	// reorg. Zero uses the default of 64.
+	MaxReorgDepth uint64
+	// MintConfirmTimeout is how long MintNFT waits for the listing
+	// transaction, and a purchase verification for its transaction, to be
+	// mined.
+	// Zero uses the default of one minute.
+	MintConfirmTimeout time.Duration
+	// MintConfirmations is how many confirmations MintNFTAfterConfirmations
+	// waits for before recording a mint. Zero records mints as soon as
+	// MintNFT returns.
+	MintConfirmations uint64
+	// VerifyPurchases makes TransferNFT check in the background, once the
+	// purchase is mined, that the buyer owns the token, at the cost of extra
+	// RPC calls.
+	VerifyPurchases bool
+	// QueryCache caches the results of SearchNFTs, GetSellerNFTs and
+	// GetSellerNFTsPage until InvalidateQueries is called. Nil disables
+	// caching.
+	QueryCache QueryCache
+	// PinQueue pins the metadata of minted tokens to IPFS in the
+	// background. Nil leaves metadata unpinned.
+	PinQueue *PinQueue
+	// RPCPool bounds the calls batch reads such as BatchCheckOwnership,
+	// GetListings and GetOwnedTokenIDs have in flight at once. Nil shares a
+	// pool of eight among the services without one.
+	RPCPool *rpcpool.Pool
+
+	// Clock is the time source of caches and expiries. Nil means the wall
+	// clock.
+	Clock clock.Clock
+
+	// signerMu guards PrivateKey once the service is running, see
+	// RotateSigner.
+	signerMu   sync.RWMutex
+	commission commissionCache
+	fees       feeCache
+	ens        ensCache
+	owner      ownerCache
+	blockTimes blockTimeCache
+	// nftAddresses caches GetNFTContractAddress and GetEnumerableAddress.
+	nftAddresses nftAddressCache
+	reads        singleflight.Group
+	// queryGeneration prefixes QueryCache keys, see cachedQuery.
+	queryGeneration atomic.Uint64
+
+	// lifeMu guards closed and done, see Close.
+	lifeMu sync.Mutex
+	closed bool
+	done   chan struct{}
+}
+
+const defaultMintConfirmTimeout = time.Minute
+
+var defaultRPCPool = rpcpool.New(0)
+
+// rpcPool returns the pool batch reads run their calls on.
+func (es *EthereumService) rpcPool() *rpcpool.Pool {
+	if es.RPCPool != nil {
+		return es.RPCPool
+	}
+	return defaultRPCPool
+}
+
+// clock returns the service's time source.
+func (es *EthereumService) clock() clock.Clock {
+	return clock.OrReal(es.Clock)
 }
 
 type NFTContract struct {
@@ -30,10 +168,11 @@ type NFTContract struct {
 }
 
 type NFTListing struct {
-	Seller   common.Address
-	TokenID  *big.Int
-	Price    *big.Int
-	IsActive bool
+	ListingID *big.Int `json:",omitempty"`
+	Seller    common.Address
+	TokenID   *big.Int
+	Price     *utils.Wei
+	IsActive  bool
 }
 
 // NewEthereumService creates a new instance of EthereumService.
@@ -41,83 +180,63 @@ type NFTListing struct {
 // It takes two string parameters: rpcURL and contractAddress. The rpcURL is the
 // URL of the Ethereum node to connect to, and the contractAddress is the address
 // of the smart contract to interact with.
+//
+// The arguments are all validated before the node is dialed, and the
+// connection is closed again when no contract is deployed at contractAddress.
 func NewEthereumService(rpcURL, contractAddress, privateKeyHex, abiJSON string, chainID *big.Int) (*EthereumService, error) {
-	privateKeyHex = strings.TrimPrefix(privateKeyHex, "0x")
-
-	privateKey, err := crypto.HexToECDSA(privateKeyHex)
-	if err != nil {
-		log.Printf("Invalid private key: %v", err)
-		return nil, fmt.Errorf("invalid private key: %w", err)
+	if contractAddress == "" {
+		return nil, fmt.Errorf("contract address is required")
 	}
-
-	if rpcURL == "" {
-		log.Printf("RPC URL is required")
-		return nil, fmt.Errorf("RPC URL is required")
+	if !common.IsHexAddress(contractAddress) {
+		return nil, fmt.Errorf("invalid contract address %q", contractAddress)
 	}
+	if abiJSON == "" {
+		return nil, fmt.Errorf("ABI JSON is required")
+	}
+	if chainID == nil {
+		return nil, fmt.Errorf("chain ID is required")
+	}
+
+	privateKeyHex = strings.TrimPrefix(privateKeyHex, "0x")
+	logging.RegisterSecret(privateKeyHex)
 
-	client, err := ethclient.Dial(rpcURL)
+	privateKey, err := crypto.HexToECDSA(privateKeyHex)
 	if err != nil {
-		log.Printf("Failed to connect to Ethereum client: %v", err)
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPrivateKey, err)
 	}
 
 	parsedABI, err := abi.JSON(strings.NewReader(abiJSON))
 	if err != nil {
-		log.Printf("Failed to parse contract ABI: %v", err)
-		return nil, fmt.Errorf("failed to parse contract ABI: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrABIParse, err)
 	}
 
-	auth, err := bind.NewKeyedTransactorWithChainID(privateKey, chainID)
-	if err != nil {
-		log.Printf("Failed to create transactor: %v", err)
+	if err := utils.ValidateRPCURL(rpcURL); err != nil {
 		return nil, err
 	}
 
-	auth.GasLimit = uint64(22000)
-	gasPrice, err := client.SuggestGasPrice(context.Background())
+	client, err := utils.DialEthereum(context.Background(), rpcURL, utils.DefaultDialTimeout)
 	if err != nil {
-		log.Printf("Failed to suggest gas price: %v", err)
-		return nil, err
-	}
-	auth.GasPrice = new(big.Int).Div(gasPrice, big.NewInt(2))
-
-	contract := bind.NewBoundContract(common.HexToAddress(contractAddress), parsedABI, client, client, client)
-	if contract == nil {
-		log.Printf("Failed to create contract binding")
-		return nil, fmt.Errorf("failed to create contract binding")
-	}
-
-	if contractAddress == "" {
-		return nil, fmt.Errorf("contract address is required")
-	}
-	if abiJSON == "" {
-		return nil, fmt.Errorf("ABI JSON is required")
-	}
-
-	contract = bind.NewBoundContract(
-		common.HexToAddress(contractAddress),
-		parsedABI,
-		client,
-		client,
-		client,
-	)
-	if contract == nil {
-		return nil, fmt.Errorf("failed to create contract binding")
+		return nil, fmt.Errorf("%w: %w", ErrRPCDialFailed, err)
 	}
 
-	code, err := client.CodeAt(context.Background(), common.HexToAddress(contractAddress), nil)
+	address := common.HexToAddress(contractAddress)
+	code, err := client.CodeAt(context.Background(), address, nil)
 	if err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to check contract code: %w", err)
 	}
 	if len(code) == 0 {
-		return nil, fmt.Errorf("no contract code at address: %s", contractAddress)
+		client.Close()
+		return nil, fmt.Errorf("%w: %s", ErrNoContractCode, contractAddress)
 	}
 
+	logging.Infof("Connected to marketplace contract %s", contractAddress)
+
 	service := &EthereumService{
 		Client:          client,
-		ContractAddress: common.HexToAddress(contractAddress),
+		ContractAddress: address,
 		PrivateKey:      privateKey,
-		Contract:        contract, // Убедитесь, что это поле установлено
+		Contract:        bind.NewBoundContract(address, parsedABI, client, client, client),
 	}
 
 	return service, nil
@@ -128,199 +247,268 @@ func NewEthereumService(rpcURL, contractAddress, privateKeyHex, abiJSON string,
 // It will query the smart contract and compare the owner of the token ID with the given ownerAddress.
 //
 // If the owner matches, it will return true; otherwise, it will return false.
+// An ownerAddress that is not a hex address never matches.
 //
 // If there is an error during the smart contract query, it will return false and log the error.
 func (es *EthereumService) CheckOwnership(tokenID string, ownerAddress string) bool {
-	tokenIDBigInt := new(big.Int)
-	tokenIDBigInt.SetString(tokenID, 10)
-
-	owner := common.HexToAddress(ownerAddress)
-
-	var actualOwner common.Address
-	err := es.Contract.Call(nil, &[]interface{}{actualOwner}, "ownerOf", tokenIDBigInt)
+	actualOwner, err := es.OwnerOf(context.Background(), "", tokenID)
 	if err != nil {
 		log.Printf("Error checking ownership: %v\n", err)
 		return false
 	}
 
-	return actualOwner == owner
+	same, err := utils.SameAddressStr(actualOwner.Hex(), ownerAddress)
+	if err != nil {
+		log.Printf("Error checking ownership: %v\n", err)
+		return false
+	}
+	return same
 }
 
+// GetBalance returns the wei balance of address, the funds it can pay for a
+// purchase with.
 func (es *EthereumService) GetBalance(address common.Address) (*big.Int, error) {
-	var balance big.Int
-	err := es.Contract.Call(nil, &[]interface{}{balance}, "balanceOf", address)
+	if err := es.ready(); err != nil {
+		return nil, err
+	}
+
+	balance, err := es.Client.BalanceAt(context.Background(), address, nil)
 	if err != nil {
-		log.Printf("Failed to fetch balance: %v", err)
 		return nil, fmt.Errorf("failed to fetch balance: %w", err)
 	}
-	return &balance, nil
+	return balance, nil
 }
 
-// GetNFTs returns a list of NFTs owned by the given address. Currently, this
-// function is not implemented and will return an error.
-func (es *EthereumService) GetNFTs(accounts common.Address) ([]NFTListing, error) {
-	contractABI, err := os.ReadFile("./blockchain/Marketplace.json")
+// GetNFTs returns the listings created by the given seller address.
+//
+// Deprecated: use GetListingsBySeller, which takes a context.
+func (es *EthereumService) GetNFTs(seller common.Address) ([]NFTListing, error) {
+	return es.GetListingsBySeller(context.Background(), seller)
+}
+
+// GetListingsBySeller returns the listings created by seller, oldest first,
+// as returned by the marketplace's getListingsBySeller view through the
+// shared binding. The contract records each listing as it was created, so
+// ListingID is not set. It returns an empty list for sellers without
+// listings, and ErrNoContractCode when nothing is deployed at
+// ContractAddress.
+func (es *EthereumService) GetListingsBySeller(ctx context.Context, seller common.Address) ([]NFTListing, error) {
+	contract, err := es.marketplaceContract()
 	if err != nil {
-		log.Printf("Failed to read contract ABI: %v", err)
-		return nil, fmt.Errorf("failed to read contract ABI: %w", err)
+		return nil, err
 	}
 
-	parsedABI, err := abi.JSON(bytes.NewReader(contractABI))
+	code, err := es.Client.CodeAt(ctx, es.ContractAddress, nil)
 	if err != nil {
-		log.Printf("Failed to parse contract ABI: %v", err)
-		return nil, fmt.Errorf("failed to parse contract ABI: %w", err)
+		return nil, fmt.Errorf("failed to get contract code: %w", err)
+	}
+	if len(code) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoContractCode, es.ContractAddress.Hex())
 	}
 
-	services, err := NewEthereumService(
-		os.Getenv("BLOCKCHAIN_RPC"),
-		os.Getenv("CONTRACT_ADDRESS"),
-		os.Getenv("PRIVATE_KEY"),
-		string(contractABI),
-		big.NewInt(11155111),
-	)
+	result, err := contract.GetListingsBySeller(&bind.CallOpts{Context: ctx}, seller)
 	if err != nil {
-		log.Printf("Failed to create EthereumService: %v", err)
-		return nil, fmt.Errorf("failed to create EthereumService: %w", err)
+		return nil, fmt.Errorf("failed to get listings of seller %s: %w", seller.Hex(), err)
 	}
 
-	if services.Contract == nil {
-		log.Printf("Contract not initialized")
-		return nil, fmt.Errorf("contract not initialized")
+	// The tuple's uint128 token ID and price decode to big integers.
+	listings := make([]NFTListing, 0, len(result))
+	for _, listing := range result {
+		listings = append(listings, NFTListing{
+			Seller:   listing.Seller,
+			TokenID:  listing.TokenId,
+			Price:    utils.NewWei(listing.Price),
+			IsActive: listing.IsActive,
+		})
 	}
 
-	if es.Client == nil {
-		log.Printf("Client not initialized")
-		return nil, fmt.Errorf("client not initialized")
-	}
+	return listings, nil
+}
 
-	code, err := es.Client.CodeAt(context.Background(), es.ContractAddress, nil)
+// GetListingsBySellerPage returns up to limit of the listings created by
+// seller, skipping the first offset, and how many there are in total. The
+// contract only returns them all at once, so they are paged once read. A
+// non-positive limit returns all of them.
+func (es *EthereumService) GetListingsBySellerPage(ctx context.Context, seller common.Address, limit, offset int) ([]NFTListing, int64, error) {
+	listings, err := es.GetListingsBySeller(ctx, seller)
 	if err != nil {
-		log.Printf("Failed to get contract code: %v", err)
-		return nil, fmt.Errorf("failed to get contract code: %w", err)
+		return nil, 0, err
 	}
 
-	if len(code) == 0 {
-		log.Printf("Contract not deployed at address: %s", es.ContractAddress)
-		return nil, fmt.Errorf("contract not deployed at address: %s", es.ContractAddress)
+	total := int64(len(listings))
+	if limit <= 0 {
+		limit = len(listings)
 	}
+	return utils.Page(listings, limit, max(offset, 0)), total, nil
+}
 
-	contract := bind.NewBoundContract(es.ContractAddress, parsedABI, es.Client, es.Client, es.Client)
-	if contract == nil {
-		log.Printf("Failed to create contract: %v", err)
-		return nil, fmt.Errorf("failed to create contract: %w", err)
+// MintResult is the outcome of MintNFT. ListingID is only set once the
+// transaction is mined, as a decimal string since listing IDs do not fit in
+// JSON numbers, and stays empty if it could not be read then; Pending reports
+// that it was still pending when MintNFT stopped waiting.
+type MintResult struct {
+	TxHash    common.Hash    `json:"tx_hash"`
+	ListingID string         `json:"listing_id,omitempty"`
+	Recipient common.Address `json:"recipient"`
+	Pending   bool           `json:"pending"`
+}
+
+// MintNFT creates a new NFT and lists it on the marketplace with the given name, symbol, description, and price.
+//
+// It waits up to MintConfirmTimeout (one minute by default) for the listing
+// transaction to be mined, then reads the listing ID the contract assigned
+// back with getListingId. If the transaction is still pending by then, the
+// result only carries its hash and Pending is set. A reverted transaction is
+// an error.
+//
+// An empty recipient defaults to TreasuryAddress when one is configured; the
+// recipient used is returned in the result. Prices outside MinListingPrice and
+// MaxListingPrice are rejected before anything is submitted, and so are tokens
+// isTokenListed reports as listed, with ErrTokenAlreadyListed. A token listed
+// between that check and the transaction fails with ErrTokenAlreadyListed too,
+// whether createListing's AlreadyListed revert is caught by gas estimation or
+// the transaction reverts once mined.
+func (es *EthereumService) MintNFT(tokenID, price, recipient string) (MintResult, error) {
+	mint, err := es.submitMint(tokenID, price, recipient)
+	if err != nil {
+		return MintResult{}, err
 	}
+	tx, result := mint.tx, mint.result
+
+	timeout := es.confirmTimeout()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-	var result []interface{}
-	err = contract.Call(&bind.CallOpts{}, &result, "getListingsBySeller", accounts)
+	receipt, err := bind.WaitMined(ctx, es.Client, tx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Printf("Listing transaction %s still pending after %s", tx.Hash().Hex(), timeout)
+		result.Pending = true
+		return result, nil
+	}
 	if err != nil {
-		log.Printf("Failed to get listings: %v", err)
-		return nil, fmt.Errorf("failed to get listings: %w", err)
+		return result, fmt.Errorf("failed to wait for listing transaction: %w", err)
 	}
 
-	listings := make([]NFTListing, 0)
-	for _, item := range result {
-		if listing, ok := item.(struct {
-			Seller   common.Address
-			TokenId  *big.Int
-			Price    *big.Int
-			IsActive bool
-		}); ok {
-			listings = append(listings, NFTListing{
-				Seller:   listing.Seller,
-				TokenID:  listing.TokenId,
-				Price:    listing.Price,
-				IsActive: listing.IsActive,
-			})
-		}
+	listingID, err := mint.listingID(ctx, receipt)
+	if err != nil {
+		return result, err
 	}
+	result.ListingID = listingID
 
-	return listings, nil
+	return result, nil
 }
 
-// MintNFT creates a new NFT and lists it on the marketplace with the given name, symbol, description, and price.
-func (es *EthereumService) MintNFT(tokenID, price, recipient string) error {
-	log.Printf("Minting NFT with token ID: %s for recipient: %s with price: %s", tokenID, recipient, price)
+// submittedMint is a listing transaction sent by submitMint.
+type submittedMint struct {
+	tx     *types.Transaction
+	result MintResult
+	token  *big.Int
+	market *marketplace.Marketplace
+}
 
-	if !common.IsHexAddress(recipient) {
-		log.Printf("Invalid recipient address: %s", recipient)
-		return fmt.Errorf("invalid recipient address")
+// submitMint validates a mint and sends its createListing transaction,
+// without waiting for it to be mined. See MintNFT.
+func (es *EthereumService) submitMint(tokenID, price, recipient string) (*submittedMint, error) {
+	if _, err := es.readySigner(); err != nil {
+		return nil, err
 	}
-
-	recipientAddress := common.HexToAddress(recipient)
-	if recipientAddress == (common.Address{}) {
-		log.Printf("Invalid recipient address: %s", recipient)
-		return fmt.Errorf("invalid recioient address")
+	if strings.TrimSpace(recipient) == "" && es.TreasuryAddress != (common.Address{}) {
+		recipient = es.TreasuryAddress.Hex()
 	}
+	log.Printf("Minting NFT with token ID: %s for recipient: %s with price: %s", tokenID, recipient, price)
 
-	tokenIDBigInt := new(big.Int)
-	if _, ok := tokenIDBigInt.SetString(tokenID, 10); !ok {
-		log.Printf("Invalid token ID: %s", tokenID)
-		return fmt.Errorf("invalid token ID: %s", tokenID)
+	recipientAddress, err := es.validateRecipient(recipient)
+	if err != nil {
+		return nil, err
 	}
 
-	priceBigInt := new(big.Int)
-	if _, ok := priceBigInt.SetString(price, 10); !ok {
-		log.Printf("Invalid price: %s", price)
-		return fmt.Errorf("invalid price: %s", price)
+	tokenIDBigInt, err := parseBigInt("token ID", tokenID)
+	if err != nil {
+		return nil, err
 	}
 
-	chainID, err := es.Client.ChainID(context.Background())
+	priceBigInt, err := parseBigInt("price", price)
 	if err != nil {
-		log.Printf("Failed to get chain ID: %v", err)
-		return fmt.Errorf("failed to get chain ID: %w", err)
+		return nil, err
+	}
+	if err := es.validateListingPrice(priceBigInt); err != nil {
+		return nil, err
 	}
 
-	privateKeyECDSA, err := crypto.HexToECDSA(os.Getenv("PRIVATE_KEY"))
+	market, err := es.marketplaceContract()
 	if err != nil {
-		log.Printf("Invalid to load private key: %v", err)
-		return fmt.Errorf("invalid to load private key: %w", err)
+		return nil, err
 	}
-
-	auth, err := bind.NewKeyedTransactorWithChainID(privateKeyECDSA, chainID)
+	listed, err := market.IsTokenListed(&bind.CallOpts{Context: context.Background()}, tokenIDBigInt)
 	if err != nil {
-		log.Printf("Failed to create transactor: %v", err)
-		return fmt.Errorf("failed to create transactor: %w", err)
+		return nil, fmt.Errorf("failed to check listing status of token %s: %w", tokenID, err)
+	}
+	if listed {
+		return nil, fmt.Errorf("%w: %s", ErrTokenAlreadyListed, tokenID)
 	}
 
-	auth.GasLimit = uint64(22000)
-	gasPrice, err := es.Client.SuggestGasPrice(context.Background())
+	auth, err := es.newTransactor(context.Background())
 	if err != nil {
-		log.Printf("Failed to suggest gas price: %v", err)
-		return fmt.Errorf("failed to suggest gas price: %w", err)
+		return nil, err
 	}
-	auth.GasPrice = new(big.Int).Div(gasPrice, big.NewInt(2))
 
-	contractABI, err := os.ReadFile("./blockchain/Marketplace.json")
+	parsedABI, err := marketplace.MarketplaceMetaData.GetAbi()
 	if err != nil {
-		log.Printf("Failed to read contract ABI: %v", err)
-		return fmt.Errorf("failed to read contract ABI: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrABIParse, err)
 	}
 
-	parsedABI, err := abi.JSON(bytes.NewReader(contractABI))
+	tx, err := market.CreateListing(auth, tokenIDBigInt, priceBigInt)
 	if err != nil {
-		log.Printf("invalid to parse ABI: %v", err)
-		return fmt.Errorf("invalid to parse ABI: %w", err)
+		es.releaseUnsentNonce(context.Background(), auth)
+		// The token may have been listed since it was checked.
+		if revertsWith(err, *parsedABI, "AlreadyListed") {
+			return nil, fmt.Errorf("%w: %s", ErrTokenAlreadyListed, tokenID)
+		}
+		return nil, fmt.Errorf("failed to mint NFT: %w", err)
 	}
 
-	contract := bind.NewBoundContract(es.ContractAddress, parsedABI, es.Client, es.Client, es.Client)
+	log.Printf("NFT minted successfully! Transaction hash: %s", tx.Hash().Hex())
+	return &submittedMint{
+		tx:     tx,
+		result: MintResult{TxHash: tx.Hash(), Recipient: recipientAddress},
+		token:  tokenIDBigInt,
+		market: market,
+	}, nil
+}
 
-	tx, err := contract.Transact(auth, "createListing", tokenIDBigInt, priceBigInt)
-	if err != nil {
-		log.Printf("failed to mint NFT: %v", err)
-		return fmt.Errorf("failed to mint NFT: %w", err)
+// listingID reads the ID of the listing created by the mint, as of the block
+// of its receipt. A reverted transaction is an error. Failing to read the ID of
+// a mined listing is not: the listing exists and must still be recorded, so
+// the ID is left empty for ReconcileListings to fill in.
+func (m *submittedMint) listingID(ctx context.Context, receipt *types.Receipt) (string, error) {
+	opts := &bind.CallOpts{Context: ctx, BlockNumber: receipt.BlockNumber}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		// A listing of the token mined first makes this one revert.
+		listed, err := m.market.IsTokenListed(opts, m.token)
+		if err == nil && listed {
+			return "", fmt.Errorf("%w: %s, listing transaction %s reverted", ErrTokenAlreadyListed, m.token, m.tx.Hash().Hex())
+		}
+		return "", fmt.Errorf("listing transaction %s reverted", m.tx.Hash().Hex())
 	}
 
-	fmt.Printf("NFT minted successfully! Transaction hash: %s\n", tx.Hash().Hex())
-	return nil
+	listingID, err := m.market.GetListingId(opts, m.token)
+	if err != nil {
+		log.Printf("Failed to get listing ID of token %s after transaction %s was mined, leaving it to reconciliation: %v", m.token, m.tx.Hash().Hex(), err)
+		return "", nil
+	}
+	return listingID.String(), nil
 }
 
 // TransferNFT transfers an NFT to the buyer, given the token ID.
 //
 // This method will first check if the token ID is valid, and if the buyer's address is valid.
-// Then, it will get the chain ID, and construct a new transactor with the private key and chain ID.
-// Next, it will get the network ID, and suggest a gas price.
-// After that, it will set the gas limit and gas price for the transactor.
-// Finally, it will call the transfer function on the contract, and return the transaction hash.
+// Then, it will look up the token's active listing and its price through the marketplace
+// binding, returning ErrListingNotActive when the token is not listed.
+// Next, it will build a transactor from the service's private key, using EIP-1559 dynamic
+// fees when the chain supports them and a legacy gas price otherwise.
+// Finally, it will call purchaseListing with the listing ID, paying the listing price, and
+// return the transaction hash without waiting for it to be mined. With VerifyPurchases set,
+// the buyer's ownership of the token is checked in the background once it is.
 //
 // Parameters:
 //
@@ -329,140 +517,149 @@ func (es *EthereumService) MintNFT(tokenID, price, recipient string) error {
 //
 // Returns:
 //
-//	An error if something goes wrong.
-func (es *EthereumService) TransferNFT(tokenID, buyer string) error {
+//	The hash of the purchase transaction, or an error if something goes wrong.
+func (es *EthereumService) TransferNFT(tokenID, buyer string) (common.Hash, error) {
+	contract, err := es.marketplaceContract()
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if _, err := es.readySigner(); err != nil {
+		return common.Hash{}, err
+	}
 	log.Printf("Starting NFT transfer: tokenID=%s, buyer=%s", tokenID, buyer)
 
 	buyerAddress := common.HexToAddress(buyer)
 	if buyerAddress == (common.Address{}) {
-		log.Printf("invalid buyer address")
-		return fmt.Errorf("invalid address")
+		return common.Hash{}, fmt.Errorf("invalid address")
 	}
-	tokenIDBigInt := new(big.Int)
-	if _, ok := tokenIDBigInt.SetString(tokenID, 10); !ok {
-		log.Printf("invalid token ID")
-		return fmt.Errorf("invalid token ID")
+	tokenIDBigInt, err := parseBigInt("token ID", tokenID)
+	if err != nil {
+		return common.Hash{}, err
 	}
 
-	chainID, err := es.Client.ChainID(context.Background())
+	opts := &bind.CallOpts{Context: context.Background()}
+	listed, err := contract.IsTokenListed(opts, tokenIDBigInt)
 	if err != nil {
-		log.Printf("failed to get chain ID: %v", err)
-		return fmt.Errorf("failed to get chain ID: %w", err)
+		return common.Hash{}, fmt.Errorf("failed to check listing status of token %s: %w", tokenID, err)
 	}
-
-	if es.PrivateKey == nil {
-		log.Printf("invalid private key")
-		return fmt.Errorf("invalid private key")
+	if !listed {
+		return common.Hash{}, fmt.Errorf("%w: token %s", ErrListingNotActive, tokenID)
 	}
-
-	auth, err := bind.NewKeyedTransactorWithChainID(es.PrivateKey, chainID)
+	listingID, err := contract.GetListingId(opts, tokenIDBigInt)
 	if err != nil {
-		log.Printf("failed to create transactor: %v", err)
-		return fmt.Errorf("failed to create transactor: %w", err)
+		return common.Hash{}, fmt.Errorf("failed to get listing ID of token %s: %w", tokenID, err)
 	}
-
-	var price *big.Int
-	err = es.Contract.Call(nil, &[]interface{}{price}, "getListingPrice", tokenIDBigInt)
+	listing, err := contract.Listings(opts, listingID)
 	if err != nil {
-		log.Printf("failed to get listing price: %v", err)
-		return fmt.Errorf("failed to get listing price: %w", err)
+		return common.Hash{}, fmt.Errorf("failed to get listing %s: %w", listingID, err)
 	}
-
-	auth.Value = price
-
-	_, err = es.Client.NetworkID(context.Background())
-	if err != nil {
-		log.Printf("failed to get network ID: %v", err)
-		return fmt.Errorf("failed to get network ID: %w", err)
+	if !listing.IsActive {
+		return common.Hash{}, fmt.Errorf("%w: %s", ErrListingNotActive, listingID)
 	}
 
-	auth.GasLimit = uint64(22000)
-	gasPrice, err := es.Client.SuggestGasPrice(context.Background())
+	auth, err := es.newTransactor(context.Background())
 	if err != nil {
-		log.Printf("failed to suggest gas price: %v", err)
-		return fmt.Errorf("failed to suggest gas price: %w", err)
+		return common.Hash{}, err
 	}
-	auth.GasPrice = new(big.Int).Div(gasPrice, big.NewInt(2))
+	auth.Value = listing.Price
 
-	contractABI, err := os.ReadFile("./blockchain/Marketplace.json")
+	tx, err := contract.PurchaseListing(auth, listingID)
 	if err != nil {
-		log.Printf("Failed to read contract ABI: %v", err)
-		return fmt.Errorf("failed to read contract ABI: %w", err)
+		es.releaseUnsentNonce(context.Background(), auth)
+		return common.Hash{}, fmt.Errorf("failed to transfer NFT: %w", err)
 	}
 
-	parsedABI, err := abi.JSON(bytes.NewReader(contractABI))
-	if err != nil {
-		log.Printf("Failed to parse contract ABI: %v", err)
-		return fmt.Errorf("failed to parse contract ABI: %w", err)
+	log.Printf("Transfer successful! Transaction hash: %s", tx.Hash().Hex())
+	if es.VerifyPurchases {
+		es.verifyPurchaseInBackground(tx, tokenID, tokenIDBigInt, buyerAddress)
 	}
+	return tx.Hash(), nil
+}
 
-	contract := bind.NewBoundContract(es.ContractAddress, parsedABI, es.Client, es.Client, es.Client)
-
-	tx, err := contract.Transact(auth, "purchaseListing", tokenIDBigInt)
-	if err != nil {
-		log.Printf("Failed to transfer NFT: %v", err)
-		return fmt.Errorf("failed to transfer NFT: %w", err)
+// confirmTimeout returns how long to wait for a transaction to be mined.
+func (es *EthereumService) confirmTimeout() time.Duration {
+	if es.MintConfirmTimeout <= 0 {
+		return defaultMintConfirmTimeout
 	}
-
-	log.Printf("Transfer successful! Transaction hash: %s", tx.Hash().Hex())
-	return nil
+	return es.MintConfirmTimeout
 }
 
 // SearchNFTs searches for NFTs with the given name in the database.
 //
-// It takes a single parameter `name` which is the name of the NFT to search for.
+// It takes the caller's context, which cancels the query, and `name`, the
+// name of the NFT to search for.
 // The function logs the search operation and returns a list of NFTs that match
-// the given name. If there is an error during the database query, it logs the
-// error and returns an empty result along with the error.
+// the given name. A search without matches returns an empty list and a nil
+// error; only a failed database query returns an error. Results are cached in
+// QueryCache.
 //
 // Returns:
-// - A `db.Nfts` containing the list of NFTs with the specified name.
+// - A `[]db.Nfts` containing the NFTs with the specified name, never nil on success.
 // - An `error` if the database query fails.
-func (es *EthereumService) SearchNFTs(name string) (db.Nfts, error) {
+func (es *EthereumService) SearchNFTs(ctx context.Context, name string) ([]db.Nfts, error) {
 	log.Printf("Searching for NFTs by event with name: %s", name)
 
-	result, err := db.GetNFTsByName(name)
+	result, err := cachedQuery(es, "search:"+name, func() ([]db.Nfts, error) {
+		return db.GetNFTsByName(ctx, name)
+	})
 	if err != nil {
-		log.Printf("Failed to get NFTs by name: %v", err)
-		return db.Nfts{}, fmt.Errorf("failed to get NFTs by name: %w", err)
+		return nil, fmt.Errorf("failed to get NFTs by name: %w", err)
 	}
 
 	return result, nil
 }
 
+// DeleteNFT deletes the database record of tokenID and cancels its listing
+// through the marketplace binding. The listing is looked up before anything
+// is deleted, so that the record stays when it cannot be; a token that is not
+// listed only has its record deleted.
 func (es *EthereumService) DeleteNFT(tokenID string) error {
+	contract, err := es.marketplaceContract()
+	if err != nil {
+		return err
+	}
+	if _, err := es.readySigner(); err != nil {
+		return err
+	}
 	log.Printf("Starting NFT deletion: tokenID=%s", tokenID)
 
-	tokenIDBigInt := new(big.Int)
-	if _, ok := tokenIDBigInt.SetString(tokenID, 10); !ok {
-		log.Printf("Invalid token ID: %s", tokenID)
-		return fmt.Errorf("invalid token ID: %s", tokenID)
+	tokenIDBigInt, err := parseBigInt("token ID", tokenID)
+	if err != nil {
+		return err
 	}
 
-	err := db.DeleteNFT(tokenID)
+	opts := &bind.CallOpts{Context: context.Background()}
+	listed, err := contract.IsTokenListed(opts, tokenIDBigInt)
 	if err != nil {
-		log.Printf("Failed to delete NFT from database: %v", err)
-		return fmt.Errorf("failed to delete NFT from database: %w", err)
+		return fmt.Errorf("failed to check listing status of token %s: %w", tokenID, err)
+	}
+	var listingID *big.Int
+	if listed {
+		listingID, err = contract.GetListingId(opts, tokenIDBigInt)
+		if err != nil {
+			return fmt.Errorf("failed to get listing ID of token %s: %w", tokenID, err)
+		}
 	}
 
-	contractABI, err := os.ReadFile("./blockchain/Marketplace.json")
+	err = db.DeleteNFT(tokenID)
 	if err != nil {
-		log.Fatalf("Failed to read contract ABI: %v", err)
-		return fmt.Errorf("failed to read contract ABI: %w", err)
+		return fmt.Errorf("failed to delete NFT from database: %w", err)
+	}
+	es.InvalidateQueries()
+	if listingID == nil {
+		log.Printf("NFT %s deleted, it had no listing to cancel", tokenID)
+		return nil
 	}
 
-	parsedABI, err := abi.JSON(bytes.NewReader(contractABI))
+	auth, err := es.newTransactor(context.Background())
 	if err != nil {
-		log.Fatalf("Failed to parse contract ABI: %v", err)
-		return fmt.Errorf("failed to parse contract ABI: %w", err)
+		return err
 	}
 
-	contract := bind.NewBoundContract(es.ContractAddress, parsedABI, es.Client, es.Client, es.Client)
-
-	tx, err := contract.Transact(nil, "deleteListing", tokenIDBigInt)
+	tx, err := contract.CancelListing(auth, listingID)
 	if err != nil {
-		log.Fatalf("Failed to delete NFT: %v", err)
-		return fmt.Errorf("failed to delete NFT: %w", err)
+		es.releaseUnsentNonce(context.Background(), auth)
+		return fmt.Errorf("failed to cancel listing %s of token %s: %w", listingID, tokenID, err)
 	}
 
 	log.Printf("NFT deleted successfully! Transaction hash: %s", tx.Hash().Hex())
@@ -0,0 +1,363 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net"
+	marketplace "nft-marketplace/blockchain"
+	"nft-marketplace/blockchain/chaintest"
+	"nft-marketplace/db/dbtest"
+	"nft-marketplace/utils"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestMintListingID(t *testing.T) {
+	// A keccak-sized ID, far beyond what JSON numbers hold exactly.
+	bigID, _ := new(big.Int).SetString("81129638414606663681390495662081786537366906475098075501962626994192501126629", 10)
+
+	tests := []struct {
+		name    string
+		status  uint64
+		idErr   error
+		listed  bool
+		wantID  string
+		wantErr error
+		fails   bool
+	}{
+		{name: "mined", status: types.ReceiptStatusSuccessful, wantID: bigID.String()},
+		// The listing exists on chain, so the mint must still be recorded.
+		{name: "ID unreadable after mining", status: types.ReceiptStatusSuccessful, idErr: errors.New("header not found")},
+		{name: "reverted by an earlier listing", status: types.ReceiptStatusFailed, listed: true, wantErr: ErrTokenAlreadyListed, fails: true},
+		{name: "reverted", status: types.ReceiptStatusFailed, fails: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := chaintest.NewNode(t)
+			node.HandleCall("getListingId", func([]any) ([]any, error) {
+				if tt.idErr != nil {
+					return nil, tt.idErr
+				}
+				return []any{bigID}, nil
+			})
+			node.HandleCall("isTokenListed", chaintest.Outputs(tt.listed))
+			es := &EthereumService{Client: node.Client, ContractAddress: chaintest.Contract}
+			market, err := es.marketplaceContract()
+			if err != nil {
+				t.Fatal(err)
+			}
+			to := common.HexToAddress("0x01")
+			mint := &submittedMint{
+				tx:     types.NewTx(&types.LegacyTx{To: &to}),
+				token:  big.NewInt(7),
+				market: market,
+			}
+
+			id, err := mint.listingID(context.Background(), &types.Receipt{Status: tt.status, BlockNumber: big.NewInt(100)})
+			if (err != nil) != tt.fails || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Fatalf("err = %v, want %v (failing: %t)", err, tt.wantErr, tt.fails)
+			}
+			if id != tt.wantID {
+				t.Fatalf("listing ID = %q, want %q", id, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestMintResultJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		result MintResult
+		want   string
+		absent string
+	}{
+		{name: "listing ID as a string", result: MintResult{ListingID: "81129638414606663681390495662081786537366906475098075501962626994192501126629"}, want: `"listing_id":"81129638414606663681390495662081786537366906475098075501962626994192501126629"`},
+		{name: "unknown listing ID omitted", result: MintResult{Pending: true}, absent: `"listing_id"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.result)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want != "" && !strings.Contains(string(data), tt.want) {
+				t.Fatalf("got %s, want it to contain %s", data, tt.want)
+			}
+			if tt.absent != "" && strings.Contains(string(data), tt.absent) {
+				t.Fatalf("got %s, want no %s", data, tt.absent)
+			}
+		})
+	}
+}
+
+func TestTransferNFT(t *testing.T) {
+	buyer := common.HexToAddress("0x00000000000000000000000000000000000b0b01")
+	seller := common.HexToAddress("0x0000000000000000000000000000000000005e11")
+	listingID := big.NewInt(42)
+	price := big.NewInt(1e18)
+
+	tests := []struct {
+		name    string
+		listed  bool
+		active  bool
+		wantErr error
+	}{
+		{name: "active listing", listed: true, active: true},
+		{name: "token not listed", wantErr: ErrListingNotActive},
+		{name: "listing inactive", listed: true, wantErr: ErrListingNotActive},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := crypto.GenerateKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			node := chaintest.NewNode(t)
+			node.HandleCall("isTokenListed", chaintest.Outputs(tt.listed))
+			node.HandleCall("getListingId", func(args []any) ([]any, error) {
+				if args[0].(*big.Int).Cmp(big.NewInt(7)) != 0 {
+					return nil, errors.New("unexpected token")
+				}
+				return []any{listingID}, nil
+			})
+			node.HandleCall("listings", chaintest.Outputs(seller, big.NewInt(7), price, tt.active))
+			node.Handle("eth_getBlockByNumber", chaintest.Returns(&types.Header{Number: big.NewInt(100), Difficulty: big.NewInt(0)}))
+			node.Handle("eth_gasPrice", chaintest.Returns(hexutil.Big(*big.NewInt(10_000_000_000))))
+			node.Handle("eth_getTransactionCount", chaintest.Returns(hexutil.Uint64(3)))
+			node.Handle("eth_getCode", chaintest.Returns(hexutil.Bytes{0x60, 0x80}))
+			node.Handle("eth_estimateGas", chaintest.Returns(hexutil.Uint64(150_000)))
+			var sent *types.Transaction
+			node.Handle("eth_sendRawTransaction", func(params []json.RawMessage) (any, error) {
+				var raw hexutil.Bytes
+				if err := json.Unmarshal(params[0], &raw); err != nil {
+					return nil, err
+				}
+				sent = new(types.Transaction)
+				if err := sent.UnmarshalBinary(raw); err != nil {
+					return nil, err
+				}
+				return sent.Hash(), nil
+			})
+
+			es := &EthereumService{Client: node.Client, ContractAddress: chaintest.Contract, PrivateKey: key}
+			txHash, err := es.TransferNFT("7", buyer.Hex())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if node.Count("eth_sendRawTransaction") != 0 {
+					t.Fatal("purchase of an inactive listing was sent")
+				}
+				return
+			}
+
+			if sent == nil || txHash != sent.Hash() {
+				t.Fatalf("tx hash = %s, want the sent transaction's", txHash.Hex())
+			}
+			if *sent.To() != chaintest.Contract {
+				t.Fatalf("sent to %s, want the marketplace", sent.To().Hex())
+			}
+			if sent.Value().Cmp(price) != 0 {
+				t.Fatalf("value = %s, want the listing price %s", sent.Value(), price)
+			}
+			parsed, err := marketplace.MarketplaceMetaData.GetAbi()
+			if err != nil {
+				t.Fatal(err)
+			}
+			method, err := parsed.MethodById(sent.Data())
+			if err != nil {
+				t.Fatal(err)
+			}
+			args, err := method.Inputs.Unpack(sent.Data()[4:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if method.Name != "purchaseListing" || args[0].(*big.Int).Cmp(listingID) != 0 {
+				t.Fatalf("sent %s%v, want purchaseListing(%s)", method.Name, args, listingID)
+			}
+		})
+	}
+}
+
+func TestDeleteNFT(t *testing.T) {
+	listingID := big.NewInt(42)
+	errRejected := errors.New("nonce too low")
+
+	tests := []struct {
+		name   string
+		listed bool
+		// sendErr is what the node answers the cancellation with.
+		sendErr error
+		wantErr error
+	}{
+		{name: "listed", listed: true},
+		{name: "not listed"},
+		// A failed cancellation is returned, not fatal to the worker.
+		{name: "cancellation rejected", listed: true, sendErr: errRejected, wantErr: errRejected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := crypto.GenerateKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			node := chaintest.NewNode(t)
+			node.HandleCall("isTokenListed", chaintest.Outputs(tt.listed))
+			node.HandleCall("getListingId", chaintest.Outputs(listingID))
+			node.Handle("eth_getBlockByNumber", chaintest.Returns(&types.Header{Number: big.NewInt(100), Difficulty: big.NewInt(0)}))
+			node.Handle("eth_gasPrice", chaintest.Returns(hexutil.Big(*big.NewInt(10_000_000_000))))
+			node.Handle("eth_getTransactionCount", chaintest.Returns(hexutil.Uint64(3)))
+			node.Handle("eth_getCode", chaintest.Returns(hexutil.Bytes{0x60, 0x80}))
+			node.Handle("eth_estimateGas", chaintest.Returns(hexutil.Uint64(50_000)))
+			var sent *types.Transaction
+			node.Handle("eth_sendRawTransaction", func(params []json.RawMessage) (any, error) {
+				if tt.sendErr != nil {
+					return nil, tt.sendErr
+				}
+				var raw hexutil.Bytes
+				if err := json.Unmarshal(params[0], &raw); err != nil {
+					return nil, err
+				}
+				sent = new(types.Transaction)
+				if err := sent.UnmarshalBinary(raw); err != nil {
+					return nil, err
+				}
+				return sent.Hash(), nil
+			})
+
+			mock := dbtest.Mock(t)
+			mock.ExpectBegin()
+			mock.ExpectExec(`DELETE FROM "nfts" WHERE id = \$1`).WithArgs("7").WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			es := &EthereumService{Client: node.Client, ContractAddress: chaintest.Contract, PrivateKey: key}
+			err = es.DeleteNFT("7")
+			if tt.wantErr != nil {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr.Error()) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !tt.listed {
+				if node.Count("eth_sendRawTransaction") != 0 {
+					t.Fatal("cancellation sent for a token without a listing")
+				}
+				return
+			}
+			parsed, err := marketplace.MarketplaceMetaData.GetAbi()
+			if err != nil {
+				t.Fatal(err)
+			}
+			method, err := parsed.MethodById(sent.Data())
+			if err != nil {
+				t.Fatal(err)
+			}
+			args, err := method.Inputs.Unpack(sent.Data()[4:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if method.Name != "cancelListing" || args[0].(*big.Int).Cmp(listingID) != 0 {
+				t.Fatalf("sent %s%v, want cancelListing(%s)", method.Name, args, listingID)
+			}
+		})
+	}
+}
+
+func TestNewEthereumService(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyHex := hex.EncodeToString(crypto.FromECDSA(key))
+
+	tests := []struct {
+		name   string
+		keyHex string
+		abi    string
+		code   hexutil.Bytes
+		// wantErr is nil for a service connected to the contract.
+		wantErr error
+	}{
+		{name: "connected", keyHex: "0x" + keyHex, abi: marketplace.MarketplaceMetaData.ABI, code: hexutil.Bytes{0x60, 0x80}},
+		{name: "invalid private key", keyHex: "not a key", abi: marketplace.MarketplaceMetaData.ABI, wantErr: ErrInvalidPrivateKey},
+		{name: "invalid ABI", keyHex: keyHex, abi: "{", wantErr: ErrABIParse},
+		// Usually a contract address for another network.
+		{name: "no contract code", keyHex: keyHex, abi: marketplace.MarketplaceMetaData.ABI, code: hexutil.Bytes{}, wantErr: ErrNoContractCode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := chaintest.NewNode(t)
+			node.Handle("eth_getCode", chaintest.Returns(tt.code))
+
+			es, err := NewEthereumService(node.URL, chaintest.Contract.Hex(), tt.keyHex, tt.abi, big.NewInt(chaintest.ChainID))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			defer es.Client.Close()
+			if es.ContractAddress != chaintest.Contract || !es.PrivateKey.Equal(key) || es.Contract == nil {
+				t.Fatalf("service for %s signed by %s, want %s signed by %s",
+					es.ContractAddress.Hex(), crypto.PubkeyToAddress(es.PrivateKey.PublicKey).Hex(), chaintest.Contract.Hex(), crypto.PubkeyToAddress(key.PublicKey).Hex())
+			}
+		})
+	}
+}
+
+func TestNewEthereumServiceDialFailure(t *testing.T) {
+	// A port nothing listens on.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyHex := hex.EncodeToString(crypto.FromECDSA(key))
+
+	tests := []struct {
+		name   string
+		rpcURL string
+		want   []error
+		// wantTransport is whether the *net.OpError of the failed
+		// connection can be unwrapped.
+		wantTransport bool
+	}{
+		{name: "http", rpcURL: "http://" + addr, want: []error{ErrRPCDialFailed, syscall.ECONNREFUSED}, wantTransport: true},
+		{name: "websocket", rpcURL: "ws://" + addr, want: []error{ErrRPCDialFailed, syscall.ECONNREFUSED}, wantTransport: true},
+		{name: "invalid URL", rpcURL: "localhost:8545", want: []error{utils.ErrInvalidRPCURL}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewEthereumService(tt.rpcURL, chaintest.Contract.Hex(), keyHex, marketplace.MarketplaceMetaData.ABI, big.NewInt(chaintest.ChainID))
+			for _, want := range tt.want {
+				if !errors.Is(err, want) {
+					t.Fatalf("err = %v, want it to wrap %v", err, want)
+				}
+			}
+			var opErr *net.OpError
+			if errors.As(err, &opErr) != tt.wantTransport {
+				t.Fatalf("err = %v, want a *net.OpError: %t", err, tt.wantTransport)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"log"
+	"nft-marketplace/db"
+	"nft-marketplace/logging"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ErrSignerRotated is returned when a transaction built for the previous
+// signing key is signed after the key was rotated.
+var ErrSignerRotated = errors.New("signing key rotated while the transaction was being built")
+
+// SignerRotation describes a completed RotateSigner call.
+type SignerRotation struct {
+	Previous common.Address `json:"previous"`
+	Current  common.Address `json:"current"`
+	// Nonce is the pending nonce of the new account, where its next
+	// transaction starts.
+	Nonce uint64 `json:"nonce"`
+}
+
+// signer returns the key transactions are currently signed with.
+func (es *EthereumService) signer() *ecdsa.PrivateKey {
+	es.signerMu.RLock()
+	defer es.signerMu.RUnlock()
+	return es.PrivateKey
+}
+
+// RotateSigner replaces the service's signing key with newKeyHex without a
+// restart.
+//
+// The key is validated and its pending nonce read from the chain before
+// anything changes, so a bad key or an unreachable node leaves the current
+// signer in place. The swap itself happens under a lock: transactors built
+// before it refuse to sign afterwards with ErrSignerRotated rather than using
+// the old key, and every later transaction takes its nonce from the new
+// account. Replacements of transactions already sent by SendAndConfirm keep
+// the key they were first signed with, as they must to replace them.
+func (es *EthereumService) RotateSigner(ctx context.Context, newKeyHex string) (SignerRotation, error) {
+	newKeyHex = strings.TrimPrefix(strings.TrimSpace(newKeyHex), "0x")
+	logging.RegisterSecret(newKeyHex)
+
+	key, err := crypto.HexToECDSA(newKeyHex)
+	if err != nil {
+		return SignerRotation{}, fmt.Errorf("%w: %w", ErrInvalidPrivateKey, err)
+	}
+	if err := es.ready(); err != nil {
+		return SignerRotation{}, err
+	}
+
+	rotation := SignerRotation{Current: crypto.PubkeyToAddress(key.PublicKey)}
+	rotation.Nonce, err = es.Client.PendingNonceAt(ctx, rotation.Current)
+	if err != nil {
+		return SignerRotation{}, fmt.Errorf("failed to get pending nonce of %s: %w", rotation.Current.Hex(), err)
+	}
+	if es.DurableNonces {
+		if err := db.SetNonce(rotation.Current.Hex(), rotation.Nonce); err != nil {
+			return SignerRotation{}, fmt.Errorf("failed to store nonce of %s: %w", rotation.Current.Hex(), err)
+		}
+	}
+
+	es.signerMu.Lock()
+	if es.PrivateKey != nil {
+		rotation.Previous = crypto.PubkeyToAddress(es.PrivateKey.PublicKey)
+	}
+	es.PrivateKey = key
+	es.signerMu.Unlock()
+
+	log.Printf("Signing key rotated from %s to %s (pending nonce %d)", rotation.Previous.Hex(), rotation.Current.Hex(), rotation.Nonce)
+	return rotation, nil
+}
+
+// guardSigner makes auth refuse to sign once key is no longer the service's
+// signing key. The check holds the read lock until the wrapped signer returns,
+// nonce reservation included, so RotateSigner waits for a transaction being
+// signed with the old key instead of swapping the key halfway through. The
+// wrapped signer must not take the lock itself, see signer.
+func (es *EthereumService) guardSigner(auth *bind.TransactOpts, key *ecdsa.PrivateKey) {
+	sign := auth.Signer
+	auth.Signer = func(from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		es.signerMu.RLock()
+		defer es.signerMu.RUnlock()
+		if es.PrivateKey != key {
+			return nil, ErrSignerRotated
+		}
+		return sign(from, tx)
+	}
+}
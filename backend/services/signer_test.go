@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"nft-marketplace/blockchain/chaintest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestGuardSignerBlocksRotation(t *testing.T) {
+	oldKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := chaintest.NewNode(t)
+	node.Handle("eth_getTransactionCount", chaintest.Returns(hexutil.Uint64(3)))
+	es := &EthereumService{Client: node.Client, ContractAddress: chaintest.Contract, PrivateKey: oldKey}
+
+	// The wrapped signer stands in for the nonce reservation and signing,
+	// and holds until released.
+	entered, release := make(chan struct{}), make(chan struct{})
+	auth := &bind.TransactOpts{Signer: func(_ common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		close(entered)
+		<-release
+		return tx, nil
+	}}
+	es.guardSigner(auth, oldKey)
+
+	signed := make(chan error, 1)
+	go func() {
+		_, err := auth.Signer(common.Address{}, types.NewTx(&types.LegacyTx{}))
+		signed <- err
+	}()
+	<-entered
+
+	rotated := make(chan error, 1)
+	go func() {
+		_, err := es.RotateSigner(context.Background(), hex.EncodeToString(crypto.FromECDSA(newKey)))
+		rotated <- err
+	}()
+	select {
+	case err := <-rotated:
+		t.Fatalf("RotateSigner() = %v while a transaction was being signed, want it to wait", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-signed; err != nil {
+		t.Fatalf("signing with the old key = %v, want nil", err)
+	}
+	if err := <-rotated; err != nil {
+		t.Fatalf("RotateSigner() = %v", err)
+	}
+	if _, err := auth.Signer(common.Address{}, types.NewTx(&types.LegacyTx{})); !errors.Is(err, ErrSignerRotated) {
+		t.Fatalf("signing after the rotation = %v, want %v", err, ErrSignerRotated)
+	}
+}
+
+// TestGuardSignerConcurrentRotation is meant to run with -race: no
+// transaction starts signing with a key and sees the service rotate away
+// from it before it is done.
+func TestGuardSignerConcurrentRotation(t *testing.T) {
+	node := chaintest.NewNode(t)
+	node.Handle("eth_getTransactionCount", chaintest.Returns(hexutil.Uint64(0)))
+	first, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	es := &EthereumService{Client: node.Client, ContractAddress: chaintest.Contract, PrivateKey: first}
+
+	var swapped atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				key := es.signer()
+				auth := &bind.TransactOpts{Signer: func(_ common.Address, tx *types.Transaction) (*types.Transaction, error) {
+					// Reading the key without the lock is only safe,
+					// and only stays key, because the guard holds it.
+					if es.PrivateKey != key {
+						swapped.Add(1)
+					}
+					return tx, nil
+				}}
+				es.guardSigner(auth, key)
+				if _, err := auth.Signer(common.Address{}, types.NewTx(&types.LegacyTx{})); err != nil && !errors.Is(err, ErrSignerRotated) {
+					t.Errorf("Signer() = %v", err)
+				}
+			}
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := es.RotateSigner(context.Background(), hex.EncodeToString(crypto.FromECDSA(key))); err != nil {
+			t.Fatalf("RotateSigner() = %v", err)
+		}
+	}
+	wg.Wait()
+
+	if n := swapped.Load(); n != 0 {
+		t.Fatalf("key rotated during %d signatures", n)
+	}
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"golang.org/x/sync/singleflight"
+)
+
+// dedupBackend is the contract backend of the service's bindings. It shares a
+// single in-flight eth_call between concurrent identical view reads: calls to
+// the same contract with the same calldata (method and arguments), sender,
+// value and block wait for the first one and all receive its result. Nothing
+// is cached once that call returns, errors included.
+type dedupBackend struct {
+	*ethclient.Client
+	group *singleflight.Group
+}
+
+// sharedCallTimeout bounds a view read shared by dedupBackend, which no
+// caller's context can cancel.
+const sharedCallTimeout = 30 * time.Second
+
+// backend returns the contract backend bindings should use. Besides sharing
+// view reads, it pads gas estimates, see gasMarginBackend.
+func (es *EthereumService) backend() bind.ContractBackend {
+	return gasMarginBackend{&dedupBackend{Client: es.Client, group: &es.reads}}
+}
+
+func (b *dedupBackend) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	var key bytes.Buffer
+	if call.To != nil {
+		key.WriteString(call.To.Hex())
+	}
+	key.WriteString("|" + call.From.Hex() + "|")
+	if call.Value != nil {
+		key.WriteString(call.Value.String())
+	}
+	key.WriteString("|")
+	if blockNumber != nil {
+		key.WriteString(blockNumber.String())
+	}
+	key.WriteString("|" + hex.EncodeToString(call.Data))
+
+	// The shared call must not fail because the caller that happened to
+	// start it gave up, so it runs detached from that caller's context, with
+	// a timeout of its own. Each caller still stops waiting once its own
+	// context is done.
+	results := b.group.DoChan(key.String(), func() (interface{}, error) {
+		shared, cancel := context.WithTimeout(context.WithoutCancel(ctx), sharedCallTimeout)
+		defer cancel()
+		return b.Client.CallContract(shared, call, blockNumber)
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-results:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return bytes.Clone(res.Val.([]byte)), nil
+	}
+}
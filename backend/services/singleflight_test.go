@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"nft-marketplace/blockchain/chaintest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+)
+
+func TestDedupBackendCallerCancellation(t *testing.T) {
+	tests := []struct {
+		name string
+		// cancelLeader cancels the caller that started the shared call
+		// while it is in flight.
+		cancelLeader bool
+	}{
+		{name: "both callers wait"},
+		{name: "leader gives up", cancelLeader: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := chaintest.NewNode(t)
+			received, release := make(chan struct{}, 1), make(chan struct{})
+			node.HandleCall("commissionPercent", func([]any) ([]any, error) {
+				received <- struct{}{}
+				<-release
+				return []any{big.NewInt(250)}, nil
+			})
+			es := &EthereumService{Client: node.Client, ContractAddress: chaintest.Contract}
+			contract, err := es.marketplaceContract()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			type result struct {
+				percent *big.Int
+				err     error
+			}
+			read := func(ctx context.Context) <-chan result {
+				done := make(chan result, 1)
+				go func() {
+					percent, err := contract.CommissionPercent(&bind.CallOpts{Context: ctx})
+					done <- result{percent, err}
+				}()
+				return done
+			}
+
+			leaderCtx, cancelLeader := context.WithCancel(context.Background())
+			defer cancelLeader()
+			leader := read(leaderCtx)
+			<-received
+			follower := read(context.Background())
+
+			if tt.cancelLeader {
+				cancelLeader()
+				if res := <-leader; !errors.Is(res.err, context.Canceled) {
+					t.Fatalf("cancelled leader got %v, %v, want context.Canceled", res.percent, res.err)
+				}
+			}
+			// Let the follower join the call in flight before it completes.
+			time.Sleep(20 * time.Millisecond)
+			close(release)
+
+			if res := <-follower; res.err != nil || res.percent.Int64() != 250 {
+				t.Fatalf("follower got %v, %v, want 250", res.percent, res.err)
+			}
+			if !tt.cancelLeader {
+				if res := <-leader; res.err != nil || res.percent.Int64() != 250 {
+					t.Fatalf("leader got %v, %v, want 250", res.percent, res.err)
+				}
+			}
+			if got := node.Count("commissionPercent"); got != 1 {
+				t.Fatalf("commissionPercent called %d times, want 1", got)
+			}
+		})
+	}
+}
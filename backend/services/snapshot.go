@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	marketplace "nft-marketplace/blockchain"
+	"nft-marketplace/utils"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrTokenNotFound is returned when the NFT contract does not know a token.
+var ErrTokenNotFound = errors.New("token does not exist")
+
+// TokenSnapshot is the ownership and listing state of a token, all read at
+// BlockNumber.
+//
+// Pinned is false when the node could not serve reads at that block and they
+// were made against the latest block instead, in which case the fields may
+// come from consecutive blocks. ListingID, Price and Seller are only set while
+// the token is listed; Seller is the on-chain seller, the service's account for
+// listings created by MintNFT.
+type TokenSnapshot struct {
+	TokenID     string          `json:"token_id"`
+	BlockNumber uint64          `json:"block_number"`
+	Pinned      bool            `json:"pinned"`
+	Owner       common.Address  `json:"owner"`
+	Listed      bool            `json:"listed"`
+	ListingID   string          `json:"listing_id,omitempty"`
+	Price       *utils.Wei      `json:"price,omitempty"`
+	Seller      *common.Address `json:"seller,omitempty"`
+}
+
+// GetTokenSnapshot reads the owner and listing of tokenID at a single block,
+// the latest one when it is called, so that the fields are consistent with
+// each other. It returns ErrTokenNotFound for tokens that were never minted or
+// were burnt.
+func (es *EthereumService) GetTokenSnapshot(ctx context.Context, tokenID string) (TokenSnapshot, error) {
+	token, err := parseBigInt("token ID", tokenID)
+	if err != nil {
+		return TokenSnapshot{}, err
+	}
+
+	contract, err := es.marketplaceContract()
+	if err != nil {
+		return TokenSnapshot{}, err
+	}
+
+	header, err := es.Client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return TokenSnapshot{}, fmt.Errorf("failed to get latest header: %w", err)
+	}
+
+	snapshot, err := es.readTokenSnapshot(&bind.CallOpts{Context: ctx, BlockNumber: header.Number}, contract, token)
+	if err != nil && !errors.Is(err, ErrTokenNotFound) {
+		// Nodes behind load balancers or with pruned state may not serve the
+		// block just returned as latest; read the latest state instead.
+		snapshot, err = es.readTokenSnapshot(&bind.CallOpts{Context: ctx}, contract, token)
+		snapshot.Pinned = false
+	}
+	if err != nil {
+		return TokenSnapshot{}, err
+	}
+
+	snapshot.BlockNumber = header.Number.Uint64()
+	return snapshot, nil
+}
+
+// CheckTokenExists returns ErrTokenNotFound unless the NFT contract knows
+// tokenID, that is the token was minted and not burnt.
+func (es *EthereumService) CheckTokenExists(ctx context.Context, tokenID string) error {
+	token, err := parseBigInt("token ID", tokenID)
+	if err != nil {
+		return err
+	}
+
+	nftAddress, err := es.GetNFTContractAddress(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := es.ownerOf(&bind.CallOpts{Context: ctx}, nftAddress, token); err != nil {
+		if isRevert(err) {
+			return fmt.Errorf("%w: %s", ErrTokenNotFound, token)
+		}
+		return err
+	}
+	return nil
+}
+
+// readTokenSnapshot reads the snapshot of token with opts, setting Pinned when
+// opts names a block.
+func (es *EthereumService) readTokenSnapshot(opts *bind.CallOpts, contract *marketplace.Marketplace, token *big.Int) (TokenSnapshot, error) {
+	snapshot := TokenSnapshot{TokenID: token.String(), Pinned: opts.BlockNumber != nil}
+
+	owner, err := es.tokenOwner(opts, contract, token)
+	if err != nil {
+		if isRevert(err) {
+			return TokenSnapshot{}, fmt.Errorf("%w: %s", ErrTokenNotFound, token)
+		}
+		return TokenSnapshot{}, err
+	}
+	snapshot.Owner = owner
+
+	snapshot.Listed, err = contract.IsTokenListed(opts, token)
+	if err != nil {
+		return TokenSnapshot{}, fmt.Errorf("failed to check listing status of token %s: %w", token, err)
+	}
+	if !snapshot.Listed {
+		return snapshot, nil
+	}
+
+	id, err := contract.GetListingId(opts, token)
+	if err != nil {
+		return TokenSnapshot{}, fmt.Errorf("failed to get listing ID of token %s: %w", token, err)
+	}
+	listing, err := contract.Listings(opts, id)
+	if err != nil {
+		return TokenSnapshot{}, fmt.Errorf("failed to get listing %s: %w", id, err)
+	}
+
+	snapshot.ListingID = id.String()
+	snapshot.Price = utils.NewWei(listing.Price)
+	snapshot.Seller = &listing.Seller
+	return snapshot, nil
+}
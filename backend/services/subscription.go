@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"nft-marketplace/utils"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	defaultMinBackoff = time.Second
+	defaultMaxBackoff = 30 * time.Second
+)
+
+// LogClient is the subset of the Ethereum client used by LogSubscriber.
+type LogClient interface {
+	ethereum.LogFilterer
+	ethereum.BlockNumberReader
+	Close()
+}
+
+// LogSubscriber keeps a log subscription alive across dropped connections.
+//
+// ethclient subscriptions end permanently when the websocket drops. The
+// subscriber redials with exponential backoff, backfills every log emitted
+// since the last one it delivered and then resubscribes, so consumers read a
+// single channel without gaps or duplicates across reconnects.
+type LogSubscriber struct {
+	Dial       func(ctx context.Context) (LogClient, error)
+	Query      ethereum.FilterQuery
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	cursor   logCursor
+	backfrom *big.Int
+}
+
+// logCursor remembers the position of the last delivered log.
+type logCursor struct {
+	set   bool
+	block uint64
+	index uint
+}
+
+// after reports whether l comes after the cursor.
+func (c logCursor) after(l types.Log) bool {
+	if !c.set {
+		return true
+	}
+	return l.BlockNumber > c.block || (l.BlockNumber == c.block && l.Index > c.index)
+}
+
+// NewLogSubscriber creates a LogSubscriber that dials rpcURL, which must be a
+// websocket endpoint, and subscribes with the given filter query.
+func NewLogSubscriber(rpcURL string, query ethereum.FilterQuery) *LogSubscriber {
+	return &LogSubscriber{
+		Dial: func(ctx context.Context) (LogClient, error) {
+			client, err := utils.DialEthereum(ctx, rpcURL, utils.DefaultDialTimeout)
+			if err != nil {
+				return nil, err
+			}
+			return client, nil
+		},
+		Query:      query,
+		MinBackoff: defaultMinBackoff,
+		MaxBackoff: defaultMaxBackoff,
+	}
+}
+
+// Subscribe starts the subscription and returns the channel logs are delivered
+// on. The channel is closed once ctx is cancelled.
+func (s *LogSubscriber) Subscribe(ctx context.Context) <-chan types.Log {
+	out := make(chan types.Log)
+	s.backfrom = s.Query.FromBlock
+
+	go func() {
+		defer close(out)
+
+		redial := backoff{min: s.minBackoff(), max: s.maxBackoff()}
+		for {
+			before := s.cursor
+			err := s.run(ctx, out)
+			if ctx.Err() != nil {
+				return
+			}
+			if s.cursor != before {
+				redial.reset()
+			}
+
+			delay := redial.next()
+			log.Printf("Log subscription dropped, reconnecting in %s: %v", delay, err)
+			if sleep(ctx, nil, delay) != nil {
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// run dials, backfills and then streams live logs until the connection or the
+// subscription fails.
+func (s *LogSubscriber) run(ctx context.Context, out chan<- types.Log) error {
+	client, err := s.Dial(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to dial: %w", err)
+	}
+	defer client.Close()
+
+	// Subscribe before backfilling so no log emitted in between is lost; the
+	// cursor drops whatever shows up in both.
+	live := make(chan types.Log)
+	query := s.Query
+	query.FromBlock, query.ToBlock = nil, nil
+	sub, err := client.SubscribeFilterLogs(ctx, query, live)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe: %w", err)
+	}
+	defer sub.Unsubscribe()
+
+	if err := s.backfill(ctx, client, out); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-sub.Err():
+			return fmt.Errorf("subscription failed: %w", err)
+		case l := <-live:
+			if err := s.deliver(ctx, out, l); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// backfill delivers the logs emitted since the last delivered one, or since
+// the query's FromBlock on the first connection.
+func (s *LogSubscriber) backfill(ctx context.Context, client LogClient, out chan<- types.Log) error {
+	var from *big.Int
+	switch {
+	case s.cursor.set:
+		from = new(big.Int).SetUint64(s.cursor.block)
+	case s.backfrom != nil:
+		from = s.backfrom
+	default:
+		return nil
+	}
+
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get block number: %w", err)
+	}
+	if from.Uint64() > head {
+		return nil
+	}
+
+	query := s.Query
+	query.FromBlock, query.ToBlock = from, new(big.Int).SetUint64(head)
+	logs, err := client.FilterLogs(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to backfill logs: %w", err)
+	}
+
+	for _, l := range logs {
+		if err := s.deliver(ctx, out, l); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deliver sends l to the consumer unless it was already delivered. Removed
+// logs are always forwarded so consumers can undo them after a reorg.
+func (s *LogSubscriber) deliver(ctx context.Context, out chan<- types.Log, l types.Log) error {
+	if !l.Removed && !s.cursor.after(l) {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case out <- l:
+	}
+
+	if !l.Removed {
+		s.cursor = logCursor{set: true, block: l.BlockNumber, index: l.Index}
+	}
+	return nil
+}
+
+func (s *LogSubscriber) minBackoff() time.Duration {
+	if s.MinBackoff <= 0 {
+		return defaultMinBackoff
+	}
+	return s.MinBackoff
+}
+
+func (s *LogSubscriber) maxBackoff() time.Duration {
+	if s.MaxBackoff <= 0 {
+		return defaultMaxBackoff
+	}
+	return s.MaxBackoff
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// fakeLogConn is one connection to a node: FilterLogs answers with backfill,
+// the subscription streams live and then fails when drop is set.
+type fakeLogConn struct {
+	backfill []types.Log
+	live     []types.Log
+	drop     bool
+
+	mu   sync.Mutex
+	from *big.Int
+}
+
+func (c *fakeLogConn) FilterLogs(_ context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.from = q.FromBlock
+	return c.backfill, nil
+}
+
+func (c *fakeLogConn) SubscribeFilterLogs(_ context.Context, _ ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	sub := &fakeSubscription{errc: make(chan error, 1), quit: make(chan struct{})}
+	go func() {
+		for _, l := range c.live {
+			select {
+			case ch <- l:
+			case <-sub.quit:
+				return
+			}
+		}
+		if c.drop {
+			sub.errc <- errors.New("websocket: close 1006")
+		}
+	}()
+	return sub, nil
+}
+
+func (c *fakeLogConn) BlockNumber(context.Context) (uint64, error) { return 10, nil }
+func (c *fakeLogConn) Close()                                      {}
+
+type fakeSubscription struct {
+	errc chan error
+	quit chan struct{}
+	once sync.Once
+}
+
+func (s *fakeSubscription) Err() <-chan error { return s.errc }
+func (s *fakeSubscription) Unsubscribe()      { s.once.Do(func() { close(s.quit) }) }
+
+func TestLogSubscriberReconnects(t *testing.T) {
+	at := func(block uint64, index uint) types.Log { return types.Log{BlockNumber: block, Index: index} }
+	removed := at(7, 0)
+	removed.Removed = true
+
+	first := &fakeLogConn{backfill: []types.Log{at(5, 0), at(5, 1)}, live: []types.Log{at(6, 0)}, drop: true}
+	// The second connection backfills from the last delivered block, which
+	// repeats it, and then sees a reorg remove a log.
+	second := &fakeLogConn{backfill: []types.Log{at(6, 0), at(7, 0)}, live: []types.Log{removed, at(8, 0)}}
+
+	var mu sync.Mutex
+	dials := 0
+	s := &LogSubscriber{
+		Dial: func(context.Context) (LogClient, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			dials++
+			switch dials {
+			case 1:
+				return first, nil
+			case 3:
+				return second, nil
+			default:
+				return nil, errors.New("connection refused")
+			}
+		},
+		Query:      ethereum.FilterQuery{FromBlock: big.NewInt(5)},
+		MinBackoff: time.Millisecond,
+		MaxBackoff: time.Millisecond,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	logs := s.Subscribe(ctx)
+
+	want := []types.Log{at(5, 0), at(5, 1), at(6, 0), at(7, 0), removed, at(8, 0)}
+	var got []types.Log
+	for len(got) < len(want) {
+		l, ok := <-logs
+		if !ok {
+			t.Fatalf("channel closed after %d logs, want %d", len(got), len(want))
+		}
+		got = append(got, l)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("delivered %+v, want %+v", got, want)
+	}
+
+	first.mu.Lock()
+	firstFrom := first.from
+	first.mu.Unlock()
+	second.mu.Lock()
+	secondFrom := second.from
+	second.mu.Unlock()
+	if firstFrom.Int64() != 5 || secondFrom.Int64() != 6 {
+		t.Fatalf("backfilled from blocks %s and %s, want 5 and 6", firstFrom, secondFrom)
+	}
+
+	cancel()
+	for range logs {
+		t.Fatal("log delivered after cancellation")
+	}
+}
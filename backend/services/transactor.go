@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"nft-marketplace/config"
+	"nft-marketplace/db"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// gasLimitMarginPercent is added to the gas the node estimates for a
+// transaction to make its gas limit, so that state changing between the
+// estimate and the block it lands in does not run it out of gas.
+const gasLimitMarginPercent = 20
+
+// gasPreset is how a gas strategy prices transactions.
+type gasPreset struct {
+	// tipPercent is the share of the node's suggested tip paid.
+	tipPercent int64
+	// baseFeeMultiplier sets the fee cap to this many base fees plus the tip.
+	baseFeeMultiplier int64
+	// gasPricePercent is the share of the suggested gas price paid by legacy
+	// transactions. Below 100 the transaction waits for a quieter block, and
+	// SendAndConfirm's fee bumps get it mined if it waits too long.
+	gasPricePercent int64
+}
+
+var gasPresets = map[config.GasStrategy]gasPreset{
+	config.GasEconomy:  {tipPercent: 50, baseFeeMultiplier: 1, gasPricePercent: 90},
+	config.GasStandard: {tipPercent: 100, baseFeeMultiplier: 2, gasPricePercent: 100},
+	config.GasFast:     {tipPercent: 200, baseFeeMultiplier: 3, gasPricePercent: 150},
+}
+
+type gasStrategyKey struct{}
+
+// WithGasStrategy returns a copy of ctx making the transactions built with it
+// use strategy instead of the service's GasStrategy.
+func WithGasStrategy(ctx context.Context, strategy config.GasStrategy) context.Context {
+	return context.WithValue(ctx, gasStrategyKey{}, strategy)
+}
+
+// gasPresetFor returns the preset of the strategy in effect for ctx.
+func (es *EthereumService) gasPresetFor(ctx context.Context) (gasPreset, error) {
+	strategy, ok := ctx.Value(gasStrategyKey{}).(config.GasStrategy)
+	if !ok {
+		strategy = es.GasStrategy
+	}
+	if strategy == "" {
+		strategy = config.GasStandard
+	}
+
+	preset, ok := gasPresets[strategy]
+	if !ok {
+		return gasPreset{}, fmt.Errorf("%w %q", config.ErrUnknownGasStrategy, strategy)
+	}
+	return preset, nil
+}
+
+// percentOf returns percent percent of n.
+func percentOf(n *big.Int, percent int64) *big.Int {
+	return new(big.Int).Div(new(big.Int).Mul(n, big.NewInt(percent)), big.NewInt(100))
+}
+
+// gasMarginBackend adds gasLimitMarginPercent to the gas estimates of the
+// backend it wraps, which bindings use as the gas limit of transactions sent
+// without one.
+type gasMarginBackend struct {
+	bind.ContractBackend
+}
+
+func (b gasMarginBackend) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	gas, err := b.ContractBackend.EstimateGas(ctx, call)
+	if err != nil {
+		return 0, err
+	}
+	return gas + gas*gasLimitMarginPercent/100, nil
+}
+
+// newTransactor builds the transaction options used to sign and send
+// marketplace transactions with the service's private key. They stop signing
+// once the key is rotated, see RotateSigner. With DurableNonces, the nonce is
+// reserved from the nonce store when the transaction is signed, replacing any
+// nonce set in the options, and callers must hand the options to
+// releaseUnsentNonce when the transaction fails to be sent.
+//
+// The gas limit is left unset, so that bindings using the service's backend
+// set it to the node's estimate plus gasLimitMarginPercent, see
+// gasMarginBackend.
+//
+// On chains that support EIP-1559, detected by the latest header carrying a
+// base fee, it produces a dynamic fee transaction: with the standard gas
+// strategy the tip is the node's suggestion and the fee cap is twice the base
+// fee plus the tip, which keeps the transaction includable through several
+// blocks of rising base fee. Older chains get a legacy transaction priced at
+// the suggested gas price. The economy strategy pays half the tip, a fee cap
+// of one base fee and 90% of the gas price; the fast one twice the tip, three
+// base fees and one and a half times the gas price.
+func (es *EthereumService) newTransactor(ctx context.Context) (*bind.TransactOpts, error) {
+	key, err := es.readySigner()
+	if err != nil {
+		return nil, err
+	}
+
+	preset, err := es.gasPresetFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	chainID, err := es.Client.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chain ID: %w", err)
+	}
+
+	auth, err := bind.NewKeyedTransactorWithChainID(key, chainID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transactor: %w", err)
+	}
+	if es.DurableNonces {
+		es.reserveNonceOnSign(ctx, auth)
+	}
+	es.guardSigner(auth, key)
+	auth.Context = ctx
+
+	if _, err := es.setGasPrice(ctx, auth, preset); err != nil {
+		return nil, err
+	}
+	return auth, nil
+}
+
+// setGasPrice prices auth with preset, as described on newTransactor, and
+// returns the latest base fee, nil on chains without EIP-1559.
+func (es *EthereumService) setGasPrice(ctx context.Context, auth *bind.TransactOpts, preset gasPreset) (*big.Int, error) {
+	header, err := es.Client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest header: %w", err)
+	}
+
+	if header.BaseFee != nil {
+		tip, err := es.Client.SuggestGasTipCap(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to suggest gas tip cap: %w", err)
+		}
+
+		auth.GasTipCap = percentOf(tip, preset.tipPercent)
+		auth.GasFeeCap = new(big.Int).Add(auth.GasTipCap, new(big.Int).Mul(header.BaseFee, big.NewInt(preset.baseFeeMultiplier)))
+		return header.BaseFee, nil
+	}
+
+	gasPrice, err := es.Client.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to suggest gas price: %w", err)
+	}
+	auth.GasPrice = percentOf(gasPrice, preset.gasPricePercent)
+
+	return nil, nil
+}
+
+// nextNonce returns the nonce of the next transaction from account: reserved
+// from the nonce store with DurableNonces, the pending nonce otherwise.
+func (es *EthereumService) nextNonce(ctx context.Context, account common.Address) (uint64, error) {
+	pending, err := es.Client.PendingNonceAt(ctx, account)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get pending nonce: %w", err)
+	}
+	if !es.DurableNonces {
+		return pending, nil
+	}
+
+	nonce, err := db.ReserveNonce(account.Hex(), pending)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reserve nonce: %w", err)
+	}
+	return nonce, nil
+}
+
+// SyncNonce resets the stored next nonce of the service's account to its
+// pending nonce on chain. It is meant to run on startup, before anything is
+// sent, so that nonces reserved for transactions that never reached the node
+// do not leave a gap.
+func (es *EthereumService) SyncNonce(ctx context.Context) error {
+	key, err := es.readySigner()
+	if err != nil {
+		return err
+	}
+	return es.syncNonce(ctx, crypto.PubkeyToAddress(key.PublicKey))
+}
+
+func (es *EthereumService) syncNonce(ctx context.Context, account common.Address) error {
+	pending, err := es.Client.PendingNonceAt(ctx, account)
+	if err != nil {
+		return fmt.Errorf("failed to get pending nonce: %w", err)
+	}
+	if err := db.SetNonce(account.Hex(), pending); err != nil {
+		return fmt.Errorf("failed to store nonce: %w", err)
+	}
+	return nil
+}
+
+// releaseNonce undoes the reservation of nonce for a transaction from account
+// that never reached the node. Without it the nonce store would run ahead of
+// the chain, and every later transaction would wait behind the gap until the
+// next SyncNonce.
+//
+// The nonce goes back to the store when no later one was reserved since.
+// Otherwise the stored nonce is reset to the pending one, which is where the
+// gap is: the next transaction fills it, and the ones reserved after the
+// released nonce are either pending behind it or fail to send and are
+// released in turn. Failures are logged, the send having failed already. It
+// does nothing without DurableNonces.
+func (es *EthereumService) releaseNonce(ctx context.Context, account common.Address, nonce uint64) {
+	if !es.DurableNonces {
+		return
+	}
+
+	released, err := db.ReleaseNonce(account.Hex(), nonce)
+	if err != nil {
+		log.Printf("Failed to release nonce %d of %s: %v", nonce, account.Hex(), err)
+		return
+	}
+	if released {
+		return
+	}
+
+	log.Printf("Nonce %d of %s is not the last one reserved, resyncing it from the chain", nonce, account.Hex())
+	if err := es.syncNonce(ctx, account); err != nil {
+		log.Printf("Failed to resync nonce of %s: %v", account.Hex(), err)
+	}
+}
+
+// releaseUnsentNonce releases the nonce auth, built by newTransactor, reserved
+// for a transaction that failed to be sent. It does nothing when no nonce was
+// reserved, such as when gas estimation failed before signing.
+func (es *EthereumService) releaseUnsentNonce(ctx context.Context, auth *bind.TransactOpts) {
+	if auth == nil || auth.Nonce == nil || !es.DurableNonces {
+		return
+	}
+	es.releaseNonce(ctx, auth.From, auth.Nonce.Uint64())
+	auth.Nonce = nil
+}
+
+// reserveNonceOnSign makes auth take its nonce from the nonce store when it
+// signs. Reserving only once the transaction is built and about to be sent
+// keeps calls that fail earlier, such as during gas estimation, from using up
+// nonces. The reserved nonce is recorded in auth.Nonce for
+// releaseUnsentNonce.
+func (es *EthereumService) reserveNonceOnSign(ctx context.Context, auth *bind.TransactOpts) {
+	sign := auth.Signer
+	auth.Nonce = nil
+	auth.Signer = func(from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		nonce, err := es.nextNonce(ctx, from)
+		if err != nil {
+			return nil, err
+		}
+		auth.Nonce = new(big.Int).SetUint64(nonce)
+		tx, err = withNonce(tx, nonce)
+		if err != nil {
+			return nil, err
+		}
+		return sign(from, tx)
+	}
+}
+
+// withNonce returns a copy of the unsigned tx with the given nonce.
+func withNonce(tx *types.Transaction, nonce uint64) (*types.Transaction, error) {
+	if tx.Nonce() == nonce {
+		return tx, nil
+	}
+
+	switch tx.Type() {
+	case types.LegacyTxType:
+		return types.NewTx(&types.LegacyTx{
+			Nonce: nonce, GasPrice: tx.GasPrice(), Gas: tx.Gas(), To: tx.To(), Value: tx.Value(), Data: tx.Data(),
+		}), nil
+	case types.AccessListTxType:
+		return types.NewTx(&types.AccessListTx{
+			ChainID: tx.ChainId(), Nonce: nonce, GasPrice: tx.GasPrice(), Gas: tx.Gas(), To: tx.To(), Value: tx.Value(), Data: tx.Data(), AccessList: tx.AccessList(),
+		}), nil
+	case types.DynamicFeeTxType:
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID: tx.ChainId(), Nonce: nonce, GasTipCap: tx.GasTipCap(), GasFeeCap: tx.GasFeeCap(), Gas: tx.Gas(), To: tx.To(), Value: tx.Value(), Data: tx.Data(), AccessList: tx.AccessList(),
+		}), nil
+	default:
+		return nil, fmt.Errorf("cannot renumber transaction of type %d", tx.Type())
+	}
+}
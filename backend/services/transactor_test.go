@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"math/big"
+	"nft-marketplace/blockchain/chaintest"
+	"nft-marketplace/config"
+	"nft-marketplace/db/dbtest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestReleaseUnsentNonce(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	account := crypto.PubkeyToAddress(key.PublicKey)
+
+	tests := []struct {
+		name string
+		// signed is whether the transaction got as far as being signed,
+		// which is when the nonce is reserved.
+		signed      bool
+		wantRelease bool
+	}{
+		{name: "failed after signing", signed: true, wantRelease: true},
+		{name: "failed before signing", signed: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := chaintest.NewNode(t)
+			node.Handle("eth_getTransactionCount", chaintest.Returns(hexutil.Uint64(5)))
+			mock := dbtest.Mock(t)
+			if tt.signed {
+				mock.ExpectQuery(`INSERT INTO signer_nonces`).WithArgs(account.Hex(), 6, sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"next_nonce"}).AddRow(5))
+			}
+			if tt.wantRelease {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "signer_nonces"`).WithArgs(5, sqlmock.AnyArg(), account.Hex(), 6).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			es := &EthereumService{Client: node.Client, PrivateKey: key, DurableNonces: true}
+			auth, err := bind.NewKeyedTransactorWithChainID(key, big.NewInt(chaintest.ChainID))
+			if err != nil {
+				t.Fatal(err)
+			}
+			auth.Nonce = big.NewInt(99)
+			es.reserveNonceOnSign(context.Background(), auth)
+
+			if tt.signed {
+				to := common.HexToAddress("0x01")
+				signed, err := auth.Signer(account, types.NewTx(&types.LegacyTx{GasPrice: big.NewInt(1), Gas: 21000, To: &to}))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if signed.Nonce() != 5 {
+					t.Fatalf("signed nonce = %d, want 5", signed.Nonce())
+				}
+			}
+			es.releaseUnsentNonce(context.Background(), auth)
+			if auth.Nonce != nil {
+				t.Fatalf("nonce %s still recorded after release", auth.Nonce)
+			}
+		})
+	}
+}
+
+func TestNewTransactor(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gwei := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e9)) }
+
+	tests := []struct {
+		name     string
+		strategy config.GasStrategy
+		baseFee  *big.Int
+		// Legacy transactions pay wantGasPrice, dynamic fee ones wantTip and
+		// wantFeeCap.
+		wantGasPrice *big.Int
+		wantTip      *big.Int
+		wantFeeCap   *big.Int
+	}{
+		{name: "legacy economy", strategy: config.GasEconomy, wantGasPrice: gwei(18)},
+		{name: "legacy standard", wantGasPrice: gwei(20)},
+		{name: "legacy fast", strategy: config.GasFast, wantGasPrice: gwei(30)},
+		{name: "dynamic economy", strategy: config.GasEconomy, baseFee: gwei(10), wantTip: gwei(1), wantFeeCap: gwei(11)},
+		{name: "dynamic standard", baseFee: gwei(10), wantTip: gwei(2), wantFeeCap: gwei(22)},
+		{name: "dynamic fast", strategy: config.GasFast, baseFee: gwei(10), wantTip: gwei(4), wantFeeCap: gwei(34)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := chaintest.NewNode(t)
+			node.Handle("eth_getBlockByNumber", chaintest.Returns(&types.Header{Number: big.NewInt(100), Difficulty: big.NewInt(0), BaseFee: tt.baseFee}))
+			node.Handle("eth_gasPrice", chaintest.Returns(hexutil.Big(*gwei(20))))
+			node.Handle("eth_maxPriorityFeePerGas", chaintest.Returns(hexutil.Big(*gwei(2))))
+
+			es := &EthereumService{Client: node.Client, ContractAddress: chaintest.Contract, PrivateKey: key, GasStrategy: tt.strategy}
+			auth, err := es.newTransactor(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if auth.GasLimit != 0 {
+				t.Errorf("gas limit = %d, want it left to the estimate", auth.GasLimit)
+			}
+			check := func(what string, got, want *big.Int) {
+				if (got == nil) != (want == nil) || (got != nil && got.Cmp(want) != 0) {
+					t.Errorf("%s = %v, want %v", what, got, want)
+				}
+			}
+			check("gas price", auth.GasPrice, tt.wantGasPrice)
+			check("tip", auth.GasTipCap, tt.wantTip)
+			check("fee cap", auth.GasFeeCap, tt.wantFeeCap)
+		})
+	}
+}
+
+func TestGasMarginBackend(t *testing.T) {
+	tests := []struct {
+		name     string
+		estimate uint64
+		want     uint64
+	}{
+		{name: "transfer", estimate: 21_000, want: 25_200},
+		{name: "listing", estimate: 150_000, want: 180_000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := chaintest.NewNode(t)
+			node.Handle("eth_estimateGas", chaintest.Returns(hexutil.Uint64(tt.estimate)))
+
+			es := &EthereumService{Client: node.Client, ContractAddress: chaintest.Contract}
+			got, err := es.backend().EstimateGas(context.Background(), ethereum.CallMsg{To: &chaintest.Contract})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("gas limit = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
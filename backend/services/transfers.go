@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// transferTopic is the topic of the ERC-721 Transfer event.
+var transferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// Transfer is an ERC-721 Transfer event of the marketplace's NFT contract.
+// Removed is set when a reorg dropped a transfer delivered before.
+type Transfer struct {
+	From        common.Address
+	To          common.Address
+	TokenID     *big.Int
+	BlockNumber uint64
+	TxHash      common.Hash
+	Removed     bool
+}
+
+// WatchTransfersTo delivers every transfer of a token of the marketplace's NFT
+// contract to addr, from the moment it is called until ctx is cancelled or the
+// service closed, when the channel is closed.
+//
+// The subscription goes through WebsocketRPC and survives dropped connections
+// like the event indexer's: transfers emitted while reconnecting are
+// backfilled, so none is missed or delivered twice.
+func (es *EthereumService) WatchTransfersTo(ctx context.Context, addr common.Address) (<-chan Transfer, error) {
+	if es.WebsocketRPC == "" {
+		return nil, fmt.Errorf("websocket RPC endpoint not configured")
+	}
+
+	contract, err := es.marketplaceContract()
+	if err != nil {
+		return nil, err
+	}
+	nftAddress, err := contract.NftContract(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get NFT contract address: %w", err)
+	}
+
+	head, err := es.Client.BlockNumber(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block number: %w", err)
+	}
+
+	subscriber := NewLogSubscriber(es.WebsocketRPC, ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(head + 1),
+		Addresses: []common.Address{nftAddress},
+		Topics:    [][]common.Hash{{transferTopic}, nil, {common.BytesToHash(addr.Bytes())}},
+	})
+
+	ctx, cancel := es.untilClosed(ctx)
+	logs := subscriber.Subscribe(ctx)
+	out := make(chan Transfer)
+	go func() {
+		defer cancel()
+		defer close(out)
+
+		for l := range logs {
+			transfer, ok := decodeTransfer(l)
+			if !ok {
+				log.Printf("Skipping non ERC-721 Transfer log %d in tx %s", l.Index, l.TxHash.Hex())
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case out <- transfer:
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// decodeTransfer decodes an ERC-721 Transfer log, whose three parameters are
+// all indexed. ERC-20 style transfers, with an unindexed value, are rejected.
+func decodeTransfer(l types.Log) (Transfer, bool) {
+	if len(l.Topics) != 4 || l.Topics[0] != transferTopic {
+		return Transfer{}, false
+	}
+
+	return Transfer{
+		From:        common.BytesToAddress(l.Topics[1].Bytes()),
+		To:          common.BytesToAddress(l.Topics[2].Bytes()),
+		TokenID:     new(big.Int).SetBytes(l.Topics[3].Bytes()),
+		BlockNumber: l.BlockNumber,
+		TxHash:      l.TxHash,
+		Removed:     l.Removed,
+	}, true
+}
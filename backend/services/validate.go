@@ -0,0 +1,82 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DeadAddress is the conventional burn address. Tokens sent to it are lost.
+var DeadAddress = common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+
+// Errors returned by recipient validation.
+var (
+	ErrInvalidRecipient = errors.New("invalid recipient address")
+	ErrZeroRecipient    = errors.New("recipient is the zero address")
+	ErrBlockedRecipient = errors.New("recipient address is blocklisted")
+)
+
+// Errors returned by listing price validation.
+var (
+	ErrPriceTooHigh = errors.New("listing price above the maximum")
+	ErrPriceTooLow  = errors.New("listing price below the minimum")
+)
+
+// ErrInvalidNumber is returned by parseBigInt for values that are not decimal
+// integers.
+var ErrInvalidNumber = errors.New("not a decimal integer")
+
+// maxQuotedValue is how much of a malformed value parseBigInt quotes in its
+// error.
+const maxQuotedValue = 32
+
+// validateRecipient parses recipient and rejects the zero address and every
+// address in RecipientBlocklist.
+func (es *EthereumService) validateRecipient(recipient string) (common.Address, error) {
+	if !common.IsHexAddress(recipient) {
+		return common.Address{}, fmt.Errorf("%w: %s", ErrInvalidRecipient, recipient)
+	}
+
+	address := common.HexToAddress(recipient)
+	if address == (common.Address{}) {
+		return common.Address{}, ErrZeroRecipient
+	}
+
+	for _, blocked := range es.RecipientBlocklist {
+		if address == blocked {
+			return common.Address{}, fmt.Errorf("%w: %s", ErrBlockedRecipient, address.Hex())
+		}
+	}
+
+	return address, nil
+}
+
+// validateListingPrice rejects prices outside MinListingPrice and
+// MaxListingPrice. A nil or zero bound leaves that side unbounded.
+func (es *EthereumService) validateListingPrice(price *big.Int) error {
+	if upper := es.MaxListingPrice; upper != nil && upper.Sign() > 0 && price.Cmp(upper) > 0 {
+		return fmt.Errorf("%w: %s > %s wei", ErrPriceTooHigh, price, upper)
+	}
+	if lower := es.MinListingPrice; lower != nil && lower.Sign() > 0 && price.Cmp(lower) < 0 {
+		return fmt.Errorf("%w: %s < %s wei", ErrPriceTooLow, price, lower)
+	}
+	return nil
+}
+
+// parseBigInt parses value as a base 10 integer. The error names field and
+// quotes value, truncated to maxQuotedValue characters, so that callers parsing
+// several numbers can tell which one was malformed.
+func parseBigInt(field, value string) (*big.Int, error) {
+	n, ok := new(big.Int).SetString(value, 10)
+	if !ok {
+		quoted := value
+		if len(quoted) > maxQuotedValue {
+			quoted = quoted[:maxQuotedValue] + "..."
+		}
+		return nil, fmt.Errorf("invalid %s %q: %w", field, quoted, ErrInvalidNumber)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	marketplace "nft-marketplace/blockchain"
+	"nft-marketplace/utils"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// VerifyTokenOwner checks that expected owns tokenID in the marketplace's NFT
+// contract at the given block, nil meaning the latest one. It returns
+// ErrOwnershipMismatch when another account owns it.
+func (es *EthereumService) VerifyTokenOwner(ctx context.Context, tokenID *big.Int, expected common.Address, block *big.Int) error {
+	contract, err := es.marketplaceContract()
+	if err != nil {
+		return err
+	}
+
+	owner, err := es.tokenOwner(&bind.CallOpts{Context: ctx, BlockNumber: block}, contract, tokenID)
+	if err != nil {
+		return err
+	}
+
+	if !utils.SameAddress(owner, expected) {
+		return fmt.Errorf("%w: token %s is owned by %s, not %s", ErrOwnershipMismatch, tokenID, owner.Hex(), expected.Hex())
+	}
+	return nil
+}
+
+// tokenOwner reads the owner of tokenID in the marketplace's NFT contract with
+// the given call options.
+func (es *EthereumService) tokenOwner(opts *bind.CallOpts, contract *marketplace.Marketplace, tokenID *big.Int) (common.Address, error) {
+	nftAddress, err := contract.NftContract(opts)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to get NFT contract address: %w", err)
+	}
+	return es.ownerOf(opts, nftAddress, tokenID)
+}
+
+// ownerOf reads the owner of tokenID in the ERC-721 contract at nftAddress
+// with the given call options.
+func (es *EthereumService) ownerOf(opts *bind.CallOpts, nftAddress common.Address, tokenID *big.Int) (common.Address, error) {
+	parsedABI, err := abi.JSON(strings.NewReader(ERC721ABI))
+	if err != nil {
+		return common.Address{}, fmt.Errorf("%w: %w", ErrABIParse, err)
+	}
+	backend := es.backend()
+	nft := bind.NewBoundContract(nftAddress, parsedABI, backend, backend, backend)
+
+	var out []interface{}
+	if err := nft.Call(opts, &out, "ownerOf", tokenID); err != nil {
+		return common.Address{}, fmt.Errorf("failed to get owner of token %s: %w", tokenID, err)
+	}
+	if len(out) != 1 {
+		return common.Address{}, fmt.Errorf("unexpected ownerOf result: %v", out)
+	}
+	owner, ok := out[0].(common.Address)
+	if !ok {
+		return common.Address{}, fmt.Errorf("unexpected ownerOf result: %v", out)
+	}
+
+	return owner, nil
+}
+
+// verifyPurchaseInBackground waits for the purchase tx of tokenID to be
+// mined and checks that buyer received the token, without holding up the
+// caller. Failures are logged; a purchase still pending after the confirmation
+// timeout, or when the service is closed, is left unverified.
+func (es *EthereumService) verifyPurchaseInBackground(tx *types.Transaction, tokenID string, token *big.Int, buyer common.Address) {
+	ctx, cancel := es.untilClosed(context.Background())
+	go func() {
+		defer cancel()
+		timeout := es.confirmTimeout()
+		ctx, cancelWait := context.WithTimeout(ctx, timeout)
+		defer cancelWait()
+
+		receipt, err := bind.WaitMined(ctx, es.Client, tx)
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Printf("Purchase transaction %s still pending after %s, ownership not verified", tx.Hash().Hex(), timeout)
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to wait for purchase transaction %s: %v", tx.Hash().Hex(), err)
+			return
+		}
+		if err := es.checkPurchase(ctx, receipt, tokenID, token, buyer); err != nil {
+			log.Printf("Purchase of token %s not verified: %v", tokenID, err)
+		}
+	}()
+}
+
+// checkPurchase checks that the mined purchase with receipt succeeded and left
+// token, whose DB row is tokenID, owned by buyer. A mismatch triggers a
+// reconciliation of the row.
+func (es *EthereumService) checkPurchase(ctx context.Context, receipt *types.Receipt, tokenID string, token *big.Int, buyer common.Address) error {
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return fmt.Errorf("purchase transaction %s reverted", receipt.TxHash.Hex())
+	}
+
+	err := es.VerifyTokenOwner(ctx, token, buyer, receipt.BlockNumber)
+	if errors.Is(err, ErrOwnershipMismatch) {
+		if reconcileErr := es.ReconcileToken(ctx, tokenID); reconcileErr != nil {
+			log.Printf("Failed to reconcile token %s after purchase: %v", tokenID, reconcileErr)
+		}
+	}
+	return err
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"nft-marketplace/blockchain/chaintest"
+	"nft-marketplace/db/dbtest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestCheckPurchase(t *testing.T) {
+	buyer := common.HexToAddress("0x00000000000000000000000000000000000b0b01")
+	serviceAccount := common.HexToAddress("0x0000000000000000000000000000000000005e01")
+
+	erc721, err := abi.JSON(strings.NewReader(ERC721ABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		status    uint64
+		owner     common.Address
+		wantErr   error
+		fails     bool
+		reconcile bool
+	}{
+		{name: "buyer received the token", status: types.ReceiptStatusSuccessful, owner: buyer},
+		// The service account pays for the purchase, but it is the buyer who
+		// must end up with the token.
+		{name: "token went to the service account", status: types.ReceiptStatusSuccessful, owner: serviceAccount, wantErr: ErrOwnershipMismatch, fails: true, reconcile: true},
+		{name: "reverted", status: types.ReceiptStatusFailed, fails: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := chaintest.NewNode(t)
+			node.AddABI(&erc721)
+			node.HandleCall("nftContract", chaintest.Outputs(common.HexToAddress("0x0000000000000000000000000000000000000721")))
+			node.HandleCall("ownerOf", chaintest.Outputs(tt.owner))
+
+			mock := dbtest.Mock(t)
+			if tt.reconcile {
+				mock.ExpectQuery(`SELECT \* FROM "nfts"`).WithArgs("7", 1).WillReturnError(errors.New("connection refused"))
+			}
+
+			es := &EthereumService{Client: node.Client, ContractAddress: chaintest.Contract}
+			receipt := &types.Receipt{Status: tt.status, BlockNumber: big.NewInt(100)}
+			err := es.checkPurchase(context.Background(), receipt, "7", big.NewInt(7), buyer)
+			if tt.fails != (err != nil) || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Fatalf("err = %v, want %v (fails: %t)", err, tt.wantErr, tt.fails)
+			}
+			if tt.status == types.ReceiptStatusFailed && node.Count("ownerOf") != 0 {
+				t.Fatal("ownership of a reverted purchase was checked")
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nft-marketplace/utils"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+)
+
+const (
+	listingPollMinInterval = 500 * time.Millisecond
+	listingPollMaxInterval = 5 * time.Second
+)
+
+// ErrListingWaitTimeout is returned by WaitForListingActive when the listing
+// did not become active in time.
+var ErrListingWaitTimeout = errors.New("timed out waiting for the listing to become active")
+
+// WaitForListingActive waits for tokenID to be listed, so that a client that
+// just minted it can display the listing, and returns the active listing.
+//
+// The isTokenListed view is polled, every 500ms at first and backing off up to
+// every 5s, until it returns true; the listing is then read with listings(id).
+// It fails with ErrListingWaitTimeout once timeout has elapsed, and with the
+// context's error when ctx is done. Read errors are returned as they occur,
+// except reverts, which nodes that have not caught up yet may answer with.
+func (es *EthereumService) WaitForListingActive(ctx context.Context, tokenID string, timeout time.Duration) (NFTListing, error) {
+	token, err := parseBigInt("token ID", tokenID)
+	if err != nil {
+		return NFTListing{}, err
+	}
+
+	contract, err := es.marketplaceContract()
+	if err != nil {
+		return NFTListing{}, err
+	}
+
+	deadline := es.clock().Now().Add(timeout)
+	interval := listingPollMinInterval
+	for {
+		opts := &bind.CallOpts{Context: ctx}
+		listed, err := contract.IsTokenListed(opts, token)
+		if err != nil && !isRevert(err) {
+			return NFTListing{}, fmt.Errorf("failed to check listing status of token %s: %w", token, err)
+		}
+		if listed {
+			id, err := contract.GetListingId(opts, token)
+			if err != nil {
+				return NFTListing{}, fmt.Errorf("failed to get listing ID of token %s: %w", token, err)
+			}
+			listing, err := contract.Listings(opts, id)
+			if err != nil {
+				return NFTListing{}, fmt.Errorf("failed to get listing %s: %w", id, err)
+			}
+			if listing.IsActive {
+				return NFTListing{
+					ListingID: id,
+					Seller:    listing.Seller,
+					TokenID:   listing.TokenId,
+					Price:     utils.NewWei(listing.Price),
+					IsActive:  listing.IsActive,
+				}, nil
+			}
+		}
+
+		remaining := deadline.Sub(es.clock().Now())
+		if remaining <= 0 {
+			return NFTListing{}, fmt.Errorf("%w: token %s after %s", ErrListingWaitTimeout, token, timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return NFTListing{}, ctx.Err()
+		case <-es.clock().After(min(interval, remaining)):
+		}
+		interval = min(interval*2, listingPollMaxInterval)
+	}
+}
@@ -0,0 +1,124 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+const defaultShutdownTimeout = 30 * time.Second
+
+// ErrShutdownTimeout is returned by Run when components are still running
+// once the shutdown timeout is over.
+var ErrShutdownTimeout = errors.New("components did not stop before the shutdown timeout")
+
+// Component is a long-running part of the application. Run must return once
+// its context is cancelled. Returning an error stops every other component;
+// returning nil before that only ends the component itself.
+type Component struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Supervisor starts components together and stops them together.
+type Supervisor struct {
+	// ShutdownTimeout bounds how long Run waits for the components to stop
+	// once shutdown started. Zero uses the default of 30 seconds.
+	ShutdownTimeout time.Duration
+
+	components []Component
+}
+
+// New returns a supervisor waiting up to shutdownTimeout for its components to
+// stop.
+func New(shutdownTimeout time.Duration) *Supervisor {
+	return &Supervisor{ShutdownTimeout: shutdownTimeout}
+}
+
+// Add registers a component. Components must be added before Run.
+func (s *Supervisor) Add(name string, run func(ctx context.Context) error) {
+	s.components = append(s.components, Component{Name: name, Run: run})
+}
+
+// Run starts every component and blocks until they all stopped.
+//
+// Shutdown starts when ctx is cancelled, typically on a signal, or when a
+// component fails: the context of every component is then cancelled and Run
+// waits up to ShutdownTimeout for them to return. It returns the first
+// component error, if any, and ErrShutdownTimeout when components outlived the
+// timeout. Errors caused by the cancellation itself are not reported.
+func (s *Supervisor) Run(ctx context.Context) error {
+	group, groupCtx := errgroup.WithContext(ctx)
+
+	for _, component := range s.components {
+		group.Go(func() error {
+			err := component.Run(groupCtx)
+			if err != nil && groupCtx.Err() != nil && errors.Is(err, context.Canceled) {
+				err = nil
+			}
+			if err != nil {
+				log.Printf("Component %s failed: %v", component.Name, err)
+				return fmt.Errorf("%s: %w", component.Name, err)
+			}
+			log.Printf("Component %s stopped", component.Name)
+			return nil
+		})
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- group.Wait() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-groupCtx.Done():
+	}
+
+	timeout := s.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	log.Printf("Shutting down, waiting up to %s for components to stop", timeout)
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		// A failed component cancels groupCtx too; report its error
+		// alongside the timeout.
+		if cause := context.Cause(groupCtx); ctx.Err() == nil && cause != nil {
+			return errors.Join(cause, ErrShutdownTimeout)
+		}
+		return ErrShutdownTimeout
+	}
+}
+
+// HTTPServer returns a component serving srv until its context is cancelled,
+// after which in-flight requests are given timeout to complete.
+func HTTPServer(srv *http.Server, timeout time.Duration) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		errs := make(chan error, 1)
+		go func() { errs <- srv.ListenAndServe() }()
+
+		select {
+		case err := <-errs:
+			return err
+		case <-ctx.Done():
+		}
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			return fmt.Errorf("failed to shut down HTTP server: %w", err)
+		}
+		if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+}
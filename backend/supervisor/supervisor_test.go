@@ -0,0 +1,123 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// untilCancelled is a component running until its context is cancelled.
+func untilCancelled(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestRun(t *testing.T) {
+	failure := errors.New("connection lost")
+	stuck := make(chan struct{})
+	t.Cleanup(func() { close(stuck) })
+
+	tests := []struct {
+		name string
+		// cancel is whether the parent context is cancelled, as on a
+		// signal.
+		cancel     bool
+		components map[string]func(ctx context.Context) error
+		wantErr    []error
+	}{
+		{
+			name:       "stopped by a signal",
+			cancel:     true,
+			components: map[string]func(context.Context) error{"api": untilCancelled, "indexer": untilCancelled},
+		},
+		{
+			name: "component failure stops the others",
+			components: map[string]func(context.Context) error{
+				"api":     untilCancelled,
+				"indexer": func(context.Context) error { return failure },
+			},
+			wantErr: []error{failure},
+		},
+		{
+			name: "component ending on its own",
+			components: map[string]func(context.Context) error{
+				"migrations": func(context.Context) error { return nil },
+			},
+		},
+		{
+			name:   "component outliving the timeout",
+			cancel: true,
+			components: map[string]func(context.Context) error{
+				"api":     untilCancelled,
+				"indexer": func(context.Context) error { <-stuck; return nil },
+			},
+			wantErr: []error{ErrShutdownTimeout},
+		},
+		{
+			name: "failure and timeout",
+			components: map[string]func(context.Context) error{
+				"api":     func(context.Context) error { return failure },
+				"indexer": func(context.Context) error { <-stuck; return nil },
+			},
+			wantErr: []error{failure, ErrShutdownTimeout},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			s := New(50 * time.Millisecond)
+			for name, run := range tt.components {
+				s.Add(name, run)
+			}
+			if tt.cancel {
+				time.AfterFunc(10*time.Millisecond, cancel)
+			}
+
+			err := s.Run(ctx)
+			if len(tt.wantErr) == 0 && err != nil {
+				t.Fatalf("Run() = %v, want nil", err)
+			}
+			for _, want := range tt.wantErr {
+				if !errors.Is(err, want) {
+					t.Fatalf("Run() = %v, want %v", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	srv := &http.Server{Addr: addr, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- HTTPServer(srv, time.Second)(ctx) }()
+
+	// The server answers until its context is cancelled.
+	var resp *http.Response
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if resp, err = http.Get("http://" + addr); err == nil {
+			resp.Body.Close()
+			break
+		}
+	}
+	if err != nil {
+		t.Fatalf("server not serving: %v", err)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("shutdown = %v, want nil", err)
+	}
+}
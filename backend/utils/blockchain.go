@@ -2,21 +2,141 @@ package utils
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
-func ConnectEthereum(repURL string) (*ethclient.Client, error) {
-	client, err := ethclient.Dial(repURL)
+// DefaultDialTimeout bounds DialEthereum when no timeout is given.
+const DefaultDialTimeout = 10 * time.Second
+
+// ErrInvalidRPCURL is returned for RPC URLs that do not parse or use a scheme
+// other than http, https, ws and wss.
+var ErrInvalidRPCURL = errors.New("invalid RPC URL")
+
+// ValidateRPCURL checks that rpcURL is an http, https, ws or wss URL with a
+// host. Like DialEthereum it leaves the URL out of its errors.
+func ValidateRPCURL(rpcURL string) error {
+	if rpcURL == "" {
+		return fmt.Errorf("%w: URL is empty", ErrInvalidRPCURL)
+	}
+
+	u, err := url.Parse(rpcURL)
 	if err != nil {
-		log.Fatal("Failed to connect to Ethereum client:", err)
+		return fmt.Errorf("%w: URL does not parse", ErrInvalidRPCURL)
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "ws", "wss":
+	case "":
+		return fmt.Errorf("%w: scheme is missing, expected http://, https://, ws:// or wss://", ErrInvalidRPCURL)
+	default:
+		return fmt.Errorf("%w: unsupported scheme %q, expected http, https, ws or wss", ErrInvalidRPCURL, u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%w: host is missing", ErrInvalidRPCURL)
+	}
+
+	return nil
+}
+
+// IsWebsocketURL reports whether rpcURL is a ws or wss URL, which features
+// relying on subscriptions need.
+func IsWebsocketURL(rpcURL string) bool {
+	u, err := url.Parse(rpcURL)
+	if err != nil {
+		return false
+	}
+	scheme := strings.ToLower(u.Scheme)
+	return scheme == "ws" || scheme == "wss"
+}
+
+// DialEthereum connects to the node at rpcURL, giving up after timeout
+// (DefaultDialTimeout when zero). URLs rejected by ValidateRPCURL are refused
+// without dialing.
+//
+// HTTP endpoints get a client with keep-alive connection pooling and bounded
+// connect, TLS handshake and response header times. As HTTP connections are
+// opened lazily, the endpoint is probed with eth_chainId within the timeout so
+// that an unreachable one fails here rather than on first use. The URL is left
+// out of errors since it often embeds a provider API key.
+func DialEthereum(ctx context.Context, rpcURL string, timeout time.Duration) (*ethclient.Client, error) {
+	if err := ValidateRPCURL(rpcURL); err != nil {
 		return nil, err
 	}
 
+	if timeout <= 0 {
+		timeout = DefaultDialTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	isHTTP := !IsWebsocketURL(rpcURL)
+
+	var opts []rpc.ClientOption
+	if isHTTP {
+		opts = append(opts, rpc.WithHTTPClient(newRPCHTTPClient(timeout)))
+	}
+
+	rpcClient, err := rpc.DialOptions(ctx, rpcURL, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial RPC endpoint: %w", err)
+	}
+	client := ethclient.NewClient(rpcClient)
+
+	if isHTTP {
+		if _, err := client.ChainID(ctx); err != nil {
+			client.Close()
+			var urlErr *url.Error
+			if errors.As(err, &urlErr) {
+				err = urlErr.Err
+			}
+			return nil, fmt.Errorf("RPC endpoint unreachable: %w", err)
+		}
+	}
+
+	return client, nil
+}
+
+// newRPCHTTPClient returns the HTTP client used for JSON-RPC over HTTP, guarded
+// by the RPC circuit breaker (see SetRPCBreaker). Requests themselves are
+// bounded by their context rather than a client timeout, since log queries can
+// legitimately take long.
+func newRPCHTTPClient(dialTimeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: breakerTransport{next: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   dialTimeout,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   10,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   dialTimeout,
+			ResponseHeaderTimeout: time.Minute,
+			ExpectContinueTimeout: time.Second,
+		}},
+	}
+}
+
+func ConnectEthereum(repURL string) (*ethclient.Client, error) {
+	client, err := DialEthereum(context.Background(), repURL, DefaultDialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Ethereum client: %w", err)
+	}
+
 	log.Println("Successfully connected to Ethereum client")
 	return client, nil
 }
@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Sort orders accepted by ParseListingFilter.
+const (
+	SortNewest    = "newest"
+	SortOldest    = "oldest"
+	SortPriceAsc  = "price_asc"
+	SortPriceDesc = "price_desc"
+)
+
+// maxListingPrice is the largest price the marketplace accepts, as listing
+// prices are stored as uint128.
+var maxListingPrice = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+
+// ListingFilter holds the optional query parameters of listing endpoints.
+type ListingFilter struct {
+	// MinPrice and MaxPrice bound the price in wei, inclusively. Nil means
+	// unbounded.
+	MinPrice *big.Int
+	MaxPrice *big.Int
+	// Sort is one of SortNewest, SortOldest, SortPriceAsc and SortPriceDesc.
+	Sort string
+	// Active selects active or inactive listings. Nil selects both.
+	Active *bool
+	// Owner restricts the listings to a seller. Nil means any seller.
+	Owner *common.Address
+}
+
+// ParseListingFilter reads the "min_price", "max_price", "sort", "active" and
+// "owner" query parameters.
+//
+// Prices are decimal wei amounts within the uint128 range and min_price may not
+// exceed max_price. sort defaults to SortNewest. active defaults to true and
+// accepts "all" to select both active and inactive listings. owner must be a
+// hex address. The returned error describes the first malformed parameter and
+// is meant to be sent back with a 400.
+func ParseListingFilter(r *http.Request) (ListingFilter, error) {
+	query := r.URL.Query()
+	active := true
+	filter := ListingFilter{Sort: SortNewest, Active: &active}
+
+	var err error
+	if filter.MinPrice, err = parsePrice("min_price", query.Get("min_price")); err != nil {
+		return ListingFilter{}, err
+	}
+	if filter.MaxPrice, err = parsePrice("max_price", query.Get("max_price")); err != nil {
+		return ListingFilter{}, err
+	}
+	if filter.MinPrice != nil && filter.MaxPrice != nil && filter.MinPrice.Cmp(filter.MaxPrice) > 0 {
+		return ListingFilter{}, fmt.Errorf("min_price %s is greater than max_price %s", filter.MinPrice, filter.MaxPrice)
+	}
+
+	if value := query.Get("sort"); value != "" {
+		switch value = strings.ToLower(value); value {
+		case SortNewest, SortOldest, SortPriceAsc, SortPriceDesc:
+			filter.Sort = value
+		default:
+			return ListingFilter{}, fmt.Errorf("invalid sort: %s", value)
+		}
+	}
+
+	if value := query.Get("active"); value != "" {
+		if strings.EqualFold(value, "all") {
+			filter.Active = nil
+		} else {
+			active, err = strconv.ParseBool(value)
+			if err != nil {
+				return ListingFilter{}, fmt.Errorf("invalid active: %s", value)
+			}
+		}
+	}
+
+	if value := query.Get("owner"); value != "" {
+		if !common.IsHexAddress(value) {
+			return ListingFilter{}, fmt.Errorf("invalid owner: %s", value)
+		}
+		owner := common.HexToAddress(value)
+		filter.Owner = &owner
+	}
+
+	return filter, nil
+}
+
+// parsePrice parses an optional wei amount, returning nil when value is empty.
+func parsePrice(name, value string) (*big.Int, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	price, ok := new(big.Int).SetString(value, 10)
+	if !ok || price.Sign() < 0 || price.Cmp(maxListingPrice) > 0 {
+		return nil, fmt.Errorf("invalid %s: %s", name, value)
+	}
+
+	return price, nil
+}
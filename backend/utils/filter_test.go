@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// describeFilter renders f for comparison, with "-" for unset bounds and
+// owner and "all" for both active and inactive listings.
+func describeFilter(f ListingFilter) string {
+	fields := []string{"-", "-", f.Sort, "all", "-"}
+	if f.MinPrice != nil {
+		fields[0] = f.MinPrice.String()
+	}
+	if f.MaxPrice != nil {
+		fields[1] = f.MaxPrice.String()
+	}
+	if f.Active != nil {
+		fields[3] = fmt.Sprint(*f.Active)
+	}
+	if f.Owner != nil {
+		fields[4] = f.Owner.Hex()
+	}
+	return strings.Join(fields, " ")
+}
+
+func TestParseListingFilter(t *testing.T) {
+	const owner = "0x00000000000000000000000000000000000000aa"
+	maxPrice := maxListingPrice.String()
+
+	tests := []struct {
+		query string
+		// want is the filter as rendered by describeFilter: min price, max
+		// price, sort, active and owner.
+		want    string
+		wantErr string
+	}{
+		{query: "", want: "- - newest true -"},
+		{query: "min_price=10&max_price=100000000000000000000&sort=PRICE_DESC&active=false&owner=" + owner,
+			want: "10 100000000000000000000 price_desc false " + common.HexToAddress(owner).Hex()},
+		{query: "active=all&sort=oldest", want: "- - oldest all -"},
+		{query: "min_price=5&max_price=5", want: "5 5 newest true -"},
+		{query: "max_price=" + maxPrice, want: "- " + maxPrice + " newest true -"},
+		{query: "min_price=-1", wantErr: "invalid min_price: -1"},
+		{query: "min_price=0.5", wantErr: "invalid min_price: 0.5"},
+		{query: "max_price=1" + maxPrice, wantErr: "invalid max_price: 1" + maxPrice},
+		{query: "min_price=6&max_price=5", wantErr: "min_price 6 is greater than max_price 5"},
+		{query: "sort=cheapest", wantErr: "invalid sort: cheapest"},
+		{query: "active=maybe", wantErr: "invalid active: maybe"},
+		{query: "owner=0x123", wantErr: "invalid owner: 0x123"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			filter, err := ParseListingFilter(httptest.NewRequest(http.MethodGet, "/listings?"+tt.query, nil))
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("ParseListingFilter() = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseListingFilter() = %v", err)
+			}
+			if got := describeFilter(filter); got != tt.want {
+				t.Fatalf("ParseListingFilter() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"fmt"
+	"math/big"
+	"net/http"
+	"reflect"
+
+	"github.com/ugorji/go/codec"
+)
+
+// Extension tags of the amounts encoded by MsgpackHandle. They are only sent
+// when the handle writes extensions, which it does not.
+const (
+	bigIntMsgpackTag = 1
+	weiMsgpackTag    = 2
+)
+
+// MsgpackHandle encodes MessagePack responses. *big.Int and Wei amounts, which
+// the codec would otherwise encode as empty maps, are encoded as decimal
+// strings, like in JSON.
+var MsgpackHandle = newMsgpackHandle()
+
+func newMsgpackHandle() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{}
+	h.RawToString = true
+	if err := h.SetBytesExt(reflect.TypeOf(big.Int{}), bigIntMsgpackTag, decimalExt{}); err != nil {
+		panic(err)
+	}
+	if err := h.SetBytesExt(reflect.TypeOf(Wei{}), weiMsgpackTag, decimalExt{}); err != nil {
+		panic(err)
+	}
+	return h
+}
+
+// decimalExt encodes big.Int and Wei values as their decimal text.
+type decimalExt struct{}
+
+func (decimalExt) WriteExt(v any) []byte {
+	switch v := v.(type) {
+	case *big.Int:
+		return []byte(v.String())
+	case big.Int:
+		return []byte(v.String())
+	case *Wei:
+		return []byte(v.Int().String())
+	case Wei:
+		return []byte((*big.Int)(&v).String())
+	}
+	panic(fmt.Sprintf("decimalExt cannot encode %T", v))
+}
+
+func (decimalExt) ReadExt(dst any, src []byte) {
+	amount, ok := new(big.Int).SetString(string(src), 10)
+	if !ok {
+		panic(fmt.Errorf("invalid decimal amount %q", src))
+	}
+	switch dst := dst.(type) {
+	case *big.Int:
+		dst.Set(amount)
+	case *Wei:
+		dst.Int().Set(amount)
+	default:
+		panic(fmt.Sprintf("decimalExt cannot decode into %T", dst))
+	}
+}
+
+// msgpackRender renders Data with MsgpackHandle.
+type msgpackRender struct {
+	Data any
+}
+
+func (r msgpackRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return codec.NewEncoder(w, MsgpackHandle).Encode(r.Data)
+}
+
+func (r msgpackRender) WriteContentType(w http.ResponseWriter) {
+	if header := w.Header(); len(header["Content-Type"]) == 0 {
+		header["Content-Type"] = []string{"application/msgpack; charset=utf-8"}
+	}
+}
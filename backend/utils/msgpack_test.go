@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ugorji/go/codec"
+)
+
+type amounts struct {
+	Price   *Wei     `json:"price"`
+	Balance *big.Int `json:"balance"`
+	Missing *Wei     `json:"missing"`
+}
+
+func TestMsgpackAmountsRoundTrip(t *testing.T) {
+	huge, _ := new(big.Int).SetString("115792089237316195423570985008687907853269984665640564039457584007913129639935", 10)
+
+	tests := []struct {
+		name   string
+		amount *big.Int
+	}{
+		{name: "zero", amount: big.NewInt(0)},
+		{name: "one ether", amount: big.NewInt(1_000_000_000_000_000_000)},
+		{name: "above 2^53", amount: new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), 53), big.NewInt(1))},
+		{name: "max uint256", amount: huge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var encoded []byte
+			in := amounts{Price: NewWei(tt.amount), Balance: tt.amount}
+			if err := codec.NewEncoderBytes(&encoded, MsgpackHandle).Encode(in); err != nil {
+				t.Fatal(err)
+			}
+
+			// Clients see decimal strings, not empty maps.
+			var generic map[string]any
+			if err := codec.NewDecoderBytes(encoded, MsgpackHandle).Decode(&generic); err != nil {
+				t.Fatal(err)
+			}
+			for _, key := range []string{"price", "balance"} {
+				if got := generic[key]; got != tt.amount.String() {
+					t.Fatalf("%s = %#v, want %q", key, got, tt.amount.String())
+				}
+			}
+			if generic["missing"] != nil {
+				t.Fatalf("missing = %#v, want nil", generic["missing"])
+			}
+
+			out := amounts{Price: new(Wei), Balance: new(big.Int)}
+			if err := codec.NewDecoderBytes(encoded, MsgpackHandle).Decode(&out); err != nil {
+				t.Fatal(err)
+			}
+			if out.Price.Int().Cmp(tt.amount) != 0 || out.Balance.Cmp(tt.amount) != 0 {
+				t.Fatalf("decoded price %s, balance %s, want %s", out.Price, out.Balance, tt.amount)
+			}
+		})
+	}
+}
+
+func TestWriteMsgpack(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	c.Request.Header.Set("Accept", "application/msgpack")
+
+	Write(c, http.StatusOK, amounts{Price: NewWei(big.NewInt(42))})
+
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/msgpack; charset=utf-8" {
+		t.Fatalf("got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	var generic map[string]any
+	if err := codec.NewDecoderBytes(w.Body.Bytes(), MsgpackHandle).Decode(&generic); err != nil {
+		t.Fatal(err)
+	}
+	if generic["price"] != "42" {
+		t.Fatalf("price = %#v, want \"42\"", generic["price"])
+	}
+}
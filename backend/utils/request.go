@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// ErrUnsupportedMediaType is returned by ParseJSON for requests whose body is
+// not declared as JSON.
+var ErrUnsupportedMediaType = errors.New("unsupported media type, expected application/json")
+
+type parseOptions struct {
+	anyContentType bool
+}
+
+// ParseOption tunes ParseJSON.
+type ParseOption func(*parseOptions)
+
+// AllowAnyContentType makes ParseJSON decode the body as JSON whatever its
+// Content-Type, for endpoints that must keep accepting clients that do not
+// set it.
+func AllowAnyContentType() ParseOption {
+	return func(o *parseOptions) { o.anyContentType = true }
+}
+
+// ParseJSON decodes the JSON request body into v and validates it like
+// ShouldBindJSON.
+//
+// The request's Content-Type must be application/json, parameters such as
+// charset being allowed; otherwise ErrUnsupportedMediaType is returned without
+// reading the body. Pass AllowAnyContentType to skip the check. Use
+// ParseStatus to pick the response status of the returned error.
+func ParseJSON(c *gin.Context, v any, opts ...ParseOption) error {
+	var o parseOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if !o.anyContentType {
+		contentType := c.GetHeader("Content-Type")
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || mediaType != binding.MIMEJSON {
+			if contentType == "" {
+				contentType = "none"
+			}
+			return fmt.Errorf("%w, got %s", ErrUnsupportedMediaType, contentType)
+		}
+	}
+
+	return c.ShouldBindJSON(v)
+}
+
+// ParseStatus returns the status to answer a ParseJSON error with: 415
+// Unsupported Media Type for a wrong Content-Type and 400 Bad Request
+// otherwise.
+func ParseStatus(err error) int {
+	if errors.Is(err, ErrUnsupportedMediaType) {
+		return http.StatusUnsupportedMediaType
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// DefaultPageLimit and MaxPageLimit are the page limits used until
+// SetPageLimits configures others.
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+type pageLimits struct{ def, max int }
+
+var currentPageLimits atomic.Pointer[pageLimits]
+
+func init() {
+	SetPageLimits(0, 0)
+}
+
+// SetPageLimits replaces the page size used when a request gives no limit and
+// the largest page size a request may ask for. Non-positive values use
+// DefaultPageLimit and MaxPageLimit, and a default above the maximum is
+// lowered to it. Call it before serving requests.
+func SetPageLimits(defaultLimit, maxLimit int) {
+	if defaultLimit < 1 {
+		defaultLimit = DefaultPageLimit
+	}
+	if maxLimit < 1 {
+		maxLimit = MaxPageLimit
+	}
+	currentPageLimits.Store(&pageLimits{def: min(defaultLimit, maxLimit), max: maxLimit})
+}
+
+// PageLimits returns the configured default and maximum page sizes.
+func PageLimits() (defaultLimit, maxLimit int) {
+	limits := currentPageLimits.Load()
+	return limits.def, limits.max
+}
+
+// Pagination describes the page of a list response.
+type Pagination struct {
+	Total   int64 `json:"total"`
+	Limit   int   `json:"limit"`
+	Offset  int   `json:"offset"`
+	HasNext bool  `json:"hasNext"`
+}
+
+// Write encodes payload in the format requested by the client's Accept header.
+//
+// MessagePack, encoded with MsgpackHandle, is used when the client asks for
+// application/msgpack or application/x-msgpack; every other Accept value,
+// including a missing one, falls back to JSON. Handlers that must always answer with JSON should keep
+// using c.JSON directly.
+func Write(c *gin.Context, status int, payload any) {
+	switch c.NegotiateFormat(binding.MIMEJSON, binding.MIMEMSGPACK2, binding.MIMEMSGPACK) {
+	case binding.MIMEMSGPACK, binding.MIMEMSGPACK2:
+		c.Render(status, msgpackRender{Data: payload})
+	default:
+		c.JSON(status, payload)
+	}
+}
+
+// WritePaged writes a list response of the form
+// {"data": items, "pagination": {"total", "limit", "offset", "hasNext"}}.
+//
+// items must be a slice holding the current page; hasNext is true when items
+// after this page remain, that is when offset+len(items) < total.
+func WritePaged(c *gin.Context, status int, items any, total int64, limit, offset int) {
+	WritePagedWith(c, status, items, total, limit, offset, nil)
+}
+
+// WritePagedWith writes a WritePaged response with the top-level fields
+// added, such as "degraded" for responses served from a fallback source.
+func WritePagedWith(c *gin.Context, status int, items any, total int64, limit, offset int, fields gin.H) {
+	count := 0
+	if v := reflect.ValueOf(items); v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		count = v.Len()
+	}
+
+	payload := gin.H{}
+	for k, v := range fields {
+		payload[k] = v
+	}
+	payload["data"] = items
+	payload["pagination"] = Pagination{
+		Total:   total,
+		Limit:   limit,
+		Offset:  offset,
+		HasNext: int64(offset+count) < total,
+	}
+	Write(c, status, payload)
+}
+
+// ParsePagination reads the "limit" and "offset" query parameters. A missing
+// limit defaults to the configured default page size and larger limits are
+// clamped to the configured maximum, see SetPageLimits. Negative or
+// non-numeric values are rejected.
+func ParsePagination(c *gin.Context) (limit, offset int, err error) {
+	defaultLimit, maxLimit := PageLimits()
+	limit, offset = defaultLimit, 0
+
+	if value := c.Query("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return 0, 0, fmt.Errorf("invalid limit: %s", value)
+		}
+		if limit > maxLimit {
+			limit = maxLimit
+		}
+	}
+
+	if value := c.Query("offset"); value != "" {
+		offset, err = strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("invalid offset: %s", value)
+		}
+	}
+
+	return limit, offset, nil
+}
+
+// Page returns the items of the page starting at offset, at most limit long.
+func Page[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return items[:0]
+	}
+
+	end := offset + limit
+	if end > len(items) {
+		end = len(items)
+	}
+
+	return items[offset:end]
+}
+
+// WriteError writes a {"error": message} JSON response for plain net/http
+// handlers that have no gin context.
+func WriteError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// WriteValidationError answers with 400 Bad Request, listing every field
+// violation of err under "violations".
+func WriteValidationError(c *gin.Context, err *ValidationError) {
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "violations": err.Violations})
+}
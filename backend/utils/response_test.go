@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestWritePagedWith(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name          string
+		items         []int
+		total         int64
+		limit, offset int
+		fields        gin.H
+		wantHasNext   bool
+	}{
+		{name: "more pages", items: []int{1, 2}, total: 5, limit: 2, wantHasNext: true},
+		{name: "last page", items: []int{5}, total: 5, limit: 2, offset: 4},
+		{name: "past the end", items: []int{}, total: 5, limit: 2, offset: 8},
+		{name: "degraded", items: []int{1}, total: 1, limit: 20, fields: gin.H{"degraded": true}},
+		// The page itself cannot be overridden by the extra fields.
+		{name: "colliding field", items: []int{1}, total: 1, limit: 20, fields: gin.H{"data": "other"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			WritePagedWith(c, http.StatusOK, tt.items, tt.total, tt.limit, tt.offset, tt.fields)
+
+			var got struct {
+				Data       []int      `json:"data"`
+				Pagination Pagination `json:"pagination"`
+				Degraded   bool       `json:"degraded"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("%v: %s", err, w.Body)
+			}
+			want := Pagination{Total: tt.total, Limit: tt.limit, Offset: tt.offset, HasNext: tt.wantHasNext}
+			if got.Pagination != want {
+				t.Fatalf("pagination = %+v, want %+v", got.Pagination, want)
+			}
+			if len(got.Data) != len(tt.items) {
+				t.Fatalf("data = %v, want %v", got.Data, tt.items)
+			}
+			if got.Degraded != (tt.fields["degraded"] == true) {
+				t.Fatalf("degraded = %t, want %v", got.Degraded, tt.fields["degraded"])
+			}
+		})
+	}
+}
+
+func TestParsePagination(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	SetPageLimits(20, 100)
+
+	tests := []struct {
+		query      string
+		wantLimit  int
+		wantOffset int
+		wantErr    bool
+	}{
+		{query: "", wantLimit: 20},
+		{query: "limit=5&offset=10", wantLimit: 5, wantOffset: 10},
+		{query: "limit=500", wantLimit: 100},
+		{query: "limit=0", wantErr: true},
+		{query: "offset=-1", wantErr: true},
+		{query: "limit=ten", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+
+			limit, offset, err := ParsePagination(c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %t", err, tt.wantErr)
+			}
+			if !tt.wantErr && (limit != tt.wantLimit || offset != tt.wantOffset) {
+				t.Fatalf("limit, offset = %d, %d, want %d, %d", limit, offset, tt.wantLimit, tt.wantOffset)
+			}
+		})
+	}
+}
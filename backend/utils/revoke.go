@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"nft-marketplace/db"
+	"time"
+)
+
+// ErrTokenRevoked is returned for tokens revoked with RevokeToken.
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// ErrTokenNotRevocable is returned by RevokeToken for tokens without a JTI or
+// an expiry, such as those issued before tokens carried a JTI.
+var ErrTokenNotRevocable = errors.New("token cannot be revoked")
+
+// newJTI returns a random token ID.
+func newJTI() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate token ID: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// RevokeToken revokes the token with the given claims until it expires, so
+// that it is rejected from then on even though its signature is valid.
+func RevokeToken(claims map[string]interface{}) error {
+	jti, _ := claims["jti"].(string)
+	exp, ok := claims["exp"].(float64)
+	if jti == "" || !ok {
+		return ErrTokenNotRevocable
+	}
+
+	if err := db.RevokeToken(jti, time.Unix(int64(exp), 0).UTC()); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+// CheckRevoked returns ErrTokenRevoked when the token with the given claims was
+// revoked. Tokens without a JTI cannot have been. A revocation that cannot be
+// checked is an error too, so that revoked tokens are never let through.
+func CheckRevoked(claims map[string]interface{}) error {
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return nil
+	}
+
+	revoked, err := db.IsTokenRevoked(jti)
+	if err != nil {
+		return fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// RunRevocationCleanup removes the revocations of expired tokens every
+// interval until ctx is cancelled.
+func RunRevocationCleanup(ctx context.Context, interval time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-Clock.After(interval):
+		}
+
+		removed, err := db.DeleteExpiredRevocations(Clock.Now())
+		if err != nil {
+			log.Printf("Failed to remove expired token revocations: %v", err)
+			continue
+		}
+		if removed > 0 {
+			log.Printf("Removed %d expired token revocations", removed)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"nft-marketplace/clock"
+	"nft-marketplace/db"
+	"nft-marketplace/db/dbtest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+)
+
+func TestRevokedTokenRejected(t *testing.T) {
+	t.Setenv("API_SECRET", testSecret)
+	t.Setenv("TOKEN_HOUR_LIFESPAN", "1")
+	gin.SetMode(gin.TestMode)
+
+	issued, err := IssueToken(db.User{Model: gorm.Model{ID: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(issued.Token, claims, func(*jwt.Token) (any, error) { return []byte(testSecret), nil }); err != nil {
+		t.Fatal(err)
+	}
+	jti := claims["jti"].(string)
+	getToken := func() error {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Request.Header.Set("Authorization", "Bearer "+issued.Token)
+		_, err := GetToken(c)
+		return err
+	}
+
+	mock := dbtest.Mock(t)
+	countRevoked := `SELECT count\(\*\) FROM "revoked_tokens" WHERE jti = \$1`
+	mock.ExpectQuery(countRevoked).WithArgs(jti).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "revoked_tokens" .* ON CONFLICT DO NOTHING`).WithArgs(jti, issued.ExpiresAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(countRevoked).WithArgs(jti).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(countRevoked).WithArgs(jti).WillReturnError(errors.New("connection reset"))
+
+	if err := getToken(); err != nil {
+		t.Fatalf("GetToken() before revoking = %v, want nil", err)
+	}
+	if err := RevokeToken(claims); err != nil {
+		t.Fatalf("RevokeToken() = %v", err)
+	}
+	if err := getToken(); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("GetToken() after revoking = %v, want %v", err, ErrTokenRevoked)
+	}
+	// A revocation that cannot be checked does not let the token through.
+	if err := getToken(); err == nil {
+		t.Fatal("GetToken() with the revocation check failing = nil, want an error")
+	}
+}
+
+func TestRevokeTokenNotRevocable(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+	}{
+		// Tokens issued before they carried a JTI.
+		{name: "no JTI", claims: map[string]interface{}{"id": 1, "exp": float64(1_700_000_000)}},
+		{name: "no expiry", claims: map[string]interface{}{"id": 1, "jti": "abc"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := RevokeToken(tt.claims); !errors.Is(err, ErrTokenNotRevocable) {
+				t.Fatalf("RevokeToken() = %v, want %v", err, ErrTokenNotRevocable)
+			}
+		})
+	}
+}
+
+// waitingClock is a fake clock telling waiting whenever After is called, so
+// that a test advances it only once the code under test waits on it.
+type waitingClock struct {
+	*clock.Fake
+	waiting chan struct{}
+}
+
+func (c waitingClock) After(d time.Duration) <-chan time.Time {
+	ch := c.Fake.After(d)
+	c.waiting <- struct{}{}
+	return ch
+}
+
+func TestRunRevocationCleanup(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	fake := waitingClock{Fake: clock.NewFake(start), waiting: make(chan struct{})}
+	Clock = fake
+	t.Cleanup(func() { Clock = clock.Real })
+
+	mock := dbtest.Mock(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "revoked_tokens" WHERE expires_at <= \$1`).WithArgs(start.Add(time.Hour)).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunRevocationCleanup(ctx, time.Hour)
+		close(done)
+	}()
+
+	<-fake.waiting
+	fake.Advance(time.Hour)
+	// Waiting again means the expired revocations were removed.
+	<-fake.waiting
+	cancel()
+	<-done
+}
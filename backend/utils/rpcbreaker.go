@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"nft-marketplace/breaker"
+	"nft-marketplace/metrics"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	rpcBreakerState = metrics.NewGauge("rpc_circuit_breaker_state", "State of the RPC circuit breaker: 0 closed, 1 open, 2 half-open.")
+	rpcBreakerTrips = metrics.NewCounter("rpc_circuit_breaker_trips_total", "Times the RPC circuit breaker opened.")
+	rpcRejected     = metrics.NewCounter("rpc_circuit_breaker_rejected_total", "RPC requests failed fast by the open circuit breaker.")
+
+	rpcBreaker atomic.Pointer[breaker.Breaker]
+)
+
+func init() {
+	SetRPCBreaker(0, 0)
+}
+
+// SetRPCBreaker replaces the circuit breaker guarding JSON-RPC over HTTP, which
+// opens after threshold consecutive failed requests and fast-fails requests for
+// cooldown. Non-positive values use the breaker package defaults. Call it
+// before dialing.
+func SetRPCBreaker(threshold int, cooldown time.Duration) {
+	b := breaker.New(threshold, cooldown)
+	b.OnStateChange = func(from, to breaker.State) {
+		rpcBreakerState.Set(int64(to))
+		if to == breaker.Open {
+			rpcBreakerTrips.Inc()
+			log.Printf("RPC circuit breaker opened for %s", b.Cooldown)
+		} else if to == breaker.Closed {
+			log.Printf("RPC circuit breaker closed")
+		}
+	}
+	rpcBreakerState.Set(int64(breaker.Closed))
+	rpcBreaker.Store(b)
+}
+
+// RPCBreakerState returns the state of the circuit breaker guarding the RPC
+// endpoint.
+func RPCBreakerState() breaker.State {
+	return rpcBreaker.Load().State()
+}
+
+// breakerTransport fails RPC requests fast while the circuit breaker is open.
+// Transport errors, 5xx and 429 responses count as failures; requests
+// cancelled by their caller do not count at all.
+type breakerTransport struct {
+	next http.RoundTripper
+}
+
+func (t breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	b := rpcBreaker.Load()
+	if err := b.Allow(); err != nil {
+		rpcRejected.Inc()
+		return nil, fmt.Errorf("RPC endpoint unavailable: %w", err)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	switch {
+	case err != nil && errors.Is(err, context.Canceled):
+		// The caller gave up: release a half-open probe without judging
+		// the endpoint.
+		b.Release()
+	case err != nil:
+		b.Failure()
+	case resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests:
+		b.Failure()
+	default:
+		b.Success()
+	}
+
+	return resp, err
+}
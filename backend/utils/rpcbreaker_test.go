@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"nft-marketplace/breaker"
+	"nft-marketplace/clock"
+	"testing"
+	"time"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestBreakerTransport(t *testing.T) {
+	t.Cleanup(func() { SetRPCBreaker(0, 0) })
+
+	errDown := errors.New("connection refused")
+	respond := func(status int, err error) roundTripFunc {
+		return func(*http.Request) (*http.Response, error) {
+			if err != nil {
+				return nil, err
+			}
+			return &http.Response{StatusCode: status, Body: http.NoBody}, nil
+		}
+	}
+
+	tests := []struct {
+		name string
+		// trip opens the breaker and lets its cooldown pass first, so the
+		// request is a half-open probe.
+		trip      bool
+		next      roundTripFunc
+		wantState breaker.State
+		// wantProbe is whether another probe may be sent afterwards.
+		wantProbe bool
+	}{
+		{name: "success", next: respond(http.StatusOK, nil), wantState: breaker.Closed},
+		{name: "transport error", next: respond(0, errDown), wantState: breaker.Open},
+		{name: "server error", next: respond(http.StatusBadGateway, nil), wantState: breaker.Open},
+		{name: "rate limited", next: respond(http.StatusTooManyRequests, nil), wantState: breaker.Open},
+		{name: "client error", next: respond(http.StatusBadRequest, nil), wantState: breaker.Closed},
+		{name: "successful probe", trip: true, next: respond(http.StatusOK, nil), wantState: breaker.Closed},
+		{name: "failed probe", trip: true, next: respond(0, errDown), wantState: breaker.Open},
+		// A cancelled probe neither closes nor reopens the breaker.
+		{name: "cancelled probe", trip: true, next: respond(0, fmt.Errorf("Post: %w", context.Canceled)), wantState: breaker.HalfOpen, wantProbe: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetRPCBreaker(1, time.Minute)
+			fake := clock.NewFake(time.Unix(1_700_000_000, 0))
+			b := rpcBreaker.Load()
+			b.Clock = fake
+			if tt.trip {
+				if err := b.Allow(); err != nil {
+					t.Fatal(err)
+				}
+				b.Failure()
+				fake.Advance(time.Minute)
+			}
+
+			transport := breakerTransport{next: tt.next}
+			resp, _ := transport.RoundTrip(httptest.NewRequest(http.MethodPost, "/", nil))
+			if resp != nil {
+				resp.Body.Close()
+			}
+			if got := b.State(); got != tt.wantState {
+				t.Fatalf("state = %s, want %s", got, tt.wantState)
+			}
+			if tt.wantProbe {
+				if err := b.Allow(); err != nil {
+					t.Fatalf("Allow() = %v, want another probe let through", err)
+				}
+			}
+		})
+	}
+}
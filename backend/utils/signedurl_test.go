@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"errors"
+	"net/url"
+	"nft-marketplace/clock"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withParam returns a tamper function setting the query parameter key to
+// value, or removing it when value is empty.
+func withParam(key, value string) func(u *url.URL) {
+	return func(u *url.URL) {
+		query := u.Query()
+		if value == "" {
+			query.Del(key)
+		} else {
+			query.Set(key, value)
+		}
+		u.RawQuery = query.Encode()
+	}
+}
+
+func TestVerifySignedURL(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+
+	tests := []struct {
+		name string
+		// tamper changes the signed URL before it is verified, advance moves
+		// the clock and secret replaces API_SECRET in between.
+		tamper  func(u *url.URL)
+		advance time.Duration
+		secret  string
+		wantErr error
+	}{
+		{name: "valid"},
+		{name: "still valid at expiry", advance: time.Minute},
+		{name: "expired", advance: time.Minute + time.Second, wantErr: ErrSignedURLExpired},
+		{name: "other path", tamper: func(u *url.URL) { u.Path = "/metadata/8" }, wantErr: ErrSignedURLSignature},
+		{name: "parameter changed", tamper: withParam("size", "original"), wantErr: ErrSignedURLSignature},
+		{name: "parameter added", tamper: withParam("download", "1"), wantErr: ErrSignedURLSignature},
+		{name: "expiry extended", tamper: withParam(SignedURLExpiresParam, strconv.FormatInt(now.Add(time.Hour).Unix(), 10)), wantErr: ErrSignedURLSignature},
+		{name: "unsigned", tamper: withParam(SignedURLSignatureParam, ""), wantErr: ErrSignedURLMissing},
+		{name: "other secret", secret: "rotated-secret", wantErr: ErrSignedURLSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("API_SECRET", testSecret)
+			fake := clock.NewFake(now)
+			Clock = fake
+			t.Cleanup(func() { Clock = clock.Real })
+
+			signed, err := url.Parse(SignURL("/metadata/7?size=thumb", time.Minute))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.tamper != nil {
+				tt.tamper(signed)
+			}
+			fake.Advance(tt.advance)
+			if tt.secret != "" {
+				t.Setenv("API_SECRET", tt.secret)
+			}
+
+			if err := VerifySignedURL(signed); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifySignedURL(%s) = %v, want %v", signed, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifySignedURLParameterOrder(t *testing.T) {
+	t.Setenv("API_SECRET", testSecret)
+
+	signed := SignURL("/metadata/7?size=thumb&format=png", time.Minute)
+	path, query, _ := strings.Cut(signed, "?")
+	params := strings.Split(query, "&")
+	for i, j := 0, len(params)-1; i < j; i, j = i+1, j-1 {
+		params[i], params[j] = params[j], params[i]
+	}
+
+	reordered, err := url.Parse(path + "?" + strings.Join(params, "&"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySignedURL(reordered); err != nil {
+		t.Fatalf("VerifySignedURL(%s) = %v, want nil", reordered, err)
+	}
+}
+
+func TestVerifySignedURLWithoutSecret(t *testing.T) {
+	t.Setenv("API_SECRET", "")
+
+	signed, err := url.Parse(SignURL("/metadata/7", time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySignedURL(signed); !errors.Is(err, ErrSignedURLSignature) {
+		t.Fatalf("VerifySignedURL() = %v, want %v", err, ErrSignedURLSignature)
+	}
+}
@@ -4,47 +4,98 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"nft-marketplace/clock"
 	"nft-marketplace/db"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
 )
 
+// Clock is the time source of token and signed URL expiries. Tests may replace
+// it with a clock.Fake.
+var Clock clock.Clock = clock.Real
+
+func init() {
+	jwt.TimeFunc = func() time.Time { return Clock.Now() }
+}
+
+// Keys under which the authenticated user is stored in the gin context by
+// SetAuthenticatedUser.
+const (
+	UserIDKey      = "user_id"
+	UserAddressKey = "user_address"
+)
+
+// walletClaim is the claim holding the user's verified wallet address. Tokens
+// issued before wallets had to be proven carry an unverified "user_address"
+// claim instead, which is ignored.
+const walletClaim = "wallet"
+
+// IssuedToken is a token signed by IssueToken, with the claims clients would
+// otherwise have to decode it for.
+type IssuedToken struct {
+	Token     string
+	UserID    uint
+	ExpiresAt time.Time
+}
+
 func GenerateToken(user db.User) (string, error) {
+	issued, err := IssueToken(user)
+	if err != nil {
+		return "", err
+	}
+	return issued.Token, nil
+}
+
+// IssueToken signs a token for user, valid for TOKEN_HOUR_LIFESPAN hours, and
+// returns it with its expiry. The expiry is whole seconds, as in the token.
+// Each token gets a random JTI, by which RevokeToken revokes it.
+func IssueToken(user db.User) (IssuedToken, error) {
 	tokenLifespanStr := os.Getenv("TOKEN_HOUR_LIFESPAN")
 	if tokenLifespanStr == "" {
-		return "", fmt.Errorf("TOKEN_HOUR_LIFESPAN is not set")
+		return IssuedToken{}, fmt.Errorf("TOKEN_HOUR_LIFESPAN is not set")
 	}
 
 	tokenLifespan, err := strconv.Atoi(tokenLifespanStr)
 	if err != nil {
 		log.Printf("Error converting TOKEN_HOUR_LIFESPAN: %v", err)
-		return "", err
+		return IssuedToken{}, err
 	}
 
+	jti, err := newJTI()
+	if err != nil {
+		return IssuedToken{}, err
+	}
+
+	expiresAt := Clock.Now().Add(time.Hour * time.Duration(tokenLifespan)).Truncate(time.Second).UTC()
 	claims := jwt.MapClaims{
 		"authorized": true,
 		"id":         user.ID,
-		"exp":        time.Now().Add(time.Hour * time.Duration(tokenLifespan)).Unix(),
+		"jti":        jti,
+		"exp":        expiresAt.Unix(),
+	}
+	if user.WalletVerified && common.IsHexAddress(user.WalletAddress) {
+		claims[walletClaim] = user.WalletAddress
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	apiSecret := os.Getenv("API_SECRET")
 	if apiSecret == "" {
-		return "", fmt.Errorf("API_SECRET is not set")
+		return IssuedToken{}, fmt.Errorf("API_SECRET is not set")
 	}
 
 	signedToken, err := token.SignedString([]byte(apiSecret))
 	if err != nil {
 		log.Printf("Error signing the token: %v", err)
-		return "", err
+		return IssuedToken{}, err
 	}
 
-	return signedToken, nil
+	return IssuedToken{Token: signedToken, UserID: user.ID, ExpiresAt: expiresAt}, nil
 }
 
 func ValidateToken(c *gin.Context) error {
@@ -85,6 +136,11 @@ func GetToken(c *gin.Context) (*jwt.Token, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %v", err)
 	}
+	if claims, ok := token.Claims.(jwt.MapClaims); ok {
+		if err := CheckRevoked(claims); err != nil {
+			return nil, err
+		}
+	}
 
 	return token, nil
 }
@@ -124,3 +180,65 @@ func CurrentUser(c *gin.Context) (db.User, error) {
 
 	return user, nil
 }
+
+// SetAuthenticatedUser validates the request's token and stores the user ID and,
+// when the token carries one, the user's wallet address in the gin context so
+// that handlers can pass them on to the service layer.
+func SetAuthenticatedUser(c *gin.Context) error {
+	token, err := GetToken(c)
+	if err != nil {
+		return err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return errors.New("invalid token")
+	}
+
+	id, ok := claims["id"].(float64)
+	if !ok {
+		return errors.New("invalid user ID claim")
+	}
+	c.Set(UserIDKey, uint(id))
+
+	if address, ok := WalletClaim(claims); ok {
+		c.Set(UserAddressKey, address)
+	}
+
+	return nil
+}
+
+// WalletClaim returns the verified wallet address carried by a token's
+// claims. The boolean is false when the user has no verified wallet.
+func WalletClaim(claims map[string]any) (common.Address, bool) {
+	address, ok := claims[walletClaim].(string)
+	if !ok || !common.IsHexAddress(address) {
+		return common.Address{}, false
+	}
+	return common.HexToAddress(address), true
+}
+
+// AuthenticatedUserID returns the ID of the authenticated user. The boolean is
+// false when the request is not authenticated.
+func AuthenticatedUserID(c *gin.Context) (uint, bool) {
+	value, ok := c.Get(UserIDKey)
+	if !ok {
+		return 0, false
+	}
+
+	id, ok := value.(uint)
+	return id, ok
+}
+
+// AuthenticatedAddress returns the verified wallet address of the
+// authenticated user. The boolean is false when the request is not
+// authenticated or the user has not proven control of a wallet.
+func AuthenticatedAddress(c *gin.Context) (common.Address, bool) {
+	value, ok := c.Get(UserAddressKey)
+	if !ok {
+		return common.Address{}, false
+	}
+
+	address, ok := value.(common.Address)
+	return address, ok
+}
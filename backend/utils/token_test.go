@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"nft-marketplace/db"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const testSecret = "test-secret"
+
+func TestIssueTokenWalletClaim(t *testing.T) {
+	t.Setenv("API_SECRET", testSecret)
+	t.Setenv("TOKEN_HOUR_LIFESPAN", "1")
+	wallet := "0x00000000000000000000000000000000000000aa"
+
+	tests := []struct {
+		name     string
+		user     db.User
+		want     common.Address
+		wantSeen bool
+	}{
+		{name: "verified wallet", user: db.User{WalletAddress: wallet, WalletVerified: true}, want: common.HexToAddress(wallet), wantSeen: true},
+		{name: "unverified wallet", user: db.User{WalletAddress: wallet}},
+		{name: "no wallet", user: db.User{WalletVerified: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issued, err := IssueToken(tt.user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims := jwt.MapClaims{}
+			if _, err := jwt.ParseWithClaims(issued.Token, claims, func(*jwt.Token) (any, error) { return []byte(testSecret), nil }); err != nil {
+				t.Fatal(err)
+			}
+
+			got, ok := WalletClaim(claims)
+			if ok != tt.wantSeen || got != tt.want {
+				t.Fatalf("WalletClaim() = %s, %t, want %s, %t", got.Hex(), ok, tt.want.Hex(), tt.wantSeen)
+			}
+		})
+	}
+}
+
+func TestSetAuthenticatedUserIgnoresUnverifiedAddress(t *testing.T) {
+	t.Setenv("API_SECRET", testSecret)
+	gin.SetMode(gin.TestMode)
+	wallet := "0x00000000000000000000000000000000000000aa"
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		want   bool
+	}{
+		// Tokens issued before wallets were verified carry user_address.
+		{name: "legacy user_address claim", claims: jwt.MapClaims{"id": 1, "user_address": wallet}},
+		{name: "verified wallet claim", claims: jwt.MapClaims{"id": 1, "wallet": wallet}, want: true},
+		{name: "malformed wallet claim", claims: jwt.MapClaims{"id": 1, "wallet": "nope"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Without a JTI the revocation check needs no database.
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, tt.claims).SignedString([]byte(testSecret))
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			c.Request.Header.Set("Authorization", "Bearer "+token)
+
+			if err := SetAuthenticatedUser(c); err != nil {
+				t.Fatal(err)
+			}
+			if id, ok := AuthenticatedUserID(c); !ok || id != 1 {
+				t.Fatalf("AuthenticatedUserID() = %d, %t, want 1, true", id, ok)
+			}
+			if _, ok := AuthenticatedAddress(c); ok != tt.want {
+				t.Fatalf("AuthenticatedAddress() found = %t, want %t", ok, tt.want)
+			}
+		})
+	}
+}
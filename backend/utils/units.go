@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const etherDecimals = 18
+
+// EtherToWei converts a decimal ether amount such as "0.05" into wei.
+//
+// The conversion is exact: the amount is parsed as a decimal string rather
+// than a float, and amounts with more than 18 decimal places, which cannot be
+// represented in wei, are rejected. Negative amounts and exponents are not
+// accepted.
+func EtherToWei(ether string) (*big.Int, error) {
+	ether = strings.TrimSpace(ether)
+
+	whole, frac, _ := strings.Cut(ether, ".")
+	if whole == "" && frac == "" {
+		return nil, fmt.Errorf("invalid ether amount: %q", ether)
+	}
+	if !isDigits(whole) || !isDigits(frac) {
+		return nil, fmt.Errorf("invalid ether amount: %q", ether)
+	}
+	if len(frac) > etherDecimals {
+		return nil, fmt.Errorf("ether amount %q has more than %d decimal places", ether, etherDecimals)
+	}
+
+	digits := whole + frac + strings.Repeat("0", etherDecimals-len(frac))
+	wei, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid ether amount: %q", ether)
+	}
+
+	return wei, nil
+}
+
+// WeiToEther formats a wei amount as a decimal ether amount, the inverse of
+// EtherToWei. Trailing zeros of the fraction are dropped, so one wei is
+// "0.000000000000000001" and 10^18 wei is "1".
+func WeiToEther(wei *big.Int) string {
+	if wei == nil {
+		return "0"
+	}
+
+	sign := ""
+	abs := new(big.Int).Set(wei)
+	if abs.Sign() < 0 {
+		sign = "-"
+		abs.Neg(abs)
+	}
+
+	digits := abs.String()
+	if len(digits) <= etherDecimals {
+		digits = strings.Repeat("0", etherDecimals-len(digits)+1) + digits
+	}
+
+	whole, frac := digits[:len(digits)-etherDecimals], strings.TrimRight(digits[len(digits)-etherDecimals:], "0")
+	if frac == "" {
+		return sign + whole
+	}
+	return sign + whole + "." + frac
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestEtherToWei(t *testing.T) {
+	tests := []struct {
+		ether   string
+		want    string
+		wantErr bool
+	}{
+		{ether: "0.05", want: "50000000000000000"},
+		{ether: "1", want: "1000000000000000000"},
+		{ether: " 2.5 ", want: "2500000000000000000"},
+		{ether: ".5", want: "500000000000000000"},
+		{ether: "3.", want: "3000000000000000000"},
+		{ether: "0.000000000000000001", want: "1"},
+		// Amounts far beyond what a float64 holds exactly.
+		{ether: "123456789012345678.123456789012345678", want: "123456789012345678123456789012345678"},
+		{ether: "0.0000000000000000001", wantErr: true},
+		{ether: "", wantErr: true},
+		{ether: ".", wantErr: true},
+		{ether: "-1", wantErr: true},
+		{ether: "1e18", wantErr: true},
+		{ether: "1.2.3", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ether, func(t *testing.T) {
+			got, err := EtherToWei(tt.ether)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("EtherToWei(%q) = %s, want an error", tt.ether, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EtherToWei(%q) = %v", tt.ether, err)
+			}
+			if got.String() != tt.want {
+				t.Fatalf("EtherToWei(%q) = %s, want %s", tt.ether, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWeiToEther(t *testing.T) {
+	tests := []struct {
+		wei  *big.Int
+		want string
+	}{
+		{wei: nil, want: "0"},
+		{wei: big.NewInt(0), want: "0"},
+		{wei: big.NewInt(1), want: "0.000000000000000001"},
+		{wei: big.NewInt(50000000000000000), want: "0.05"},
+		{wei: big.NewInt(1000000000000000000), want: "1"},
+		{wei: big.NewInt(-1500000000000000000), want: "-1.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := WeiToEther(tt.wei); got != tt.want {
+				t.Fatalf("WeiToEther(%s) = %q, want %q", tt.wei, got, tt.want)
+			}
+			if tt.wei == nil || tt.wei.Sign() < 0 {
+				return
+			}
+			back, err := EtherToWei(tt.want)
+			if err != nil || back.Cmp(tt.wei) != 0 {
+				t.Fatalf("EtherToWei(%q) = %v, %v, want %s", tt.want, back, err, tt.wei)
+			}
+		})
+	}
+}
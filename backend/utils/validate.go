@@ -1,9 +1,12 @@
 package utils
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"math/big"
 	"strconv"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
 )
@@ -16,6 +19,29 @@ func ValidateEthereumAddress(owner string) error {
 	return nil
 }
 
+// ErrInvalidAddress is returned by SameAddressStr for strings that are not hex
+// addresses.
+var ErrInvalidAddress = errors.New("invalid address")
+
+// SameAddress reports whether a and b are the same address, comparing their
+// bytes. Use it rather than comparing hex strings, whose case may differ.
+func SameAddress(a, b common.Address) bool {
+	return bytes.Equal(a.Bytes(), b.Bytes())
+}
+
+// SameAddressStr reports whether the hex addresses a and b are the same,
+// whatever their case and whether they have the 0x prefix. Either not being a
+// hex address fails with ErrInvalidAddress, so that garbage never compares
+// equal to the zero address common.HexToAddress would turn it into.
+func SameAddressStr(a, b string) (bool, error) {
+	for _, address := range []string{a, b} {
+		if !common.IsHexAddress(address) {
+			return false, fmt.Errorf("%w: %q", ErrInvalidAddress, address)
+		}
+	}
+	return SameAddress(common.HexToAddress(a), common.HexToAddress(b)), nil
+}
+
 func ValidateFromAddress(from string) error {
 	if !common.IsHexAddress(from) {
 		return fmt.Errorf("invalid address: %s", from)
@@ -51,3 +77,36 @@ func ValidatePrice(price string) error {
 
 	return nil
 }
+
+// FieldViolation describes why a single request field is invalid.
+type FieldViolation struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
+}
+
+// ValidationError collects every invalid field of a request, so that clients
+// can fix them all at once.
+type ValidationError struct {
+	Violations []FieldViolation
+}
+
+// Add records a violation of field.
+func (e *ValidationError) Add(field, description string) {
+	e.Violations = append(e.Violations, FieldViolation{Field: field, Description: description})
+}
+
+// Err returns e if any violation was recorded and nil otherwise.
+func (e *ValidationError) Err() error {
+	if len(e.Violations) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		parts = append(parts, v.Field+": "+v.Description)
+	}
+	return "invalid input: " + strings.Join(parts, "; ")
+}
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync/atomic"
+)
+
+// Wei is an amount of wei that is encoded in JSON as a decimal string, such as
+// "1500000000000000000", rather than as a number: amounts above 2^53 lose
+// precision once parsed by JavaScript clients. Decoding accepts both strings
+// and numbers.
+type Wei big.Int
+
+var weiAsNumbers atomic.Bool
+
+// SetWeiJSONNumbers makes Wei amounts encode as JSON numbers again, for
+// clients that do not expect strings yet. Call it before serving requests.
+func SetWeiJSONNumbers(numbers bool) {
+	weiAsNumbers.Store(numbers)
+}
+
+// NewWei returns amount as a Wei, nil when amount is nil. The amount is
+// shared, not copied.
+func NewWei(amount *big.Int) *Wei {
+	return (*Wei)(amount)
+}
+
+// Int returns the amount as a *big.Int, nil when w is nil.
+func (w *Wei) Int() *big.Int {
+	return (*big.Int)(w)
+}
+
+// String returns the amount in decimal.
+func (w *Wei) String() string {
+	if w == nil {
+		return "<nil>"
+	}
+	return w.Int().String()
+}
+
+// MarshalJSON encodes the amount as a decimal string, or a number after
+// SetWeiJSONNumbers(true).
+func (w *Wei) MarshalJSON() ([]byte, error) {
+	if w == nil {
+		return []byte("null"), nil
+	}
+	if weiAsNumbers.Load() {
+		return []byte(w.Int().String()), nil
+	}
+	return []byte(`"` + w.Int().String() + `"`), nil
+}
+
+// UnmarshalJSON decodes a non-negative decimal amount given as a string or a
+// number. Null leaves the amount unchanged.
+func (w *Wei) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	text := data
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		text = []byte(s)
+	}
+	amount, ok := new(big.Int).SetString(string(text), 10)
+	if !ok || amount.Sign() < 0 {
+		return fmt.Errorf("invalid wei amount %s", data)
+	}
+	w.Int().Set(amount)
+	return nil
+}